@@ -0,0 +1,53 @@
+// Package authz holds the access policy for user-owned resources. Handlers
+// ask Can before acting instead of comparing author IDs themselves.
+package authz
+
+import (
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+type Action string
+
+const (
+	Read     Action = "read"
+	Create   Action = "create"
+	Update   Action = "update"
+	Delete   Action = "delete"
+	Moderate Action = "moderate"
+)
+
+// User is the caller an access decision is made for.
+type User struct {
+	Id   uuid.UUID
+	Role schema.Role
+}
+
+// Resource is anything owned by a single user.
+type Resource interface {
+	OwnerID() uuid.UUID
+}
+
+// Can reports whether user may perform action on resource.
+//
+// Anyone may read. Any signed in user may create; the new resource must be
+// owned by them. Only the owner or an admin may update or delete, and
+// moderation removals are open to moderators and admins.
+func Can(user User, action Action, resource Resource) bool {
+	if action == Read {
+		return true
+	}
+	if user.Id == uuid.Nil {
+		return false
+	}
+
+	switch action {
+	case Create:
+		return resource.OwnerID() == user.Id
+	case Update, Delete:
+		return resource.OwnerID() == user.Id || user.Role == schema.RoleAdmin
+	case Moderate:
+		return user.Role == schema.RoleModerator || user.Role == schema.RoleAdmin
+	}
+	return false
+}
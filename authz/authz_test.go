@@ -0,0 +1,57 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestCan(t *testing.T) {
+	owner := User{Id: uuid.New(), Role: schema.RoleUser}
+	other := User{Id: uuid.New(), Role: schema.RoleUser}
+	moderator := User{Id: uuid.New(), Role: schema.RoleModerator}
+	admin := User{Id: uuid.New(), Role: schema.RoleAdmin}
+	anonymous := User{}
+
+	resources := map[string]Resource{
+		"post":      schema.Post{AuthorId: owner.Id},
+		"recipe":    schema.Recipe{AuthorId: owner.Id},
+		"meal plan": schema.MealPlan{AuthorId: owner.Id},
+		"media":     schema.Media{AuthorId: owner.Id},
+	}
+
+	tests := []struct {
+		name     string
+		user     User
+		action   Action
+		expected bool
+	}{
+		{name: "anyone can read", user: anonymous, action: Read, expected: true},
+		{name: "owner can create", user: owner, action: Create, expected: true},
+		{name: "cannot create for another user", user: other, action: Create, expected: false},
+		{name: "anonymous cannot create", user: anonymous, action: Create, expected: false},
+		{name: "owner can update", user: owner, action: Update, expected: true},
+		{name: "owner can delete", user: owner, action: Delete, expected: true},
+		{name: "other user cannot update", user: other, action: Update, expected: false},
+		{name: "other user cannot delete", user: other, action: Delete, expected: false},
+		{name: "moderator cannot update", user: moderator, action: Update, expected: false},
+		{name: "moderator cannot delete", user: moderator, action: Delete, expected: false},
+		{name: "admin can update", user: admin, action: Update, expected: true},
+		{name: "admin can delete", user: admin, action: Delete, expected: true},
+		{name: "owner cannot moderate", user: owner, action: Moderate, expected: false},
+		{name: "moderator can moderate", user: moderator, action: Moderate, expected: true},
+		{name: "admin can moderate", user: admin, action: Moderate, expected: true},
+		{name: "unknown action is denied", user: admin, action: "publish", expected: false},
+	}
+
+	for kind, resource := range resources {
+		for _, tt := range tests {
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				if got := Can(tt.user, tt.action, resource); got != tt.expected {
+					t.Errorf("Can(%s, %s) = %v, expected %v", tt.user.Role, tt.action, got, tt.expected)
+				}
+			})
+		}
+	}
+}
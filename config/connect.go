@@ -5,11 +5,16 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	_ "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/smilecs/foody/mailer"
+	"github.com/smilecs/foody/oidc"
+	"github.com/smilecs/foody/pubsub"
+	"github.com/smilecs/foody/utils"
 )
 
 type Config struct {
@@ -17,8 +22,31 @@ type Config struct {
 	AWSSess   *session.Session
 	S3_Bucket string
 	Port      string
+	Mailer    mailer.Mailer
+	AppURL    string
+	// FrontendURL is the web app that emailed links (email verification,
+	// password reset) open. Those pages collect the token and POST it to
+	// the API.
+	FrontendURL string
+	// RequireVerifiedEmail blocks unverified users from creating content.
+	RequireVerifiedEmail bool
+	// DeletionGracePeriod is how long a deleted account can still be
+	// restored by logging in before it is purged.
+	DeletionGracePeriod time.Duration
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For. Only
+	// enable it behind a proxy that sets the header.
+	TrustProxyHeaders bool
+	// JWTKeys signs and verifies access tokens.
+	JWTKeys *utils.KeySet
+	// OIDCProviders are the identity providers users can sign in with,
+	// keyed by name.
+	OIDCProviders map[string]*oidc.Provider
+	// Hub pushes real-time events to connected clients.
+	Hub pubsub.Hub
 }
 
+const defaultDeletionGracePeriod = 14 * 24 * time.Hour
+
 var (
 	instance *Config
 	once     sync.Once
@@ -27,11 +55,24 @@ var (
 // SetTestInstance sets a mock instance for testing
 func SetTestInstance(mockDB Database) {
 	fmt.Println("[DEBUG] SetTestInstance called, setting singleton instance")
+	key, err := utils.GenerateEd25519Key("test")
+	if err != nil {
+		log.Fatalf("failed to generate test signing key: %v", err)
+	}
+	keys, _ := utils.NewKeySet(key)
 	instance = &Config{
 		DB:        mockDB,
 		AWSSess:   nil,
 		S3_Bucket: "test-bucket",
 		Port:      "8080",
+		Mailer:    &mailer.LogMailer{},
+		AppURL:    "http://localhost:8080",
+
+		FrontendURL:         "http://localhost:3000",
+		DeletionGracePeriod: defaultDeletionGracePeriod,
+		JWTKeys:             keys,
+		OIDCProviders:       make(map[string]*oidc.Provider),
+		Hub:                 pubsub.NewMemoryHub(),
 	}
 }
 
@@ -44,15 +85,42 @@ func Init() *Config {
 			log.Fatalf("failed to connect to the database url: %v", err)
 		}
 
+		keys, err := utils.LoadKeySet(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_ACTIVE_KEY_ID"))
+		if err != nil {
+			log.Fatalf("failed to load JWT signing keys: %v", err)
+		}
+
+		frontendURL := os.Getenv("FRONTEND_URL")
+		if frontendURL == "" {
+			log.Fatal("FRONTEND_URL must be set to the web app that serves email links")
+		}
+		providers, err := oidc.FromEnv(os.Getenv("APP_URL"))
+		if err != nil {
+			log.Fatalf("failed to configure identity providers: %v", err)
+		}
+
 		sess := session.Must(session.NewSessionWithOptions(session.Options{
 			SharedConfigState: session.SharedConfigEnable,
 		},
 		))
 		instance = &Config{
-			DB:        &SQLDatabase{DB: conn},
-			AWSSess:   sess,
-			S3_Bucket: os.Getenv("S3_BUCKET_NAME"),
-			Port:      os.Getenv("PORT"),
+			DB:          &SQLDatabase{DB: conn},
+			AWSSess:     sess,
+			S3_Bucket:   os.Getenv("S3_BUCKET_NAME"),
+			Port:        os.Getenv("PORT"),
+			Mailer:      mailer.FromEnv(),
+			AppURL:      os.Getenv("APP_URL"),
+			FrontendURL: frontendURL,
+			// Enabled unless explicitly turned off
+			RequireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") != "false",
+			DeletionGracePeriod:  defaultDeletionGracePeriod,
+			TrustProxyHeaders:    os.Getenv("TRUST_PROXY_HEADERS") == "true",
+			JWTKeys:              keys,
+			OIDCProviders:        providers,
+			Hub:                  pubsub.NewMemoryHub(),
+		}
+		if grace, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")); err == nil {
+			instance.DeletionGracePeriod = grace
 		}
 	})
 	return instance
@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/url"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-// Function variable for testability
-var UploadFileAndGetUrl = uploadFileAndGetUrl
+// Function variables for testability
+var (
+	UploadFileAndGetUrl = uploadFileAndGetUrl
+	DeleteFile          = deleteFile
+)
 
 // Real implementation
 func uploadFileAndGetUrl(sess *session.Session, bucket, key string, file multipart.File, size int64, contentType string) (string, error) {
@@ -45,3 +50,24 @@ func uploadFileAndGetUrl(sess *session.Session, bucket, key string, file multipa
 	)
 	return url, err
 }
+
+func deleteFile(sess *session.Session, bucket, key string) error {
+	_, err := s3.New(sess).DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// KeyFromURL returns the object key of a URL produced by UploadFileAndGetUrl.
+func KeyFromURL(fileURL string) (string, error) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if key == "" {
+		return "", fmt.Errorf("no object key in url %q", fileURL)
+	}
+	return key, nil
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+)
+
+// ExportData returns a zip archive with everything stored about the current
+// user: profile, posts, recipes, meal plans and a manifest of their media.
+func (u *UserHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.loadUser(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	posts, err := u.Manager.PostRepo.GetPostsByAuthorID(userID)
+	if err != nil {
+		http.Error(w, "Failed to export posts", http.StatusInternalServerError)
+		return
+	}
+
+	// Listing by author doesn't load ingredients and steps
+	authorRecipes, err := u.Manager.RecipeRepo.GetRecipesByAuthorID(userID)
+	if err != nil {
+		http.Error(w, "Failed to export recipes", http.StatusInternalServerError)
+		return
+	}
+	recipes := make([]repository.RecipeWithMedia, 0, len(authorRecipes))
+	for _, recipe := range authorRecipes {
+		full, err := u.Manager.RecipeRepo.GetRecipeByID(recipe.Id)
+		if err != nil || full == nil {
+			http.Error(w, "Failed to export recipes", http.StatusInternalServerError)
+			return
+		}
+		recipes = append(recipes, *full)
+	}
+
+	mealPlans, err := u.Manager.MealPlanRepo.GetMealPlansByAuthorID(userID)
+	if err != nil {
+		http.Error(w, "Failed to export meal plans", http.StatusInternalServerError)
+		return
+	}
+
+	media, err := u.Manager.MediaRepo.GetMediaByAuthorID(userID)
+	if err != nil {
+		http.Error(w, "Failed to export media", http.StatusInternalServerError)
+		return
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"posts.json", posts},
+		{"recipes.json", recipes},
+		{"meal_plans.json", mealPlans},
+		{"media.json", media},
+	}
+
+	// Build the archive in memory so failures can still be reported
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			http.Error(w, "Failed to build export", http.StatusInternalServerError)
+			return
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			http.Error(w, "Failed to build export", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		http.Error(w, "Failed to build export", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("foody-export-%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
+// DeleteAccount schedules the current user's account for deletion after the
+// configured grace period and logs them out everywhere. Logging in again
+// before the period ends cancels the deletion.
+func (u *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.PasswordConfirmReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	authenticated, err := u.Manager.UserRepo.AuthUser(user.Email, req.Password)
+	if err != nil || !authenticated {
+		http.Error(w, "Invalid password", http.StatusForbidden)
+		return
+	}
+
+	deleteAt := time.Now().Add(config.Get().DeletionGracePeriod)
+	if err := u.Manager.UserRepo.ScheduleDeletion(userID, deleteAt); err != nil {
+		http.Error(w, "Failed to schedule account deletion", http.StatusInternalServerError)
+		return
+	}
+
+	if err := u.Manager.SessionRepo.RevokeUserSessions(userID); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	}{
+		DeletionScheduledAt: deleteAt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestUserHandler_ExportData(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "export@example.com")
+	other, _ := loginTestUser(t, manager, handler, "someone@example.com")
+
+	manager.PostRepo.CreatePost(schema.Post{Id: uuid.New(), Title: "Mine", AuthorId: user.Id}, uuid.New(), "")
+	manager.PostRepo.CreatePost(schema.Post{Id: uuid.New(), Title: "Theirs", AuthorId: other.Id}, uuid.New(), "")
+	manager.RecipeRepo.CreateRecipe(schema.Recipe{Id: uuid.New(), Title: "Soup", AuthorId: user.Id}, uuid.Nil, "")
+	manager.MealPlanRepo.CreateMealPlan(schema.MealPlan{Id: uuid.New(), AuthorId: user.Id, MealType: schema.Lunch})
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me/export", nil), user.Id)
+	w := httptest.NewRecorder()
+	handler.ExportData(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("expected zip content type, got %q", contentType)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open export archive: %v", err)
+	}
+
+	contents := make(map[string][]byte)
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		contents[file.Name], _ = io.ReadAll(f)
+		f.Close()
+	}
+
+	for _, name := range []string{"profile.json", "posts.json", "recipes.json", "meal_plans.json", "media.json"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("Expected %s in export", name)
+		}
+	}
+
+	var posts []schema.PostWithMedia
+	if err := json.Unmarshal(contents["posts.json"], &posts); err != nil {
+		t.Fatalf("Failed to decode posts: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Title != "Mine" {
+		t.Errorf("Expected only the user's own post, got %+v", posts)
+	}
+
+	var recipes []repository.RecipeWithMedia
+	json.Unmarshal(contents["recipes.json"], &recipes)
+	if len(recipes) != 1 {
+		t.Errorf("Expected 1 recipe, got %d", len(recipes))
+	}
+
+	var media []schema.Media
+	json.Unmarshal(contents["media.json"], &media)
+	if len(media) != 1 {
+		t.Errorf("Expected the profile image in the media manifest, got %d items", len(media))
+	}
+}
+
+func TestUserHandler_DeleteAccount(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "delete@example.com")
+	mgr := config.Get().DB.(*MockRepositoryManager)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Missing password",
+			body:           map[string]string{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong password",
+			body:           map[string]string{"password": "wrongpassword"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Correct password",
+			body:           map[string]string{"password": "password123"},
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodDelete, "/api/me", tt.body), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.DeleteAccount(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	stored := mgr.Users[user.Id]
+	if stored.DeletionScheduledAt == nil {
+		t.Fatal("Expected account deletion to be scheduled")
+	}
+	if until := time.Until(*stored.DeletionScheduledAt); until < config.Get().DeletionGracePeriod-time.Minute {
+		t.Errorf("Expected deletion after the grace period, got %v", until)
+	}
+	for _, session := range mgr.Sessions {
+		if session.UserId == user.Id && session.RevokedAt == nil {
+			t.Error("Expected all sessions to be revoked")
+		}
+	}
+
+	// Logging in again during the grace period restores the account
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    user.Email,
+		"password": "password123",
+	})
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if stored.DeletionScheduledAt != nil {
+		t.Error("Expected login to cancel the pending deletion")
+	}
+}
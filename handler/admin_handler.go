@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+)
+
+// AdminHandler serves the user management and moderation endpoints.
+type AdminHandler struct {
+	Manager *repository.Manager
+}
+
+func NewAdminHandler(manager *repository.Manager) *AdminHandler {
+	return &AdminHandler{
+		Manager: manager,
+	}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	limit := 20
+	offset := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	users, err := h.Manager.UserRepo.ListUsers(limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	totalCount, err := h.Manager.UserRepo.GetTotalUsersCount()
+	if err != nil {
+		http.Error(w, "Failed to get total users count", http.StatusInternalServerError)
+		return
+	}
+
+	type pagination struct {
+		Total  int `json:"total"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	response := struct {
+		Users      []schema.User `json:"users"`
+		Pagination pagination    `json:"pagination"`
+	}{
+		Users:      users,
+		Pagination: pagination{Total: totalCount, Limit: limit, Offset: offset},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SuspendUser blocks a user from logging in and ends all of their sessions.
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.Role == schema.RoleAdmin {
+		http.Error(w, "Admins cannot be suspended", http.StatusForbidden)
+		return
+	}
+
+	if err := h.Manager.UserRepo.SetSuspended(user.Id, true); err != nil {
+		http.Error(w, "Failed to suspend user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Manager.SessionRepo.RevokeUserSessions(user.Id); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Manager.UserRepo.SetSuspended(user.Id, false); err != nil {
+		http.Error(w, "Failed to unsuspend user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateUserRole changes a user's role. The new role takes effect on the
+// user's next login or token refresh.
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requests.UpdateRoleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	role := schema.Role(req.Role)
+	if !role.Valid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if userID, _ := r.Context().Value("user_id").(uuid.UUID); userID == user.Id {
+		http.Error(w, "You cannot change your own role", http.StatusForbidden)
+		return
+	}
+
+	if err := h.Manager.UserRepo.UpdateRole(user.Id, role); err != nil {
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	postID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.Manager.PostRepo.GetPostByID(postID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to load post", http.StatusInternalServerError)
+		return
+	}
+	if post == nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	if user, _ := currentUser(r); !authz.Can(user, authz.Moderate, post) {
+		http.Error(w, "Unauthorized to remove this post", http.StatusForbidden)
+		return
+	}
+
+	if err := h.Manager.PostRepo.DeletePost(postID); err != nil {
+		http.Error(w, "Failed to delete post", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
+	recipeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	recipe, err := h.Manager.RecipeRepo.GetRecipeByID(recipeID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to load recipe", http.StatusInternalServerError)
+		return
+	}
+	if recipe == nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	if user, _ := currentUser(r); !authz.Can(user, authz.Moderate, recipe) {
+		http.Error(w, "Unauthorized to remove this recipe", http.StatusForbidden)
+		return
+	}
+
+	if err := h.Manager.RecipeRepo.DeleteRecipe(recipeID); err != nil {
+		http.Error(w, "Failed to delete recipe", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMedia removes a media record and its stored file. Posts, recipes and
+// profiles that used it are left without media.
+func (h *AdminHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	mediaID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.Manager.MediaRepo.GetMediaByID(mediaID)
+	if err != nil || media == nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	if user, _ := currentUser(r); !authz.Can(user, authz.Moderate, media) {
+		http.Error(w, "Unauthorized to remove this media", http.StatusForbidden)
+		return
+	}
+
+	cfg := config.Get()
+	key, err := data.KeyFromURL(media.URL)
+	if err == nil {
+		err = data.DeleteFile(cfg.AWSSess, cfg.S3_Bucket, key)
+	}
+	if err != nil {
+		log.Printf("error deleting media file %s: %v\n", media.Id, err)
+		http.Error(w, "Failed to delete media file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Manager.MediaRepo.DeleteMedia(mediaID); err != nil {
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// targetUser loads the user named by the {id} URL parameter, writing an
+// error response if it cannot.
+func (h *AdminHandler) targetUser(w http.ResponseWriter, r *http.Request) (*schema.User, bool) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, err := h.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/middleware"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestRequireRole(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	protected := middleware.RequireRole(schema.RoleModerator, schema.RoleAdmin)(ok)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		role           schema.Role
+		expectedStatus int
+	}{
+		{
+			name:           "Admin allowed",
+			role:           schema.RoleAdmin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Moderator allowed",
+			role:           schema.RoleModerator,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "User forbidden",
+			role:           schema.RoleUser,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Missing role forbidden",
+			role:           "",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupRoleContext(setupTestRequest(t, http.MethodGet, "/api/admin/users", nil), uuid.New(), tt.role)
+			w := httptest.NewRecorder()
+
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_LoginIncludesRole(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	_, login := loginTestUser(t, manager, handler, "role@example.com")
+
+	claims, err := config.Get().JWTKeys.ValidateToken(login.Token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if claims.Role != schema.RoleUser {
+		t.Errorf("Expected role %q, got %q", schema.RoleUser, claims.Role)
+	}
+}
+
+func TestAdminHandler_ListUsers(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewAdminHandler(manager)
+	admin, _ := loginTestUser(t, manager, userHandler, "admin@example.com")
+	loginTestUser(t, manager, userHandler, "member@example.com")
+
+	req := setupRoleContext(setupTestRequest(t, http.MethodGet, "/api/admin/users", nil), admin.Id, schema.RoleAdmin)
+	w := httptest.NewRecorder()
+	handler.ListUsers(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Users      []schema.User `json:"users"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	readResponseBody(t, w, &response)
+	if response.Pagination.Total != 2 || len(response.Users) != 2 {
+		t.Errorf("Expected 2 users, got %d (total %d)", len(response.Users), response.Pagination.Total)
+	}
+}
+
+func TestAdminHandler_SuspendUser(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewAdminHandler(manager)
+	admin, _ := loginTestUser(t, manager, userHandler, "admin@example.com")
+	member, login := loginTestUser(t, manager, userHandler, "member@example.com")
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	mgr.Users[admin.Id].Role = schema.RoleAdmin
+
+	suspend := func(method, userID string) int {
+		req := setupRoleContext(setupTestRequest(t, method, "/api/admin/users/"+userID+"/suspend", nil), admin.Id, schema.RoleAdmin)
+		req = setupURLParams(req, map[string]string{"id": userID})
+		w := httptest.NewRecorder()
+		if method == http.MethodDelete {
+			handler.UnsuspendUser(w, req)
+		} else {
+			handler.SuspendUser(w, req)
+		}
+		return w.Code
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid user ID",
+			userID:         "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown user",
+			userID:         uuid.New().String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Admins cannot be suspended",
+			userID:         admin.Id.String(),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Suspend user",
+			userID:         member.Id.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := suspend(http.MethodPost, tt.userID); code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, code)
+			}
+		})
+	}
+
+	// Suspended users lose their sessions and cannot log in or refresh
+	for _, s := range mgr.Sessions {
+		if s.UserId == member.Id && s.RevokedAt == nil {
+			t.Error("Expected sessions of suspended user to be revoked")
+		}
+	}
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    member.Email,
+		"password": "password123",
+	})
+	w := httptest.NewRecorder()
+	userHandler.Login(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected suspended login to return %d, got %d", http.StatusForbidden, w.Code)
+	}
+	req = setupFormRequest(t, http.MethodPost, "/token/refresh", map[string]string{"refresh_token": login.RefreshToken})
+	w = httptest.NewRecorder()
+	userHandler.RefreshToken(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected suspended refresh to return %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// Lifting the suspension allows logging in again
+	if code := suspend(http.MethodDelete, member.Id.String()); code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+	}
+	w = httptest.NewRecorder()
+	userHandler.Login(w, setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    member.Email,
+		"password": "password123",
+	}))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestAdminHandler_UpdateUserRole(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewAdminHandler(manager)
+	admin, _ := loginTestUser(t, manager, userHandler, "admin@example.com")
+	member, _ := loginTestUser(t, manager, userHandler, "member@example.com")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		role           string
+		expectedStatus int
+	}{
+		{
+			name:           "Unknown role",
+			userID:         member.Id,
+			role:           "superuser",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Cannot change own role",
+			userID:         admin.Id,
+			role:           "user",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Promote to moderator",
+			userID:         member.Id,
+			role:           "moderator",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPut, "/api/admin/users/"+tt.userID.String()+"/role", map[string]string{"role": tt.role})
+			req = setupURLParams(setupRoleContext(req, admin.Id, schema.RoleAdmin), map[string]string{"id": tt.userID.String()})
+			w := httptest.NewRecorder()
+
+			handler.UpdateUserRole(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if role := config.Get().DB.(*MockRepositoryManager).Users[member.Id].Role; role != schema.RoleModerator {
+		t.Errorf("Expected role %q, got %q", schema.RoleModerator, role)
+	}
+}
+
+func TestAdminHandler_DeleteContent(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewAdminHandler(manager)
+	moderator := uuid.New()
+	author := uuid.New()
+
+	var deletedKeys []string
+	originalDeleteFile := data.DeleteFile
+	t.Cleanup(func() { data.DeleteFile = originalDeleteFile })
+	data.DeleteFile = func(sess *session.Session, bucket, key string) error {
+		deletedKeys = append(deletedKeys, key)
+		return nil
+	}
+
+	postID := uuid.New()
+	manager.PostRepo.CreatePost(schema.Post{Id: postID, AuthorId: author}, uuid.New(), "")
+	recipeID := uuid.New()
+	manager.RecipeRepo.CreateRecipe(schema.Recipe{Id: recipeID, AuthorId: author}, uuid.New(), "")
+	mediaID, _ := manager.MediaRepo.CreateMedia(schema.Media{
+		Id:       uuid.New(),
+		URL:      "https://test-bucket.s3.us-east-1.amazonaws.com/posts/photo.jpg",
+		AuthorId: author,
+	})
+
+	// Test cases
+	tests := []struct {
+		name           string
+		handle         http.HandlerFunc
+		id             string
+		expectedStatus int
+	}{
+		{
+			name:           "Delete post",
+			handle:         handler.DeletePost,
+			id:             postID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Delete missing post",
+			handle:         handler.DeletePost,
+			id:             postID.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Delete recipe",
+			handle:         handler.DeleteRecipe,
+			id:             recipeID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Invalid recipe ID",
+			handle:         handler.DeleteRecipe,
+			id:             "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Delete media",
+			handle:         handler.DeleteMedia,
+			id:             mediaID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Delete missing media",
+			handle:         handler.DeleteMedia,
+			id:             mediaID.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupRoleContext(setupTestRequest(t, http.MethodDelete, "/api/admin", nil), moderator, schema.RoleModerator)
+			req = setupURLParams(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			tt.handle(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	if len(mgr.Posts) != 0 || len(mgr.Recipes) != 0 || len(mgr.Media) != 0 {
+		t.Error("Expected moderated content to be removed")
+	}
+	if len(deletedKeys) != 1 || deletedKeys[0] != "posts/photo.jpg" {
+		t.Errorf("Expected stored file to be deleted, got %v", deletedKeys)
+	}
+}
+
+// failingPostLookup is a post repository whose lookups by ID fail.
+type failingPostLookup struct {
+	repository.PostRepositoryInterface
+}
+
+func (failingPostLookup) GetPostByID(id uuid.UUID) (*repository.PostWithMedia, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestAdminHandler_DeletePost_LookupFails(t *testing.T) {
+	manager := mockRepositoryManager()
+	postID := uuid.New()
+	manager.PostRepo.CreatePost(schema.Post{Id: postID, AuthorId: uuid.New()}, uuid.New(), "")
+	manager.PostRepo = failingPostLookup{manager.PostRepo}
+	handler := NewAdminHandler(manager)
+
+	req := setupRoleContext(setupTestRequest(t, http.MethodDelete, "/api/admin", nil), uuid.New(), schema.RoleModerator)
+	req = setupURLParams(req, map[string]string{"id": postID.String()})
+	w := httptest.NewRecorder()
+	handler.DeletePost(w, req)
+
+	// A post that cannot be loaded is not reported as missing
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if len(config.Get().DB.(*MockRepositoryManager).Posts) != 1 {
+		t.Error("Expected the post to be kept")
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+const (
+	maxAPIKeys          = 20
+	maxAPIKeyNameLength = 100
+	// apiKeyPrefixLength is how much of a key is kept in the clear so users
+	// can tell their keys apart.
+	apiKeyPrefixLength = len(schema.APIKeyPrefix) + 6
+)
+
+// CreatedAPIKey is returned once when a key is created; the key itself
+// cannot be retrieved again.
+type CreatedAPIKey struct {
+	Key    string         `json:"key"`
+	APIKey *schema.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey creates a personal API key for the current user.
+func (u *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.CreateAPIKeyReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[schema.APIScope]bool)
+	for _, s := range req.Scopes {
+		scope := schema.APIScope(s)
+		if !scope.Valid() {
+			http.Error(w, "Unknown scope "+s, http.StatusBadRequest)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays <= 0 {
+			http.Error(w, "expires_in_days must be positive", http.StatusBadRequest)
+			return
+		}
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	existing, err := u.Manager.APIKeyRepo.GetAPIKeysByUserID(userID)
+	if err != nil {
+		http.Error(w, "Failed to load API keys", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxAPIKeys {
+		http.Error(w, "Too many API keys, revoke one first", http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	key := schema.APIKeyPrefix + secret
+
+	apiKey := schema.APIKey{
+		Id:        uuid.New(),
+		UserId:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   utils.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := u.Manager.APIKeyRepo.CreateAPIKey(apiKey); err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedAPIKey{Key: key, APIKey: &apiKey})
+}
+
+// GetAPIKeys lists the current user's keys that have not been revoked.
+func (u *UserHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := u.Manager.APIKeyRepo.GetAPIKeysByUserID(userID)
+	if err != nil {
+		http.Error(w, "Failed to load API keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []schema.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey revokes one of the current user's keys.
+func (u *UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Manager.APIKeyRepo.RevokeAPIKey(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/middleware"
+	"github.com/smilecs/foody/schema"
+)
+
+// createTestAPIKey creates a key through the handler and returns it.
+func createTestAPIKey(t *testing.T, handler *UserHandler, userID uuid.UUID, scopes []string) CreatedAPIKey {
+	t.Helper()
+	body := map[string]interface{}{"name": "script", "scopes": scopes}
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/api-keys", body), userID)
+	w := httptest.NewRecorder()
+	handler.CreateAPIKey(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var created CreatedAPIKey
+	readResponseBody(t, w, &created)
+	return created
+}
+
+func TestUserHandler_CreateAPIKey(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "apikeys@example.com")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Valid key with scopes",
+			body:           map[string]interface{}{"name": "sync", "scopes": []string{"recipes:write", "mealplans:read"}},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Valid key with follows scopes",
+			body:           map[string]interface{}{"name": "social", "scopes": []string{"follows:read", "follows:write"}},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Valid key without scopes",
+			body:           map[string]interface{}{"name": "everything", "expires_in_days": 30},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Missing name",
+			body:           map[string]interface{}{"name": " "},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown scope",
+			body:           map[string]interface{}{"name": "sync", "scopes": []string{"users:admin"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Non-positive expiry",
+			body:           map[string]interface{}{"name": "sync", "expires_in_days": 0},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/api-keys", tt.body), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.CreateAPIKey(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	created := createTestAPIKey(t, handler, user.Id, nil)
+	if !strings.HasPrefix(created.Key, schema.APIKeyPrefix) || !strings.HasPrefix(created.Key, created.APIKey.Prefix) {
+		t.Errorf("Unexpected key %q with prefix %q", created.Key, created.APIKey.Prefix)
+	}
+	stored := config.Get().DB.(*MockRepositoryManager).APIKeys[created.APIKey.Id]
+	if stored.KeyHash == created.Key || strings.Contains(stored.KeyHash, created.Key) {
+		t.Error("API key must be stored hashed")
+	}
+}
+
+func TestUserHandler_ListAndRevokeAPIKeys(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "revoke@example.com")
+	other, _ := loginTestUser(t, manager, handler, "other-keys@example.com")
+	created := createTestAPIKey(t, handler, user.Id, []string{"recipes:read"})
+
+	list := func(userID uuid.UUID) []schema.APIKey {
+		req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me/api-keys", nil), userID)
+		w := httptest.NewRecorder()
+		handler.GetAPIKeys(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if strings.Contains(w.Body.String(), created.Key) {
+			t.Error("Listing must not include the key")
+		}
+		var keys []schema.APIKey
+		readResponseBody(t, w, &keys)
+		return keys
+	}
+
+	if keys := list(user.Id); len(keys) != 1 || keys[0].Id != created.APIKey.Id {
+		t.Fatalf("Expected the created key, got %+v", keys)
+	}
+	if keys := list(other.Id); len(keys) != 0 {
+		t.Errorf("Expected no keys for another user, got %d", len(keys))
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		keyID          string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid key ID",
+			userID:         user.Id,
+			keyID:          "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Another user's key",
+			userID:         other.Id,
+			keyID:          created.APIKey.Id.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Own key",
+			userID:         user.Id,
+			keyID:          created.APIKey.Id.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Already revoked",
+			userID:         user.Id,
+			keyID:          created.APIKey.Id.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodDelete, "/api/me/api-keys/"+tt.keyID, nil), tt.userID)
+			req = setupURLParams(req, map[string]string{"id": tt.keyID})
+			w := httptest.NewRecorder()
+
+			handler.RevokeAPIKey(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if keys := list(user.Id); len(keys) != 0 {
+		t.Errorf("Expected revoked key to be hidden, got %d", len(keys))
+	}
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, login := loginTestUser(t, manager, handler, "keyauth@example.com")
+	scoped := createTestAPIKey(t, handler, user.Id, []string{"recipes:read"})
+	unscoped := createTestAPIKey(t, handler, user.Id, nil)
+	revoked := createTestAPIKey(t, handler, user.Id, nil)
+	manager.APIKeyRepo.RevokeAPIKey(user.Id, revoked.APIKey.Id)
+
+	var seen uuid.UUID
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = r.Context().Value("user_id").(uuid.UUID)
+		w.WriteHeader(http.StatusOK)
+	})
+	auth := middleware.AuthMiddleware(manager)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		token          string
+		handler        http.Handler
+		expectedStatus int
+	}{
+		{
+			name:           "Key with scope",
+			token:          scoped.Key,
+			handler:        middleware.RequireScope(schema.ScopeRecipesRead)(ok),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Key without the scope",
+			token:          scoped.Key,
+			handler:        middleware.RequireScope(schema.ScopeRecipesWrite)(ok),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Key without the follows scope",
+			token:          scoped.Key,
+			handler:        middleware.RequireScope(schema.ScopeFollowsWrite)(ok),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Unscoped key",
+			token:          unscoped.Key,
+			handler:        middleware.RequireScope(schema.ScopeMealPlansWrite)(ok),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Access token passes scope checks",
+			token:          login.Token,
+			handler:        middleware.RequireScope(schema.ScopeRecipesWrite)(ok),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Key rejected for account management",
+			token:          unscoped.Key,
+			handler:        middleware.RequireSession(ok),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Revoked key",
+			token:          revoked.Key,
+			handler:        ok,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown key",
+			token:          schema.APIKeyPrefix + "unknown",
+			handler:        ok,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = uuid.Nil
+			req := setupTestRequest(t, http.MethodGet, "/api/recipes", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			auth(tt.handler).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && seen != user.Id {
+				t.Errorf("Expected request as %s, got %s", user.Id, seen)
+			}
+		})
+	}
+
+	if stored := config.Get().DB.(*MockRepositoryManager).APIKeys[scoped.APIKey.Id]; stored.LastUsedAt == nil {
+		t.Error("Expected last used time to be recorded")
+	}
+
+	// Suspending the owner disables their keys
+	config.Get().DB.(*MockRepositoryManager).Users[user.Id].SuspendedAt = &scoped.APIKey.CreatedAt
+	req := setupTestRequest(t, http.MethodGet, "/api/recipes", nil)
+	req.Header.Set("Authorization", "Bearer "+unscoped.Key)
+	w := httptest.NewRecorder()
+	auth(ok).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
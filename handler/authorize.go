@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/schema"
+)
+
+// currentUser returns the caller that AuthMiddleware stored on the request.
+func currentUser(r *http.Request) (authz.User, bool) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		return authz.User{}, false
+	}
+	role, _ := r.Context().Value("role").(schema.Role)
+	return authz.User{Id: userID, Role: role}, true
+}
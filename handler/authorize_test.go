@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// ownershipCases are shared by the update and delete tests of every resource
+var ownershipCases = []struct {
+	name           string
+	caller         string
+	role           schema.Role
+	expectedStatus int
+}{
+	{
+		name:           "Other user is forbidden",
+		caller:         "other",
+		role:           schema.RoleUser,
+		expectedStatus: http.StatusForbidden,
+	},
+	{
+		name:           "Moderator is forbidden",
+		caller:         "other",
+		role:           schema.RoleModerator,
+		expectedStatus: http.StatusForbidden,
+	},
+	{
+		name:           "Owner is allowed",
+		caller:         "owner",
+		role:           schema.RoleUser,
+		expectedStatus: http.StatusOK,
+	},
+	{
+		name:           "Admin is allowed",
+		caller:         "other",
+		role:           schema.RoleAdmin,
+		expectedStatus: http.StatusOK,
+	},
+}
+
+func callerID(caller string, ownerID, otherID uuid.UUID) uuid.UUID {
+	if caller == "owner" {
+		return ownerID
+	}
+	return otherID
+}
+
+func TestRecipeHandler_CreateRecipeSetsAuthor(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	userID := uuid.New()
+
+	recipe := schema.Recipe{Title: "Soup", AuthorId: uuid.New()}
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/recipes", recipe), userID)
+	w := httptest.NewRecorder()
+	handler.CreateRecipe(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created schema.Recipe
+	readResponseBody(t, w, &created)
+	if created.AuthorId != userID {
+		t.Errorf("Expected author %s, got %s", userID, created.AuthorId)
+	}
+
+	// Media owned by someone else cannot be attached
+	mediaID, _ := manager.MediaRepo.CreateMedia(schema.Media{Id: uuid.New(), AuthorId: uuid.New()})
+	recipe.MediaId = mediaID
+	req = setupTestContext(setupTestRequest(t, http.MethodPost, "/api/recipes", recipe), userID)
+	w = httptest.NewRecorder()
+	handler.CreateRecipe(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestRecipeHandler_Ownership(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	ownerID, otherID := uuid.New(), uuid.New()
+
+	for _, tt := range ownershipCases {
+		t.Run(tt.name, func(t *testing.T) {
+			recipeID := uuid.New()
+			manager.RecipeRepo.CreateRecipe(schema.Recipe{Id: recipeID, Title: "Soup", AuthorId: ownerID}, uuid.Nil, "")
+			caller := callerID(tt.caller, ownerID, otherID)
+			params := map[string]string{"id": recipeID.String()}
+
+			body := schema.Recipe{Title: "Updated", AuthorId: caller}
+			req := setupTestRequest(t, http.MethodPut, "/api/recipes/"+recipeID.String(), body)
+			req = setupURLParams(setupRoleContext(req, caller, tt.role), params)
+			w := httptest.NewRecorder()
+			handler.UpdateRecipe(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("update: expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			stored := config.Get().DB.(*MockRepositoryManager).Recipes[recipeID]
+			if stored.AuthorId != ownerID {
+				t.Error("Update must not change the recipe author")
+			}
+
+			req = setupTestRequest(t, http.MethodDelete, "/api/recipes/"+recipeID.String(), nil)
+			req = setupURLParams(setupRoleContext(req, caller, tt.role), params)
+			w = httptest.NewRecorder()
+			handler.DeleteRecipe(w, req)
+			expected := tt.expectedStatus
+			if expected == http.StatusOK {
+				expected = http.StatusNoContent
+			}
+			if w.Code != expected {
+				t.Errorf("delete: expected status %d, got %d", expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestPostHandler_Ownership(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewPostHandler(manager)
+	ownerID, otherID := uuid.New(), uuid.New()
+
+	for _, tt := range ownershipCases {
+		t.Run(tt.name, func(t *testing.T) {
+			postID := uuid.New()
+			manager.PostRepo.CreatePost(schema.Post{Id: postID, Title: "Dinner", AuthorId: ownerID}, uuid.Nil, "")
+			caller := callerID(tt.caller, ownerID, otherID)
+
+			body := schema.Post{Title: "Updated", AuthorId: caller}
+			req := setupTestRequest(t, http.MethodPut, "/posts/x?id="+postID.String(), body)
+			req = setupRoleContext(req, caller, tt.role)
+			w := httptest.NewRecorder()
+			handler.UpdatePost(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("update: expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			stored := config.Get().DB.(*MockRepositoryManager).Posts[postID]
+			if stored.AuthorId != ownerID {
+				t.Error("Update must not change the post author")
+			}
+
+			req = setupTestRequest(t, http.MethodDelete, "/posts/x?id="+postID.String(), nil)
+			req = setupRoleContext(req, caller, tt.role)
+			w = httptest.NewRecorder()
+			handler.DeletePost(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("delete: expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestMealPlanHandler_Ownership(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewMealPlanHandler(manager)
+	ownerID, otherID := uuid.New(), uuid.New()
+
+	for _, tt := range ownershipCases {
+		t.Run(tt.name, func(t *testing.T) {
+			mealPlanID := uuid.New()
+			manager.MealPlanRepo.CreateMealPlan(schema.MealPlan{Id: mealPlanID, MealType: schema.Lunch, AuthorId: ownerID})
+			caller := callerID(tt.caller, ownerID, otherID)
+			params := map[string]string{"id": mealPlanID.String()}
+
+			body := schema.MealPlan{MealType: schema.Dinner, AuthorId: caller}
+			req := setupTestRequest(t, http.MethodPut, "/api/meal-plans/"+mealPlanID.String(), body)
+			req = setupURLParams(setupRoleContext(req, caller, tt.role), params)
+			w := httptest.NewRecorder()
+			handler.UpdateMealPlan(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("update: expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			stored := config.Get().DB.(*MockRepositoryManager).MealPlans[mealPlanID]
+			if stored.AuthorId != ownerID {
+				t.Error("Update must not change the meal plan author")
+			}
+
+			req = setupTestRequest(t, http.MethodDelete, "/api/meal-plans/"+mealPlanID.String(), nil)
+			req = setupURLParams(setupRoleContext(req, caller, tt.role), params)
+			w = httptest.NewRecorder()
+			handler.DeleteMealPlan(w, req)
+			expected := tt.expectedStatus
+			if expected == http.StatusOK {
+				expected = http.StatusNoContent
+			}
+			if w.Code != expected {
+				t.Errorf("delete: expected status %d, got %d", expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ModerationRequiresRole(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewAdminHandler(manager)
+	ownerID := uuid.New()
+
+	postID := uuid.New()
+	manager.PostRepo.CreatePost(schema.Post{Id: postID, AuthorId: ownerID}, uuid.Nil, "")
+
+	// Even the author needs a moderator role to use the moderation endpoint
+	req := setupRoleContext(setupTestRequest(t, http.MethodDelete, "/api/admin/posts/"+postID.String(), nil), ownerID, schema.RoleUser)
+	req = setupURLParams(req, map[string]string{"id": postID.String()})
+	w := httptest.NewRecorder()
+	handler.DeletePost(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,509 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	maxCollectionsPerUser        = 100
+	maxCollectionNameLength      = 100
+	maxCollectionDescriptionSize = 500
+	maxCollectionNoteLength      = 500
+	defaultCollectionPageSize    = 50
+)
+
+// CollectionHandler serves user-curated recipe collections and saving
+// recipes to the default "Saved" collection.
+type CollectionHandler struct {
+	Manager *repository.Manager
+}
+
+func NewCollectionHandler(manager *repository.Manager) *CollectionHandler {
+	return &CollectionHandler{
+		Manager: manager,
+	}
+}
+
+// CollectionEntry is a collection item together with its recipe.
+type CollectionEntry struct {
+	schema.CollectionItem
+	Recipe *repository.RecipeWithMedia `json:"recipe"`
+}
+
+// GetMyCollections lists the current user's collections, private ones
+// included, starting with the "Saved" collection.
+func (h *CollectionHandler) GetMyCollections(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Make sure the default collection shows up before anything was saved
+	if _, err := h.Manager.CollectionRepo.GetDefaultCollection(userID); err != nil {
+		http.Error(w, "Failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
+	h.writeCollections(w, userID, true)
+}
+
+// GetUserCollections lists the public collections of the user in the URL.
+func (h *CollectionHandler) GetUserCollections(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	ownerID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	h.writeCollections(w, ownerID, ownerID == userID)
+}
+
+func (h *CollectionHandler) writeCollections(w http.ResponseWriter, userID uuid.UUID, includePrivate bool) {
+	collections, err := h.Manager.CollectionRepo.GetCollectionsByUserID(userID, includePrivate)
+	if err != nil {
+		http.Error(w, "Failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
+	if collections == nil {
+		collections = []schema.Collection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collections)
+}
+
+// CreateCollection creates a collection for the current user.
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.CollectionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	collection := schema.Collection{
+		Id:          uuid.New(),
+		UserId:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		IsPublic:    req.IsPublic,
+	}
+	if msg := validateCollection(collection); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.Manager.CollectionRepo.GetCollectionsByUserID(userID, true)
+	if err != nil {
+		http.Error(w, "Failed to create collection", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxCollectionsPerUser {
+		http.Error(w, "Too many collections", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Manager.CollectionRepo.CreateCollection(collection); err != nil {
+		http.Error(w, "Failed to create collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// validateCollection returns why collection cannot be stored, or "".
+func validateCollection(collection schema.Collection) string {
+	switch {
+	case collection.Name == "":
+		return "Name is required"
+	case utf8.RuneCountInString(collection.Name) > maxCollectionNameLength:
+		return "Name is too long"
+	case utf8.RuneCountInString(collection.Description) > maxCollectionDescriptionSize:
+		return "Description is too long"
+	}
+	return ""
+}
+
+// GetCollection returns the collection in the URL with a page of its
+// recipes in order. Private collections are only visible to their owner.
+func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.visibleCollection(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := pageParams(r, defaultCollectionPageSize)
+	items, err := h.Manager.CollectionRepo.GetItems(collection.Id, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch collection", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]CollectionEntry, 0, len(items))
+	for _, item := range items {
+		recipe, err := h.Manager.RecipeRepo.GetRecipeByID(item.RecipeId)
+		if err != nil || recipe == nil {
+			continue
+		}
+		entries = append(entries, CollectionEntry{CollectionItem: item, Recipe: recipe})
+	}
+
+	response := struct {
+		*schema.Collection
+		Items      []CollectionEntry `json:"items"`
+		Pagination Pagination        `json:"pagination"`
+	}{
+		Collection: collection,
+		Items:      entries,
+		Pagination: Pagination{Total: collection.ItemCount, Limit: limit, Offset: offset},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateCollection changes the name, description or visibility of the
+// collection in the URL. The default collection keeps its name.
+func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var req requests.UpdateCollectionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if collection.IsDefault && name != collection.Name {
+			http.Error(w, "The default collection cannot be renamed", http.StatusBadRequest)
+			return
+		}
+		collection.Name = name
+	}
+	if req.Description != nil {
+		collection.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.IsPublic != nil {
+		collection.IsPublic = *req.IsPublic
+	}
+	if msg := validateCollection(*collection); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Manager.CollectionRepo.UpdateCollection(*collection); err != nil {
+		http.Error(w, "Failed to update collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+// DeleteCollection deletes the collection in the URL. The recipes in it
+// are not affected.
+func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+	if collection.IsDefault {
+		http.Error(w, "The default collection cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Manager.CollectionRepo.DeleteCollection(collection.Id); err != nil {
+		http.Error(w, "Failed to delete collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddItem adds a recipe to the end of the collection in the URL. Adding a
+// recipe that is already there returns the existing item.
+func (h *CollectionHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var req requests.CollectionItemReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.addItem(w, collection.Id, req.RecipeId, req.Note)
+}
+
+// SaveRecipe adds the recipe in the URL to the current user's "Saved"
+// collection.
+func (h *CollectionHandler) SaveRecipe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	recipeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.Manager.CollectionRepo.GetDefaultCollection(userID)
+	if err != nil {
+		http.Error(w, "Failed to save recipe", http.StatusInternalServerError)
+		return
+	}
+
+	h.addItem(w, collection.Id, recipeID, "")
+}
+
+// UnsaveRecipe takes the recipe in the URL out of the current user's
+// "Saved" collection.
+func (h *CollectionHandler) UnsaveRecipe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	recipeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.Manager.CollectionRepo.GetDefaultCollection(userID)
+	if err != nil {
+		http.Error(w, "Failed to unsave recipe", http.StatusInternalServerError)
+		return
+	}
+
+	h.removeItem(w, collection.Id, recipeID)
+}
+
+func (h *CollectionHandler) addItem(w http.ResponseWriter, collectionID, recipeID uuid.UUID, note string) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > maxCollectionNoteLength {
+		http.Error(w, "Note is too long", http.StatusBadRequest)
+		return
+	}
+
+	recipe, err := h.Manager.RecipeRepo.GetRecipeByID(recipeID)
+	if err != nil || recipe == nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	added, err := h.Manager.CollectionRepo.AddItem(schema.CollectionItem{CollectionId: collectionID, RecipeId: recipeID, Note: note})
+	if err != nil {
+		http.Error(w, "Failed to add recipe", http.StatusInternalServerError)
+		return
+	}
+	item, err := h.Manager.CollectionRepo.GetItem(collectionID, recipeID)
+	if err != nil {
+		http.Error(w, "Failed to add recipe", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(CollectionEntry{CollectionItem: *item, Recipe: recipe})
+}
+
+// UpdateItem replaces the note on a recipe in the collection in the URL.
+func (h *CollectionHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	recipeID, err := uuid.Parse(chi.URLParam(r, "recipe_id"))
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	var req requests.UpdateCollectionItemReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(note) > maxCollectionNoteLength {
+		http.Error(w, "Note is too long", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Manager.CollectionRepo.UpdateItemNote(collection.Id, recipeID, note)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Recipe is not in this collection", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update note", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := h.Manager.CollectionRepo.GetItem(collection.Id, recipeID)
+	if err != nil {
+		http.Error(w, "Failed to update note", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// RemoveItem takes a recipe out of the collection in the URL.
+func (h *CollectionHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	recipeID, err := uuid.Parse(chi.URLParam(r, "recipe_id"))
+	if err != nil {
+		http.Error(w, "Invalid recipe ID", http.StatusBadRequest)
+		return
+	}
+
+	h.removeItem(w, collection.Id, recipeID)
+}
+
+func (h *CollectionHandler) removeItem(w http.ResponseWriter, collectionID, recipeID uuid.UUID) {
+	err := h.Manager.CollectionRepo.RemoveItem(collectionID, recipeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Recipe is not in this collection", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to remove recipe", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderItems puts the recipes of the collection in the URL in the order
+// given, which must list every recipe in the collection exactly once.
+func (h *CollectionHandler) ReorderItems(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var req requests.ReorderCollectionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Manager.CollectionRepo.ReorderItems(collection.Id, req.RecipeIds)
+	if errors.Is(err, repository.ErrCollectionOrder) {
+		http.Error(w, "Order must list every recipe in the collection exactly once", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reorder collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// urlCollection loads the collection in the URL, writing an error response
+// if there is none.
+func (h *CollectionHandler) urlCollection(w http.ResponseWriter, r *http.Request) (*schema.Collection, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	collection, err := h.Manager.CollectionRepo.GetCollectionByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch collection", http.StatusInternalServerError)
+		return nil, false
+	}
+	return collection, true
+}
+
+// visibleCollection loads the collection in the URL if the current user may
+// see it. Private collections of others look like they do not exist.
+func (h *CollectionHandler) visibleCollection(w http.ResponseWriter, r *http.Request) (*schema.Collection, bool) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	collection, ok := h.urlCollection(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !collection.IsPublic && collection.UserId != userID {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return nil, false
+	}
+	return collection, true
+}
+
+// ownCollection loads the collection in the URL if the current user may
+// change it.
+func (h *CollectionHandler) ownCollection(w http.ResponseWriter, r *http.Request) (*schema.Collection, bool) {
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	collection, ok := h.visibleCollection(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !authz.Can(user, authz.Update, collection) {
+		http.Error(w, "Unauthorized to change this collection", http.StatusForbidden)
+		return nil, false
+	}
+	return collection, true
+}
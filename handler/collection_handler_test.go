@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// collectionRequest calls fn as userID with the given URL parameters.
+func collectionRequest(t *testing.T, fn http.HandlerFunc, method string, userID uuid.UUID, params map[string]string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	req := setupTestRequest(t, method, "/api/collections", body)
+	req = setupURLParams(setupTestContext(req, userID), params)
+	w := httptest.NewRecorder()
+	fn(w, req)
+	return w
+}
+
+// createTestRecipes stores count recipes by authorID and returns their IDs.
+func createTestRecipes(t *testing.T, manager *repository.Manager, authorID uuid.UUID, count int) []uuid.UUID {
+	t.Helper()
+	var ids []uuid.UUID
+	for i := 0; i < count; i++ {
+		recipe := schema.Recipe{Id: uuid.New(), Title: "Recipe", AuthorId: authorID}
+		if err := manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), "https://test-bucket.s3.amazonaws.com/recipe.jpg"); err != nil {
+			t.Fatalf("Failed to create recipe: %v", err)
+		}
+		ids = append(ids, recipe.Id)
+	}
+	return ids
+}
+
+func TestCollectionHandler_CreateCollection(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCollectionHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "collector@example.com")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Valid collection",
+			body:           map[string]interface{}{"name": " Weeknight dinners ", "is_public": true},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Missing name",
+			body:           map[string]interface{}{"name": "  "},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Name too long",
+			body:           map[string]interface{}{"name": strings.Repeat("a", maxCollectionNameLength+1)},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := collectionRequest(t, handler.CreateCollection, http.MethodPost, user.Id, nil, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// The default collection comes first and exists without saving anything
+	w := collectionRequest(t, handler.GetMyCollections, http.MethodGet, user.Id, nil, nil)
+	var collections []schema.Collection
+	readResponseBody(t, w, &collections)
+	if len(collections) != 2 || !collections[0].IsDefault || collections[0].Name != schema.DefaultCollectionName {
+		t.Fatalf("Expected the Saved collection and one other, got %+v", collections)
+	}
+	if collections[1].Name != "Weeknight dinners" {
+		t.Errorf("Expected name to be trimmed, got %q", collections[1].Name)
+	}
+}
+
+func TestCollectionHandler_Visibility(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCollectionHandler(manager)
+	owner, _ := loginTestUser(t, manager, userHandler, "owner@example.com")
+	visitor, _ := loginTestUser(t, manager, userHandler, "visitor@example.com")
+
+	var public, private schema.Collection
+	readResponseBody(t, collectionRequest(t, handler.CreateCollection, http.MethodPost, owner.Id, nil, map[string]interface{}{"name": "Public", "is_public": true}), &public)
+	readResponseBody(t, collectionRequest(t, handler.CreateCollection, http.MethodPost, owner.Id, nil, map[string]interface{}{"name": "Private"}), &private)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		collectionID   uuid.UUID
+		expectedStatus int
+	}{
+		{name: "Owner sees private", userID: owner.Id, collectionID: private.Id, expectedStatus: http.StatusOK},
+		{name: "Visitor sees public", userID: visitor.Id, collectionID: public.Id, expectedStatus: http.StatusOK},
+		{name: "Visitor cannot see private", userID: visitor.Id, collectionID: private.Id, expectedStatus: http.StatusNotFound},
+		{name: "Non-existent collection", userID: owner.Id, collectionID: uuid.New(), expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := collectionRequest(t, handler.GetCollection, http.MethodGet, tt.userID, map[string]string{"id": tt.collectionID.String()}, nil)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// Visitors only get public collections in the listing
+	w := collectionRequest(t, handler.GetUserCollections, http.MethodGet, visitor.Id, map[string]string{"id": owner.Id.String()}, nil)
+	var collections []schema.Collection
+	readResponseBody(t, w, &collections)
+	if len(collections) != 1 || collections[0].Id != public.Id {
+		t.Errorf("Expected only the public collection, got %+v", collections)
+	}
+
+	// Visitors cannot change a public collection
+	w = collectionRequest(t, handler.UpdateCollection, http.MethodPatch, visitor.Id, map[string]string{"id": public.Id.String()}, map[string]interface{}{"name": "Mine"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestCollectionHandler_Items(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCollectionHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "cook@example.com")
+	recipes := createTestRecipes(t, manager, uuid.New(), 3)
+
+	var collection schema.Collection
+	readResponseBody(t, collectionRequest(t, handler.CreateCollection, http.MethodPost, user.Id, nil, map[string]interface{}{"name": "Soups"}), &collection)
+	params := map[string]string{"id": collection.Id.String()}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		{name: "Add first recipe", body: map[string]interface{}{"recipe_id": recipes[0], "note": "Double the garlic"}, expectedStatus: http.StatusCreated},
+		{name: "Add second recipe", body: map[string]interface{}{"recipe_id": recipes[1]}, expectedStatus: http.StatusCreated},
+		{name: "Add third recipe", body: map[string]interface{}{"recipe_id": recipes[2]}, expectedStatus: http.StatusCreated},
+		{name: "Add recipe again", body: map[string]interface{}{"recipe_id": recipes[0]}, expectedStatus: http.StatusOK},
+		{name: "Non-existent recipe", body: map[string]interface{}{"recipe_id": uuid.New()}, expectedStatus: http.StatusNotFound},
+		{name: "Note too long", body: map[string]interface{}{"recipe_id": recipes[1], "note": strings.Repeat("a", maxCollectionNoteLength+1)}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := collectionRequest(t, handler.AddItem, http.MethodPost, user.Id, params, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// An order missing a recipe is rejected
+	w := collectionRequest(t, handler.ReorderItems, http.MethodPut, user.Id, params, map[string]interface{}{"recipe_ids": []uuid.UUID{recipes[2], recipes[0]}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = collectionRequest(t, handler.ReorderItems, http.MethodPut, user.Id, params, map[string]interface{}{"recipe_ids": []uuid.UUID{recipes[2], recipes[0], recipes[1]}})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	itemParams := map[string]string{"id": collection.Id.String(), "recipe_id": recipes[1].String()}
+	w = collectionRequest(t, handler.UpdateItem, http.MethodPatch, user.Id, itemParams, map[string]string{"note": "Freezes well"})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = collectionRequest(t, handler.GetCollection, http.MethodGet, user.Id, params, nil)
+	var response struct {
+		schema.Collection
+		Items      []CollectionEntry `json:"items"`
+		Pagination Pagination        `json:"pagination"`
+	}
+	readResponseBody(t, w, &response)
+	if len(response.Items) != 3 || response.ItemCount != 3 || response.Pagination.Total != 3 {
+		t.Fatalf("Expected 3 items, got %d (count %d)", len(response.Items), response.ItemCount)
+	}
+	expected := []uuid.UUID{recipes[2], recipes[0], recipes[1]}
+	for i, entry := range response.Items {
+		if entry.RecipeId != expected[i] || entry.Recipe == nil || entry.Recipe.Id != expected[i] {
+			t.Errorf("Item %d: expected recipe %s, got %+v", i, expected[i], entry)
+		}
+	}
+	if response.Items[1].Note != "Double the garlic" || response.Items[2].Note != "Freezes well" {
+		t.Errorf("Unexpected notes: %q, %q", response.Items[1].Note, response.Items[2].Note)
+	}
+
+	w = collectionRequest(t, handler.RemoveItem, http.MethodDelete, user.Id, itemParams, nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = collectionRequest(t, handler.RemoveItem, http.MethodDelete, user.Id, itemParams, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCollectionHandler_SaveRecipe(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCollectionHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "saver@example.com")
+	recipe := createTestRecipes(t, manager, uuid.New(), 1)[0]
+	params := map[string]string{"id": recipe.String()}
+
+	w := collectionRequest(t, handler.SaveRecipe, http.MethodPut, user.Id, params, nil)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	w = collectionRequest(t, handler.SaveRecipe, http.MethodPut, user.Id, params, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	saved, _ := manager.CollectionRepo.GetDefaultCollection(user.Id)
+	if saved.ItemCount != 1 {
+		t.Errorf("Expected 1 saved recipe, got %d", saved.ItemCount)
+	}
+
+	// The default collection can be made public but not renamed or deleted
+	savedParams := map[string]string{"id": saved.Id.String()}
+	w = collectionRequest(t, handler.UpdateCollection, http.MethodPatch, user.Id, savedParams, map[string]interface{}{"name": "Favourites"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = collectionRequest(t, handler.UpdateCollection, http.MethodPatch, user.Id, savedParams, map[string]interface{}{"is_public": true})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	w = collectionRequest(t, handler.DeleteCollection, http.MethodDelete, user.Id, savedParams, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = collectionRequest(t, handler.UnsaveRecipe, http.MethodDelete, user.Id, params, nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = collectionRequest(t, handler.UnsaveRecipe, http.MethodDelete, user.Id, params, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/notify"
+	"github.com/smilecs/foody/pubsub"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	defaultCommentsPageSize = 20
+	// maxMentionsPerComment caps how many users one comment can mention.
+	maxMentionsPerComment = 10
+)
+
+// mentionPattern matches @username where the @ does not follow a word
+// character, so email addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@])@([A-Za-z0-9_.]{3,30})`)
+
+// CommentHandler serves comments on posts and recipes. Handlers for
+// creating and listing comments are built per target type.
+type CommentHandler struct {
+	Manager *repository.Manager
+}
+
+func NewCommentHandler(manager *repository.Manager) *CommentHandler {
+	return &CommentHandler{
+		Manager: manager,
+	}
+}
+
+// parseMentions returns the distinct usernames mentioned in body, in the
+// order they first appear. A dot ending a mention is read as punctuation.
+func parseMentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".")
+		key := strings.ToLower(username)
+		if len(username) < 3 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// resolveMentions looks up the users mentioned in body. Unknown usernames
+// and the author mentioning themselves are skipped.
+func (h *CommentHandler) resolveMentions(authorID uuid.UUID, body string) []uuid.UUID {
+	var userIDs []uuid.UUID
+	for _, username := range parseMentions(body) {
+		if len(userIDs) == maxMentionsPerComment {
+			break
+		}
+		user, err := h.Manager.UserRepo.GetUserByUsername(username)
+		if err != nil || user.Id == authorID {
+			continue
+		}
+		userIDs = append(userIDs, user.Id)
+	}
+	return userIDs
+}
+
+// validCommentBody trims body and checks that it can be stored, writing an
+// error response if it cannot.
+func validCommentBody(w http.ResponseWriter, body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		http.Error(w, "Comment cannot be empty", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > schema.MaxCommentLength {
+		http.Error(w, "Comment is too long", http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
+
+// CreateComment comments on the item in the URL. With a parent_id it
+// replies to that comment instead; replying to a reply adds to the same
+// thread since replies are only one level deep.
+func (h *CommentHandler) CreateComment(target schema.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("user_id").(uuid.UUID)
+		if !ok {
+			http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid "+string(target)+" ID", http.StatusBadRequest)
+			return
+		}
+		item := findTarget(h.Manager, target, targetID)
+		if item == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		var req requests.CommentReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		body, ok := validCommentBody(w, req.Body)
+		if !ok {
+			return
+		}
+
+		comment := schema.Comment{
+			Id:         uuid.New(),
+			TargetType: target,
+			TargetId:   targetID,
+			AuthorId:   userID,
+			Body:       body,
+		}
+		var parent *schema.Comment
+		if req.ParentId != nil {
+			parent, err = h.Manager.CommentRepo.GetCommentByID(*req.ParentId)
+			if err != nil || parent.TargetType != target || parent.TargetId != targetID {
+				http.Error(w, "Invalid parent comment", http.StatusBadRequest)
+				return
+			}
+			comment.ParentId = &parent.Id
+			if parent.ParentId != nil {
+				comment.ParentId = parent.ParentId
+			}
+		}
+
+		mentions := h.resolveMentions(userID, body)
+		if err := h.Manager.CommentRepo.CreateComment(comment, mentions); err != nil {
+			http.Error(w, "Failed to create comment", http.StatusInternalServerError)
+			return
+		}
+		h.notifyComment(comment, item.OwnerID(), parent, mentions)
+
+		created, err := h.Manager.CommentRepo.GetCommentByID(comment.Id)
+		if err != nil {
+			http.Error(w, "Failed to fetch comment", http.StatusInternalServerError)
+			return
+		}
+		config.Get().Hub.Publish(pubsub.ItemTopic(string(target), targetID), pubsub.Event{Type: pubsub.EventComment, Data: created})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// notifyComment tells the owner of the item, the author of the comment
+// replied to and every mentioned user about a new comment. Each user hears
+// about it once, mentions taking precedence.
+func (h *CommentHandler) notifyComment(comment schema.Comment, ownerID uuid.UUID, parent *schema.Comment, mentions []uuid.UUID) {
+	service := notify.New(h.Manager)
+	notified := map[uuid.UUID]bool{comment.AuthorId: true}
+	for _, userID := range mentions {
+		notified[userID] = true
+		service.Notify(notify.Event{
+			Type:        schema.NotifyMention,
+			RecipientId: userID,
+			ActorId:     comment.AuthorId,
+			TargetType:  schema.TargetComment,
+			TargetId:    comment.Id,
+		})
+	}
+
+	if parent != nil && !notified[parent.AuthorId] {
+		notified[parent.AuthorId] = true
+		service.Notify(notify.Event{
+			Type:        schema.NotifyReply,
+			RecipientId: parent.AuthorId,
+			ActorId:     comment.AuthorId,
+			TargetType:  schema.TargetComment,
+			TargetId:    parent.Id,
+		})
+	}
+
+	if !notified[ownerID] {
+		service.Notify(notify.Event{
+			Type:        schema.NotifyComment,
+			RecipientId: ownerID,
+			ActorId:     comment.AuthorId,
+			TargetType:  comment.TargetType,
+			TargetId:    comment.TargetId,
+		})
+	}
+}
+
+// GetComments lists the top-level comments on the item in the URL, oldest
+// first. Each carries its reply count; replies are listed by GetReplies.
+func (h *CommentHandler) GetComments(target schema.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid "+string(target)+" ID", http.StatusBadRequest)
+			return
+		}
+		if findTarget(h.Manager, target, targetID) == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		limit, offset := pageParams(r, defaultCommentsPageSize)
+		comments, err := h.Manager.CommentRepo.GetComments(target, targetID, limit, offset)
+		if err != nil {
+			http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+			return
+		}
+		total, err := h.Manager.CommentRepo.CountComments(target, targetID)
+		if err != nil {
+			http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+			return
+		}
+
+		writeComments(w, comments, Pagination{Total: total, Limit: limit, Offset: offset})
+	}
+}
+
+// GetReplies lists the replies to the comment in the URL, oldest first.
+func (h *CommentHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	parent, ok := h.urlComment(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := pageParams(r, defaultCommentsPageSize)
+	replies, err := h.Manager.CommentRepo.GetReplies(parent.Id, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch replies", http.StatusInternalServerError)
+		return
+	}
+
+	writeComments(w, replies, Pagination{Total: parent.ReplyCount, Limit: limit, Offset: offset})
+}
+
+// UpdateComment lets the author of the comment in the URL change its body.
+// The users it mentions are parsed again.
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	comment, ok := h.urlComment(w, r)
+	if !ok {
+		return
+	}
+	if !authz.Can(user, authz.Update, comment) {
+		http.Error(w, "Unauthorized to update this comment", http.StatusForbidden)
+		return
+	}
+
+	var req requests.UpdateCommentReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body, ok := validCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+
+	if err := h.Manager.CommentRepo.UpdateComment(comment.Id, body, h.resolveMentions(comment.AuthorId, body)); err != nil {
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.Manager.CommentRepo.GetCommentByID(comment.Id)
+	if err != nil {
+		http.Error(w, "Failed to fetch comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteComment removes the comment in the URL and its replies. Besides
+// the author, the owner of the post or recipe and moderators may remove it.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	comment, ok := h.urlComment(w, r)
+	if !ok {
+		return
+	}
+
+	allowed := authz.Can(user, authz.Delete, comment) || authz.Can(user, authz.Moderate, comment)
+	if !allowed {
+		item := findTarget(h.Manager, comment.TargetType, comment.TargetId)
+		allowed = item != nil && authz.Can(user, authz.Delete, item)
+	}
+	if !allowed {
+		http.Error(w, "Unauthorized to delete this comment", http.StatusForbidden)
+		return
+	}
+
+	err := h.Manager.CommentRepo.DeleteComment(comment.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// urlComment loads the comment in the URL, writing an error response if
+// there is none.
+func (h *CommentHandler) urlComment(w http.ResponseWriter, r *http.Request) (*schema.Comment, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	comment, err := h.Manager.CommentRepo.GetCommentByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch comment", http.StatusInternalServerError)
+		return nil, false
+	}
+	return comment, true
+}
+
+func writeComments(w http.ResponseWriter, comments []schema.Comment, pagination Pagination) {
+	response := struct {
+		Comments   []schema.Comment `json:"comments"`
+		Pagination Pagination       `json:"pagination"`
+	}{
+		Comments:   comments,
+		Pagination: pagination,
+	}
+	if response.Comments == nil {
+		response.Comments = []schema.Comment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// commentCounts counts the comments on the given items. Like reactions, the
+// counts only decorate a listing, so a failure is logged and the items are
+// served without them.
+func commentCounts(manager *repository.Manager, target schema.TargetType, ids []uuid.UUID) map[uuid.UUID]int {
+	counts, err := manager.CommentRepo.GetCommentCounts(target, ids)
+	if err != nil {
+		log.Printf("error loading comment counts: %v\n", err)
+		return nil
+	}
+	return counts
+}
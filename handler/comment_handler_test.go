@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// createComment comments on the post as userID and returns the response.
+func createComment(t *testing.T, handler *CommentHandler, userID, postID uuid.UUID, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	req := setupTestRequest(t, http.MethodPost, "/posts/"+postID.String()+"/comments", body)
+	req = setupURLParams(setupTestContext(req, userID), map[string]string{"id": postID.String()})
+	w := httptest.NewRecorder()
+	handler.CreateComment(schema.TargetPost)(w, req)
+	return w
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{body: "no mentions here", expected: nil},
+		{body: "@alice try this", expected: []string{"alice"}},
+		{body: "thanks @bob_1, and @Carol.", expected: []string{"bob_1", "Carol"}},
+		{body: "@alice @ALICE again", expected: []string{"alice"}},
+		{body: "mail me at chef@example.com", expected: nil},
+		{body: "@al is too short", expected: nil},
+		{body: "(cc @dave.smith)", expected: []string{"dave.smith"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := parseMentions(tt.body); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.body, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCommentHandler_CreateComment(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCommentHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "commenter@example.com")
+	friend, _ := loginTestUser(t, manager, userHandler, "friend@example.com")
+	postID := createFeedPost(t, manager, uuid.New(), time.Now(), nil)
+	otherPostID := createFeedPost(t, manager, uuid.New(), time.Now(), nil)
+
+	w := createComment(t, handler, user.Id, postID, map[string]interface{}{"body": "First!"})
+	var top schema.Comment
+	readResponseBody(t, w, &top)
+	w = createComment(t, handler, user.Id, postID, map[string]interface{}{"body": "Reply", "parent_id": top.Id})
+	var reply schema.Comment
+	readResponseBody(t, w, &reply)
+	w = createComment(t, handler, user.Id, otherPostID, map[string]interface{}{"body": "Elsewhere"})
+	var elsewhere schema.Comment
+	readResponseBody(t, w, &elsewhere)
+
+	// Test cases
+	tests := []struct {
+		name             string
+		postID           uuid.UUID
+		body             map[string]interface{}
+		expectedStatus   int
+		expectedParent   *uuid.UUID
+		expectedMentions []string
+	}{
+		{
+			name:             "Comment with mentions",
+			postID:           postID,
+			body:             map[string]interface{}{"body": "  Looks great @" + friend.Username + " @nobody_here @" + user.Username + "  "},
+			expectedStatus:   http.StatusCreated,
+			expectedMentions: []string{friend.Username},
+		},
+		{
+			name:           "Reply",
+			postID:         postID,
+			body:           map[string]interface{}{"body": "Agreed", "parent_id": top.Id},
+			expectedStatus: http.StatusCreated,
+			expectedParent: &top.Id,
+		},
+		{
+			name:           "Reply to a reply joins the thread",
+			postID:         postID,
+			body:           map[string]interface{}{"body": "Me too", "parent_id": reply.Id},
+			expectedStatus: http.StatusCreated,
+			expectedParent: &top.Id,
+		},
+		{
+			name:           "Parent on another post",
+			postID:         postID,
+			body:           map[string]interface{}{"body": "Lost", "parent_id": elsewhere.Id},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown parent",
+			postID:         postID,
+			body:           map[string]interface{}{"body": "Lost", "parent_id": uuid.New()},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Empty comment",
+			postID:         postID,
+			body:           map[string]interface{}{"body": "   "},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Comment too long",
+			postID:         postID,
+			body:           map[string]interface{}{"body": strings.Repeat("a", schema.MaxCommentLength+1)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Non-existent post",
+			postID:         uuid.New(),
+			body:           map[string]interface{}{"body": "Hello"},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := createComment(t, handler, user.Id, tt.postID, tt.body)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var comment schema.Comment
+			readResponseBody(t, w, &comment)
+			if comment.AuthorId != user.Id || comment.AuthorUsername != user.Username {
+				t.Errorf("Unexpected author: %+v", comment)
+			}
+			if !reflect.DeepEqual(comment.ParentId, tt.expectedParent) {
+				t.Errorf("Expected parent %v, got %v", tt.expectedParent, comment.ParentId)
+			}
+			if tt.expectedMentions != nil && !reflect.DeepEqual([]string(comment.Mentions), tt.expectedMentions) {
+				t.Errorf("Expected mentions %v, got %v", tt.expectedMentions, comment.Mentions)
+			}
+			if strings.HasPrefix(comment.Body, " ") {
+				t.Errorf("Expected body to be trimmed, got %q", comment.Body)
+			}
+		})
+	}
+}
+
+func TestCommentHandler_GetComments(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCommentHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "talker@example.com")
+	postID := createFeedPost(t, manager, user.Id, time.Now(), nil)
+
+	var first schema.Comment
+	for i := 0; i < 3; i++ {
+		w := createComment(t, handler, user.Id, postID, map[string]interface{}{"body": "Comment"})
+		if i == 0 {
+			readResponseBody(t, w, &first)
+		}
+	}
+	createComment(t, handler, user.Id, postID, map[string]interface{}{"body": "Reply", "parent_id": first.Id})
+	createComment(t, handler, user.Id, postID, map[string]interface{}{"body": "Reply", "parent_id": first.Id})
+
+	type listResponse struct {
+		Comments   []schema.Comment `json:"comments"`
+		Pagination Pagination       `json:"pagination"`
+	}
+
+	req := setupTestRequest(t, http.MethodGet, "/posts/"+postID.String()+"/comments?limit=2", nil)
+	req = setupURLParams(setupTestContext(req, user.Id), map[string]string{"id": postID.String()})
+	w := httptest.NewRecorder()
+	handler.GetComments(schema.TargetPost)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var comments listResponse
+	readResponseBody(t, w, &comments)
+	if len(comments.Comments) != 2 || comments.Pagination.Total != 3 {
+		t.Errorf("Expected 2 of 3 top-level comments, got %d of %d", len(comments.Comments), comments.Pagination.Total)
+	}
+	if comments.Comments[0].Id != first.Id || comments.Comments[0].ReplyCount != 2 {
+		t.Errorf("Expected the first comment with 2 replies, got %+v", comments.Comments[0])
+	}
+
+	req = setupTestRequest(t, http.MethodGet, "/api/comments/"+first.Id.String()+"/replies", nil)
+	req = setupURLParams(setupTestContext(req, user.Id), map[string]string{"id": first.Id.String()})
+	w = httptest.NewRecorder()
+	handler.GetReplies(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var replies listResponse
+	readResponseBody(t, w, &replies)
+	if len(replies.Comments) != 2 || replies.Pagination.Total != 2 {
+		t.Errorf("Expected 2 replies, got %d of %d", len(replies.Comments), replies.Pagination.Total)
+	}
+
+	// Posts report every comment, replies included
+	postHandler := NewPostHandler(manager)
+	req = setupTestRequest(t, http.MethodGet, "/posts/"+postID.String()+"?id="+postID.String(), nil)
+	w = httptest.NewRecorder()
+	postHandler.GetPostByID(w, setupTestContext(req, user.Id))
+	var post struct {
+		CommentCount int `json:"comment_count"`
+	}
+	readResponseBody(t, w, &post)
+	if post.CommentCount != 5 {
+		t.Errorf("Expected comment count 5, got %d", post.CommentCount)
+	}
+}
+
+func TestCommentHandler_UpdateComment(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCommentHandler(manager)
+	author, _ := loginTestUser(t, manager, userHandler, "author@example.com")
+	other, _ := loginTestUser(t, manager, userHandler, "other@example.com")
+	postID := createFeedPost(t, manager, other.Id, time.Now(), nil)
+
+	var comment schema.Comment
+	readResponseBody(t, createComment(t, handler, author.Id, postID, map[string]interface{}{"body": "Typo"}), &comment)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		commentID      string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Someone else's comment",
+			userID:         other.Id,
+			commentID:      comment.Id.String(),
+			body:           "Hijacked",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Empty body",
+			userID:         author.Id,
+			commentID:      comment.Id.String(),
+			body:           "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Non-existent comment",
+			userID:         author.Id,
+			commentID:      uuid.New().String(),
+			body:           "Fixed",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Own comment",
+			userID:         author.Id,
+			commentID:      comment.Id.String(),
+			body:           "Fixed, thanks @" + other.Username,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPatch, "/api/comments/"+tt.commentID, map[string]string{"body": tt.body})
+			req = setupURLParams(setupTestContext(req, tt.userID), map[string]string{"id": tt.commentID})
+			w := httptest.NewRecorder()
+
+			handler.UpdateComment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	updated, _ := manager.CommentRepo.GetCommentByID(comment.Id)
+	if updated.Body != "Fixed, thanks @"+other.Username || updated.EditedAt == nil {
+		t.Errorf("Comment was not edited: %+v", updated)
+	}
+	if len(updated.Mentions) != 1 || updated.Mentions[0] != other.Username {
+		t.Errorf("Expected mentions to be parsed again, got %v", updated.Mentions)
+	}
+}
+
+func TestCommentHandler_DeleteComment(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewCommentHandler(manager)
+	owner, _ := loginTestUser(t, manager, userHandler, "owner@example.com")
+	author, _ := loginTestUser(t, manager, userHandler, "writer@example.com")
+	bystander, _ := loginTestUser(t, manager, userHandler, "bystander@example.com")
+	postID := createFeedPost(t, manager, owner.Id, time.Now(), nil)
+
+	newComment := func(body string) string {
+		var comment schema.Comment
+		readResponseBody(t, createComment(t, handler, author.Id, postID, map[string]interface{}{"body": body}), &comment)
+		return comment.Id.String()
+	}
+	threaded := newComment("Thread")
+	createComment(t, handler, bystander.Id, postID, map[string]interface{}{"body": "Reply", "parent_id": threaded})
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		role           schema.Role
+		commentID      string
+		expectedStatus int
+	}{
+		{
+			name:           "Bystander cannot delete",
+			userID:         bystander.Id,
+			role:           schema.RoleUser,
+			commentID:      newComment("Keep"),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Author deletes thread",
+			userID:         author.Id,
+			role:           schema.RoleUser,
+			commentID:      threaded,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Post owner moderates",
+			userID:         owner.Id,
+			role:           schema.RoleUser,
+			commentID:      newComment("Spam"),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Moderator removes",
+			userID:         bystander.Id,
+			role:           schema.RoleModerator,
+			commentID:      newComment("Rude"),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Non-existent comment",
+			userID:         author.Id,
+			role:           schema.RoleUser,
+			commentID:      uuid.New().String(),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodDelete, "/api/comments/"+tt.commentID, nil)
+			req = setupURLParams(setupRoleContext(req, tt.userID, tt.role), map[string]string{"id": tt.commentID})
+			w := httptest.NewRecorder()
+
+			handler.DeleteComment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// Only the bystander's undeletable comment is left; the reply went with its thread
+	if remaining := config.Get().DB.(*MockRepositoryManager).Comments; len(remaining) != 1 || remaining[0].Body != "Keep" {
+		t.Errorf("Unexpected remaining comments: %d", len(remaining))
+	}
+}
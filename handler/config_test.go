@@ -19,11 +19,18 @@ func TestConfigSingleton(t *testing.T) {
 
 	// Create mock DB
 	mockDB := &MockRepositoryManager{
-		Users:     make(map[uuid.UUID]*schema.User),
-		Posts:     make(map[uuid.UUID]*repository.PostWithMedia),
-		Recipes:   make(map[uuid.UUID]*repository.RecipeWithMedia),
-		MealPlans: make(map[uuid.UUID]*repository.MealPlanWithMedia),
-		Media:     make(map[uuid.UUID]*schema.Media),
+		Users:         make(map[uuid.UUID]*schema.User),
+		Posts:         make(map[uuid.UUID]*repository.PostWithMedia),
+		Recipes:       make(map[uuid.UUID]*repository.RecipeWithMedia),
+		MealPlans:     make(map[uuid.UUID]*repository.MealPlanWithMedia),
+		Media:         make(map[uuid.UUID]*schema.Media),
+		Sessions:      make(map[uuid.UUID]*schema.Session),
+		Tokens:        make(map[string]*schema.UserToken),
+		TOTP:          make(map[uuid.UUID]*schema.TOTPSecret),
+		RecoveryCodes: make(map[uuid.UUID][]*schema.RecoveryCode),
+		APIKeys:       make(map[uuid.UUID]*schema.APIKey),
+		Identities:    make(map[string]*schema.UserIdentity),
+		AuthRequests:  make(map[string]*schema.OIDCAuthRequest),
 	}
 
 	// Create a mock AWS session
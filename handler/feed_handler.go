@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+const defaultFeedPageSize = 20
+
+// encodeFeedCursor turns the last post of a page into an opaque cursor.
+func encodeFeedCursor(post schema.PostWithMedia) string {
+	raw := post.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + post.Id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFeedCursor(cursor string) (*repository.FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	postID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.FeedCursor{CreatedAt: t, PostID: postID}, nil
+}
+
+// GetFeed returns the current user's home feed: their own posts and those
+// of the accounts they follow, newest first. Pass the next_cursor of a
+// response as cursor to get the following page.
+func (h *PostHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	limit, _ := pageParams(r, defaultFeedPageSize)
+
+	var after *repository.FeedCursor
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		if after, err = decodeFeedCursor(cursor); err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	posts, err := h.Manager.PostRepo.GetFeed(userID, after, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	// Several posts often share a recipe, so load each one once
+	recipes := make(map[uuid.UUID]*schema.Recipe)
+	for i := range posts {
+		if posts[i].Recipe == nil {
+			continue
+		}
+		id := posts[i].Recipe.Id
+		if _, loaded := recipes[id]; !loaded {
+			recipes[id] = nil
+			if recipe, err := h.Manager.RecipeRepo.GetRecipeByID(id); err == nil && recipe != nil {
+				recipes[id] = &recipe.Recipe
+			}
+		}
+		posts[i].Recipe = recipes[id]
+	}
+
+	ids := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].Id
+	}
+	reactions := reactionSummaries(h.Manager, r, schema.TargetPost, ids)
+	comments := commentCounts(h.Manager, schema.TargetPost, ids)
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].Id]
+		posts[i].CommentCount = comments[posts[i].Id]
+	}
+
+	response := struct {
+		Posts      []schema.PostWithMedia `json:"posts"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	}{
+		Posts: posts,
+	}
+	if response.Posts == nil {
+		response.Posts = []schema.PostWithMedia{}
+	}
+	// A full page means there may be more
+	if len(posts) == limit {
+		response.NextCursor = encodeFeedCursor(posts[len(posts)-1])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
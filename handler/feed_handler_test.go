@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// createFeedPost stores a post by authorID created at the given time.
+func createFeedPost(t *testing.T, manager *repository.Manager, authorID uuid.UUID, createdAt time.Time, recipe *schema.Recipe) uuid.UUID {
+	t.Helper()
+	post := schema.Post{Id: uuid.New(), Title: "Post", Body: "Body", AuthorId: authorID, Recipe: recipe}
+	if err := manager.PostRepo.CreatePost(post, uuid.New(), "https://test-bucket.s3.amazonaws.com/post.jpg"); err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	config.Get().DB.(*MockRepositoryManager).Posts[post.Id].CreatedAt = createdAt
+	return post.Id
+}
+
+type feedResponse struct {
+	Posts      []schema.PostWithMedia `json:"posts"`
+	NextCursor string                 `json:"next_cursor"`
+}
+
+func TestPostHandler_GetFeed(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	followHandler := NewFollowHandler(manager)
+	handler := NewPostHandler(manager)
+
+	viewer, _ := loginTestUser(t, manager, userHandler, "reader@example.com")
+	followed, _ := loginTestUser(t, manager, userHandler, "chef@example.com")
+	stranger, _ := loginTestUser(t, manager, userHandler, "unknown-chef@example.com")
+	private, _ := loginTestUser(t, manager, userHandler, "secret-chef@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[private.Id].IsPrivate = true
+	followRequest(t, followHandler.FollowUser, http.MethodPost, viewer.Id, followed.Id.String())
+	followRequest(t, followHandler.FollowUser, http.MethodPost, viewer.Id, private.Id.String())
+
+	recipe := schema.Recipe{Id: uuid.New(), Title: "Shared Stew", AuthorId: followed.Id}
+	manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), "https://test-bucket.s3.amazonaws.com/stew.jpg")
+
+	now := time.Now()
+	expected := []uuid.UUID{
+		createFeedPost(t, manager, followed.Id, now, &schema.Recipe{Id: recipe.Id}),
+		createFeedPost(t, manager, viewer.Id, now.Add(-time.Minute), nil),
+		createFeedPost(t, manager, followed.Id, now.Add(-2*time.Minute), nil),
+		createFeedPost(t, manager, followed.Id, now.Add(-3*time.Minute), &schema.Recipe{Id: recipe.Id}),
+		createFeedPost(t, manager, followed.Id, now.Add(-4*time.Minute), nil),
+	}
+	createFeedPost(t, manager, stranger.Id, now.Add(-30*time.Second), nil)
+	createFeedPost(t, manager, private.Id, now.Add(-90*time.Second), nil)
+
+	getFeed := func(query url.Values) *httptest.ResponseRecorder {
+		req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/feed?"+query.Encode(), nil), viewer.Id)
+		w := httptest.NewRecorder()
+		handler.GetFeed(w, req)
+		return w
+	}
+
+	// Walk the feed two posts at a time
+	var seen []uuid.UUID
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		query := url.Values{"limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		w := getFeed(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var response feedResponse
+		readResponseBody(t, w, &response)
+		for _, post := range response.Posts {
+			seen = append(seen, post.Id)
+			if post.MediaURL == nil || *post.MediaURL == "" {
+				t.Errorf("Expected media URL on post %s", post.Id)
+			}
+			if post.Recipe != nil && post.Recipe.Title != "Shared Stew" {
+				t.Errorf("Expected linked recipe to be loaded, got %+v", post.Recipe)
+			}
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		cursor = response.NextCursor
+	}
+
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %d posts, got %d", len(expected), len(seen))
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("Post %d: expected %s, got %s", i, expected[i], seen[i])
+		}
+	}
+
+	if w := getFeed(url.Values{"cursor": {"not-a-cursor"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestFeedCursor_RoundTrip(t *testing.T) {
+	post := schema.PostWithMedia{Id: uuid.New(), CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)}
+
+	cursor, err := decodeFeedCursor(encodeFeedCursor(post))
+	if err != nil {
+		t.Fatalf("decodeFeedCursor: %v", err)
+	}
+	if cursor.PostID != post.Id || !cursor.CreatedAt.Equal(post.CreatedAt) {
+		t.Errorf("Cursor did not round-trip: %+v", cursor)
+	}
+}
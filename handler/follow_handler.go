@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/notify"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// FollowHandler serves following users and managing follow requests.
+type FollowHandler struct {
+	Manager *repository.Manager
+}
+
+func NewFollowHandler(manager *repository.Manager) *FollowHandler {
+	return &FollowHandler{
+		Manager: manager,
+	}
+}
+
+// FollowUser follows the user in the URL. Following a private account
+// sends a request the account has to accept first. Following someone again
+// returns the existing follow.
+func (h *FollowHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+	if target.Id == userID {
+		http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	follow, err := h.Manager.FollowRepo.GetFollow(userID, target.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		follow = &schema.Follow{FollowerId: userID, FolloweeId: target.Id, Status: schema.FollowAccepted}
+		if target.IsPrivate {
+			follow.Status = schema.FollowPending
+		}
+		follow, err = h.Manager.FollowRepo.CreateFollow(*follow)
+		status = http.StatusCreated
+	}
+	if err != nil {
+		http.Error(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusCreated {
+		event := notify.Event{Type: schema.NotifyFollow, RecipientId: target.Id, ActorId: userID}
+		if follow.Status == schema.FollowPending {
+			event.Type = schema.NotifyFollowRequest
+		}
+		notify.New(h.Manager).Notify(event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(follow)
+}
+
+// UnfollowUser stops following the user in the URL, or withdraws a pending
+// request.
+func (h *FollowHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Manager.FollowRepo.DeleteFollow(userID, targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "You are not following this user", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowers lists who follows the user in the URL.
+func (h *FollowHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, func(id uuid.UUID, limit, offset int) ([]schema.FollowListEntry, error) {
+		return h.Manager.FollowRepo.GetFollowers(id, schema.FollowAccepted, limit, offset)
+	}, func(counts *schema.FollowCounts) int {
+		return counts.Followers
+	})
+}
+
+// GetFollowing lists who the user in the URL follows.
+func (h *FollowHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.Manager.FollowRepo.GetFollowing, func(counts *schema.FollowCounts) int {
+		return counts.Following
+	})
+}
+
+// listFollows writes a page of a follow list. The lists of a private
+// account are only visible to the account and its followers.
+func (h *FollowHandler) listFollows(w http.ResponseWriter, r *http.Request,
+	list func(id uuid.UUID, limit, offset int) ([]schema.FollowListEntry, error),
+	total func(counts *schema.FollowCounts) int) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !canSeeFollowers(h.Manager, userID, target) {
+		http.Error(w, "This account is private", http.StatusForbidden)
+		return
+	}
+
+	limit, offset := pageParams(r, 20)
+	entries, err := list(target.Id, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch follows", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.Manager.FollowRepo.GetFollowCounts(target.Id)
+	if err != nil {
+		http.Error(w, "Failed to count follows", http.StatusInternalServerError)
+		return
+	}
+
+	writeFollowList(w, entries, Pagination{Total: total(counts), Limit: limit, Offset: offset})
+}
+
+// GetFollowRequests lists the pending requests to follow the current user.
+func (h *FollowHandler) GetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset := pageParams(r, 20)
+	entries, err := h.Manager.FollowRepo.GetFollowers(userID, schema.FollowPending, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch follow requests", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.Manager.FollowRepo.GetFollowCounts(userID)
+	if err != nil {
+		http.Error(w, "Failed to count follow requests", http.StatusInternalServerError)
+		return
+	}
+
+	writeFollowList(w, entries, Pagination{Total: counts.Requests, Limit: limit, Offset: offset})
+}
+
+// AcceptFollowRequest lets the user in the URL follow the current user.
+func (h *FollowHandler) AcceptFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	followerID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Manager.FollowRepo.AcceptFollow(followerID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Follow request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to accept follow request", http.StatusInternalServerError)
+		return
+	}
+
+	notify.New(h.Manager).Notify(notify.Event{Type: schema.NotifyFollowAccepted, RecipientId: followerID, ActorId: userID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveFollower declines a follow request from the user in the URL or
+// removes them as a follower.
+func (h *FollowHandler) RemoveFollower(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	followerID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Manager.FollowRepo.DeleteFollow(followerID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Follower not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to remove follower", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *FollowHandler) targetUser(w http.ResponseWriter, r *http.Request) (*schema.User, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, err := h.Manager.UserRepo.GetUserByID(id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// canSeeFollowers reports whether viewerID may see who target follows and
+// is followed by.
+func canSeeFollowers(manager *repository.Manager, viewerID uuid.UUID, target *schema.User) bool {
+	if !target.IsPrivate || viewerID == target.Id {
+		return true
+	}
+	follow, err := manager.FollowRepo.GetFollow(viewerID, target.Id)
+	return err == nil && follow.Status == schema.FollowAccepted
+}
+
+func writeFollowList(w http.ResponseWriter, entries []schema.FollowListEntry, pagination Pagination) {
+	if entries == nil {
+		entries = []schema.FollowListEntry{}
+	}
+	response := struct {
+		Users      []schema.FollowListEntry `json:"users"`
+		Pagination Pagination               `json:"pagination"`
+	}{
+		Users:      entries,
+		Pagination: pagination,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
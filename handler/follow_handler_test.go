@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// followRequest calls a FollowHandler method as userID for the user in the URL.
+func followRequest(t *testing.T, fn http.HandlerFunc, method string, userID uuid.UUID, targetID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := setupTestContext(setupTestRequest(t, method, "/api/users/"+targetID+"/follow", nil), userID)
+	req = setupURLParams(req, map[string]string{"id": targetID})
+	w := httptest.NewRecorder()
+	fn(w, req)
+	return w
+}
+
+func TestFollowHandler_FollowUser(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewFollowHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "follower@example.com")
+	public, _ := loginTestUser(t, manager, userHandler, "public@example.com")
+	private, _ := loginTestUser(t, manager, userHandler, "private@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[private.Id].IsPrivate = true
+
+	// Test cases
+	tests := []struct {
+		name           string
+		targetID       string
+		expectedStatus int
+		expectedFollow schema.FollowStatus
+	}{
+		{
+			name:           "Follow public account",
+			targetID:       public.Id.String(),
+			expectedStatus: http.StatusCreated,
+			expectedFollow: schema.FollowAccepted,
+		},
+		{
+			name:           "Follow again is idempotent",
+			targetID:       public.Id.String(),
+			expectedStatus: http.StatusOK,
+			expectedFollow: schema.FollowAccepted,
+		},
+		{
+			name:           "Follow private account",
+			targetID:       private.Id.String(),
+			expectedStatus: http.StatusCreated,
+			expectedFollow: schema.FollowPending,
+		},
+		{
+			name:           "Follow yourself",
+			targetID:       user.Id.String(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Follow unknown user",
+			targetID:       uuid.New().String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid user ID",
+			targetID:       "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := followRequest(t, handler.FollowUser, http.MethodPost, user.Id, tt.targetID)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedFollow != "" {
+				var follow schema.Follow
+				readResponseBody(t, w, &follow)
+				if follow.Status != tt.expectedFollow {
+					t.Errorf("Expected follow status %q, got %q", tt.expectedFollow, follow.Status)
+				}
+			}
+		})
+	}
+
+	if n := len(config.Get().DB.(*MockRepositoryManager).Follows); n != 2 {
+		t.Errorf("Expected 2 follows, got %d", n)
+	}
+
+	// Unfollowing twice fails the second time
+	if w := followRequest(t, handler.UnfollowUser, http.MethodDelete, user.Id, public.Id.String()); w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := followRequest(t, handler.UnfollowUser, http.MethodDelete, user.Id, public.Id.String()); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestFollowHandler_PrivateAccount(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewFollowHandler(manager)
+	private, _ := loginTestUser(t, manager, userHandler, "owner@example.com")
+	requester, _ := loginTestUser(t, manager, userHandler, "requester@example.com")
+	stranger, _ := loginTestUser(t, manager, userHandler, "stranger@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[private.Id].IsPrivate = true
+
+	followRequest(t, handler.FollowUser, http.MethodPost, requester.Id, private.Id.String())
+	followRequest(t, handler.FollowUser, http.MethodPost, stranger.Id, private.Id.String())
+
+	listFollowers := func(viewerID uuid.UUID) *httptest.ResponseRecorder {
+		return followRequest(t, handler.GetFollowers, http.MethodGet, viewerID, private.Id.String())
+	}
+
+	// Pending requests don't count as following
+	if w := listFollowers(requester.Id); w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me/follow-requests", nil), private.Id)
+	w := httptest.NewRecorder()
+	handler.GetFollowRequests(w, req)
+	var requests struct {
+		Users      []schema.FollowListEntry `json:"users"`
+		Pagination Pagination               `json:"pagination"`
+	}
+	readResponseBody(t, w, &requests)
+	if len(requests.Users) != 2 || requests.Pagination.Total != 2 {
+		t.Fatalf("Expected 2 follow requests, got %+v", requests)
+	}
+
+	// Accept one request and decline the other
+	if w := followRequest(t, handler.AcceptFollowRequest, http.MethodPost, private.Id, requester.Id.String()); w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := followRequest(t, handler.AcceptFollowRequest, http.MethodPost, private.Id, requester.Id.String()); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := followRequest(t, handler.RemoveFollower, http.MethodDelete, private.Id, stranger.Id.String()); w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	// Followers and the owner can see the lists, others can't
+	tests := []struct {
+		name           string
+		viewerID       uuid.UUID
+		expectedStatus int
+	}{
+		{"Owner", private.Id, http.StatusOK},
+		{"Accepted follower", requester.Id, http.StatusOK},
+		{"Declined requester", stranger.Id, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := listFollowers(tt.viewerID); w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			w := followRequest(t, handler.GetFollowing, http.MethodGet, tt.viewerID, private.Id.String())
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	var followers struct {
+		Users []schema.FollowListEntry `json:"users"`
+	}
+	readResponseBody(t, listFollowers(private.Id), &followers)
+	if len(followers.Users) != 1 || followers.Users[0].UserId != requester.Id || followers.Users[0].Username != "requester" {
+		t.Errorf("Expected the accepted follower, got %+v", followers.Users)
+	}
+}
+
+func TestFollowHandler_MakePublicAcceptsRequests(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewFollowHandler(manager)
+	owner, _ := loginTestUser(t, manager, userHandler, "going-public@example.com")
+	requester, _ := loginTestUser(t, manager, userHandler, "waiting@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[owner.Id].IsPrivate = true
+	followRequest(t, handler.FollowUser, http.MethodPost, requester.Id, owner.Id.String())
+
+	req := setupTestContext(setupTestRequest(t, http.MethodPatch, "/api/me", map[string]bool{"is_private": false}), owner.Id)
+	w := httptest.NewRecorder()
+	userHandler.UpdateMe(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	follow, err := manager.FollowRepo.GetFollow(requester.Id, owner.Id)
+	if err != nil || follow.Status != schema.FollowAccepted {
+		t.Errorf("Expected pending request to be accepted, got %+v", follow)
+	}
+}
+
+func TestUserHandler_GetUser_FollowCounts(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewFollowHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "popular@example.com")
+	fan, _ := loginTestUser(t, manager, userHandler, "fan@example.com")
+	followRequest(t, handler.FollowUser, http.MethodPost, fan.Id, user.Id.String())
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/users/"+user.Id.String(), nil), fan.Id)
+	req = setupURLParams(req, map[string]string{"id": user.Id.String()})
+	w := httptest.NewRecorder()
+	userHandler.GetUser(w, req)
+
+	var profile PublicProfile
+	readResponseBody(t, w, &profile)
+	if profile.FollowersCount != 1 || profile.FollowingCount != 0 || profile.FollowStatus != schema.FollowAccepted {
+		t.Errorf("Unexpected profile counts: %+v", profile)
+	}
+
+	req = setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me", nil), fan.Id)
+	w = httptest.NewRecorder()
+	userHandler.GetMe(w, req)
+
+	var me schema.FollowCounts
+	readResponseBody(t, w, &me)
+	if me.Following != 1 || me.Followers != 0 {
+		t.Errorf("Unexpected own counts: %+v", me)
+	}
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/smilecs/foody/config"
+)
+
+// JWKS publishes the public keys that verify access tokens, so other
+// services can check foody tokens without sharing a secret. Verify-only keys
+// stay listed until they are removed from the key directory.
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(config.Get().JWTKeys.JWKS())
+}
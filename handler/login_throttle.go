@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	// Failed logins are forgotten after loginFailureWindow.
+	loginFailureWindow = 24 * time.Hour
+	// After this many failures each further failure doubles the lockout,
+	// starting at loginLockoutBase and capped at loginLockoutMax.
+	accountFailureThreshold = 5
+	// An IP is allowed more failures than an account since several users
+	// can share one address.
+	ipFailureThreshold = 20
+	loginLockoutBase   = 30 * time.Second
+	loginLockoutMax    = time.Hour
+)
+
+// lockoutDelay returns how long after the last failure logins stay blocked.
+func lockoutDelay(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	delay := loginLockoutBase
+	for i := threshold; i < failures && delay < loginLockoutMax; i++ {
+		delay *= 2
+	}
+	if delay > loginLockoutMax {
+		delay = loginLockoutMax
+	}
+	return delay
+}
+
+// loginRetryAfter returns how long the caller has to wait before trying to
+// log in to the account from ip again, or zero if they may try now.
+func (u *UserHandler) loginRetryAfter(email, ip string, now time.Time) (time.Duration, error) {
+	since := now.Add(-loginFailureWindow)
+
+	account, err := u.Manager.LoginAttemptRepo.GetAccountFailures(email, since)
+	if err != nil {
+		return 0, err
+	}
+	byIP, err := u.Manager.LoginAttemptRepo.GetIPFailures(ip, since)
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, f := range []struct {
+		failures  *schema.LoginFailures
+		threshold int
+	}{
+		{account, accountFailureThreshold},
+		{byIP, ipFailureThreshold},
+	} {
+		if f.failures.LastFailure == nil {
+			continue
+		}
+		until := f.failures.LastFailure.Add(lockoutDelay(f.failures.Count, f.threshold))
+		if remaining := until.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginAttempt writes the audit record of a login attempt. A nil
+// reason records a success.
+func (u *UserHandler) recordLoginAttempt(r *http.Request, email string, userID *uuid.UUID, reason *schema.LoginFailureReason) {
+	attempt := schema.LoginAttempt{
+		Email:     email,
+		IP:        clientIP(r),
+		UserId:    userID,
+		Success:   reason == nil,
+		Reason:    reason,
+		UserAgent: r.UserAgent(),
+	}
+	if err := u.Manager.LoginAttemptRepo.RecordLoginAttempt(attempt); err != nil {
+		log.Printf("error auditing login attempt for %s: %v\n", email, err)
+	}
+}
+
+// normalizeEmail is the key login attempts are tracked under.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns the address of the client, taking proxies into account
+// only when configured to.
+func clientIP(r *http.Request) string {
+	if config.Get().TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
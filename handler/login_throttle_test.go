@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: accountFailureThreshold - 1, expected: 0},
+		{failures: accountFailureThreshold, expected: loginLockoutBase},
+		{failures: accountFailureThreshold + 1, expected: 2 * loginLockoutBase},
+		{failures: accountFailureThreshold + 3, expected: 8 * loginLockoutBase},
+		{failures: accountFailureThreshold + 50, expected: loginLockoutMax},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutDelay(tt.failures, accountFailureThreshold); got != tt.expected {
+			t.Errorf("lockoutDelay(%d) = %v, expected %v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+// attemptLogin posts the login form and returns the response
+func attemptLogin(t *testing.T, handler *UserHandler, email, password string) *httptest.ResponseRecorder {
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+	return w
+}
+
+// ageLoginAttempts moves every recorded attempt into the past
+func ageLoginAttempts(d time.Duration) {
+	for _, attempt := range config.Get().DB.(*MockRepositoryManager).LoginAttempts {
+		attempt.CreatedAt = attempt.CreatedAt.Add(-d)
+	}
+}
+
+func TestUserHandler_LoginLockout(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "locked@example.com")
+
+	// Unknown emails and wrong passwords are indistinguishable
+	wrongPassword := attemptLogin(t, handler, user.Email, "wrong-password")
+	unknownEmail := attemptLogin(t, handler, "nobody@example.com", "wrong-password")
+	if wrongPassword.Code != http.StatusUnauthorized || unknownEmail.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d and %d", http.StatusUnauthorized, wrongPassword.Code, unknownEmail.Code)
+	}
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Errorf("Responses differ: %q vs %q", wrongPassword.Body.String(), unknownEmail.Body.String())
+	}
+
+	for i := 1; i < accountFailureThreshold; i++ {
+		if w := attemptLogin(t, handler, user.Email, "wrong-password"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	}
+
+	// Even the right password is refused while locked out
+	w := attemptLogin(t, handler, user.Email, "password123")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > int(loginLockoutBase.Seconds())+1 {
+		t.Errorf("Unexpected Retry-After %q", w.Header().Get("Retry-After"))
+	}
+
+	// Email case does not get around the lockout
+	if w := attemptLogin(t, handler, "LOCKED@example.com", "password123"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+
+	// Once the lockout has passed the right password works again
+	ageLoginAttempts(loginLockoutBase)
+	if w := attemptLogin(t, handler, user.Email, "password123"); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// A successful login resets the account counter
+	if w := attemptLogin(t, handler, user.Email, "wrong-password"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	var failed, locked, succeeded int
+	for _, attempt := range config.Get().DB.(*MockRepositoryManager).LoginAttempts {
+		switch {
+		case attempt.Success:
+			succeeded++
+		case *attempt.Reason == schema.LoginLocked:
+			locked++
+		case *attempt.Reason == schema.InvalidCredentials:
+			failed++
+			if attempt.IP == "" {
+				t.Error("Expected failed attempt to record the client IP")
+			}
+		}
+	}
+	if failed != accountFailureThreshold+2 || locked != 2 || succeeded != 2 {
+		t.Errorf("Unexpected audit trail: %d failed, %d locked, %d succeeded", failed, locked, succeeded)
+	}
+}
+
+func TestUserHandler_LoginLockoutByIP(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "shared-ip@example.com")
+
+	// Spread failures over many accounts from one address
+	for i := 0; i < ipFailureThreshold; i++ {
+		attemptLogin(t, handler, "victim"+strconv.Itoa(i)+"@example.com", "guess")
+	}
+
+	if w := attemptLogin(t, handler, user.Email, "password123"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+
+	// Failures outside the window are forgotten
+	ageLoginAttempts(loginFailureWindow)
+	if w := attemptLogin(t, handler, user.Email, "password123"); w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cfg := config.Get()
+	t.Cleanup(func() { cfg.TrustProxyHeaders = false })
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = "203.0.113.7:5123"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+
+	if ip := clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("Expected remote address when proxies are not trusted, got %s", ip)
+	}
+
+	cfg.TrustProxyHeaders = true
+	if ip := clientIP(req); ip != "198.51.100.1" {
+		t.Errorf("Expected forwarded address, got %s", ip)
+	}
+}
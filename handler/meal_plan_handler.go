@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
 	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
 )
@@ -25,8 +26,8 @@ func (h *MealPlanHandler) CreateMealPlan(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get user ID from context (set by auth middleware)
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	// Get the caller from context (set by auth middleware)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
@@ -34,7 +35,11 @@ func (h *MealPlanHandler) CreateMealPlan(w http.ResponseWriter, r *http.Request)
 
 	// Set the author ID
 	mealPlan.Id = uuid.New()
-	mealPlan.AuthorId = userID
+	mealPlan.AuthorId = user.Id
+	if !authz.Can(user, authz.Create, mealPlan) {
+		http.Error(w, "Unauthorized to create this meal plan", http.StatusForbidden)
+		return
+	}
 
 	if err := h.Manager.MealPlanRepo.CreateMealPlan(mealPlan); err != nil {
 		http.Error(w, "Failed to create meal plan", http.StatusInternalServerError)
@@ -87,8 +92,8 @@ func (h *MealPlanHandler) UpdateMealPlan(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get user ID from context (set by auth middleware)
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	// Get the caller from context (set by auth middleware)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
@@ -96,13 +101,13 @@ func (h *MealPlanHandler) UpdateMealPlan(w http.ResponseWriter, r *http.Request)
 
 	// Get existing meal plan to verify ownership
 	existingMealPlan, err := h.Manager.MealPlanRepo.GetMealPlanByID(id)
-	if err != nil {
+	if err != nil || existingMealPlan == nil {
 		http.Error(w, "Meal plan not found", http.StatusNotFound)
 		return
 	}
 
 	// Verify ownership
-	if existingMealPlan.AuthorId != userID {
+	if !authz.Can(user, authz.Update, existingMealPlan) {
 		http.Error(w, "Unauthorized to update this meal plan", http.StatusForbidden)
 		return
 	}
@@ -114,7 +119,7 @@ func (h *MealPlanHandler) UpdateMealPlan(w http.ResponseWriter, r *http.Request)
 	}
 
 	mealPlan.Id = id
-	mealPlan.AuthorId = userID
+	mealPlan.AuthorId = existingMealPlan.AuthorId
 
 	if err := h.Manager.MealPlanRepo.UpdateMealPlan(mealPlan); err != nil {
 		http.Error(w, "Failed to update meal plan", http.StatusInternalServerError)
@@ -133,8 +138,8 @@ func (h *MealPlanHandler) DeleteMealPlan(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get user ID from context (set by auth middleware)
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	// Get the caller from context (set by auth middleware)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
@@ -142,13 +147,13 @@ func (h *MealPlanHandler) DeleteMealPlan(w http.ResponseWriter, r *http.Request)
 
 	// Get existing meal plan to verify ownership
 	existingMealPlan, err := h.Manager.MealPlanRepo.GetMealPlanByID(id)
-	if err != nil {
+	if err != nil || existingMealPlan == nil {
 		http.Error(w, "Meal plan not found", http.StatusNotFound)
 		return
 	}
 
 	// Verify ownership
-	if existingMealPlan.AuthorId != userID {
+	if !authz.Can(user, authz.Delete, existingMealPlan) {
 		http.Error(w, "Unauthorized to delete this meal plan", http.StatusForbidden)
 		return
 	}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"fmt"
+	"mime/multipart"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// uploadMedia stores an uploaded file in the bucket under dir and records
+// it as media owned by authorID. The key starts with the new media ID, so
+// uploading a file with the same name never overwrites an earlier one.
+func uploadMedia(manager *repository.Manager, file multipart.File, header *multipart.FileHeader, dir string, mediaType schema.MediaType, authorID uuid.UUID) (*schema.Media, error) {
+	mediaID := uuid.New()
+	key := fmt.Sprintf("%s/%s-%s", dir, mediaID, header.Filename)
+
+	cfg := config.Get()
+	url, err := data.UploadFileAndGetUrl(cfg.AWSSess, cfg.S3_Bucket, key, file, header.Size, header.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("upload error: %v", err)
+	}
+
+	media := schema.Media{
+		Id:        mediaID,
+		URL:       url,
+		MediaType: mediaType,
+		AuthorId:  authorID,
+	}
+
+	media.Id, err = manager.MediaRepo.CreateMedia(media)
+	if err != nil {
+		return nil, fmt.Errorf("error creating media: %v", err)
+	}
+
+	return &media, nil
+}
@@ -1,33 +1,63 @@
 package handler
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
+	"html"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/smilecs/foody/ingredients"
 	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
 )
 
 // MockRepositoryManager implements repository.Manager for testing
 type MockRepositoryManager struct {
-	Users     map[uuid.UUID]*schema.User
-	Posts     map[uuid.UUID]*repository.PostWithMedia
-	Recipes   map[uuid.UUID]*repository.RecipeWithMedia
-	MealPlans map[uuid.UUID]*repository.MealPlanWithMedia
-	Media     map[uuid.UUID]*schema.Media
+	Users                   map[uuid.UUID]*schema.User
+	Posts                   map[uuid.UUID]*repository.PostWithMedia
+	Recipes                 map[uuid.UUID]*repository.RecipeWithMedia
+	MealPlans               map[uuid.UUID]*repository.MealPlanWithMedia
+	Media                   map[uuid.UUID]*schema.Media
+	Sessions                map[uuid.UUID]*schema.Session
+	Tokens                  map[string]*schema.UserToken
+	TOTP                    map[uuid.UUID]*schema.TOTPSecret
+	RecoveryCodes           map[uuid.UUID][]*schema.RecoveryCode
+	LoginAttempts           []*schema.LoginAttempt
+	APIKeys                 map[uuid.UUID]*schema.APIKey
+	Identities              map[string]*schema.UserIdentity
+	AuthRequests            map[string]*schema.OIDCAuthRequest
+	Follows                 []*schema.Follow
+	Interactions            []*schema.Interaction
+	Comments                []*schema.Comment
+	Collections             []*schema.Collection
+	CollectionItems         []*schema.CollectionItem
+	Notifications           []*schema.Notification
+	NotificationPreferences []*schema.NotificationPreference
+	PostTags                []*mockPostTag
 }
 
 // NewMockRepositoryManager creates a new mock repository manager
 func NewMockRepositoryManager() *repository.Manager {
 	mock := &MockRepositoryManager{
-		Users:     make(map[uuid.UUID]*schema.User),
-		Posts:     make(map[uuid.UUID]*repository.PostWithMedia),
-		Recipes:   make(map[uuid.UUID]*repository.RecipeWithMedia),
-		MealPlans: make(map[uuid.UUID]*repository.MealPlanWithMedia),
-		Media:     make(map[uuid.UUID]*schema.Media),
+		Users:         make(map[uuid.UUID]*schema.User),
+		Posts:         make(map[uuid.UUID]*repository.PostWithMedia),
+		Recipes:       make(map[uuid.UUID]*repository.RecipeWithMedia),
+		MealPlans:     make(map[uuid.UUID]*repository.MealPlanWithMedia),
+		Media:         make(map[uuid.UUID]*schema.Media),
+		Sessions:      make(map[uuid.UUID]*schema.Session),
+		Tokens:        make(map[string]*schema.UserToken),
+		TOTP:          make(map[uuid.UUID]*schema.TOTPSecret),
+		RecoveryCodes: make(map[uuid.UUID][]*schema.RecoveryCode),
+		APIKeys:       make(map[uuid.UUID]*schema.APIKey),
+		Identities:    make(map[string]*schema.UserIdentity),
+		AuthRequests:  make(map[string]*schema.OIDCAuthRequest),
 	}
 
 	// Create mock repositories
@@ -36,13 +66,37 @@ func NewMockRepositoryManager() *repository.Manager {
 	recipeRepo := &MockRecipeRepository{manager: mock}
 	mealPlanRepo := &MockMealPlanRepository{manager: mock}
 	mediaRepo := &MockMediaRepository{manager: mock}
+	sessionRepo := &MockSessionRepository{manager: mock}
+	tokenRepo := &MockUserTokenRepository{manager: mock}
+	twoFactorRepo := &MockTwoFactorRepository{manager: mock}
+	loginAttemptRepo := &MockLoginAttemptRepository{manager: mock}
+	apiKeyRepo := &MockAPIKeyRepository{manager: mock}
+	identityRepo := &MockIdentityRepository{manager: mock}
+	followRepo := &MockFollowRepository{manager: mock}
+	interactionRepo := &MockInteractionRepository{manager: mock}
+	commentRepo := &MockCommentRepository{manager: mock}
+	collectionRepo := &MockCollectionRepository{manager: mock}
+	notificationRepo := &MockNotificationRepository{manager: mock}
+	tagRepo := &MockTagRepository{manager: mock}
 
 	return &repository.Manager{
-		UserRepo:     userRepo,
-		PostRepo:     postRepo,
-		RecipeRepo:   recipeRepo,
-		MealPlanRepo: mealPlanRepo,
-		MediaRepo:    mediaRepo,
+		UserRepo:         userRepo,
+		PostRepo:         postRepo,
+		RecipeRepo:       recipeRepo,
+		MealPlanRepo:     mealPlanRepo,
+		MediaRepo:        mediaRepo,
+		SessionRepo:      sessionRepo,
+		TokenRepo:        tokenRepo,
+		TwoFactorRepo:    twoFactorRepo,
+		LoginAttemptRepo: loginAttemptRepo,
+		APIKeyRepo:       apiKeyRepo,
+		IdentityRepo:     identityRepo,
+		FollowRepo:       followRepo,
+		InteractionRepo:  interactionRepo,
+		CommentRepo:      commentRepo,
+		CollectionRepo:   collectionRepo,
+		NotificationRepo: notificationRepo,
+		TagRepo:          tagRepo,
 	}
 }
 
@@ -61,6 +115,10 @@ func (r *MockUserRepository) GetUserByID(id uuid.UUID) (*schema.User, error) {
 
 func (r *MockUserRepository) CreateUser(user schema.User, password string, mediaID uuid.UUID) (string, error) {
 	// Store user
+	user.MediaId = &mediaID
+	if user.Role == "" {
+		user.Role = schema.RoleUser
+	}
 	r.manager.Users[user.Id] = &user
 
 	// Store password for authentication
@@ -117,6 +175,163 @@ func (r *MockUserRepository) GetUserByEmail(email string) (*schema.User, error)
 	return nil, sql.ErrNoRows
 }
 
+func (r *MockUserRepository) UpdatePassword(userID uuid.UUID, password string) error {
+	if _, ok := r.manager.Users[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	if r.passwords == nil {
+		r.passwords = make(map[uuid.UUID]string)
+	}
+	r.passwords[userID] = password
+	return nil
+}
+
+func (r *MockUserRepository) MarkEmailVerified(userID uuid.UUID) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+func (r *MockUserRepository) GetUserByUsername(username string) (*schema.User, error) {
+	for _, user := range r.manager.Users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockUserRepository) UpdateProfile(user schema.User) error {
+	existing, ok := r.manager.Users[user.Id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	existing.Name = user.Name
+	existing.Bio = user.Bio
+	existing.DOB = user.DOB
+	existing.IsPrivate = user.IsPrivate
+	return nil
+}
+
+func (r *MockUserRepository) UpdateAvatar(userID, mediaID uuid.UUID) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.MediaId = &mediaID
+	return nil
+}
+
+func (r *MockUserRepository) UpdateUsername(userID uuid.UUID, username string) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if other, err := r.GetUserByUsername(username); err == nil && other.Id != userID {
+		return repository.ErrUsernameTaken
+	}
+	now := time.Now()
+	user.Username = username
+	user.UsernameChangedAt = &now
+	return nil
+}
+
+func (r *MockUserRepository) ScheduleDeletion(userID uuid.UUID, at time.Time) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.DeletionScheduledAt = &at
+	return nil
+}
+
+func (r *MockUserRepository) CancelDeletion(userID uuid.UUID) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.DeletionScheduledAt = nil
+	return nil
+}
+
+func (r *MockUserRepository) GetUsersDueForDeletion(now time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, user := range r.manager.Users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			ids = append(ids, user.Id)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteUser removes the user and everything the database would cascade
+func (r *MockUserRepository) DeleteUser(userID uuid.UUID) error {
+	delete(r.manager.Users, userID)
+	for id, post := range r.manager.Posts {
+		if post.AuthorId == userID {
+			delete(r.manager.Posts, id)
+		}
+	}
+	for id, recipe := range r.manager.Recipes {
+		if recipe.AuthorId == userID {
+			delete(r.manager.Recipes, id)
+		}
+	}
+	for id, mealPlan := range r.manager.MealPlans {
+		if mealPlan.AuthorId == userID {
+			delete(r.manager.MealPlans, id)
+		}
+	}
+	for id, media := range r.manager.Media {
+		if media.AuthorId == userID {
+			delete(r.manager.Media, id)
+		}
+	}
+	for id, session := range r.manager.Sessions {
+		if session.UserId == userID {
+			delete(r.manager.Sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *MockUserRepository) ListUsers(limit, offset int) ([]schema.User, error) {
+	var users []schema.User
+	for _, user := range r.manager.Users {
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+func (r *MockUserRepository) GetTotalUsersCount() (int, error) {
+	return len(r.manager.Users), nil
+}
+
+func (r *MockUserRepository) SetSuspended(userID uuid.UUID, suspended bool) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.SuspendedAt = nil
+	if suspended {
+		now := time.Now()
+		user.SuspendedAt = &now
+	}
+	return nil
+}
+
+func (r *MockUserRepository) UpdateRole(userID uuid.UUID, role schema.Role) error {
+	user, ok := r.manager.Users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Role = role
+	return nil
+}
+
 // MockPostRepository implements repository.PostRepository for testing
 type MockPostRepository struct {
 	manager *MockRepositoryManager
@@ -129,9 +344,63 @@ func (r *MockPostRepository) CreatePost(post schema.Post, mediaID uuid.UUID, med
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	r.manager.setPostTags(post.Id, post.Tags)
 	return nil
 }
 
+func (r *MockPostRepository) GetFeed(userID uuid.UUID, after *repository.FeedCursor, limit int) ([]schema.PostWithMedia, error) {
+	authors := map[uuid.UUID]bool{userID: true}
+	for _, follow := range r.manager.Follows {
+		if follow.FollowerId == userID && follow.Status == schema.FollowAccepted {
+			authors[follow.FolloweeId] = true
+		}
+	}
+
+	var posts []schema.PostWithMedia
+	for _, post := range r.manager.Posts {
+		if !authors[post.AuthorId] {
+			continue
+		}
+		if after != nil && !olderPost(post.CreatedAt, post.Id, after.CreatedAt, after.PostID) {
+			continue
+		}
+		posts = append(posts, mockListedPost(post))
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return olderPost(posts[j].CreatedAt, posts[j].Id, posts[i].CreatedAt, posts[i].Id)
+	})
+	return paginate(posts, limit, 0), nil
+}
+
+// mockListedPost returns post as listed in feeds and on tag pages.
+func mockListedPost(post *repository.PostWithMedia) schema.PostWithMedia {
+	mediaURL := post.MediaURL
+	listed := schema.PostWithMedia{
+		Id:        post.Id,
+		AuthorId:  post.AuthorId,
+		MediaURL:  &mediaURL,
+		Title:     post.Title,
+		Body:      post.Body,
+		Tags:      post.Tags,
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+	if post.Recipe != nil {
+		listed.Recipe = &schema.Recipe{Id: post.Recipe.Id}
+	}
+	return listed
+}
+
+// olderPost reports whether post a comes after post b in a feed, comparing
+// ids like Postgres when both were created at the same time.
+func olderPost(aTime time.Time, aID uuid.UUID, bTime time.Time, bID uuid.UUID) bool {
+	if !aTime.Equal(bTime) {
+		return aTime.Before(bTime)
+	}
+	return bytes.Compare(aID[:], bID[:]) < 0
+}
+
 func (r *MockPostRepository) GetPostByUserID(id uuid.UUID) (*repository.PostWithMedia, error) {
 	for _, post := range r.manager.Posts {
 		if post.AuthorId == id {
@@ -149,6 +418,19 @@ func (r *MockPostRepository) GetPosts(limit, offset int) ([]repository.PostWithM
 	return posts, nil
 }
 
+func (r *MockPostRepository) GetPostsByAuthorID(authorID uuid.UUID) ([]schema.PostWithMedia, error) {
+	var posts []schema.PostWithMedia
+	for _, post := range r.manager.Posts {
+		if post.AuthorId == authorID {
+			posts = append(posts, mockListedPost(post))
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return olderPost(posts[j].CreatedAt, posts[j].Id, posts[i].CreatedAt, posts[i].Id)
+	})
+	return posts, nil
+}
+
 func (r *MockPostRepository) GetPostByID(id uuid.UUID) (*repository.PostWithMedia, error) {
 	if post, ok := r.manager.Posts[id]; ok {
 		return post, nil
@@ -160,6 +442,7 @@ func (r *MockPostRepository) UpdatePost(post schema.Post) error {
 	if existingPost, ok := r.manager.Posts[post.Id]; ok {
 		existingPost.Post = post
 		existingPost.UpdatedAt = time.Now()
+		r.manager.setPostTags(post.Id, post.Tags)
 	}
 	return nil
 }
@@ -228,6 +511,130 @@ func (r *MockRecipeRepository) GetTotalRecipesCount() (int, error) {
 	return len(r.manager.Recipes), nil
 }
 
+// search approximates full-text search: every query word must appear in
+// the recipe, and matches in the title rank highest.
+func (r *MockRecipeRepository) search(search repository.RecipeSearch) []repository.RecipeSearchResult {
+	terms := strings.Fields(strings.ToLower(search.Query))
+	var results []repository.RecipeSearchResult
+	for _, recipe := range r.manager.Recipes {
+		if search.AuthorId != nil && recipe.AuthorId != *search.AuthorId {
+			continue
+		}
+		if search.MaxTotalTime != nil && (recipe.TotalTime == nil || *recipe.TotalTime > *search.MaxTotalTime) {
+			continue
+		}
+		if search.MinServings != nil && (recipe.Servings == nil || *recipe.Servings < *search.MinServings) {
+			continue
+		}
+		if search.MaxServings != nil && (recipe.Servings == nil || *recipe.Servings > *search.MaxServings) {
+			continue
+		}
+		if !mockHasIngredients(recipe.Ingredients, search.IncludeIngredients, true) ||
+			mockHasIngredients(recipe.Ingredients, search.ExcludeIngredients, false) {
+			continue
+		}
+
+		var names, steps []string
+		for _, ingredient := range recipe.Ingredients {
+			names = append(names, ingredient.Name)
+		}
+		for _, step := range recipe.Steps {
+			steps = append(steps, step.Description)
+		}
+		fields := []string{recipe.Title, recipe.Description, strings.Join(names, ", "), strings.Join(steps, " ")}
+		weights := []float64{1, 0.4, 0.4, 0.2}
+
+		result := repository.RecipeSearchResult{RecipeWithMedia: *recipe, Snippet: html.EscapeString(recipe.Description)}
+		matched := true
+		for _, term := range terms {
+			found := false
+			for i, field := range fields {
+				if strings.Contains(strings.ToLower(field), term) {
+					found = true
+					result.Rank += weights[i]
+				}
+			}
+			matched = matched && found
+		}
+		if !matched {
+			continue
+		}
+		if len(terms) > 0 {
+			result.Snippet = mockHighlight(strings.Join(fields, " "), terms)
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	return results
+}
+
+// mockHasIngredients reports whether ingredients contain all of terms, or
+// with all false any of them.
+func mockHasIngredients(ingredients []schema.Ingredient, terms []string, all bool) bool {
+	for _, term := range terms {
+		found := false
+		for _, ingredient := range ingredients {
+			if strings.Contains(strings.ToLower(ingredient.Name), strings.ToLower(term)) {
+				found = true
+				break
+			}
+		}
+		if found != all {
+			return found
+		}
+	}
+	return all
+}
+
+// mockHighlight escapes text for HTML and wraps its words starting with
+// one of terms in <mark> tags.
+func mockHighlight(text string, terms []string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = html.EscapeString(word)
+		for _, term := range terms {
+			if strings.HasPrefix(strings.ToLower(word), term) {
+				words[i] = "<mark>" + words[i] + "</mark>"
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (r *MockRecipeRepository) GetRecipesByIngredientWords(words []string, limit int) ([]repository.RecipeIngredientNames, error) {
+	var recipes []repository.RecipeIngredientNames
+	for _, recipe := range r.manager.Recipes {
+		var names []string
+		shared := false
+		for _, ingredient := range recipe.Ingredients {
+			names = append(names, ingredient.Name)
+			for _, word := range strings.Fields(ingredients.Normalize(ingredient.Name)) {
+				shared = shared || slices.Contains(words, word)
+			}
+		}
+		if shared {
+			recipes = append(recipes, repository.RecipeIngredientNames{RecipeId: recipe.Id, Names: names})
+		}
+	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].RecipeId.String() < recipes[j].RecipeId.String() })
+	return paginate(recipes, limit, 0), nil
+}
+
+func (r *MockRecipeRepository) SearchRecipes(search repository.RecipeSearch, limit, offset int) ([]repository.RecipeSearchResult, error) {
+	return paginate(r.search(search), limit, offset), nil
+}
+
+func (r *MockRecipeRepository) CountRecipeSearch(search repository.RecipeSearch) (int, error) {
+	return len(r.search(search)), nil
+}
+
 // MockMealPlanRepository implements repository.MealPlanRepository for testing
 type MockMealPlanRepository struct {
 	manager *MockRepositoryManager
@@ -297,6 +704,240 @@ func (r *MockMediaRepository) GetMediaByAuthorID(authorID uuid.UUID) ([]schema.M
 	return mediaList, nil
 }
 
+func (r *MockMediaRepository) DeleteMedia(id uuid.UUID) error {
+	delete(r.manager.Media, id)
+	return nil
+}
+
+// MockSessionRepository implements repository.SessionRepository for testing
+type MockSessionRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockSessionRepository) CreateSession(session schema.Session) error {
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = time.Now()
+	r.manager.Sessions[session.Id] = &session
+	return nil
+}
+
+func (r *MockSessionRepository) GetSessionByID(id uuid.UUID) (*schema.Session, error) {
+	if session, ok := r.manager.Sessions[id]; ok {
+		return session, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockSessionRepository) GetSessionByTokenHash(hash string) (*schema.Session, error) {
+	for _, session := range r.manager.Sessions {
+		if session.RefreshTokenHash == hash || (session.PreviousTokenHash != nil && *session.PreviousTokenHash == hash) {
+			return session, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockSessionRepository) RotateSession(id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error {
+	session, ok := r.manager.Sessions[id]
+	if !ok || session.RefreshTokenHash != oldHash || session.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	previous := session.RefreshTokenHash
+	session.PreviousTokenHash = &previous
+	session.RefreshTokenHash = newHash
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = time.Now()
+	return nil
+}
+
+func (r *MockSessionRepository) RevokeSession(id uuid.UUID) error {
+	if session, ok := r.manager.Sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *MockSessionRepository) RevokeUserSessions(userID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range r.manager.Sessions {
+		if session.UserId == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// MockUserTokenRepository implements repository.UserTokenRepository for testing
+type MockUserTokenRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockUserTokenRepository) CreateUserToken(token schema.UserToken) error {
+	token.CreatedAt = time.Now()
+	r.manager.Tokens[token.TokenHash] = &token
+	return nil
+}
+
+func (r *MockUserTokenRepository) ConsumeUserToken(hash string, purpose schema.TokenPurpose) (uuid.UUID, error) {
+	token, ok := r.manager.Tokens[hash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return uuid.Nil, sql.ErrNoRows
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return token.UserId, nil
+}
+
+func (r *MockUserTokenRepository) InvalidateUserTokens(userID uuid.UUID, purpose schema.TokenPurpose) error {
+	now := time.Now()
+	for _, token := range r.manager.Tokens {
+		if token.UserId == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MockUserTokenRepository) GetLatestUserToken(userID uuid.UUID, purpose schema.TokenPurpose) (*schema.UserToken, error) {
+	var latest *schema.UserToken
+	for _, token := range r.manager.Tokens {
+		if token.UserId == userID && token.Purpose == purpose && (latest == nil || token.CreatedAt.After(latest.CreatedAt)) {
+			latest = token
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+// MockTwoFactorRepository implements repository.TwoFactorRepository for testing
+type MockTwoFactorRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockTwoFactorRepository) GetTOTP(userID uuid.UUID) (*schema.TOTPSecret, error) {
+	if totp, ok := r.manager.TOTP[userID]; ok {
+		return totp, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockTwoFactorRepository) SavePendingTOTP(userID uuid.UUID, secret string) error {
+	if totp, ok := r.manager.TOTP[userID]; ok && totp.Enabled() {
+		return nil
+	}
+	r.manager.TOTP[userID] = &schema.TOTPSecret{UserId: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (r *MockTwoFactorRepository) EnableTOTP(userID uuid.UUID, step int64) error {
+	totp, ok := r.manager.TOTP[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	totp.EnabledAt = &now
+	totp.LastUsedStep = &step
+	return nil
+}
+
+func (r *MockTwoFactorRepository) UseTOTPStep(userID uuid.UUID, step int64) error {
+	totp, ok := r.manager.TOTP[userID]
+	if !ok || (totp.LastUsedStep != nil && *totp.LastUsedStep >= step) {
+		return sql.ErrNoRows
+	}
+	totp.LastUsedStep = &step
+	return nil
+}
+
+func (r *MockTwoFactorRepository) DisableTOTP(userID uuid.UUID) error {
+	delete(r.manager.TOTP, userID)
+	delete(r.manager.RecoveryCodes, userID)
+	return nil
+}
+
+func (r *MockTwoFactorRepository) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	codes := make([]*schema.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, &schema.RecoveryCode{UserId: userID, CodeHash: hash, CreatedAt: time.Now()})
+	}
+	r.manager.RecoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *MockTwoFactorRepository) ConsumeRecoveryCode(userID uuid.UUID, hash string) error {
+	for _, code := range r.manager.RecoveryCodes[userID] {
+		if code.CodeHash == hash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockTwoFactorRepository) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	count := 0
+	for _, code := range r.manager.RecoveryCodes[userID] {
+		if code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MockLoginAttemptRepository implements repository.LoginAttemptRepository for testing
+type MockLoginAttemptRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockLoginAttemptRepository) RecordLoginAttempt(attempt schema.LoginAttempt) error {
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now()
+	}
+	r.manager.LoginAttempts = append(r.manager.LoginAttempts, &attempt)
+	return nil
+}
+
+func (r *MockLoginAttemptRepository) GetAccountFailures(email string, since time.Time) (*schema.LoginFailures, error) {
+	failures := &schema.LoginFailures{}
+	for _, attempt := range r.manager.LoginAttempts {
+		if attempt.Email != email || !attempt.CreatedAt.After(since) {
+			continue
+		}
+		if attempt.Success {
+			// Only failures since the last success count
+			failures = &schema.LoginFailures{}
+			continue
+		}
+		countFailure(failures, attempt)
+	}
+	return failures, nil
+}
+
+func (r *MockLoginAttemptRepository) GetIPFailures(ip string, since time.Time) (*schema.LoginFailures, error) {
+	failures := &schema.LoginFailures{}
+	for _, attempt := range r.manager.LoginAttempts {
+		if attempt.IP == ip && !attempt.Success && attempt.CreatedAt.After(since) {
+			countFailure(failures, attempt)
+		}
+	}
+	return failures, nil
+}
+
+func countFailure(failures *schema.LoginFailures, attempt *schema.LoginAttempt) {
+	if attempt.Reason != nil && *attempt.Reason == schema.LoginLocked {
+		return
+	}
+	failures.Count++
+	if failures.LastFailure == nil || attempt.CreatedAt.After(*failures.LastFailure) {
+		createdAt := attempt.CreatedAt
+		failures.LastFailure = &createdAt
+	}
+}
+
 // Implement config.Database interface
 func (m *MockRepositoryManager) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	return &sqlx.Row{}
@@ -317,3 +958,813 @@ func (m *MockRepositoryManager) Beginx() (*sqlx.Tx, error) {
 func (m *MockRepositoryManager) MustExec(query string, args ...interface{}) (sql.Result, error) {
 	return nil, nil
 }
+
+// MockAPIKeyRepository implements repository.APIKeyRepository for testing
+type MockAPIKeyRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockAPIKeyRepository) CreateAPIKey(key schema.APIKey) error {
+	key.CreatedAt = time.Now()
+	r.manager.APIKeys[key.Id] = &key
+	return nil
+}
+
+func (r *MockAPIKeyRepository) GetAPIKeyByHash(hash string) (*schema.APIKey, error) {
+	for _, key := range r.manager.APIKeys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockAPIKeyRepository) GetAPIKeysByUserID(userID uuid.UUID) ([]schema.APIKey, error) {
+	var keys []schema.APIKey
+	for _, key := range r.manager.APIKeys {
+		if key.UserId == userID && key.RevokedAt == nil {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *MockAPIKeyRepository) RevokeAPIKey(userID, id uuid.UUID) error {
+	key, ok := r.manager.APIKeys[id]
+	if !ok || key.UserId != userID || key.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return nil
+}
+
+func (r *MockAPIKeyRepository) TouchAPIKey(id uuid.UUID, now time.Time) error {
+	if key, ok := r.manager.APIKeys[id]; ok {
+		key.LastUsedAt = &now
+	}
+	return nil
+}
+
+// MockIdentityRepository implements repository.IdentityRepository for testing
+type MockIdentityRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockIdentityRepository) CreateAuthRequest(req schema.OIDCAuthRequest) error {
+	r.manager.AuthRequests[req.StateHash] = &req
+	return nil
+}
+
+func (r *MockIdentityRepository) ConsumeAuthRequest(stateHash string) (*schema.OIDCAuthRequest, error) {
+	req, ok := r.manager.AuthRequests[stateHash]
+	if !ok || !time.Now().Before(req.ExpiresAt) {
+		return nil, sql.ErrNoRows
+	}
+	delete(r.manager.AuthRequests, stateHash)
+	return req, nil
+}
+
+func (r *MockIdentityRepository) GetIdentity(provider, subject string) (*schema.UserIdentity, error) {
+	if identity, ok := r.manager.Identities[provider+"|"+subject]; ok {
+		return identity, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockIdentityRepository) GetIdentitiesByUserID(userID uuid.UUID) ([]schema.UserIdentity, error) {
+	var identities []schema.UserIdentity
+	for _, identity := range r.manager.Identities {
+		if identity.UserId == userID {
+			identities = append(identities, *identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *MockIdentityRepository) CreateIdentity(identity schema.UserIdentity) error {
+	for key, existing := range r.manager.Identities {
+		if key == identity.Provider+"|"+identity.Subject || (existing.UserId == identity.UserId && existing.Provider == identity.Provider) {
+			return repository.ErrIdentityLinked
+		}
+	}
+	identity.CreatedAt = time.Now()
+	r.manager.Identities[identity.Provider+"|"+identity.Subject] = &identity
+	return nil
+}
+
+func (r *MockIdentityRepository) CreateUserWithIdentity(user schema.User, identity schema.UserIdentity) error {
+	if _, ok := r.manager.Identities[identity.Provider+"|"+identity.Subject]; ok {
+		return repository.ErrIdentityLinked
+	}
+	for _, existing := range r.manager.Users {
+		if strings.EqualFold(existing.Username, user.Username) || existing.Email == user.Email {
+			return repository.ErrUsernameTaken
+		}
+	}
+	if user.Role == "" {
+		user.Role = schema.RoleUser
+	}
+	r.manager.Users[user.Id] = &user
+	identity.UserId = user.Id
+	return r.CreateIdentity(identity)
+}
+
+func (r *MockIdentityRepository) DeleteIdentity(userID uuid.UUID, provider string) error {
+	for key, identity := range r.manager.Identities {
+		if identity.UserId == userID && identity.Provider == provider {
+			delete(r.manager.Identities, key)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// MockFollowRepository implements repository.FollowRepository for testing
+type MockFollowRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockFollowRepository) CreateFollow(follow schema.Follow) (*schema.Follow, error) {
+	if existing, err := r.GetFollow(follow.FollowerId, follow.FolloweeId); err == nil {
+		return existing, nil
+	}
+	follow.CreatedAt = time.Now()
+	if follow.Status == schema.FollowAccepted {
+		follow.AcceptedAt = &follow.CreatedAt
+	}
+	r.manager.Follows = append(r.manager.Follows, &follow)
+	return &follow, nil
+}
+
+func (r *MockFollowRepository) GetFollow(followerID, followeeID uuid.UUID) (*schema.Follow, error) {
+	for _, follow := range r.manager.Follows {
+		if follow.FollowerId == followerID && follow.FolloweeId == followeeID {
+			return follow, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockFollowRepository) DeleteFollow(followerID, followeeID uuid.UUID) error {
+	for i, follow := range r.manager.Follows {
+		if follow.FollowerId == followerID && follow.FolloweeId == followeeID {
+			r.manager.Follows = append(r.manager.Follows[:i], r.manager.Follows[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockFollowRepository) AcceptFollow(followerID, followeeID uuid.UUID) error {
+	follow, err := r.GetFollow(followerID, followeeID)
+	if err != nil || follow.Status != schema.FollowPending {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	follow.Status = schema.FollowAccepted
+	follow.AcceptedAt = &now
+	return nil
+}
+
+func (r *MockFollowRepository) AcceptAllFollows(followeeID uuid.UUID) error {
+	for _, follow := range r.manager.Follows {
+		if follow.FolloweeId == followeeID && follow.Status == schema.FollowPending {
+			r.AcceptFollow(follow.FollowerId, followeeID)
+		}
+	}
+	return nil
+}
+
+func (r *MockFollowRepository) GetFollowers(userID uuid.UUID, status schema.FollowStatus, limit, offset int) ([]schema.FollowListEntry, error) {
+	var entries []schema.FollowListEntry
+	for _, follow := range r.manager.Follows {
+		if follow.FolloweeId == userID && follow.Status == status {
+			entries = append(entries, r.listEntry(follow.FollowerId, follow))
+		}
+	}
+	return paginate(entries, limit, offset), nil
+}
+
+func (r *MockFollowRepository) GetFollowing(userID uuid.UUID, limit, offset int) ([]schema.FollowListEntry, error) {
+	var entries []schema.FollowListEntry
+	for _, follow := range r.manager.Follows {
+		if follow.FollowerId == userID && follow.Status == schema.FollowAccepted {
+			entries = append(entries, r.listEntry(follow.FolloweeId, follow))
+		}
+	}
+	return paginate(entries, limit, offset), nil
+}
+
+func (r *MockFollowRepository) listEntry(userID uuid.UUID, follow *schema.Follow) schema.FollowListEntry {
+	entry := schema.FollowListEntry{UserId: userID, Status: follow.Status, FollowedAt: follow.CreatedAt}
+	if user, ok := r.manager.Users[userID]; ok {
+		entry.Username = user.Username
+		entry.Name = user.Name
+	}
+	return entry
+}
+
+func (r *MockFollowRepository) GetFollowCounts(userID uuid.UUID) (*schema.FollowCounts, error) {
+	counts := &schema.FollowCounts{}
+	for _, follow := range r.manager.Follows {
+		switch {
+		case follow.FolloweeId == userID && follow.Status == schema.FollowAccepted:
+			counts.Followers++
+		case follow.FollowerId == userID && follow.Status == schema.FollowAccepted:
+			counts.Following++
+		case follow.FolloweeId == userID && follow.Status == schema.FollowPending:
+			counts.Requests++
+		}
+	}
+	return counts, nil
+}
+
+// MockInteractionRepository implements repository.InteractionRepository for testing
+type MockInteractionRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockInteractionRepository) find(interaction schema.Interaction) int {
+	for i, existing := range r.manager.Interactions {
+		if existing.UserId == interaction.UserId && existing.TargetType == interaction.TargetType &&
+			existing.TargetId == interaction.TargetId && existing.Reaction == interaction.Reaction {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MockInteractionRepository) AddReaction(interaction schema.Interaction) (bool, error) {
+	if r.find(interaction) >= 0 {
+		return false, nil
+	}
+	interaction.CreatedAt = time.Now()
+	r.manager.Interactions = append(r.manager.Interactions, &interaction)
+	return true, nil
+}
+
+func (r *MockInteractionRepository) RemoveReaction(interaction schema.Interaction) error {
+	if i := r.find(interaction); i >= 0 {
+		r.manager.Interactions = append(r.manager.Interactions[:i], r.manager.Interactions[i+1:]...)
+	}
+	return nil
+}
+
+func (r *MockInteractionRepository) GetReactionSummaries(targetType schema.TargetType, targetIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]*schema.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID]*schema.ReactionSummary, len(targetIDs))
+	for _, id := range targetIDs {
+		summaries[id] = schema.NewReactionSummary()
+	}
+	for _, interaction := range r.manager.Interactions {
+		if summary, ok := summaries[interaction.TargetId]; ok && interaction.TargetType == targetType {
+			summary.Add(interaction.Reaction, 1, interaction.UserId == viewerID)
+		}
+	}
+	return summaries, nil
+}
+
+func (r *MockInteractionRepository) GetReactors(targetType schema.TargetType, targetID uuid.UUID, reaction schema.Reaction, limit, offset int) ([]schema.Reactor, error) {
+	var reactors []schema.Reactor
+	for i := len(r.manager.Interactions) - 1; i >= 0; i-- {
+		interaction := r.manager.Interactions[i]
+		if interaction.TargetType != targetType || interaction.TargetId != targetID {
+			continue
+		}
+		if reaction != "" && interaction.Reaction != reaction {
+			continue
+		}
+		reactor := schema.Reactor{UserId: interaction.UserId, Reaction: interaction.Reaction, ReactedAt: interaction.CreatedAt}
+		if user, ok := r.manager.Users[interaction.UserId]; ok {
+			reactor.Username = user.Username
+			reactor.Name = user.Name
+		}
+		reactors = append(reactors, reactor)
+	}
+	return paginate(reactors, limit, offset), nil
+}
+
+// MockCommentRepository implements repository.CommentRepository for testing.
+// Mentions are stored as usernames on the comment.
+type MockCommentRepository struct {
+	manager *MockRepositoryManager
+}
+
+func (r *MockCommentRepository) mentions(userIDs []uuid.UUID) pq.StringArray {
+	usernames := pq.StringArray{}
+	for _, id := range userIDs {
+		if user, ok := r.manager.Users[id]; ok {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	sort.Strings(usernames)
+	return usernames
+}
+
+// hydrate returns a copy of comment with the fields the database derives.
+func (r *MockCommentRepository) hydrate(comment *schema.Comment) schema.Comment {
+	hydrated := *comment
+	hydrated.ReplyCount = 0
+	for _, other := range r.manager.Comments {
+		if other.ParentId != nil && *other.ParentId == comment.Id {
+			hydrated.ReplyCount++
+		}
+	}
+	if user, ok := r.manager.Users[comment.AuthorId]; ok {
+		hydrated.AuthorUsername = user.Username
+	}
+	return hydrated
+}
+
+func (r *MockCommentRepository) CreateComment(comment schema.Comment, mentions []uuid.UUID) error {
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.Mentions = r.mentions(mentions)
+	r.manager.Comments = append(r.manager.Comments, &comment)
+	return nil
+}
+
+func (r *MockCommentRepository) GetCommentByID(id uuid.UUID) (*schema.Comment, error) {
+	for _, comment := range r.manager.Comments {
+		if comment.Id == id {
+			hydrated := r.hydrate(comment)
+			return &hydrated, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockCommentRepository) GetComments(targetType schema.TargetType, targetID uuid.UUID, limit, offset int) ([]schema.Comment, error) {
+	var comments []schema.Comment
+	for _, comment := range r.manager.Comments {
+		if comment.TargetType == targetType && comment.TargetId == targetID && comment.ParentId == nil {
+			comments = append(comments, r.hydrate(comment))
+		}
+	}
+	return paginate(comments, limit, offset), nil
+}
+
+func (r *MockCommentRepository) CountComments(targetType schema.TargetType, targetID uuid.UUID) (int, error) {
+	comments, _ := r.GetComments(targetType, targetID, len(r.manager.Comments), 0)
+	return len(comments), nil
+}
+
+func (r *MockCommentRepository) GetReplies(parentID uuid.UUID, limit, offset int) ([]schema.Comment, error) {
+	var replies []schema.Comment
+	for _, comment := range r.manager.Comments {
+		if comment.ParentId != nil && *comment.ParentId == parentID {
+			replies = append(replies, r.hydrate(comment))
+		}
+	}
+	return paginate(replies, limit, offset), nil
+}
+
+func (r *MockCommentRepository) UpdateComment(id uuid.UUID, body string, mentions []uuid.UUID) error {
+	for _, comment := range r.manager.Comments {
+		if comment.Id == id {
+			now := time.Now()
+			comment.Body = body
+			comment.Mentions = r.mentions(mentions)
+			comment.EditedAt = &now
+			comment.UpdatedAt = now
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockCommentRepository) DeleteComment(id uuid.UUID) error {
+	var kept []*schema.Comment
+	found := false
+	for _, comment := range r.manager.Comments {
+		if comment.Id == id {
+			found = true
+			continue
+		}
+		if comment.ParentId != nil && *comment.ParentId == id {
+			continue
+		}
+		kept = append(kept, comment)
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+	r.manager.Comments = kept
+	return nil
+}
+
+func (r *MockCommentRepository) GetCommentCounts(targetType schema.TargetType, targetIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	counts := make(map[uuid.UUID]int, len(targetIDs))
+	for _, id := range targetIDs {
+		counts[id] = 0
+	}
+	for _, comment := range r.manager.Comments {
+		if _, ok := counts[comment.TargetId]; ok && comment.TargetType == targetType {
+			counts[comment.TargetId]++
+		}
+	}
+	return counts, nil
+}
+
+// MockCollectionRepository implements repository.CollectionRepository for testing
+type MockCollectionRepository struct {
+	manager *MockRepositoryManager
+}
+
+// hydrate returns a copy of collection with its item count.
+func (r *MockCollectionRepository) hydrate(collection *schema.Collection) schema.Collection {
+	hydrated := *collection
+	hydrated.ItemCount = 0
+	for _, item := range r.manager.CollectionItems {
+		if item.CollectionId == collection.Id {
+			hydrated.ItemCount++
+		}
+	}
+	return hydrated
+}
+
+func (r *MockCollectionRepository) CreateCollection(collection schema.Collection) error {
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = collection.CreatedAt
+	r.manager.Collections = append(r.manager.Collections, &collection)
+	return nil
+}
+
+func (r *MockCollectionRepository) GetCollectionByID(id uuid.UUID) (*schema.Collection, error) {
+	for _, collection := range r.manager.Collections {
+		if collection.Id == id {
+			hydrated := r.hydrate(collection)
+			return &hydrated, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockCollectionRepository) GetDefaultCollection(userID uuid.UUID) (*schema.Collection, error) {
+	for _, collection := range r.manager.Collections {
+		if collection.UserId == userID && collection.IsDefault {
+			hydrated := r.hydrate(collection)
+			return &hydrated, nil
+		}
+	}
+	r.CreateCollection(schema.Collection{Id: uuid.New(), UserId: userID, Name: schema.DefaultCollectionName, IsDefault: true})
+	return r.GetDefaultCollection(userID)
+}
+
+func (r *MockCollectionRepository) GetCollectionsByUserID(userID uuid.UUID, includePrivate bool) ([]schema.Collection, error) {
+	var collections []schema.Collection
+	for _, collection := range r.manager.Collections {
+		if collection.UserId == userID && (collection.IsPublic || includePrivate) {
+			collections = append(collections, r.hydrate(collection))
+		}
+	}
+	sort.SliceStable(collections, func(i, j int) bool {
+		if collections[i].IsDefault != collections[j].IsDefault {
+			return collections[i].IsDefault
+		}
+		return strings.ToLower(collections[i].Name) < strings.ToLower(collections[j].Name)
+	})
+	return collections, nil
+}
+
+func (r *MockCollectionRepository) UpdateCollection(collection schema.Collection) error {
+	for _, existing := range r.manager.Collections {
+		if existing.Id == collection.Id {
+			existing.Name = collection.Name
+			existing.Description = collection.Description
+			existing.IsPublic = collection.IsPublic
+			existing.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockCollectionRepository) DeleteCollection(id uuid.UUID) error {
+	for i, collection := range r.manager.Collections {
+		if collection.Id == id {
+			r.manager.Collections = append(r.manager.Collections[:i], r.manager.Collections[i+1:]...)
+			var kept []*schema.CollectionItem
+			for _, item := range r.manager.CollectionItems {
+				if item.CollectionId != id {
+					kept = append(kept, item)
+				}
+			}
+			r.manager.CollectionItems = kept
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockCollectionRepository) AddItem(item schema.CollectionItem) (bool, error) {
+	if _, err := r.GetItem(item.CollectionId, item.RecipeId); err == nil {
+		return false, nil
+	}
+	item.Position = 0
+	for _, existing := range r.manager.CollectionItems {
+		if existing.CollectionId == item.CollectionId && existing.Position >= item.Position {
+			item.Position = existing.Position + 1
+		}
+	}
+	item.AddedAt = time.Now()
+	r.manager.CollectionItems = append(r.manager.CollectionItems, &item)
+	return true, nil
+}
+
+func (r *MockCollectionRepository) GetItem(collectionID, recipeID uuid.UUID) (*schema.CollectionItem, error) {
+	for _, item := range r.manager.CollectionItems {
+		if item.CollectionId == collectionID && item.RecipeId == recipeID {
+			return item, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MockCollectionRepository) GetItems(collectionID uuid.UUID, limit, offset int) ([]schema.CollectionItem, error) {
+	var items []schema.CollectionItem
+	for _, item := range r.manager.CollectionItems {
+		if item.CollectionId == collectionID {
+			items = append(items, *item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+	return paginate(items, limit, offset), nil
+}
+
+func (r *MockCollectionRepository) UpdateItemNote(collectionID, recipeID uuid.UUID, note string) error {
+	item, err := r.GetItem(collectionID, recipeID)
+	if err != nil {
+		return err
+	}
+	item.Note = note
+	return nil
+}
+
+func (r *MockCollectionRepository) RemoveItem(collectionID, recipeID uuid.UUID) error {
+	for i, item := range r.manager.CollectionItems {
+		if item.CollectionId == collectionID && item.RecipeId == recipeID {
+			r.manager.CollectionItems = append(r.manager.CollectionItems[:i], r.manager.CollectionItems[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockCollectionRepository) ReorderItems(collectionID uuid.UUID, recipeIDs []uuid.UUID) error {
+	positions := make(map[uuid.UUID]int, len(recipeIDs))
+	for position, id := range recipeIDs {
+		positions[id] = position
+	}
+	var items []*schema.CollectionItem
+	for _, item := range r.manager.CollectionItems {
+		if item.CollectionId == collectionID {
+			items = append(items, item)
+		}
+	}
+	if len(items) != len(recipeIDs) || len(positions) != len(recipeIDs) {
+		return repository.ErrCollectionOrder
+	}
+	for _, item := range items {
+		if _, ok := positions[item.RecipeId]; !ok {
+			return repository.ErrCollectionOrder
+		}
+	}
+	for _, item := range items {
+		item.Position = positions[item.RecipeId]
+	}
+	return nil
+}
+
+// MockNotificationRepository implements repository.NotificationRepository for testing
+type MockNotificationRepository struct {
+	manager *MockRepositoryManager
+}
+
+// view trims the stored actors to the three most recent, like the real
+// repository does.
+func (r *MockNotificationRepository) view(notification *schema.Notification) schema.Notification {
+	viewed := *notification
+	viewed.ActorCount = len(notification.ActorIds)
+	viewed.ActorIds = nil
+	viewed.ActorUsernames = nil
+	for i, id := range notification.ActorIds {
+		if i == 3 {
+			break
+		}
+		viewed.ActorIds = append(viewed.ActorIds, id)
+		if user, ok := r.manager.Users[uuid.MustParse(id)]; ok {
+			viewed.ActorUsernames = append(viewed.ActorUsernames, user.Username)
+		}
+	}
+	return viewed
+}
+
+func (r *MockNotificationRepository) AddNotification(notification schema.Notification, actorID uuid.UUID) error {
+	now := time.Now()
+	for _, existing := range r.manager.Notifications {
+		if existing.UserId == notification.UserId && existing.GroupKey == notification.GroupKey && existing.ReadAt == nil {
+			actors := pq.StringArray{actorID.String()}
+			for _, id := range existing.ActorIds {
+				if id != actorID.String() {
+					actors = append(actors, id)
+				}
+			}
+			existing.ActorIds = actors
+			existing.UpdatedAt = now
+			return nil
+		}
+	}
+	notification.ActorIds = pq.StringArray{actorID.String()}
+	notification.CreatedAt = now
+	notification.UpdatedAt = now
+	r.manager.Notifications = append(r.manager.Notifications, &notification)
+	return nil
+}
+
+func (r *MockNotificationRepository) find(userID uuid.UUID, unreadOnly bool) []schema.Notification {
+	var notifications []schema.Notification
+	for i := len(r.manager.Notifications) - 1; i >= 0; i-- {
+		notification := r.manager.Notifications[i]
+		if notification.UserId == userID && (!unreadOnly || notification.ReadAt == nil) {
+			notifications = append(notifications, r.view(notification))
+		}
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].UpdatedAt.After(notifications[j].UpdatedAt)
+	})
+	return notifications
+}
+
+func (r *MockNotificationRepository) GetNotifications(userID uuid.UUID, unreadOnly bool, limit, offset int) ([]schema.Notification, error) {
+	return paginate(r.find(userID, unreadOnly), limit, offset), nil
+}
+
+func (r *MockNotificationRepository) CountNotifications(userID uuid.UUID, unreadOnly bool) (int, error) {
+	return len(r.find(userID, unreadOnly)), nil
+}
+
+func (r *MockNotificationRepository) MarkNotificationRead(userID, id uuid.UUID) error {
+	for _, notification := range r.manager.Notifications {
+		if notification.Id == id && notification.UserId == userID {
+			if notification.ReadAt == nil {
+				now := time.Now()
+				notification.ReadAt = &now
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MockNotificationRepository) MarkAllNotificationsRead(userID uuid.UUID) error {
+	now := time.Now()
+	for _, notification := range r.manager.Notifications {
+		if notification.UserId == userID && notification.ReadAt == nil {
+			notification.ReadAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MockNotificationRepository) GetNotificationPreferences(userID uuid.UUID) ([]schema.NotificationPreference, error) {
+	var preferences []schema.NotificationPreference
+	for _, preference := range r.manager.NotificationPreferences {
+		if preference.UserId == userID {
+			preferences = append(preferences, *preference)
+		}
+	}
+	return preferences, nil
+}
+
+func (r *MockNotificationRepository) SetNotificationPreference(preference schema.NotificationPreference) error {
+	for _, existing := range r.manager.NotificationPreferences {
+		if existing.UserId == preference.UserId && existing.Type == preference.Type {
+			existing.Enabled = preference.Enabled
+			return nil
+		}
+	}
+	r.manager.NotificationPreferences = append(r.manager.NotificationPreferences, &preference)
+	return nil
+}
+
+// mockPostTag links a post to one of its tags, like a post_tags row.
+type mockPostTag struct {
+	PostId    uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+// MockTagRepository implements repository.TagRepository for testing
+type MockTagRepository struct {
+	manager *MockRepositoryManager
+}
+
+// setPostTags replaces the tags of a post, keeping the rows of tags it
+// already had like the post repository does.
+func (m *MockRepositoryManager) setPostTags(postID uuid.UUID, tags []string) {
+	existing := make(map[string]bool)
+	var kept []*mockPostTag
+	for _, postTag := range m.PostTags {
+		if postTag.PostId == postID && !slices.Contains(tags, postTag.Tag) {
+			continue
+		}
+		if postTag.PostId == postID {
+			existing[postTag.Tag] = true
+		}
+		kept = append(kept, postTag)
+	}
+	for _, tag := range tags {
+		if !existing[tag] {
+			kept = append(kept, &mockPostTag{PostId: postID, Tag: tag, CreatedAt: time.Now()})
+		}
+	}
+	m.PostTags = kept
+}
+
+// tagged returns the post tags whose post still exists, as deleting a post
+// removes its tags.
+func (r *MockTagRepository) tagged() []*mockPostTag {
+	var postTags []*mockPostTag
+	for _, postTag := range r.manager.PostTags {
+		if _, ok := r.manager.Posts[postTag.PostId]; ok {
+			postTags = append(postTags, postTag)
+		}
+	}
+	return postTags
+}
+
+func (r *MockTagRepository) postsByTag(tag string) []schema.PostWithMedia {
+	var posts []schema.PostWithMedia
+	for _, postTag := range r.tagged() {
+		if postTag.Tag == tag {
+			posts = append(posts, mockListedPost(r.manager.Posts[postTag.PostId]))
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return olderPost(posts[j].CreatedAt, posts[j].Id, posts[i].CreatedAt, posts[i].Id)
+	})
+	return posts
+}
+
+func (r *MockTagRepository) GetPostsByTag(tag string, limit, offset int) ([]schema.PostWithMedia, error) {
+	return paginate(r.postsByTag(tag), limit, offset), nil
+}
+
+func (r *MockTagRepository) CountPostsByTag(tag string) (int, error) {
+	return len(r.postsByTag(tag)), nil
+}
+
+// countTags counts the posts of every tag matching keep and returns them
+// most used first, then most recently used.
+func (r *MockTagRepository) countTags(keep func(*mockPostTag) bool, limit int) []schema.Tag {
+	counts := make(map[string]int)
+	latest := make(map[string]time.Time)
+	for _, postTag := range r.tagged() {
+		if keep(postTag) {
+			counts[postTag.Tag]++
+			if postTag.CreatedAt.After(latest[postTag.Tag]) {
+				latest[postTag.Tag] = postTag.CreatedAt
+			}
+		}
+	}
+
+	var tags []schema.Tag
+	for name, count := range counts {
+		tags = append(tags, schema.Tag{Name: name, PostCount: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		if !latest[tags[i].Name].Equal(latest[tags[j].Name]) {
+			return latest[tags[i].Name].After(latest[tags[j].Name])
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return paginate(tags, limit, 0)
+}
+
+func (r *MockTagRepository) SearchTags(prefix string, limit int) ([]schema.Tag, error) {
+	return r.countTags(func(postTag *mockPostTag) bool {
+		return strings.HasPrefix(postTag.Tag, prefix)
+	}, limit), nil
+}
+
+func (r *MockTagRepository) GetTrendingTags(since time.Time, limit int) ([]schema.Tag, error) {
+	return r.countTags(func(postTag *mockPostTag) bool {
+		return !postTag.CreatedAt.Before(since)
+	}, limit), nil
+}
+
+// paginate returns the page of items selected by limit and offset.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/notify"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+)
+
+const defaultNotificationsPageSize = 20
+
+// NotificationHandler serves the current user's in-app notifications and
+// their notification preferences. Notifications are created by the notify
+// service as other handlers emit events.
+type NotificationHandler struct {
+	Manager *repository.Manager
+}
+
+func NewNotificationHandler(manager *repository.Manager) *NotificationHandler {
+	return &NotificationHandler{
+		Manager: manager,
+	}
+}
+
+// NotificationPage is a page of notifications with the number of unread
+// notifications in total.
+type NotificationPage struct {
+	Notifications []schema.Notification `json:"notifications"`
+	UnreadCount   int                   `json:"unread_count"`
+	Pagination    Pagination            `json:"pagination"`
+}
+
+// GetNotifications lists the current user's notifications, most recent
+// activity first. With ?unread=true only unread notifications are listed.
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	limit, offset := pageParams(r, defaultNotificationsPageSize)
+	notifications, err := h.Manager.NotificationRepo.GetNotifications(userID, unreadOnly, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+	total, err := h.Manager.NotificationRepo.CountNotifications(userID, unreadOnly)
+	if err != nil {
+		http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+	unread := total
+	if !unreadOnly {
+		unread, err = h.Manager.NotificationRepo.CountNotifications(userID, true)
+		if err != nil {
+			http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if notifications == nil {
+		notifications = []schema.Notification{}
+	}
+	for i := range notifications {
+		notifications[i].Message = notifications[i].Summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NotificationPage{
+		Notifications: notifications,
+		UnreadCount:   unread,
+		Pagination:    Pagination{Total: total, Limit: limit, Offset: offset},
+	})
+}
+
+// GetUnreadCount returns how many unread notifications the current user
+// has, for badges that should not load the notifications themselves.
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	unread, err := h.Manager.NotificationRepo.CountNotifications(userID, true)
+	if err != nil {
+		http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unread_count": unread})
+}
+
+// MarkRead marks the notification in the URL as read. Later activity on
+// the same item starts a new notification.
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Manager.NotificationRepo.MarkNotificationRead(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to mark notification read", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks every notification of the current user as read.
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.Manager.NotificationRepo.MarkAllNotificationsRead(userID); err != nil {
+		http.Error(w, "Failed to mark notifications read", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPreferences returns whether the current user wants each type of
+// notification.
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+	h.writePreferences(w, userID)
+}
+
+// UpdatePreferences turns the notification types in the request on or off
+// for the current user. Unknown types are rejected before anything is
+// saved.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.NotificationPreferencesReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for t := range req {
+		if !schema.NotificationType(t).Valid() {
+			http.Error(w, "Unknown notification type: "+t, http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, t := range schema.NotificationTypes {
+		enabled, ok := req[string(t)]
+		if !ok {
+			continue
+		}
+		preference := schema.NotificationPreference{UserId: userID, Type: t, Enabled: enabled}
+		if err := h.Manager.NotificationRepo.SetNotificationPreference(preference); err != nil {
+			http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	h.writePreferences(w, userID)
+}
+
+func (h *NotificationHandler) writePreferences(w http.ResponseWriter, userID uuid.UUID) {
+	preferences, err := notify.New(h.Manager).Preferences(userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preferences)
+}
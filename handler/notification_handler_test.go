@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// getNotifications lists userID's notifications through handler.
+func getNotifications(t *testing.T, handler *NotificationHandler, userID uuid.UUID, query string) NotificationPage {
+	t.Helper()
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/notifications"+query, nil), userID)
+	w := httptest.NewRecorder()
+	handler.GetNotifications(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var page NotificationPage
+	readResponseBody(t, w, &page)
+	return page
+}
+
+func TestNotificationHandler_Aggregation(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	reactionHandler := NewReactionHandler(manager)
+	handler := NewNotificationHandler(manager)
+	chef, _ := loginTestUser(t, manager, userHandler, "chef@example.com")
+	postID := createFeedPost(t, manager, chef.Id, time.Now(), nil)
+
+	// The chef liking their own post is not news
+	reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, chef.Id, postID.String(), "like")
+	if page := getNotifications(t, handler, chef.Id, ""); len(page.Notifications) != 0 {
+		t.Fatalf("Expected no notifications for own like, got %d", len(page.Notifications))
+	}
+
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		fan, _ := loginTestUser(t, manager, userHandler, email)
+		reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, fan.Id, postID.String(), "like")
+		// Liking twice does not count twice
+		reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, fan.Id, postID.String(), "like")
+	}
+
+	page := getNotifications(t, handler, chef.Id, "")
+	if len(page.Notifications) != 1 || page.UnreadCount != 1 {
+		t.Fatalf("Expected one aggregated notification, got %d (%d unread)", len(page.Notifications), page.UnreadCount)
+	}
+	notification := page.Notifications[0]
+	if notification.Type != schema.NotifyLike || notification.ActorCount != 3 {
+		t.Errorf("Unexpected notification: %+v", notification)
+	}
+	if expected := "carol and 2 others liked your post"; notification.Message != expected {
+		t.Errorf("Expected message %q, got %q", expected, notification.Message)
+	}
+
+	// Once read, new likes start a new notification
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/notifications/read", nil), chef.Id)
+	w := httptest.NewRecorder()
+	handler.MarkAllRead(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	dave, _ := loginTestUser(t, manager, userHandler, "dave@example.com")
+	reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, dave.Id, postID.String(), "like")
+
+	page = getNotifications(t, handler, chef.Id, "?unread=true")
+	if len(page.Notifications) != 1 || page.Notifications[0].ActorCount != 1 || page.Pagination.Total != 1 {
+		t.Errorf("Expected a new unread notification, got %+v", page)
+	}
+	if page := getNotifications(t, handler, chef.Id, ""); page.Pagination.Total != 2 {
+		t.Errorf("Expected 2 notifications in total, got %d", page.Pagination.Total)
+	}
+}
+
+func TestNotificationHandler_CommentEvents(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	commentHandler := NewCommentHandler(manager)
+	handler := NewNotificationHandler(manager)
+	chef, _ := loginTestUser(t, manager, userHandler, "chef@example.com")
+	alice, _ := loginTestUser(t, manager, userHandler, "alice@example.com")
+	bob, _ := loginTestUser(t, manager, userHandler, "bob@example.com")
+	postID := createFeedPost(t, manager, chef.Id, time.Now(), nil)
+
+	w := createComment(t, commentHandler, alice.Id, postID, map[string]interface{}{"body": "Lovely"})
+	var comment schema.Comment
+	readResponseBody(t, w, &comment)
+	createComment(t, commentHandler, bob.Id, postID, map[string]interface{}{
+		"body":      "Agreed @alice, ask @chef for the recipe",
+		"parent_id": comment.Id,
+	})
+
+	// The chef was mentioned, which replaces the comment notification
+	types := func(userID uuid.UUID) []schema.NotificationType {
+		var types []schema.NotificationType
+		for _, notification := range getNotifications(t, handler, userID, "").Notifications {
+			types = append(types, notification.Type)
+		}
+		return types
+	}
+	if got := types(chef.Id); len(got) != 2 || got[0] != schema.NotifyMention || got[1] != schema.NotifyComment {
+		t.Errorf("Unexpected notifications for chef: %v", got)
+	}
+	if got := types(alice.Id); len(got) != 1 || got[0] != schema.NotifyMention {
+		t.Errorf("Unexpected notifications for alice: %v", got)
+	}
+	if got := types(bob.Id); len(got) != 0 {
+		t.Errorf("Expected no notifications for bob, got %v", got)
+	}
+}
+
+func TestNotificationHandler_FollowEvents(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	followHandler := NewFollowHandler(manager)
+	handler := NewNotificationHandler(manager)
+	follower, _ := loginTestUser(t, manager, userHandler, "follower@example.com")
+	private, _ := loginTestUser(t, manager, userHandler, "private@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[private.Id].IsPrivate = true
+
+	followRequest(t, followHandler.FollowUser, http.MethodPost, follower.Id, private.Id.String())
+	page := getNotifications(t, handler, private.Id, "")
+	if len(page.Notifications) != 1 || page.Notifications[0].Type != schema.NotifyFollowRequest {
+		t.Fatalf("Expected a follow request notification, got %+v", page.Notifications)
+	}
+
+	followRequest(t, followHandler.AcceptFollowRequest, http.MethodPost, private.Id, follower.Id.String())
+	page = getNotifications(t, handler, follower.Id, "")
+	if len(page.Notifications) != 1 || page.Notifications[0].Type != schema.NotifyFollowAccepted {
+		t.Errorf("Expected a follow accepted notification, got %+v", page.Notifications)
+	}
+}
+
+func TestNotificationHandler_MarkRead(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	followHandler := NewFollowHandler(manager)
+	handler := NewNotificationHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "popular@example.com")
+	other, _ := loginTestUser(t, manager, userHandler, "other@example.com")
+	followRequest(t, followHandler.FollowUser, http.MethodPost, other.Id, user.Id.String())
+	notificationID := getNotifications(t, handler, user.Id, "").Notifications[0].Id
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		notificationID string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid notification ID",
+			userID:         user.Id,
+			notificationID: "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Someone else's notification",
+			userID:         other.Id,
+			notificationID: notificationID.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Mark read",
+			userID:         user.Id,
+			notificationID: notificationID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Mark read again",
+			userID:         user.Id,
+			notificationID: notificationID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPost, "/api/notifications/"+tt.notificationID+"/read", nil)
+			req = setupURLParams(setupTestContext(req, tt.userID), map[string]string{"id": tt.notificationID})
+			w := httptest.NewRecorder()
+
+			handler.MarkRead(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/notifications/unread-count", nil), user.Id)
+	w := httptest.NewRecorder()
+	handler.GetUnreadCount(w, req)
+	var count map[string]int
+	readResponseBody(t, w, &count)
+	if count["unread_count"] != 0 {
+		t.Errorf("Expected no unread notifications, got %d", count["unread_count"])
+	}
+}
+
+func TestNotificationHandler_Preferences(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	reactionHandler := NewReactionHandler(manager)
+	handler := NewNotificationHandler(manager)
+	chef, _ := loginTestUser(t, manager, userHandler, "chef@example.com")
+	fan, _ := loginTestUser(t, manager, userHandler, "fan@example.com")
+	postID := createFeedPost(t, manager, chef.Id, time.Now(), nil)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Unknown type",
+			body:           map[string]interface{}{"like": false, "poke": false},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid value",
+			body:           map[string]interface{}{"like": "no"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Mute likes",
+			body:           map[string]interface{}{"like": false},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodPut, "/api/notifications/preferences", tt.body), chef.Id)
+			w := httptest.NewRecorder()
+
+			handler.UpdatePreferences(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/notifications/preferences", nil), chef.Id)
+	w := httptest.NewRecorder()
+	handler.GetPreferences(w, req)
+	var preferences map[schema.NotificationType]bool
+	readResponseBody(t, w, &preferences)
+	if len(preferences) != len(schema.NotificationTypes) || preferences[schema.NotifyLike] || !preferences[schema.NotifyReaction] {
+		t.Errorf("Unexpected preferences: %v", preferences)
+	}
+
+	// Likes are muted, other reactions still come through
+	reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, fan.Id, postID.String(), "like")
+	reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, fan.Id, postID.String(), "yum")
+	page := getNotifications(t, handler, chef.Id, "")
+	if len(page.Notifications) != 1 || page.Notifications[0].Type != schema.NotifyReaction {
+		t.Errorf("Expected only the reaction notification, got %+v", page.Notifications)
+	}
+}
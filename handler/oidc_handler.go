@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/oidc"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+const (
+	oidcAuthRequestTTL = 10 * time.Minute
+	// oidcStateCookie ties a login attempt to the browser that started it.
+	// It holds the hash of the attempt's state.
+	oidcStateCookie = "oidc_state"
+	// usernameAttempts is how many suffixed usernames are tried for a new
+	// account before giving up.
+	usernameAttempts = 5
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+
+// oidcProvider returns the configured provider named in the URL.
+func oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	provider, ok := config.Get().OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return nil, false
+	}
+	return provider, true
+}
+
+// beginOIDC records a new login attempt at provider and returns the URL to
+// send the user to. linkUserID is set when linking rather than logging in.
+// The attempt's state is also set as a cookie, so the callback only
+// completes in the browser that started it.
+func (u *UserHandler) beginOIDC(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, linkUserID *uuid.UUID) (string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	err = u.Manager.IdentityRepo.CreateAuthRequest(schema.OIDCAuthRequest{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserId:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcAuthRequestTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return "", err
+	}
+	setOIDCStateCookie(w, utils.HashToken(state), int(oidcAuthRequestTTL.Seconds()))
+	return authURL, nil
+}
+
+// setOIDCStateCookie sets the state cookie to value for maxAge seconds. A
+// negative maxAge removes it.
+func setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Get().AppURL, "https://"),
+		// The provider redirects back with a top-level GET, which Lax
+		// cookies are sent with
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCLogin redirects to the identity provider to sign in.
+func (u *UserHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, err := u.beginOIDC(w, r, provider, nil)
+	if err != nil {
+		log.Printf("error starting %s login: %v\n", provider.Name, err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes a sign in at an identity provider. A known identity
+// logs its user in, an unknown one gets a new account, and a callback for a
+// link request attaches the identity to the user who started it.
+func (u *UserHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		http.Error(w, "Sign in was not completed at the identity provider", http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	code := query.Get("code")
+	if state == "" || code == "" {
+		http.Error(w, "State and code are required", http.StatusBadRequest)
+		return
+	}
+
+	// Only the browser that started the attempt may finish it, so a link
+	// started by someone else can't attach this browser's provider account
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(utils.HashToken(state))) != 1 {
+		http.Error(w, "Login request was started in another browser", http.StatusBadRequest)
+		return
+	}
+	setOIDCStateCookie(w, "", -1)
+
+	// Each state is used once, so a replayed callback fails here
+	authRequest, err := u.Manager.IdentityRepo.ConsumeAuthRequest(utils.HashToken(state))
+	if err != nil || authRequest.Provider != provider.Name {
+		http.Error(w, "Invalid or expired login request", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := provider.Exchange(r.Context(), code, authRequest.CodeVerifier)
+	if err != nil {
+		log.Printf("error exchanging %s code: %v\n", provider.Name, err)
+		http.Error(w, "Sign in with identity provider failed", http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, authRequest.Nonce)
+	if err != nil {
+		log.Printf("error verifying %s id token: %v\n", provider.Name, err)
+		http.Error(w, "Sign in with identity provider failed", http.StatusUnauthorized)
+		return
+	}
+
+	if authRequest.LinkUserId != nil {
+		u.linkIdentity(w, *authRequest.LinkUserId, provider.Name, idToken)
+		return
+	}
+
+	identity, err := u.Manager.IdentityRepo.GetIdentity(provider.Name, idToken.Subject)
+	if err == nil {
+		user, err := u.Manager.UserRepo.GetUserByID(identity.UserId)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusUnauthorized)
+			return
+		}
+		u.loginUser(w, r, user)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to load identity", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := u.createOIDCUser(w, provider.Name, idToken)
+	if !ok {
+		return
+	}
+	u.loginUser(w, r, user)
+}
+
+// createOIDCUser creates an account for someone signing in with a provider
+// for the first time.
+func (u *UserHandler) createOIDCUser(w http.ResponseWriter, providerName string, idToken *oidc.IDToken) (*schema.User, bool) {
+	email := strings.TrimSpace(idToken.Email)
+	if email == "" {
+		http.Error(w, "Identity provider did not share an email address", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Existing accounts are only linked by their owner, never by email
+	if _, err := u.Manager.UserRepo.GetUserByEmail(email); err == nil {
+		http.Error(w, "An account with this email already exists; log in and link the provider from your account", http.StatusConflict)
+		return nil, false
+	}
+
+	username, err := u.availableUsername(idToken.PreferredUsername, email)
+	if err != nil {
+		http.Error(w, "Account could not be created, try again", http.StatusConflict)
+		return nil, false
+	}
+
+	name := strings.TrimSpace(idToken.Name)
+	if name == "" {
+		name = username
+	}
+
+	user := schema.User{
+		Id:            uuid.New(),
+		Name:          name,
+		Username:      username,
+		Email:         email,
+		EmailVerified: bool(idToken.EmailVerified),
+		Role:          schema.RoleUser,
+	}
+	identity := schema.UserIdentity{
+		Provider: providerName,
+		Subject:  idToken.Subject,
+		UserId:   user.Id,
+		Email:    email,
+	}
+
+	err = u.Manager.IdentityRepo.CreateUserWithIdentity(user, identity)
+	if errors.Is(err, repository.ErrUsernameTaken) || errors.Is(err, repository.ErrIdentityLinked) {
+		http.Error(w, "Account could not be created, try again", http.StatusConflict)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// availableUsername derives a free username from the provider's preferred
+// username or the email address, adding a number if it is taken.
+func (u *UserHandler) availableUsername(preferred, email string) (string, error) {
+	base := preferred
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 25 {
+		base = base[:25]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < usernameAttempts; i++ {
+		_, err := u.Manager.UserRepo.GetUserByUsername(candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%04d", base, n.Int64())
+	}
+	return "", repository.ErrUsernameTaken
+}
+
+// linkIdentity attaches a provider identity to the user who asked for it.
+func (u *UserHandler) linkIdentity(w http.ResponseWriter, userID uuid.UUID, providerName string, idToken *oidc.IDToken) {
+	if _, err := u.Manager.UserRepo.GetUserByID(userID); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	identity := schema.UserIdentity{
+		Provider:  providerName,
+		Subject:   idToken.Subject,
+		UserId:    userID,
+		Email:     idToken.Email,
+		CreatedAt: time.Now(),
+	}
+	err := u.Manager.IdentityRepo.CreateIdentity(identity)
+	if errors.Is(err, repository.ErrIdentityLinked) {
+		http.Error(w, "This provider account is already linked", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to link identity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identity)
+}
+
+// GetIdentities lists the identity providers linked to the current user.
+func (u *UserHandler) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	identities, err := u.Manager.IdentityRepo.GetIdentitiesByUserID(userID)
+	if err != nil {
+		http.Error(w, "Failed to load identities", http.StatusInternalServerError)
+		return
+	}
+	if identities == nil {
+		identities = []schema.UserIdentity{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identities)
+}
+
+// LinkIdentity starts linking a provider to the current user. The client
+// sends the user to the returned URL; the provider redirects back to
+// OIDCCallback. The browser must keep the state cookie set on this
+// response for the link to complete.
+func (u *UserHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, err := u.beginOIDC(w, r, provider, &userID)
+	if err != nil {
+		log.Printf("error starting %s link: %v\n", provider.Name, err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+}
+
+// UnlinkIdentity removes a provider from the current user, as long as they
+// keep a password or another provider to log in with.
+func (u *UserHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	identities, err := u.Manager.IdentityRepo.GetIdentitiesByUserID(userID)
+	if err != nil {
+		http.Error(w, "Failed to load identities", http.StatusInternalServerError)
+		return
+	}
+	if user.Password == "" && len(identities) <= 1 {
+		http.Error(w, "Set a password before removing your only way to log in", http.StatusConflict)
+		return
+	}
+
+	err = u.Manager.IdentityRepo.DeleteIdentity(userID, chi.URLParam(r, "provider"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Identity not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unlink identity", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/oidc"
+	"github.com/smilecs/foody/oidc/oidctest"
+	"github.com/smilecs/foody/schema"
+)
+
+// setupTestProvider starts a stand-in identity provider registered as "test".
+func setupTestProvider(t *testing.T) *oidctest.Server {
+	t.Helper()
+	server := oidctest.NewServer("foody", "secret")
+	t.Cleanup(server.Close)
+
+	providers := config.Get().OIDCProviders
+	providers["test"] = oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       server.URL,
+		ClientID:     "foody",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/test/callback",
+	})
+	t.Cleanup(func() { delete(providers, "test") })
+	return server
+}
+
+// oidcCallback follows authURL at the provider and calls OIDCCallback with
+// the result, sending cookies like the browser would.
+func oidcCallback(t *testing.T, handler *UserHandler, server *oidctest.Server, authURL string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	callback, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	req := setupTestRequest(t, http.MethodGet, callback.RequestURI(), nil)
+	req = setupURLParams(req, map[string]string{"provider": "test"})
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.OIDCCallback(w, req)
+	return w
+}
+
+// oidcLogin runs a whole sign in with the test provider.
+func oidcLogin(t *testing.T, handler *UserHandler, server *oidctest.Server) *httptest.ResponseRecorder {
+	t.Helper()
+	req := setupURLParams(setupTestRequest(t, http.MethodGet, "/auth/oidc/test/login", nil), map[string]string{"provider": "test"})
+	w := httptest.NewRecorder()
+	handler.OIDCLogin(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, w.Code)
+	}
+	return oidcCallback(t, handler, server, w.Header().Get("Location"), w.Result().Cookies())
+}
+
+func TestUserHandler_OIDCLogin(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	mock := config.Get().DB.(*MockRepositoryManager)
+	server.SetUser(oidctest.User{Subject: "sub-1", Email: "new.cook@example.com", EmailVerified: true, Name: "New Cook"})
+
+	// First login creates an account
+	w := oidcLogin(t, handler, server)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var login LoginResponse
+	readResponseBody(t, w, &login)
+	if login.Token == "" || login.RefreshToken == "" {
+		t.Error("Expected a token pair")
+	}
+
+	created, err := manager.UserRepo.GetUserByEmail("new.cook@example.com")
+	if err != nil {
+		t.Fatalf("Expected account to be created: %v", err)
+	}
+	if created.Name != "New Cook" || created.Username != "new.cook" || !created.EmailVerified {
+		t.Errorf("Unexpected account: %+v", created)
+	}
+	identity, err := manager.IdentityRepo.GetIdentity("test", "sub-1")
+	if err != nil || identity.UserId != created.Id {
+		t.Fatalf("Expected identity to be linked: %v", err)
+	}
+
+	// Logging in again uses the same account
+	users := len(mock.Users)
+	if w := oidcLogin(t, handler, server); w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if len(mock.Users) != users {
+		t.Error("Expected no new account on the second login")
+	}
+
+	// Another provider account with the same username gets a suffix
+	server.SetUser(oidctest.User{Subject: "sub-2", Email: "new.cook@other.example.com"})
+	if w := oidcLogin(t, handler, server); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	second, _ := manager.UserRepo.GetUserByEmail("new.cook@other.example.com")
+	if second.Username == "new.cook" || second.EmailVerified {
+		t.Errorf("Unexpected second account: %+v", second)
+	}
+
+	// Password logins are refused for accounts without a password
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{"email": created.Email, "password": "guess"})
+	w = httptest.NewRecorder()
+	handler.Login(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestUserHandler_OIDCLogin_Rejected(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	existing, _ := loginTestUser(t, manager, handler, "taken@example.com")
+
+	// An email that already has an account is not linked automatically
+	server.SetUser(oidctest.User{Subject: "sub-1", Email: existing.Email, EmailVerified: true})
+	if w := oidcLogin(t, handler, server); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if _, err := manager.IdentityRepo.GetIdentity("test", "sub-1"); err == nil {
+		t.Error("Expected no identity to be linked")
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		provider       string
+		query          url.Values
+		expectedStatus int
+	}{
+		{
+			name:           "Unknown provider",
+			provider:       "unknown",
+			query:          url.Values{"state": {"s"}, "code": {"c"}},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Missing code",
+			provider:       "test",
+			query:          url.Values{"state": {"s"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown state",
+			provider:       "test",
+			query:          url.Values{"state": {"s"}, "code": {"c"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Provider error",
+			provider:       "test",
+			query:          url.Values{"error": {"access_denied"}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/auth/oidc/"+tt.provider+"/callback?"+tt.query.Encode(), nil)
+			req = setupURLParams(req, map[string]string{"provider": tt.provider})
+			w := httptest.NewRecorder()
+
+			handler.OIDCCallback(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// A callback can't be replayed
+	server.SetUser(oidctest.User{Subject: "sub-2", Email: "replay@example.com"})
+	req := setupURLParams(setupTestRequest(t, http.MethodGet, "/auth/oidc/test/login", nil), map[string]string{"provider": "test"})
+	w := httptest.NewRecorder()
+	handler.OIDCLogin(w, req)
+	callback, _ := server.Authorize(w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	for i, expected := range []int{http.StatusOK, http.StatusBadRequest} {
+		req := setupURLParams(setupTestRequest(t, http.MethodGet, callback.RequestURI(), nil), map[string]string{"provider": "test"})
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.OIDCCallback(w, req)
+		if w.Code != expected {
+			t.Errorf("callback %d: expected status %d, got %d", i+1, expected, w.Code)
+		}
+	}
+}
+
+func TestUserHandler_OIDCLogin_TwoFactor(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	server.SetUser(oidctest.User{Subject: "sub-1", Email: "secure@example.com"})
+	if w := oidcLogin(t, handler, server); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	user, _ := manager.UserRepo.GetUserByEmail("secure@example.com")
+	now := time.Now()
+	config.Get().DB.(*MockRepositoryManager).TOTP[user.Id] = &schema.TOTPSecret{UserId: user.Id, Secret: "JBSWY3DPEHPK3PXP", EnabledAt: &now}
+
+	w := oidcLogin(t, handler, server)
+	var challenge TwoFactorChallenge
+	readResponseBody(t, w, &challenge)
+	if !challenge.TwoFactorRequired || challenge.ChallengeToken == "" {
+		t.Errorf("Expected a two-factor challenge, got %s", w.Body.String())
+	}
+}
+
+func TestUserHandler_LinkIdentity(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	user, _ := loginTestUser(t, manager, handler, "linker@example.com")
+	other, _ := loginTestUser(t, manager, handler, "other-linker@example.com")
+
+	link := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/identities/test", nil), userID)
+		req = setupURLParams(req, map[string]string{"provider": "test"})
+		w := httptest.NewRecorder()
+		handler.LinkIdentity(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var response map[string]string
+		readResponseBody(t, w, &response)
+		return oidcCallback(t, handler, server, response["authorization_url"], w.Result().Cookies())
+	}
+
+	server.SetUser(oidctest.User{Subject: "sub-1", Email: "somewhere@else.example.com"})
+	if w := link(user.Id); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// The provider account now logs in as the user
+	w := oidcLogin(t, handler, server)
+	var login LoginResponse
+	readResponseBody(t, w, &login)
+	claims, err := config.Get().JWTKeys.ValidateToken(login.Token)
+	if err != nil || claims.UserID != user.Id {
+		t.Errorf("Expected login as the linked user: %v", err)
+	}
+
+	// It can't be linked to a second account
+	if w := link(other.Id); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me/identities", nil), user.Id)
+	w = httptest.NewRecorder()
+	handler.GetIdentities(w, req)
+	var identities []schema.UserIdentity
+	readResponseBody(t, w, &identities)
+	if len(identities) != 1 || identities[0].Provider != "test" {
+		t.Errorf("Expected the linked identity, got %+v", identities)
+	}
+}
+
+func TestUserHandler_OIDCCallback_OtherBrowser(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	attacker, _ := loginTestUser(t, manager, handler, "attacker@example.com")
+	server.SetUser(oidctest.User{Subject: "victim-sub", Email: "victim@example.com"})
+
+	// The attacker starts a link and gets the victim to open its URL
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/identities/test", nil), attacker.Id)
+	req = setupURLParams(req, map[string]string{"provider": "test"})
+	w := httptest.NewRecorder()
+	handler.LinkIdentity(w, req)
+	var response map[string]string
+	readResponseBody(t, w, &response)
+	authURL, attackerCookies := response["authorization_url"], w.Result().Cookies()
+	if len(attackerCookies) != 1 || !attackerCookies[0].HttpOnly || attackerCookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected an HttpOnly, SameSite=Lax state cookie, got %v", attackerCookies)
+	}
+
+	// The victim's browser has a state cookie of its own login attempt
+	req = setupURLParams(setupTestRequest(t, http.MethodGet, "/auth/oidc/test/login", nil), map[string]string{"provider": "test"})
+	w = httptest.NewRecorder()
+	handler.OIDCLogin(w, req)
+	victimCookies := w.Result().Cookies()
+
+	// Test cases
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+	}{
+		{name: "No state cookie"},
+		{name: "State cookie of another attempt", cookies: victimCookies},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := oidcCallback(t, handler, server, authURL, tt.cookies); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if _, err := manager.IdentityRepo.GetIdentity("test", "victim-sub"); err == nil {
+				t.Error("Expected the victim's identity not to be linked")
+			}
+		})
+	}
+
+	// The attempt is still usable by the browser that started it, which
+	// has its state cookie removed afterwards
+	w = oidcCallback(t, handler, server, authURL, attackerCookies)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	cleared := w.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].Name != oidcStateCookie || cleared[0].MaxAge >= 0 {
+		t.Errorf("Expected the state cookie to be removed, got %v", cleared)
+	}
+}
+
+func TestUserHandler_UnlinkIdentity(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	server := setupTestProvider(t)
+	server.SetUser(oidctest.User{Subject: "sub-1", Email: "only-provider@example.com"})
+	oidcLogin(t, handler, server)
+	user, _ := manager.UserRepo.GetUserByEmail("only-provider@example.com")
+
+	unlink := func(provider string) int {
+		req := setupTestContext(setupTestRequest(t, http.MethodDelete, "/api/me/identities/"+provider, nil), user.Id)
+		req = setupURLParams(req, map[string]string{"provider": provider})
+		w := httptest.NewRecorder()
+		handler.UnlinkIdentity(w, req)
+		return w.Code
+	}
+
+	// The only way to log in can't be removed
+	if code := unlink("test"); code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, code)
+	}
+
+	// After setting a password it can
+	user.Password = "hashed-password"
+	if code := unlink("test"); code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if code := unlink("test"); code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, code)
+	}
+}
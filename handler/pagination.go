@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// maxPageSize caps the limit a client can ask for in one page.
+const maxPageSize = 100
+
+// Pagination describes an offset-paginated list in a response.
+type Pagination struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// pageParams reads the limit and offset query parameters, falling back to
+// defaultLimit and 0 for missing or invalid values.
+func pageParams(r *http.Request, defaultLimit int) (limit, offset int) {
+	limit = defaultLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+	return limit, offset
+}
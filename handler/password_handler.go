@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/mailer"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+const passwordResetTTL = time.Hour
+
+// ForgotPassword emails a password reset link to the account with the given
+// email. The response is the same whether or not the account exists so the
+// endpoint cannot be used to discover registered emails.
+func (u *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	email := r.FormValue("email")
+	if email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByEmail(email)
+	if err == nil {
+		if err := u.sendPasswordReset(user); err != nil {
+			log.Printf("error sending password reset: %v\n", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (u *UserHandler) sendPasswordReset(user *schema.User) error {
+	token, err := u.issueUserToken(user.Id, schema.PasswordResetToken, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	return cfg.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your foody password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your foody account.\n\n"+
+				"Use this link within the next hour to choose a new password:\n%s/password/reset?token=%s\n\n"+
+				"If this wasn't you, you can ignore this email.\n",
+			cfg.FrontendURL, token,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword and
+// logs the user out everywhere.
+func (u *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+	if token == "" || password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := u.Manager.TokenRepo.ConsumeUserToken(utils.HashToken(token), schema.PasswordResetToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+
+	if err := u.Manager.UserRepo.UpdatePassword(userID, password); err != nil {
+		http.Error(w, "Failed to update password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := u.Manager.SessionRepo.RevokeUserSessions(userID); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/mailer"
+	"github.com/smilecs/foody/utils"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// readSentTokens returns the tokens found in every mail written to dir
+func readSentTokens(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("Failed to list sent mail: %v", err)
+	}
+
+	var tokens []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read sent mail: %v", err)
+		}
+		if match := resetTokenPattern.FindSubmatch(content); match != nil {
+			tokens = append(tokens, string(match[1]))
+		}
+	}
+	return tokens
+}
+
+// sentMailLinks counts the sent mails containing a link starting with
+// prefix.
+func sentMailLinks(t *testing.T, dir, prefix string) int {
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("Failed to list sent mail: %v", err)
+	}
+
+	count := 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read sent mail: %v", err)
+		}
+		if strings.Contains(string(content), prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestUserHandler_ForgotPassword(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	loginTestUser(t, manager, handler, "forgot@example.com")
+
+	mailDir := t.TempDir()
+	config.Get().Mailer = &mailer.FileMailer{Dir: mailDir}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		email          string
+		expectedStatus int
+		expectedMails  int
+	}{
+		{
+			name:           "Known email receives a reset link",
+			email:          "forgot@example.com",
+			expectedStatus: http.StatusAccepted,
+			expectedMails:  1,
+		},
+		{
+			name:           "Unknown email gets the same response",
+			email:          "nobody@example.com",
+			expectedStatus: http.StatusAccepted,
+			expectedMails:  1,
+		},
+		{
+			name:           "Missing email",
+			email:          "",
+			expectedStatus: http.StatusBadRequest,
+			expectedMails:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupFormRequest(t, http.MethodPost, "/password/forgot", map[string]string{"email": tt.email})
+			w := httptest.NewRecorder()
+
+			handler.ForgotPassword(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if sent := len(readSentTokens(t, mailDir)); sent != tt.expectedMails {
+				t.Errorf("expected %d mails, got %d", tt.expectedMails, sent)
+			}
+		})
+	}
+
+	// The link opens the web app, which POSTs the token back to the API
+	if sentMailLinks(t, mailDir, config.Get().FrontendURL+"/password/reset?token=") != 1 {
+		t.Error("expected the reset link to point at the frontend")
+	}
+}
+
+func TestUserHandler_ResetPassword(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "reset@example.com")
+
+	mailDir := t.TempDir()
+	config.Get().Mailer = &mailer.FileMailer{Dir: mailDir}
+
+	// Request two reset links; only the second should be usable
+	for i := 0; i < 2; i++ {
+		req := setupFormRequest(t, http.MethodPost, "/password/forgot", map[string]string{"email": user.Email})
+		handler.ForgotPassword(httptest.NewRecorder(), req)
+	}
+	tokens := readSentTokens(t, mailDir)
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 reset mails, got %d", len(tokens))
+	}
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	var firstToken, secondToken string
+	for _, token := range tokens {
+		if mgr.Tokens[utils.HashToken(token)].UsedAt != nil {
+			firstToken = token
+		} else {
+			secondToken = token
+		}
+	}
+
+	reset := func(token, password string) int {
+		req := setupFormRequest(t, http.MethodPost, "/password/reset", map[string]string{
+			"token":    token,
+			"password": password,
+		})
+		w := httptest.NewRecorder()
+		handler.ResetPassword(w, req)
+		return w.Code
+	}
+
+	if code := reset(firstToken, "newpassword"); code != http.StatusBadRequest {
+		t.Errorf("expected superseded token to be rejected, got status %d", code)
+	}
+	if code := reset(secondToken, ""); code != http.StatusBadRequest {
+		t.Errorf("expected missing password to be rejected, got status %d", code)
+	}
+	if code := reset(secondToken, "newpassword"); code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if code := reset(secondToken, "anotherpassword"); code != http.StatusBadRequest {
+		t.Errorf("expected used token to be rejected, got status %d", code)
+	}
+
+	// The new password works and existing sessions are revoked
+	authenticated, _ := manager.UserRepo.AuthUser(user.Email, "newpassword")
+	if !authenticated {
+		t.Error("Expected new password to authenticate")
+	}
+	for _, session := range mgr.Sessions {
+		if session.UserId == user.Id && session.RevokedAt == nil {
+			t.Error("Expected all sessions to be revoked after reset")
+		}
+	}
+}
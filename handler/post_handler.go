@@ -8,8 +8,10 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
 	"github.com/smilecs/foody/config"
 	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/pubsub"
 	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
 )
@@ -31,13 +33,30 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	title := r.FormValue("title")
 	body := r.FormValue("body")
-	tags := r.FormValue("tags")
+	tags, err := parseTags(r.FormValue("tags"), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
 	}
+	userID := user.Id
+
+	post := schema.Post{
+		Id:       uuid.New(),
+		Title:    title,
+		Body:     body,
+		Tags:     tags,
+		AuthorId: userID,
+	}
+	if !authz.Can(user, authz.Create, post) {
+		http.Error(w, "Unauthorized to create this post", http.StatusForbidden)
+		return
+	}
 
 	file, header, err := r.FormFile("media")
 	if err != nil {
@@ -69,7 +88,6 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	postID := uuid.New()
 	mediaID := uuid.New()
 
 	media := schema.Media{
@@ -85,20 +103,14 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post := schema.Post{
-		Id:       postID,
-		Title:    title,
-		Body:     body,
-		Tags:     tags,
-		MediaId:  mediaID,
-		AuthorId: userID,
-	}
+	post.MediaId = mediaID
 
 	err = h.Manager.PostRepo.CreatePost(post, mediaID, url)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error creating post: %v", err), http.StatusInternalServerError)
 		return
 	}
+	config.Get().Hub.Publish(pubsub.AuthorTopic(userID), pubsub.Event{Type: pubsub.EventPost, Data: post})
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(post)
@@ -126,6 +138,17 @@ func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ids := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].Id
+	}
+	reactions := reactionSummaries(h.Manager, r, schema.TargetPost, ids)
+	comments := commentCounts(h.Manager, schema.TargetPost, ids)
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].Id]
+		posts[i].CommentCount = comments[posts[i].Id]
+	}
+
 	// Get total count for pagination metadata
 	totalCount, err := h.Manager.PostRepo.GetTotalPostsCount()
 	if err != nil {
@@ -171,6 +194,10 @@ func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
+	if post != nil {
+		post.Reactions = reactionSummaries(h.Manager, r, schema.TargetPost, []uuid.UUID{postID})[postID]
+		post.CommentCount = commentCounts(h.Manager, schema.TargetPost, []uuid.UUID{postID})[postID]
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(post)
@@ -184,7 +211,7 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
@@ -198,18 +225,23 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Verify the post belongs to the user
 	existingPost, err := h.Manager.PostRepo.GetPostByID(postID)
-	if err != nil {
+	if err != nil || existingPost == nil {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
 
-	if existingPost.AuthorId != userID {
+	if !authz.Can(user, authz.Update, existingPost) {
 		http.Error(w, "Unauthorized to update this post", http.StatusForbidden)
 		return
 	}
 
 	post.Id = postID
-	post.AuthorId = userID
+	post.AuthorId = existingPost.AuthorId
+	post.Tags, err = parseTags(strings.Join(post.Tags, ","), post.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	err = h.Manager.PostRepo.UpdatePost(post)
 	if err != nil {
@@ -228,7 +260,7 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	user, ok := currentUser(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
@@ -236,12 +268,12 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 
 	// Verify the post belongs to the user
 	existingPost, err := h.Manager.PostRepo.GetPostByID(postID)
-	if err != nil {
+	if err != nil || existingPost == nil {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
 
-	if existingPost.AuthorId != userID {
+	if !authz.Can(user, authz.Delete, existingPost) {
 		http.Error(w, "Unauthorized to delete this post", http.StatusForbidden)
 		return
 	}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
 	"github.com/smilecs/foody/repository"
 )
 
@@ -77,6 +81,34 @@ func TestPostHandler_CreatePost(t *testing.T) {
 	}
 }
 
+func TestPostHandler_CreatePost_ForbiddenUploadsNothing(t *testing.T) {
+	manager := mockRepositoryManager()
+	handler := NewPostHandler(manager)
+
+	uploads := 0
+	originalUpload := data.UploadFileAndGetUrl
+	t.Cleanup(func() { data.UploadFileAndGetUrl = originalUpload })
+	data.UploadFileAndGetUrl = func(sess *session.Session, bucket, key string, file multipart.File, size int64, contentType string) (string, error) {
+		uploads++
+		return "https://test-bucket.s3.us-east-1.amazonaws.com/" + key, nil
+	}
+
+	// A caller without a user ID may not create posts
+	form := map[string]string{"title": "Test Post", "body": "Test content"}
+	req := setupMultipartRequest(t, http.MethodPost, "/posts", form, "media", "post.jpg", []byte("fake image content"))
+	req = setupTestContext(req, uuid.Nil)
+	w := httptest.NewRecorder()
+	handler.CreatePost(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	if uploads != 0 || len(mgr.Media) != 0 || len(mgr.Posts) != 0 {
+		t.Errorf("Expected nothing to be stored, got %d uploads, %d media and %d posts", uploads, len(mgr.Media), len(mgr.Posts))
+	}
+}
+
 func TestPostHandler_GetPosts(t *testing.T) {
 	// Setup
 	manager := mockRepositoryManager()
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	maxNameLength          = 255
+	maxBioLength           = 500
+	usernameChangeCooldown = 30 * 24 * time.Hour
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,30}$`)
+
+// PublicProfile is the part of a user's account visible to other users.
+type PublicProfile struct {
+	Id       uuid.UUID     `json:"user_id"`
+	Name     string        `json:"name"`
+	Username string        `json:"username"`
+	Bio      string        `json:"bio"`
+	Media    *schema.Media `json:"media,omitempty"`
+	// IsPrivate accounts approve each follower.
+	IsPrivate      bool `json:"is_private"`
+	FollowersCount int  `json:"followers_count"`
+	FollowingCount int  `json:"following_count"`
+	// FollowStatus is whether the viewer follows this user; empty if not.
+	FollowStatus schema.FollowStatus `json:"follow_status,omitempty"`
+}
+
+func newPublicProfile(user *schema.User) PublicProfile {
+	profile := PublicProfile{
+		Id:        user.Id,
+		Name:      user.Name,
+		Username:  user.Username,
+		Bio:       user.Bio,
+		IsPrivate: user.IsPrivate,
+	}
+	if user.Media.Id != uuid.Nil {
+		media := user.Media
+		profile.Media = &media
+	}
+	return profile
+}
+
+// loadUser fetches a user together with their profile image.
+func (u *UserHandler) loadUser(id uuid.UUID) (*schema.User, error) {
+	user, err := u.Manager.UserRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.MediaId != nil {
+		if media, err := u.Manager.MediaRepo.GetMediaByID(*user.MediaId); err == nil && media != nil {
+			user.Media = *media
+		}
+	}
+	return user, nil
+}
+
+func (u *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.loadUser(id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	profile := newPublicProfile(user)
+	counts, err := u.Manager.FollowRepo.GetFollowCounts(user.Id)
+	if err != nil {
+		http.Error(w, "Failed to count followers", http.StatusInternalServerError)
+		return
+	}
+	profile.FollowersCount = counts.Followers
+	profile.FollowingCount = counts.Following
+
+	if viewerID, ok := r.Context().Value("user_id").(uuid.UUID); ok {
+		if follow, err := u.Manager.FollowRepo.GetFollow(viewerID, user.Id); err == nil {
+			profile.FollowStatus = follow.Status
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (u *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.loadUser(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	counts, err := u.Manager.FollowRepo.GetFollowCounts(userID)
+	if err != nil {
+		http.Error(w, "Failed to count followers", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		*schema.User
+		*schema.FollowCounts
+	}{user, counts}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (u *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.UpdateProfileReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.loadUser(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			http.Error(w, "Name must be between 1 and 255 characters", http.StatusBadRequest)
+			return
+		}
+		user.Name = name
+	}
+
+	if req.Bio != nil {
+		if utf8.RuneCountInString(*req.Bio) > maxBioLength {
+			http.Error(w, fmt.Sprintf("Bio must be at most %d characters", maxBioLength), http.StatusBadRequest)
+			return
+		}
+		user.Bio = *req.Bio
+	}
+
+	if req.DOB != nil {
+		if *req.DOB != "" {
+			dob, err := time.Parse("2006-01-02", *req.DOB)
+			if err != nil || dob.After(time.Now()) {
+				http.Error(w, "Invalid date of birth", http.StatusBadRequest)
+				return
+			}
+		}
+		user.DOB = *req.DOB
+	}
+
+	wasPrivate := user.IsPrivate
+	if req.IsPrivate != nil {
+		user.IsPrivate = *req.IsPrivate
+	}
+
+	if err := u.Manager.UserRepo.UpdateProfile(*user); err != nil {
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+
+	// Nobody needs approval to follow a public account
+	if wasPrivate && !user.IsPrivate {
+		if err := u.Manager.FollowRepo.AcceptAllFollows(user.Id); err != nil {
+			http.Error(w, "Failed to accept follow requests", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// UpdateAvatar replaces the current user's profile image.
+func (u *UserHandler) UpdateAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("media")
+	if err != nil {
+		http.Error(w, "Missing Profile Image", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size == 0 {
+		http.Error(w, "Profile image cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	media, err := uploadMedia(u.Manager, file, header, "users/"+user.Username, schema.Image, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var previous uuid.UUID
+	if user.MediaId != nil {
+		previous = *user.MediaId
+	}
+	if err := u.Manager.UserRepo.UpdateAvatar(userID, media.Id); err != nil {
+		http.Error(w, "Failed to update profile image", http.StatusInternalServerError)
+		return
+	}
+
+	// The previous image is no longer used; failing to remove it should not
+	// fail the request
+	if previous != uuid.Nil && previous != media.Id {
+		u.deleteMedia(previous)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+// deleteMedia removes a media record and its stored file, logging any
+// failure. The record is kept if its file cannot be deleted.
+func (u *UserHandler) deleteMedia(mediaID uuid.UUID) {
+	media, err := u.Manager.MediaRepo.GetMediaByID(mediaID)
+	if err != nil || media == nil {
+		return
+	}
+
+	cfg := config.Get()
+	key, err := data.KeyFromURL(media.URL)
+	if err == nil {
+		err = data.DeleteFile(cfg.AWSSess, cfg.S3_Bucket, key)
+	}
+	if err != nil {
+		log.Printf("error deleting media file %s: %v\n", media.Id, err)
+		return
+	}
+
+	if err := u.Manager.MediaRepo.DeleteMedia(mediaID); err != nil {
+		log.Printf("error deleting media %s: %v\n", media.Id, err)
+	}
+}
+
+// UpdateUsername changes the current user's username. Usernames are unique
+// regardless of case and can only be changed once per cooldown period.
+func (u *UserHandler) UpdateUsername(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.UpdateUsernameReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !usernamePattern.MatchString(req.Username) {
+		http.Error(w, "Username must be 3-30 letters, digits, '_' or '.'", http.StatusBadRequest)
+		return
+	}
+
+	user, err := u.loadUser(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.Username == req.Username {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+		return
+	}
+
+	if user.UsernameChangedAt != nil {
+		if wait := time.Until(user.UsernameChangedAt.Add(usernameChangeCooldown)); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "Username was changed recently", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if existing, err := u.Manager.UserRepo.GetUserByUsername(req.Username); err == nil && existing.Id != userID {
+		http.Error(w, "Username is already taken", http.StatusConflict)
+		return
+	}
+
+	err = u.Manager.UserRepo.UpdateUsername(userID, req.Username)
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		http.Error(w, "Username is already taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update username", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	user.Username = req.Username
+	user.UsernameChangedAt = &now
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestUserHandler_GetUser(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "profile@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[user.Id].Password = "hashed-secret"
+
+	// Test cases
+	tests := []struct {
+		name           string
+		userID         string
+		expectedStatus int
+	}{
+		{
+			name:           "Get existing user",
+			userID:         user.Id.String(),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Get non-existent user",
+			userID:         uuid.New().String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid user ID",
+			userID:         "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/users/"+tt.userID, nil)
+			req = setupURLParams(req, map[string]string{"id": tt.userID})
+			w := httptest.NewRecorder()
+
+			handler.GetUser(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			body := w.Body.String()
+			if strings.Contains(body, "hashed-secret") || strings.Contains(body, user.Email) {
+				t.Errorf("Public profile leaks private fields: %s", body)
+			}
+		})
+	}
+}
+
+func TestUserHandler_GetMe(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "me@example.com")
+	config.Get().DB.(*MockRepositoryManager).Users[user.Id].Password = "hashed-secret"
+
+	req := setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me", nil), user.Id)
+	w := httptest.NewRecorder()
+	handler.GetMe(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "hashed-secret") {
+		t.Error("Password hash must never be serialized")
+	}
+
+	var response schema.User
+	readResponseBody(t, w, &response)
+	if response.Email != user.Email {
+		t.Errorf("Expected email %q, got %q", user.Email, response.Email)
+	}
+	if response.Media.URL == "" {
+		t.Error("Expected profile image in response")
+	}
+}
+
+func TestUserHandler_UpdateMe(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "update@example.com")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		body           map[string]string
+		expectedStatus int
+	}{
+		{
+			name: "Valid profile update",
+			body: map[string]string{
+				"name":          "Updated Name",
+				"bio":           "I cook things",
+				"date_of_birth": "1985-05-05",
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Empty name",
+			body:           map[string]string{"name": "  "},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Bio too long",
+			body:           map[string]string{"bio": strings.Repeat("a", maxBioLength+1)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid date of birth",
+			body:           map[string]string{"date_of_birth": "05/05/1985"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodPatch, "/api/me", tt.body), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.UpdateMe(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	stored := config.Get().DB.(*MockRepositoryManager).Users[user.Id]
+	if stored.Name != "Updated Name" || stored.Bio != "I cook things" || stored.DOB != "1985-05-05" {
+		t.Errorf("Profile was not updated: %+v", stored)
+	}
+}
+
+func TestUserHandler_UpdateAvatar(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "avatar@example.com")
+	stored := config.Get().DB.(*MockRepositoryManager).Users[user.Id]
+	previous := *stored.MediaId
+	manager.MediaRepo.CreateMedia(schema.Media{
+		Id:       previous,
+		URL:      "https://test-bucket.s3.us-east-1.amazonaws.com/users/avatar/old.jpg",
+		AuthorId: user.Id,
+	})
+
+	var deletedKeys []string
+	originalDeleteFile := data.DeleteFile
+	t.Cleanup(func() { data.DeleteFile = originalDeleteFile })
+	data.DeleteFile = func(sess *session.Session, bucket, key string) error {
+		deletedKeys = append(deletedKeys, key)
+		return nil
+	}
+	originalUpload := data.UploadFileAndGetUrl
+	t.Cleanup(func() { data.UploadFileAndGetUrl = originalUpload })
+	data.UploadFileAndGetUrl = func(sess *session.Session, bucket, key string, file multipart.File, size int64, contentType string) (string, error) {
+		return "https://test-bucket.s3.us-east-1.amazonaws.com/" + key, nil
+	}
+
+	upload := func() schema.Media {
+		t.Helper()
+		req := setupMultipartRequest(t, http.MethodPut, "/api/me/avatar", nil, "media", "avatar.jpg", []byte("fake image content"))
+		w := httptest.NewRecorder()
+		handler.UpdateAvatar(w, setupTestContext(req, user.Id))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var media schema.Media
+		readResponseBody(t, w, &media)
+		return media
+	}
+
+	// Missing file is rejected
+	req := setupMultipartRequest(t, http.MethodPut, "/api/me/avatar", nil, "media", "avatar.jpg", nil)
+	req = setupTestContext(req, user.Id)
+	w := httptest.NewRecorder()
+	handler.UpdateAvatar(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	media := upload()
+	if *stored.MediaId != media.Id || media.Id == previous {
+		t.Error("Expected profile image to be replaced")
+	}
+	if media.AuthorId != user.Id || media.MediaType != schema.Image {
+		t.Errorf("Unexpected media record: %+v", media)
+	}
+
+	// The previous image and its file are removed
+	if old, _ := manager.MediaRepo.GetMediaByID(previous); old != nil {
+		t.Error("Expected the previous profile image to be deleted")
+	}
+	if len(deletedKeys) != 1 || deletedKeys[0] != "users/avatar/old.jpg" {
+		t.Errorf("Expected the previous image file to be deleted, got %v", deletedKeys)
+	}
+	if current, _ := manager.MediaRepo.GetMediaByID(media.Id); current == nil {
+		t.Error("Expected the new profile image to be kept")
+	}
+
+	// Uploading a file with the same name again only removes the file of
+	// the image it replaces
+	deletedKeys = nil
+	replacement := upload()
+	firstKey, _ := data.KeyFromURL(media.URL)
+	replacementKey, _ := data.KeyFromURL(replacement.URL)
+	if firstKey == replacementKey {
+		t.Fatalf("Expected uploads with the same name to get their own keys, both got %q", firstKey)
+	}
+	if len(deletedKeys) != 1 || deletedKeys[0] != firstKey {
+		t.Errorf("Expected only %q to be deleted, got %v", firstKey, deletedKeys)
+	}
+}
+
+func TestUserHandler_UpdateUsername(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "rename@example.com")
+	loginTestUser(t, manager, handler, "taken@example.com")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		username       string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid username",
+			username:       "no spaces!",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Username taken regardless of case",
+			username:       "TAKEN",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Valid username change",
+			username:       "new_name",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Second change within cooldown",
+			username:       "another_name",
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]string{"username": tt.username}
+			req := setupTestContext(setupTestRequest(t, http.MethodPut, "/api/me/username", body), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.UpdateUsername(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if stored := config.Get().DB.(*MockRepositoryManager).Users[user.Id]; stored.Username != "new_name" {
+		t.Errorf("Expected username 'new_name', got '%s'", stored.Username)
+	}
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/notify"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+const defaultReactorsPageSize = 20
+
+// ReactionHandler serves reacting to posts and recipes and listing who
+// reacted. Its handlers are built per target type.
+type ReactionHandler struct {
+	Manager *repository.Manager
+}
+
+func NewReactionHandler(manager *repository.Manager) *ReactionHandler {
+	return &ReactionHandler{
+		Manager: manager,
+	}
+}
+
+// React adds the reaction in the URL to the item for the current user and
+// returns the item's updated reactions. Reacting twice changes nothing.
+func (h *ReactionHandler) React(target schema.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		interaction, ok := h.interaction(w, r, target)
+		if !ok {
+			return
+		}
+
+		added, err := h.Manager.InteractionRepo.AddReaction(interaction)
+		if err != nil {
+			http.Error(w, "Failed to add reaction", http.StatusInternalServerError)
+			return
+		}
+		if added {
+			h.notifyOwner(interaction)
+		}
+		h.writeSummary(w, interaction)
+	}
+}
+
+// Unreact takes back the reaction in the URL and returns the item's
+// updated reactions. Taking back a reaction that was never left changes
+// nothing.
+func (h *ReactionHandler) Unreact(target schema.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		interaction, ok := h.interaction(w, r, target)
+		if !ok {
+			return
+		}
+
+		if err := h.Manager.InteractionRepo.RemoveReaction(interaction); err != nil {
+			http.Error(w, "Failed to remove reaction", http.StatusInternalServerError)
+			return
+		}
+		h.writeSummary(w, interaction)
+	}
+}
+
+// GetReactions returns the item's reactions together with a page of the
+// users who reacted, newest first. The reaction query parameter narrows the
+// list to one kind of reaction.
+func (h *ReactionHandler) GetReactions(target schema.TargetType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("user_id").(uuid.UUID)
+		if !ok {
+			http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		targetID, ok := h.targetID(w, r, target)
+		if !ok {
+			return
+		}
+
+		reaction := schema.Reaction(r.URL.Query().Get("reaction"))
+		if reaction != "" && !reaction.Valid() {
+			http.Error(w, "Unknown reaction", http.StatusBadRequest)
+			return
+		}
+
+		limit, offset := pageParams(r, defaultReactorsPageSize)
+		summaries, err := h.Manager.InteractionRepo.GetReactionSummaries(target, []uuid.UUID{targetID}, userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch reactions", http.StatusInternalServerError)
+			return
+		}
+		users, err := h.Manager.InteractionRepo.GetReactors(target, targetID, reaction, limit, offset)
+		if err != nil {
+			http.Error(w, "Failed to fetch reactions", http.StatusInternalServerError)
+			return
+		}
+
+		summary := summaries[targetID]
+		total := summary.Total()
+		if reaction != "" {
+			total = summary.Counts[reaction]
+		}
+
+		response := struct {
+			Reactions  *schema.ReactionSummary `json:"reactions"`
+			Users      []schema.Reactor        `json:"users"`
+			Pagination Pagination              `json:"pagination"`
+		}{
+			Reactions:  summary,
+			Users:      users,
+			Pagination: Pagination{Total: total, Limit: limit, Offset: offset},
+		}
+		if response.Users == nil {
+			response.Users = []schema.Reactor{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// interaction reads the current user, the item and the reaction of a
+// request to react, writing an error response if any is missing.
+func (h *ReactionHandler) interaction(w http.ResponseWriter, r *http.Request, target schema.TargetType) (schema.Interaction, bool) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return schema.Interaction{}, false
+	}
+
+	reaction := schema.Reaction(chi.URLParam(r, "reaction"))
+	if !reaction.Valid() {
+		http.Error(w, "Unknown reaction", http.StatusBadRequest)
+		return schema.Interaction{}, false
+	}
+
+	targetID, ok := h.targetID(w, r, target)
+	if !ok {
+		return schema.Interaction{}, false
+	}
+
+	return schema.Interaction{UserId: userID, TargetType: target, TargetId: targetID, Reaction: reaction}, true
+}
+
+// targetID reads the item in the URL and checks that it exists.
+func (h *ReactionHandler) targetID(w http.ResponseWriter, r *http.Request, target schema.TargetType) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid "+string(target)+" ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	if findTarget(h.Manager, target, id) == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// findTarget loads the post or recipe that reactions and comments are
+// attached to. It returns nil if there is no such item.
+func findTarget(manager *repository.Manager, target schema.TargetType, id uuid.UUID) authz.Resource {
+	switch target {
+	case schema.TargetPost:
+		if post, err := manager.PostRepo.GetPostByID(id); err == nil && post != nil {
+			return post
+		}
+	case schema.TargetRecipe:
+		if recipe, err := manager.RecipeRepo.GetRecipeByID(id); err == nil && recipe != nil {
+			return recipe
+		}
+	}
+	return nil
+}
+
+// notifyOwner tells the owner of the item about a new reaction.
+func (h *ReactionHandler) notifyOwner(interaction schema.Interaction) {
+	item := findTarget(h.Manager, interaction.TargetType, interaction.TargetId)
+	if item == nil {
+		return
+	}
+
+	event := notify.Event{
+		Type:        schema.NotifyReaction,
+		RecipientId: item.OwnerID(),
+		ActorId:     interaction.UserId,
+		TargetType:  interaction.TargetType,
+		TargetId:    interaction.TargetId,
+	}
+	if interaction.Reaction == schema.ReactionLike {
+		event.Type = schema.NotifyLike
+	}
+	notify.New(h.Manager).Notify(event)
+}
+
+func (h *ReactionHandler) writeSummary(w http.ResponseWriter, interaction schema.Interaction) {
+	summaries, err := h.Manager.InteractionRepo.GetReactionSummaries(interaction.TargetType, []uuid.UUID{interaction.TargetId}, interaction.UserId)
+	if err != nil {
+		http.Error(w, "Failed to fetch reactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries[interaction.TargetId])
+}
+
+// reactionSummaries loads the reactions on the given items as seen by the
+// current user. Reactions only decorate a listing, so a failure is logged
+// and the items are served without them.
+func reactionSummaries(manager *repository.Manager, r *http.Request, target schema.TargetType, ids []uuid.UUID) map[uuid.UUID]*schema.ReactionSummary {
+	viewerID, _ := r.Context().Value("user_id").(uuid.UUID)
+	summaries, err := manager.InteractionRepo.GetReactionSummaries(target, ids, viewerID)
+	if err != nil {
+		log.Printf("error loading reactions: %v\n", err)
+		return nil
+	}
+	return summaries
+}
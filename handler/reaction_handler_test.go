@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// reactionRequest calls fn as userID for the item and reaction given.
+func reactionRequest(t *testing.T, fn http.HandlerFunc, method string, userID uuid.UUID, targetID, reaction string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := setupTestRequest(t, method, "/reactions", nil)
+	req = setupURLParams(setupTestContext(req, userID), map[string]string{"id": targetID, "reaction": reaction})
+	w := httptest.NewRecorder()
+	fn(w, req)
+	return w
+}
+
+func TestReactionHandler_React(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewReactionHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "fan@example.com")
+	postID := createFeedPost(t, manager, uuid.New(), time.Now(), nil)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		method         string
+		targetID       string
+		reaction       string
+		expectedStatus int
+		expectedLikes  int
+		expectedLiked  bool
+	}{
+		{
+			name:           "Like post",
+			method:         http.MethodPut,
+			targetID:       postID.String(),
+			reaction:       "like",
+			expectedStatus: http.StatusOK,
+			expectedLikes:  1,
+			expectedLiked:  true,
+		},
+		{
+			name:           "Like post again",
+			method:         http.MethodPut,
+			targetID:       postID.String(),
+			reaction:       "like",
+			expectedStatus: http.StatusOK,
+			expectedLikes:  1,
+			expectedLiked:  true,
+		},
+		{
+			name:           "Emoji reaction keeps like",
+			method:         http.MethodPut,
+			targetID:       postID.String(),
+			reaction:       "yum",
+			expectedStatus: http.StatusOK,
+			expectedLikes:  1,
+			expectedLiked:  true,
+		},
+		{
+			name:           "Unlike post",
+			method:         http.MethodDelete,
+			targetID:       postID.String(),
+			reaction:       "like",
+			expectedStatus: http.StatusOK,
+			expectedLikes:  0,
+		},
+		{
+			name:           "Unlike post again",
+			method:         http.MethodDelete,
+			targetID:       postID.String(),
+			reaction:       "like",
+			expectedStatus: http.StatusOK,
+			expectedLikes:  0,
+		},
+		{
+			name:           "Unknown reaction",
+			method:         http.MethodPut,
+			targetID:       postID.String(),
+			reaction:       "meh",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid post ID",
+			method:         http.MethodPut,
+			targetID:       "not-a-uuid",
+			reaction:       "like",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Non-existent post",
+			method:         http.MethodPut,
+			targetID:       uuid.New().String(),
+			reaction:       "like",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := handler.React(schema.TargetPost)
+			if tt.method == http.MethodDelete {
+				fn = handler.Unreact(schema.TargetPost)
+			}
+			w := reactionRequest(t, fn, tt.method, user.Id, tt.targetID, tt.reaction)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var summary schema.ReactionSummary
+			readResponseBody(t, w, &summary)
+			if summary.Likes != tt.expectedLikes || summary.LikedByMe != tt.expectedLiked {
+				t.Errorf("Expected %d likes (liked by me: %v), got %+v", tt.expectedLikes, tt.expectedLiked, summary)
+			}
+		})
+	}
+
+	summary := reactionSummaries(manager, setupTestContext(setupTestRequest(t, http.MethodGet, "/", nil), user.Id), schema.TargetPost, []uuid.UUID{postID})[postID]
+	if summary.Counts[schema.ReactionYum] != 1 || len(summary.MyReactions) != 1 {
+		t.Errorf("Expected only the yum reaction to remain, got %+v", summary)
+	}
+}
+
+func TestReactionHandler_GetReactions(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewReactionHandler(manager)
+	viewer, _ := loginTestUser(t, manager, userHandler, "viewer@example.com")
+	fans := []schema.User{}
+	for _, email := range []string{"fan1@example.com", "fan2@example.com", "fan3@example.com"} {
+		fan, _ := loginTestUser(t, manager, userHandler, email)
+		fans = append(fans, fan)
+	}
+
+	recipe := schema.Recipe{Id: uuid.New(), Title: "Pancakes", AuthorId: viewer.Id}
+	manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), "https://test-bucket.s3.amazonaws.com/pancakes.jpg")
+	for _, fan := range fans {
+		reactionRequest(t, handler.React(schema.TargetRecipe), http.MethodPut, fan.Id, recipe.Id.String(), "like")
+	}
+	reactionRequest(t, handler.React(schema.TargetRecipe), http.MethodPut, fans[0].Id, recipe.Id.String(), "love")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedUsers  int
+		expectedTotal  int
+	}{
+		{
+			name:           "All reactions",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedUsers:  4,
+			expectedTotal:  4,
+		},
+		{
+			name:           "One kind of reaction",
+			query:          "?reaction=love",
+			expectedStatus: http.StatusOK,
+			expectedUsers:  1,
+			expectedTotal:  1,
+		},
+		{
+			name:           "Paginated",
+			query:          "?reaction=like&limit=2&offset=2",
+			expectedStatus: http.StatusOK,
+			expectedUsers:  1,
+			expectedTotal:  3,
+		},
+		{
+			name:           "Unknown reaction",
+			query:          "?reaction=meh",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/recipes/"+recipe.Id.String()+"/reactions"+tt.query, nil)
+			req = setupURLParams(setupTestContext(req, viewer.Id), map[string]string{"id": recipe.Id.String()})
+			w := httptest.NewRecorder()
+
+			handler.GetReactions(schema.TargetRecipe)(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Reactions  schema.ReactionSummary `json:"reactions"`
+				Users      []schema.Reactor       `json:"users"`
+				Pagination Pagination             `json:"pagination"`
+			}
+			readResponseBody(t, w, &response)
+			if len(response.Users) != tt.expectedUsers || response.Pagination.Total != tt.expectedTotal {
+				t.Errorf("Expected %d of %d users, got %d of %d", tt.expectedUsers, tt.expectedTotal, len(response.Users), response.Pagination.Total)
+			}
+			if response.Reactions.Likes != 3 || response.Reactions.LikedByMe {
+				t.Errorf("Unexpected reactions: %+v", response.Reactions)
+			}
+			if response.Users[0].Username == "" {
+				t.Error("Expected reactors to carry their username")
+			}
+		})
+	}
+}
+
+func TestRecipeHandler_GetRecipeByID_Reactions(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewRecipeHandler(manager)
+	user, _ := loginTestUser(t, manager, userHandler, "liker@example.com")
+
+	recipe := schema.Recipe{Id: uuid.New(), Title: "Soup", AuthorId: uuid.New()}
+	manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), "https://test-bucket.s3.amazonaws.com/soup.jpg")
+	reactionRequest(t, NewReactionHandler(manager).React(schema.TargetRecipe), http.MethodPut, user.Id, recipe.Id.String(), "like")
+
+	req := setupTestRequest(t, http.MethodGet, "/api/recipes/"+recipe.Id.String(), nil)
+	req = setupURLParams(setupTestContext(req, user.Id), map[string]string{"id": recipe.Id.String()})
+	w := httptest.NewRecorder()
+	handler.GetRecipeByID(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response repository.RecipeWithMedia
+	readResponseBody(t, w, &response)
+	if response.Reactions == nil || response.Reactions.Likes != 1 || !response.Reactions.LikedByMe {
+		t.Errorf("Expected recipe to carry the viewer's like, got %+v", response.Reactions)
+	}
+}
@@ -2,13 +2,23 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/authz"
+	"github.com/smilecs/foody/ingredients"
 	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/routes/requests"
 	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/units"
 )
 
 type RecipeHandler struct {
@@ -20,24 +30,39 @@ func NewRecipeHandler(manager *repository.Manager) *RecipeHandler {
 }
 
 func (h *RecipeHandler) CreateRecipe(w http.ResponseWriter, r *http.Request) {
-	var recipe schema.Recipe
-	if err := json.NewDecoder(r.Body).Decode(&recipe); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	recipe, err := decodeRecipe(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	// Generate UUID for recipe
+	// Generate UUID for recipe and set the author
 	recipe.Id = uuid.New()
+	recipe.AuthorId = user.Id
+	if !authz.Can(user, authz.Create, recipe) {
+		http.Error(w, "Unauthorized to create this recipe", http.StatusForbidden)
+		return
+	}
 
 	// Handle media upload if present
 	var mediaID uuid.UUID
 	var mediaURL string
 	if recipe.MediaId != uuid.Nil {
 		media, err := h.Manager.MediaRepo.GetMediaByID(recipe.MediaId)
-		if err != nil {
+		if err != nil || media == nil {
 			http.Error(w, "Media not found", http.StatusNotFound)
 			return
 		}
+		if !authz.Can(user, authz.Update, media) {
+			http.Error(w, "Unauthorized to use this media", http.StatusForbidden)
+			return
+		}
 		mediaID = media.Id
 		mediaURL = media.URL
 	}
@@ -70,15 +95,29 @@ func (h *RecipeHandler) GetRecipes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	system, err := unitSystemParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	recipes, err := h.Manager.RecipeRepo.GetRecipes(limit, offset)
 	if err != nil {
 		http.Error(w, "Failed to get recipes", http.StatusInternalServerError)
 		return
 	}
+	h.attachActivity(r, recipes)
+	convertRecipes(recipes, system)
 
 	json.NewEncoder(w).Encode(recipes)
 }
 
+// maxScaledServings caps how many servings a recipe can be scaled to.
+const maxScaledServings = 100
+
+// GetRecipeByID returns a recipe. With ?servings= its ingredients are
+// scaled to make that many servings, and with ?units= given in metric or
+// imperial units.
 func (h *RecipeHandler) GetRecipeByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
@@ -87,11 +126,43 @@ func (h *RecipeHandler) GetRecipeByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	servings, err := servingsParam(r.URL.Query().Get("servings"), "servings")
+	if err == nil && servings != nil && *servings > maxScaledServings {
+		err = fmt.Errorf("servings cannot be more than %d", maxScaledServings)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	system, err := unitSystemParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	recipe, err := h.Manager.RecipeRepo.GetRecipeByID(id)
 	if err != nil {
 		http.Error(w, "Recipe not found", http.StatusNotFound)
 		return
 	}
+	if recipe != nil {
+		recipe.Reactions = reactionSummaries(h.Manager, r, schema.TargetRecipe, []uuid.UUID{id})[id]
+		recipe.CommentCount = commentCounts(h.Manager, schema.TargetRecipe, []uuid.UUID{id})[id]
+	}
+
+	// ?servings= and ?units= rework a copy of the ingredients
+	if recipe != nil && (servings != nil || system != 0) {
+		rendered := *recipe
+		if servings != nil {
+			var scaling units.Scaling
+			rendered.Recipe, scaling = units.ScaleRecipe(rendered.Recipe, *servings)
+			rendered.Scaling = &scaling
+		}
+		if system != 0 {
+			rendered.Recipe = units.ConvertRecipe(rendered.Recipe, system)
+		}
+		recipe = &rendered
+	}
 
 	json.NewEncoder(w).Encode(recipe)
 }
@@ -104,11 +175,19 @@ func (h *RecipeHandler) GetRecipesByAuthorID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	system, err := unitSystemParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	recipes, err := h.Manager.RecipeRepo.GetRecipesByAuthorID(authorID)
 	if err != nil {
 		http.Error(w, "Failed to get recipes", http.StatusInternalServerError)
 		return
 	}
+	h.attachActivity(r, recipes)
+	convertRecipes(recipes, system)
 
 	json.NewEncoder(w).Encode(recipes)
 }
@@ -121,13 +200,32 @@ func (h *RecipeHandler) UpdateRecipe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var recipe schema.Recipe
-	if err := json.NewDecoder(r.Body).Decode(&recipe); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Verify the caller may edit the recipe
+	existingRecipe, err := h.Manager.RecipeRepo.GetRecipeByID(id)
+	if err != nil || existingRecipe == nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	if !authz.Can(user, authz.Update, existingRecipe) {
+		http.Error(w, "Unauthorized to update this recipe", http.StatusForbidden)
+		return
+	}
+
+	recipe, err := decodeRecipe(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	recipe.Id = id
+	recipe.AuthorId = existingRecipe.AuthorId
 	if err := h.Manager.RecipeRepo.UpdateRecipe(recipe); err != nil {
 		http.Error(w, "Failed to update recipe", http.StatusInternalServerError)
 		return
@@ -145,6 +243,24 @@ func (h *RecipeHandler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	// Verify the caller may delete the recipe
+	existingRecipe, err := h.Manager.RecipeRepo.GetRecipeByID(id)
+	if err != nil || existingRecipe == nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	if !authz.Can(user, authz.Delete, existingRecipe) {
+		http.Error(w, "Unauthorized to delete this recipe", http.StatusForbidden)
+		return
+	}
+
 	if err := h.Manager.RecipeRepo.DeleteRecipe(id); err != nil {
 		http.Error(w, "Failed to delete recipe", http.StatusInternalServerError)
 		return
@@ -152,3 +268,388 @@ func (h *RecipeHandler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+const (
+	defaultRecipeSearchPageSize = 20
+	maxRecipeSearchQueryLength  = 200
+	// maxIngredientFilters caps the ingredients one search can include or
+	// exclude.
+	maxIngredientFilters = 10
+)
+
+// RecipeSearchPage is a page of recipe search results.
+type RecipeSearchPage struct {
+	Results    []repository.RecipeSearchResult `json:"results"`
+	Pagination Pagination                      `json:"pagination"`
+}
+
+// SearchRecipes finds recipes by text in their title, description,
+// ingredients and steps, most relevant first. The results can be narrowed
+// with ?include= and ?exclude= ingredient lists, ?max_total_time= in
+// minutes, ?min_servings=, ?max_servings= and ?author=.
+func (h *RecipeHandler) SearchRecipes(w http.ResponseWriter, r *http.Request) {
+	search, err := parseRecipeSearch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := pageParams(r, defaultRecipeSearchPageSize)
+	results, err := h.Manager.RecipeRepo.SearchRecipes(search, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to search recipes", http.StatusInternalServerError)
+		return
+	}
+	total, err := h.Manager.RecipeRepo.CountRecipeSearch(search)
+	if err != nil {
+		http.Error(w, "Failed to count recipes", http.StatusInternalServerError)
+		return
+	}
+
+	if results == nil {
+		results = []repository.RecipeSearchResult{}
+	}
+	ids := make([]uuid.UUID, len(results))
+	for i := range results {
+		ids[i] = results[i].Id
+	}
+	reactions := reactionSummaries(h.Manager, r, schema.TargetRecipe, ids)
+	comments := commentCounts(h.Manager, schema.TargetRecipe, ids)
+	for i := range results {
+		results[i].Reactions = reactions[results[i].Id]
+		results[i].CommentCount = comments[results[i].Id]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecipeSearchPage{
+		Results:    results,
+		Pagination: Pagination{Total: total, Limit: limit, Offset: offset},
+	})
+}
+
+// parseRecipeSearch reads a recipe search from the query parameters.
+func parseRecipeSearch(r *http.Request) (repository.RecipeSearch, error) {
+	query := r.URL.Query()
+	search := repository.RecipeSearch{Query: strings.TrimSpace(query.Get("q"))}
+	if utf8.RuneCountInString(search.Query) > maxRecipeSearchQueryLength {
+		return search, fmt.Errorf("Search query cannot be longer than %d characters", maxRecipeSearchQueryLength)
+	}
+
+	var err error
+	if search.IncludeIngredients, err = ingredientFilter(query.Get("include")); err != nil {
+		return search, err
+	}
+	if search.ExcludeIngredients, err = ingredientFilter(query.Get("exclude")); err != nil {
+		return search, err
+	}
+
+	if value := query.Get("max_total_time"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return search, errors.New("max_total_time must be a positive number of minutes")
+		}
+		maxTime := time.Duration(minutes) * time.Minute
+		search.MaxTotalTime = &maxTime
+	}
+
+	if search.MinServings, err = servingsParam(query.Get("min_servings"), "min_servings"); err != nil {
+		return search, err
+	}
+	if search.MaxServings, err = servingsParam(query.Get("max_servings"), "max_servings"); err != nil {
+		return search, err
+	}
+	if search.MinServings != nil && search.MaxServings != nil && *search.MinServings > *search.MaxServings {
+		return search, errors.New("min_servings cannot be greater than max_servings")
+	}
+
+	if value := query.Get("author"); value != "" {
+		authorID, err := uuid.Parse(value)
+		if err != nil {
+			return search, errors.New("Invalid author ID")
+		}
+		search.AuthorId = &authorID
+	}
+	return search, nil
+}
+
+// ingredientFilter splits a comma separated list of ingredients.
+func ingredientFilter(value string) ([]string, error) {
+	var ingredients []string
+	for _, ingredient := range strings.Split(value, ",") {
+		if ingredient = strings.ToLower(strings.TrimSpace(ingredient)); ingredient != "" {
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+	if len(ingredients) > maxIngredientFilters {
+		return nil, fmt.Errorf("Cannot filter on more than %d ingredients", maxIngredientFilters)
+	}
+	return ingredients, nil
+}
+
+func servingsParam(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	servings, err := strconv.Atoi(value)
+	if err != nil || servings <= 0 {
+		return nil, fmt.Errorf("%s must be a positive number", name)
+	}
+	return &servings, nil
+}
+
+const (
+	defaultPantryPageSize = 20
+	defaultMaxMissing     = 2
+	maxMissingLimit       = 5
+	maxPantryItems        = 50
+	// maxPantryCandidates caps how many recipes sharing an ingredient with
+	// the pantry are ranked.
+	maxPantryCandidates = 500
+)
+
+// PantryMatch is a recipe with the ingredients a pantry lacks for it.
+type PantryMatch struct {
+	repository.RecipeWithMedia
+	Missing         []string `json:"missing"`
+	MissingCount    int      `json:"missing_count"`
+	IngredientCount int      `json:"ingredient_count"`
+}
+
+// PantryMatchPage is a page of recipes that can be cooked from a pantry.
+type PantryMatchPage struct {
+	Results    []PantryMatch `json:"results"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// MatchPantry answers "what can I cook?": it takes the ingredients a user
+// has and lists the recipes they cover, those that can be made right away
+// first, then those missing up to max_missing ingredients along with what
+// is missing. Names are normalized, so "tomatoes" covers "tomato" and
+// "Cherry Tomatoes, halved".
+func (h *RecipeHandler) MatchPantry(w http.ResponseWriter, r *http.Request) {
+	var req requests.PantryMatchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Ingredients) > maxPantryItems {
+		http.Error(w, fmt.Sprintf("Cannot list more than %d ingredients", maxPantryItems), http.StatusBadRequest)
+		return
+	}
+	pantry := ingredients.NewPantry(req.Ingredients)
+	if len(pantry.Items()) == 0 {
+		http.Error(w, "List at least one ingredient", http.StatusBadRequest)
+		return
+	}
+	if req.Staples != nil {
+		pantry.Staples = *req.Staples
+	}
+
+	maxMissing := defaultMaxMissing
+	if req.MaxMissing != nil {
+		if *req.MaxMissing < 0 || *req.MaxMissing > maxMissingLimit {
+			http.Error(w, fmt.Sprintf("max_missing must be between 0 and %d", maxMissingLimit), http.StatusBadRequest)
+			return
+		}
+		maxMissing = *req.MaxMissing
+	}
+
+	candidates, err := h.Manager.RecipeRepo.GetRecipesByIngredientWords(pantry.Words(), maxPantryCandidates)
+	if err != nil {
+		http.Error(w, "Failed to match recipes", http.StatusInternalServerError)
+		return
+	}
+
+	matches := make([]PantryMatch, 0)
+	for _, candidate := range candidates {
+		missing := pantry.Missing(candidate.Names)
+		if len(missing) > maxMissing {
+			continue
+		}
+		match := PantryMatch{Missing: missing, MissingCount: len(missing), IngredientCount: len(candidate.Names)}
+		match.Id = candidate.RecipeId
+		matches = append(matches, match)
+	}
+
+	// Fewest missing first, then the recipes the pantry covers most of
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.MissingCount != b.MissingCount {
+			return a.MissingCount < b.MissingCount
+		}
+		return (a.IngredientCount-a.MissingCount)*b.IngredientCount > (b.IngredientCount-b.MissingCount)*a.IngredientCount
+	})
+
+	limit, offset := pageParams(r, defaultPantryPageSize)
+	page := make([]PantryMatch, 0, limit)
+	recipes := make([]repository.RecipeWithMedia, 0, limit)
+	for _, match := range matches[min(offset, len(matches)):min(offset+limit, len(matches))] {
+		recipe, err := h.Manager.RecipeRepo.GetRecipeByID(match.Id)
+		if err != nil || recipe == nil {
+			http.Error(w, "Failed to match recipes", http.StatusInternalServerError)
+			return
+		}
+		page = append(page, match)
+		recipes = append(recipes, *recipe)
+	}
+	h.attachActivity(r, recipes)
+	for i := range page {
+		page[i].RecipeWithMedia = recipes[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PantryMatchPage{
+		Results:    page,
+		Pagination: Pagination{Total: len(matches), Limit: limit, Offset: offset},
+	})
+}
+
+const (
+	// maxIngredientLines caps how many lines one ingredient list can have.
+	maxIngredientLines = 100
+	// maxIngredientLineLength caps the length of one ingredient line.
+	maxIngredientLineLength = 500
+)
+
+// IngredientLineError is an ingredient line that could not be parsed.
+// Line counts from 1.
+type IngredientLineError struct {
+	Line  int    `json:"line"`
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// ParsedIngredients are the ingredients read from a pasted list and the
+// lines that could not be read.
+type ParsedIngredients struct {
+	Ingredients []units.ParsedIngredient `json:"ingredients"`
+	Errors      []IngredientLineError    `json:"errors"`
+}
+
+// ParseIngredients splits a pasted ingredient list into names, quantities,
+// units and notes, so clients can fill in a recipe form. Blank lines and
+// section headers are skipped; other lines that cannot be read are listed
+// in errors.
+func (h *RecipeHandler) ParseIngredients(w http.ResponseWriter, r *http.Request) {
+	var req requests.ParseIngredientsReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := parseIngredientLines(strings.Split(req.Text, "\n"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(parsed)
+}
+
+// parseIngredientLines parses each of lines as an ingredient.
+func parseIngredientLines(lines []string) (*ParsedIngredients, error) {
+	if len(lines) > maxIngredientLines {
+		return nil, fmt.Errorf("Cannot parse more than %d ingredient lines", maxIngredientLines)
+	}
+
+	parsed := &ParsedIngredients{
+		Ingredients: []units.ParsedIngredient{},
+		Errors:      []IngredientLineError{},
+	}
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) > maxIngredientLineLength {
+			return nil, fmt.Errorf("Ingredient lines cannot be longer than %d characters", maxIngredientLineLength)
+		}
+		ingredient, err := units.ParseIngredient(line)
+		switch {
+		case errors.Is(err, units.ErrEmptyLine):
+		case err != nil:
+			parsed.Errors = append(parsed.Errors, IngredientLineError{Line: i + 1, Text: line, Error: err.Error()})
+		default:
+			parsed.Ingredients = append(parsed.Ingredients, ingredient)
+		}
+	}
+	return parsed, nil
+}
+
+// decodeRecipe reads a recipe from the request body, parsing its
+// ingredient lines and putting its units in canonical form.
+func decodeRecipe(r *http.Request) (schema.Recipe, error) {
+	var req requests.RecipeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return schema.Recipe{}, errors.New("Invalid request body")
+	}
+	recipe := req.Recipe
+
+	if len(req.IngredientLines) > 0 {
+		parsed, err := parseIngredientLines(req.IngredientLines)
+		if err != nil {
+			return recipe, err
+		}
+		if len(parsed.Errors) > 0 {
+			lineErr := parsed.Errors[0]
+			return recipe, fmt.Errorf("Invalid ingredient on line %d: %s", lineErr.Line, lineErr.Error)
+		}
+		for _, ingredient := range parsed.Ingredients {
+			recipe.Ingredients = append(recipe.Ingredients, ingredient.Ingredient())
+		}
+	}
+
+	if err := canonicalizeUnits(&recipe); err != nil {
+		return recipe, err
+	}
+	return recipe, nil
+}
+
+// canonicalizeUnits replaces the unit of every ingredient of recipe with
+// its canonical symbol, so "Grams" is stored as "g". Unknown units are an
+// error.
+func canonicalizeUnits(recipe *schema.Recipe) error {
+	for i, ingredient := range recipe.Ingredients {
+		unit, err := units.Canonical(ingredient.Unit)
+		if err != nil {
+			return fmt.Errorf("%v (ingredient %q)", err, ingredient.Name)
+		}
+		recipe.Ingredients[i].Unit = unit
+		recipe.Ingredients[i].Display = ""
+	}
+	return nil
+}
+
+// unitSystemParam reads the ?units= system recipes are shown in. Zero means
+// as written.
+func unitSystemParam(r *http.Request) (units.System, error) {
+	value := r.URL.Query().Get("units")
+	if value == "" {
+		return 0, nil
+	}
+	return units.ParseSystem(value)
+}
+
+// convertRecipes puts the ingredients of recipes in system, unless it is
+// zero.
+func convertRecipes(recipes []repository.RecipeWithMedia, system units.System) {
+	if system == 0 {
+		return
+	}
+	for i := range recipes {
+		recipes[i].Recipe = units.ConvertRecipe(recipes[i].Recipe, system)
+	}
+}
+
+// attachActivity fills in the reactions, as seen by the current user, and
+// comment counts of recipes.
+func (h *RecipeHandler) attachActivity(r *http.Request, recipes []repository.RecipeWithMedia) {
+	ids := make([]uuid.UUID, len(recipes))
+	for i := range recipes {
+		ids[i] = recipes[i].Id
+	}
+	reactions := reactionSummaries(h.Manager, r, schema.TargetRecipe, ids)
+	comments := commentCounts(h.Manager, schema.TargetRecipe, ids)
+	for i := range recipes {
+		recipes[i].Reactions = reactions[recipes[i].Id]
+		recipes[i].CommentCount = comments[recipes[i].Id]
+	}
+}
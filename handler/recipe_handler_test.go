@@ -1,19 +1,25 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/units"
 )
 
 func TestRecipeHandler_CreateRecipe(t *testing.T) {
 	// Setup
 	manager := mockRepositoryManager()
 	handler := NewRecipeHandler(manager)
+	userID := uuid.New()
 
 	// Test cases
 	tests := []struct {
@@ -63,6 +69,7 @@ func TestRecipeHandler_CreateRecipe(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create request
 			req := setupTestRequest(t, http.MethodPost, "/recipes", tt.recipe)
+			req = setupTestContext(req, userID)
 			w := httptest.NewRecorder()
 
 			// Execute request
@@ -213,3 +220,712 @@ func TestRecipeHandler_GetRecipesByAuthorID(t *testing.T) {
 		})
 	}
 }
+
+func TestRecipeHandler_SearchRecipes(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	authorID := uuid.New()
+	otherAuthorID := uuid.New()
+	minutes := func(m int) *time.Duration { d := time.Duration(m) * time.Minute; return &d }
+	servings := func(n int) *int { return &n }
+
+	recipes := []schema.Recipe{
+		{
+			Id:          uuid.New(),
+			Title:       "Tomato Basil Pasta",
+			Description: "A quick weeknight dinner",
+			Ingredients: []schema.Ingredient{{Name: "Spaghetti"}, {Name: "Cherry tomatoes"}, {Name: "Basil"}},
+			Steps:       []schema.Step{{Order: 1, Description: "Boil the pasta"}},
+			TotalTime:   minutes(25),
+			Servings:    servings(2),
+			AuthorId:    authorID,
+		},
+		{
+			Id:          uuid.New(),
+			Title:       "Pesto",
+			Description: "Goes well with pasta",
+			Ingredients: []schema.Ingredient{{Name: "Basil"}, {Name: "Pine nuts"}, {Name: "Parmesan"}},
+			TotalTime:   minutes(10),
+			Servings:    servings(4),
+			AuthorId:    otherAuthorID,
+		},
+		{
+			Id:          uuid.New(),
+			Title:       "Slow Roasted Tomatoes",
+			Description: "Sweet and jammy",
+			Ingredients: []schema.Ingredient{{Name: "Roma tomatoes"}, {Name: "Olive oil"}},
+			TotalTime:   minutes(180),
+			Servings:    servings(6),
+			AuthorId:    authorID,
+		},
+	}
+	for _, recipe := range recipes {
+		if err := manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), ""); err != nil {
+			t.Fatalf("Failed to create recipe: %v", err)
+		}
+	}
+	pasta, pesto, roasted := recipes[0].Id, recipes[1].Id, recipes[2].Id
+
+	// Test cases
+	tests := []struct {
+		name            string
+		query           string
+		expectedStatus  int
+		expectedRecipes []uuid.UUID
+	}{
+		{
+			name:            "Title matches rank first",
+			query:           "q=pasta",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{pasta, pesto},
+		},
+		{
+			name:            "Include ingredients",
+			query:           "include=basil,tomato",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{pasta},
+		},
+		{
+			name:            "Exclude ingredients",
+			query:           "q=pasta&exclude=Pine%20nuts",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{pasta},
+		},
+		{
+			name:            "Max total time",
+			query:           "q=tomato&max_total_time=60",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{pasta},
+		},
+		{
+			name:            "Servings range and author",
+			query:           "min_servings=2&max_servings=6&author=" + authorID.String() + "&q=tomatoes",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{roasted, pasta},
+		},
+		{
+			name:            "No matches",
+			query:           "q=chocolate",
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{},
+		},
+		{
+			name:           "Invalid max total time",
+			query:          "max_total_time=soon",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Inverted servings range",
+			query:          "min_servings=6&max_servings=2",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid author",
+			query:          "author=nobody",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too many ingredients",
+			query:          "include=a,b,c,d,e,f,g,h,i,j,k",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/recipes/search?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.SearchRecipes(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var page RecipeSearchPage
+			readResponseBody(t, w, &page)
+			if len(page.Results) != len(tt.expectedRecipes) || page.Pagination.Total != len(tt.expectedRecipes) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expectedRecipes), len(page.Results))
+			}
+			for i, result := range page.Results {
+				if result.Id != tt.expectedRecipes[i] {
+					t.Errorf("Expected result %d to be %s, got %s", i, tt.expectedRecipes[i], result.Title)
+				}
+			}
+		})
+	}
+
+	// Snippets highlight the search terms
+	req := setupTestRequest(t, http.MethodGet, "/api/recipes/search?q=jammy", nil)
+	w := httptest.NewRecorder()
+	handler.SearchRecipes(w, req)
+	var page RecipeSearchPage
+	readResponseBody(t, w, &page)
+	if len(page.Results) != 1 || !strings.Contains(page.Results[0].Snippet, "<mark>jammy</mark>") {
+		t.Errorf("Expected highlighted snippet, got %+v", page.Results)
+	}
+}
+
+func TestRecipeHandler_MatchPantry(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	authorID := uuid.New()
+	ingredients := func(names ...string) []schema.Ingredient {
+		var list []schema.Ingredient
+		for _, name := range names {
+			list = append(list, schema.Ingredient{Name: name})
+		}
+		return list
+	}
+
+	recipes := []schema.Recipe{
+		{Id: uuid.New(), Title: "Tomato Salad", Ingredients: ingredients("Cherry Tomatoes", "Olive oil", "Salt")},
+		{Id: uuid.New(), Title: "Tomato Pasta", Ingredients: ingredients("Spaghetti", "Tomatoes", "Garlic", "Basil")},
+		{Id: uuid.New(), Title: "Tomato Soup", Ingredients: ingredients("Tomato", "Onion", "Garlic", "Cream", "Stock")},
+		{Id: uuid.New(), Title: "Omelette", Ingredients: ingredients("Eggs", "Butter")},
+	}
+	for _, recipe := range recipes {
+		recipe.AuthorId = authorID
+		if err := manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), ""); err != nil {
+			t.Fatalf("Failed to create recipe: %v", err)
+		}
+	}
+	salad, pasta, soup := recipes[0].Id, recipes[1].Id, recipes[2].Id
+
+	// Test cases
+	tests := []struct {
+		name            string
+		body            interface{}
+		expectedStatus  int
+		expectedRecipes []uuid.UUID
+		expectedMissing [][]string
+	}{
+		{
+			name:            "Makeable first, then missing up to two",
+			body:            map[string]interface{}{"ingredients": []string{"tomatoes", "olive oil", "spaghetti"}},
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{salad, pasta},
+			expectedMissing: [][]string{{}, {"Garlic", "Basil"}},
+		},
+		{
+			name:            "Only makeable",
+			body:            map[string]interface{}{"ingredients": []string{"Tomato", "olive oil"}, "max_missing": 0},
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{salad},
+			expectedMissing: [][]string{{}},
+		},
+		{
+			name:            "Staples not assumed",
+			body:            map[string]interface{}{"ingredients": []string{"tomato", "olive oil"}, "max_missing": 0, "staples": false},
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{},
+		},
+		{
+			name:            "Ties go to the recipe covered most",
+			body:            map[string]interface{}{"ingredients": []string{"tomato", "garlic", "onion"}, "max_missing": 3},
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{salad, soup, pasta},
+			expectedMissing: [][]string{{"Olive oil"}, {"Cream", "Stock"}, {"Spaghetti", "Basil"}},
+		},
+		{
+			name:            "Nothing in common",
+			body:            map[string]interface{}{"ingredients": []string{"chocolate"}},
+			expectedStatus:  http.StatusOK,
+			expectedRecipes: []uuid.UUID{},
+		},
+		{
+			name:           "No ingredients",
+			body:           map[string]interface{}{"ingredients": []string{" "}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid max missing",
+			body:           map[string]interface{}{"ingredients": []string{"tomato"}, "max_missing": maxMissingLimit + 1},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too many ingredients",
+			body:           map[string]interface{}{"ingredients": make([]string, maxPantryItems+1)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid body",
+			body:           "tomato",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPost, "/api/recipes/match", tt.body)
+			w := httptest.NewRecorder()
+
+			handler.MatchPantry(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var page PantryMatchPage
+			readResponseBody(t, w, &page)
+			if len(page.Results) != len(tt.expectedRecipes) || page.Pagination.Total != len(tt.expectedRecipes) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expectedRecipes), len(page.Results))
+			}
+			for i, result := range page.Results {
+				if result.Id != tt.expectedRecipes[i] {
+					t.Errorf("Expected result %d to be %s, got %s", i, tt.expectedRecipes[i], result.Title)
+				}
+				if strings.Join(result.Missing, ",") != strings.Join(tt.expectedMissing[i], ",") || result.MissingCount != len(result.Missing) {
+					t.Errorf("Expected %s to miss %v, got %v", result.Title, tt.expectedMissing[i], result.Missing)
+				}
+			}
+		})
+	}
+}
+
+// failingRecipeLookup is a recipe repository whose lookups by ID fail.
+type failingRecipeLookup struct {
+	repository.RecipeRepositoryInterface
+}
+
+func (failingRecipeLookup) GetRecipeByID(id uuid.UUID) (*repository.RecipeWithMedia, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestRecipeHandler_MatchPantry_LookupFails(t *testing.T) {
+	manager := mockRepositoryManager()
+	recipe := schema.Recipe{Id: uuid.New(), Title: "Omelette", AuthorId: uuid.New(), Ingredients: []schema.Ingredient{{Name: "Eggs"}}}
+	if err := manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), ""); err != nil {
+		t.Fatalf("Failed to create recipe: %v", err)
+	}
+	manager.RecipeRepo = failingRecipeLookup{manager.RecipeRepo}
+	handler := NewRecipeHandler(manager)
+
+	req := setupTestRequest(t, http.MethodPost, "/api/recipes/match", map[string]interface{}{"ingredients": []string{"eggs"}})
+	w := httptest.NewRecorder()
+	handler.MatchPantry(w, req)
+
+	// A match that cannot be loaded fails the page rather than going
+	// missing from it while still being counted
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestRecipeHandler_GetScaledRecipe(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	servings := 4
+
+	scalable := schema.Recipe{
+		Id:       uuid.New(),
+		Title:    "Pancakes",
+		Servings: &servings,
+		Ingredients: []schema.Ingredient{
+			{Name: "Flour", Quantity: 2, Unit: "cups"},
+			{Name: "Sugar", Quantity: 3, Unit: "tbsp"},
+			{Name: "Eggs", Quantity: 2},
+		},
+		AuthorId: uuid.New(),
+	}
+	unscalable := scalable
+	unscalable.Id = uuid.New()
+	unscalable.Servings = nil
+	for _, recipe := range []schema.Recipe{scalable, unscalable} {
+		if err := manager.RecipeRepo.CreateRecipe(recipe, uuid.New(), ""); err != nil {
+			t.Fatalf("Failed to create recipe: %v", err)
+		}
+	}
+
+	// Test cases
+	tests := []struct {
+		name               string
+		recipeID           uuid.UUID
+		query              string
+		expectedStatus     int
+		expectedServings   int
+		expectedQuantities []string
+		expectedWarning    string
+	}{
+		{
+			name:               "Scaled up",
+			recipeID:           scalable.Id,
+			query:              "servings=8",
+			expectedStatus:     http.StatusOK,
+			expectedServings:   8,
+			expectedQuantities: []string{"4 cups", "6 tbsp", "4 "},
+		},
+		{
+			name:               "Scaled down",
+			recipeID:           scalable.Id,
+			query:              "servings=1",
+			expectedStatus:     http.StatusOK,
+			expectedServings:   1,
+			expectedQuantities: []string{"1/2 cups", "2 1/4 tsp", "1/2 "},
+		},
+		{
+			name:               "Growing into a larger unit",
+			recipeID:           scalable.Id,
+			query:              "servings=16",
+			expectedStatus:     http.StatusOK,
+			expectedServings:   16,
+			expectedQuantities: []string{"8 cups", "3/4 cup", "8 "},
+		},
+		{
+			name:               "No servings baseline",
+			recipeID:           unscalable.Id,
+			query:              "servings=8",
+			expectedStatus:     http.StatusOK,
+			expectedQuantities: []string{"2 cups", "3 tbsp", "2 "},
+			expectedWarning:    units.NoServingsWarning,
+		},
+		{
+			name:           "Invalid servings",
+			recipeID:       scalable.Id,
+			query:          "servings=none",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too many servings",
+			recipeID:       scalable.Id,
+			query:          "servings=101",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/recipes/"+tt.recipeID.String()+"?"+tt.query, nil)
+			req = setupURLParams(req, map[string]string{"id": tt.recipeID.String()})
+			w := httptest.NewRecorder()
+
+			handler.GetRecipeByID(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var recipe repository.RecipeWithMedia
+			readResponseBody(t, w, &recipe)
+			if recipe.Scaling == nil || recipe.Scaling.Warning != tt.expectedWarning {
+				t.Fatalf("Expected scaling with warning %q, got %+v", tt.expectedWarning, recipe.Scaling)
+			}
+			if tt.expectedServings != 0 && (recipe.Servings == nil || *recipe.Servings != tt.expectedServings) {
+				t.Errorf("Expected %d servings, got %v", tt.expectedServings, recipe.Servings)
+			}
+			for i, ingredient := range recipe.Ingredients {
+				quantity := ingredient.Display
+				if quantity == "" {
+					quantity = strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64)
+				}
+				if got := quantity + " " + ingredient.Unit; got != tt.expectedQuantities[i] {
+					t.Errorf("Expected %s to be %q, got %q", ingredient.Name, tt.expectedQuantities[i], got)
+				}
+			}
+		})
+	}
+
+	// The stored recipe is left as it was
+	stored, _ := manager.RecipeRepo.GetRecipeByID(scalable.Id)
+	if stored.Ingredients[0].Quantity != 2 || *stored.Servings != 4 {
+		t.Errorf("Expected stored recipe to be unchanged, got %+v", stored.Ingredients[0])
+	}
+}
+
+func TestRecipeHandler_RecipeUnits(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	userID := uuid.New()
+
+	createRecipe := func(ingredients []schema.Ingredient) *httptest.ResponseRecorder {
+		req := setupTestRequest(t, http.MethodPost, "/api/recipes", schema.Recipe{Title: "Cake", Ingredients: ingredients})
+		req = setupTestContext(req, userID)
+		w := httptest.NewRecorder()
+		handler.CreateRecipe(w, req)
+		return w
+	}
+
+	// Units are stored by their canonical symbol
+	w := createRecipe([]schema.Ingredient{
+		{Name: "All-purpose flour", Quantity: 2, Unit: "Cups"},
+		{Name: "Milk", Quantity: 1, Unit: "cup"},
+		{Name: "Vanilla extract", Quantity: 1, Unit: "Tbsp."},
+		{Name: "Eggs", Quantity: 2, Unit: ""},
+		{Name: "Garlic", Quantity: 2, Unit: "cloves"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created schema.Recipe
+	readResponseBody(t, w, &created)
+	for i, expected := range []string{"cup", "cup", "tbsp", "", "clove"} {
+		if created.Ingredients[i].Unit != expected {
+			t.Errorf("Expected unit %q, got %q", expected, created.Ingredients[i].Unit)
+		}
+	}
+
+	metricRecipe := schema.Recipe{
+		Id:          uuid.New(),
+		Title:       "Shortbread",
+		Ingredients: []schema.Ingredient{{Name: "Sugar", Quantity: 500, Unit: "g"}, {Name: "Butter", Quantity: 1, Unit: "kg"}},
+		AuthorId:    userID,
+	}
+	if err := manager.RecipeRepo.CreateRecipe(metricRecipe, uuid.New(), ""); err != nil {
+		t.Fatalf("Failed to create recipe: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name               string
+		recipeID           uuid.UUID
+		query              string
+		expectedStatus     int
+		expectedQuantities []string
+	}{
+		{
+			name:               "Metric",
+			recipeID:           created.Id,
+			query:              "units=metric",
+			expectedStatus:     http.StatusOK,
+			expectedQuantities: []string{"250 g", "235 ml", "1 tbsp", "2 ", "2 clove"},
+		},
+		{
+			name:               "Imperial",
+			recipeID:           metricRecipe.Id,
+			query:              "units=imperial",
+			expectedStatus:     http.StatusOK,
+			expectedQuantities: []string{"2 1/2 cup", "4 3/8 cup"},
+		},
+		{
+			name:               "Converted without a servings baseline",
+			recipeID:           metricRecipe.Id,
+			query:              "units=imperial&servings=2",
+			expectedStatus:     http.StatusOK,
+			expectedQuantities: []string{"2 1/2 cup", "4 3/8 cup"},
+		},
+		{
+			name:               "As written",
+			recipeID:           metricRecipe.Id,
+			expectedStatus:     http.StatusOK,
+			expectedQuantities: []string{"500 g", "1 kg"},
+		},
+		{
+			name:           "Unknown system",
+			recipeID:       metricRecipe.Id,
+			query:          "units=cubits",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/recipes/"+tt.recipeID.String()+"?"+tt.query, nil)
+			req = setupURLParams(req, map[string]string{"id": tt.recipeID.String()})
+			w := httptest.NewRecorder()
+
+			handler.GetRecipeByID(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var recipe repository.RecipeWithMedia
+			readResponseBody(t, w, &recipe)
+			for i, ingredient := range recipe.Ingredients {
+				quantity := ingredient.Display
+				if quantity == "" {
+					quantity = strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64)
+				}
+				if got := quantity + " " + ingredient.Unit; got != tt.expectedQuantities[i] {
+					t.Errorf("Expected %s to be %q, got %q", ingredient.Name, tt.expectedQuantities[i], got)
+				}
+			}
+		})
+	}
+
+	// Lists are converted too
+	req := setupTestRequest(t, http.MethodGet, "/api/recipes/author/"+userID.String()+"?units=imperial", nil)
+	req = setupURLParams(req, map[string]string{"author_id": userID.String()})
+	w = httptest.NewRecorder()
+	handler.GetRecipesByAuthorID(w, req)
+	var recipes []repository.RecipeWithMedia
+	readResponseBody(t, w, &recipes)
+	for _, recipe := range recipes {
+		if recipe.Id == metricRecipe.Id && recipe.Ingredients[0].Unit != "cup" {
+			t.Errorf("Expected sugar in cups, got %q", recipe.Ingredients[0].Unit)
+		}
+	}
+
+	// Unknown units are rejected
+	if w := createRecipe([]schema.Ingredient{{Name: "Flour", Quantity: 1, Unit: "smidgen"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	req = setupTestRequest(t, http.MethodPut, "/api/recipes/"+created.Id.String(), schema.Recipe{
+		Title:       "Cake",
+		Ingredients: []schema.Ingredient{{Name: "Flour", Quantity: 1, Unit: "smidgen"}},
+	})
+	req = setupURLParams(setupTestContext(req, userID), map[string]string{"id": created.Id.String()})
+	w = httptest.NewRecorder()
+	handler.UpdateRecipe(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRecipeHandler_ParseIngredients(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+
+	// Test cases
+	tests := []struct {
+		name                string
+		body                interface{}
+		expectedStatus      int
+		expectedIngredients []string
+		expectedErrorLines  []int
+	}{
+		{
+			name:                "Pasted list",
+			body:                map[string]string{"text": "For the dough:\n1 1/2 cups all-purpose flour, sifted\n\n2-3 cloves garlic\r\n½ tsp salt\n2 cups"},
+			expectedStatus:      http.StatusOK,
+			expectedIngredients: []string{"1.5 cup all-purpose flour", "2 clove garlic", "0.5 tsp salt"},
+			expectedErrorLines:  []int{6},
+		},
+		{
+			name:                "Empty text",
+			body:                map[string]string{"text": ""},
+			expectedStatus:      http.StatusOK,
+			expectedIngredients: []string{},
+			expectedErrorLines:  []int{},
+		},
+		{
+			name:           "Too many lines",
+			body:           map[string]string{"text": strings.Repeat("1 egg\n", maxIngredientLines)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Line too long",
+			body:           map[string]string{"text": "1 cup " + strings.Repeat("a", maxIngredientLineLength)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid body",
+			body:           []string{"1 egg"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPost, "/api/recipes/parse-ingredients", tt.body)
+			w := httptest.NewRecorder()
+
+			handler.ParseIngredients(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var parsed ParsedIngredients
+			readResponseBody(t, w, &parsed)
+			if len(parsed.Ingredients) != len(tt.expectedIngredients) {
+				t.Fatalf("Expected %d ingredients, got %d", len(tt.expectedIngredients), len(parsed.Ingredients))
+			}
+			for i, ingredient := range parsed.Ingredients {
+				got := strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64) + " " + ingredient.Unit + " " + ingredient.Name
+				if got != tt.expectedIngredients[i] {
+					t.Errorf("Expected ingredient %q, got %q", tt.expectedIngredients[i], got)
+				}
+			}
+			if len(parsed.Errors) != len(tt.expectedErrorLines) {
+				t.Fatalf("Expected %d errors, got %d", len(tt.expectedErrorLines), len(parsed.Errors))
+			}
+			for i, lineErr := range parsed.Errors {
+				if lineErr.Line != tt.expectedErrorLines[i] {
+					t.Errorf("Expected error on line %d, got line %d", tt.expectedErrorLines[i], lineErr.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestRecipeHandler_CreateRecipeFromIngredientLines(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewRecipeHandler(manager)
+	userID := uuid.New()
+
+	// Test cases
+	tests := []struct {
+		name                string
+		body                map[string]interface{}
+		expectedStatus      int
+		expectedIngredients []schema.Ingredient
+	}{
+		{
+			name: "Lines added after ingredients",
+			body: map[string]interface{}{
+				"title":            "Garlic Bread",
+				"ingredients":      []schema.Ingredient{{Name: "Baguette", Quantity: 1}},
+				"ingredient_lines": []string{"4 tbsp butter, softened", "", "2-3 cloves garlic, minced"},
+			},
+			expectedStatus: http.StatusCreated,
+			expectedIngredients: []schema.Ingredient{
+				{Name: "Baguette", Quantity: 1},
+				{Name: "butter, softened", Quantity: 4, Unit: "tbsp"},
+				{Name: "garlic, minced; up to 3", Quantity: 2, Unit: "clove"},
+			},
+		},
+		{
+			name: "Unreadable line",
+			body: map[string]interface{}{
+				"title":            "Garlic Bread",
+				"ingredient_lines": []string{"4 tbsp butter", "2 cups"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodPost, "/api/recipes", tt.body)
+			req = setupTestContext(req, userID)
+			w := httptest.NewRecorder()
+
+			handler.CreateRecipe(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			var recipe schema.Recipe
+			readResponseBody(t, w, &recipe)
+			stored, _ := manager.RecipeRepo.GetRecipeByID(recipe.Id)
+			if stored == nil || len(stored.Ingredients) != len(tt.expectedIngredients) {
+				t.Fatalf("Expected %d stored ingredients, got %+v", len(tt.expectedIngredients), stored)
+			}
+			for i, ingredient := range stored.Ingredients {
+				if ingredient != tt.expectedIngredients[i] {
+					t.Errorf("Expected ingredient %+v, got %+v", tt.expectedIngredients[i], ingredient)
+				}
+			}
+		})
+	}
+}
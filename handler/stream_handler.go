@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/pubsub"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	// streamKeepAlive is how often an idle stream sends a comment so
+	// proxies do not close the connection.
+	streamKeepAlive = 25 * time.Second
+	// maxStreamWatches caps how many items one stream can watch.
+	maxStreamWatches = 20
+	// maxStreamFollows caps how many followed accounts a stream receives
+	// new posts from.
+	maxStreamFollows = 5000
+)
+
+// StreamHandler pushes real-time events to connected clients as
+// Server-Sent Events.
+type StreamHandler struct {
+	Manager *repository.Manager
+	// KeepAlive is how often the stream pings the client and re-checks
+	// that the caller is still allowed to listen.
+	KeepAlive time.Duration
+}
+
+func NewStreamHandler(manager *repository.Manager) *StreamHandler {
+	return &StreamHandler{
+		Manager:   manager,
+		KeepAlive: streamKeepAlive,
+	}
+}
+
+// Stream sends the current user's notifications and the new posts of the
+// accounts they follow until the client disconnects. Clients add
+// ?watch=post:<id>,recipe:<id> to also receive new comments on the items
+// they have open. Accounts followed after connecting are picked up on
+// reconnect. The stream ends once the caller's credentials stop being valid.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	watched, err := parseWatches(r.URL.Query().Get("watch"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	followed, err := h.followedAuthors(userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch followed accounts", http.StatusInternalServerError)
+		return
+	}
+
+	topics := append([]string{pubsub.UserTopic(userID)}, watched...)
+	for _, authorID := range followed {
+		topics = append(topics, pubsub.AuthorTopic(authorID))
+	}
+	events, cancel := config.Get().Hub.Subscribe(topics...)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(h.KeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case now := <-keepAlive.C:
+			if !h.stillAuthorized(r, userID, now) {
+				return
+			}
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				log.Printf("error encoding %s event: %v\n", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// stillAuthorized repeats the checks AuthMiddleware made when the stream
+// connected, so a logout, revoked key, suspension or expired token also
+// ends streams that are already open.
+func (h *StreamHandler) stillAuthorized(r *http.Request, userID uuid.UUID, now time.Time) bool {
+	ctx := r.Context()
+	if expiresAt, ok := ctx.Value("token_expires_at").(time.Time); ok && !now.Before(expiresAt) {
+		return false
+	}
+
+	if apiKey, ok := ctx.Value("api_key").(*schema.APIKey); ok {
+		current, err := h.Manager.APIKeyRepo.GetAPIKeyByHash(apiKey.KeyHash)
+		if err != nil || current.Id != apiKey.Id || !current.Active(now) {
+			return false
+		}
+	}
+	if sessionID, ok := ctx.Value("session_id").(uuid.UUID); ok {
+		session, err := h.Manager.SessionRepo.GetSessionByID(sessionID)
+		if err != nil || session.UserId != userID || !session.Active(now) {
+			return false
+		}
+	}
+
+	user, err := h.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("error checking stream user %s: %v\n", userID, err)
+		return false
+	}
+	return user.SuspendedAt == nil && user.DeletionScheduledAt == nil
+}
+
+// followedAuthors returns the accounts userID follows, up to
+// maxStreamFollows.
+func (h *StreamHandler) followedAuthors(userID uuid.UUID) ([]uuid.UUID, error) {
+	var authors []uuid.UUID
+	for len(authors) < maxStreamFollows {
+		page, err := h.Manager.FollowRepo.GetFollowing(userID, maxPageSize, len(authors))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range page {
+			authors = append(authors, entry.UserId)
+		}
+		if len(page) < maxPageSize {
+			break
+		}
+	}
+	return authors, nil
+}
+
+// parseWatches turns a comma separated list of type:id items into their
+// topics.
+func parseWatches(watch string) ([]string, error) {
+	if watch == "" {
+		return nil, nil
+	}
+
+	items := strings.Split(watch, ",")
+	if len(items) > maxStreamWatches {
+		return nil, fmt.Errorf("Cannot watch more than %d items", maxStreamWatches)
+	}
+
+	topics := make([]string, 0, len(items))
+	for _, item := range items {
+		itemType, itemID, _ := strings.Cut(strings.TrimSpace(item), ":")
+		id, err := uuid.Parse(itemID)
+		target := schema.TargetType(itemType)
+		if err != nil || (target != schema.TargetPost && target != schema.TargetRecipe) {
+			return nil, fmt.Errorf("Invalid watch item: %s", item)
+		}
+		topics = append(topics, pubsub.ItemTopic(itemType, id))
+	}
+	return topics, nil
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/middleware"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// openStream connects userID to the event stream and returns a reader
+// positioned after the connected comment.
+func openStream(t *testing.T, handler *StreamHandler, userID uuid.UUID, query string) *bufio.Reader {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Stream(w, setupTestContext(r, userID))
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/api/stream" + query)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", contentType)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("Expected connected comment, got %q (%v)", line, err)
+	}
+	reader.ReadString('\n')
+	return reader
+}
+
+// readStreamEvent reads the next event from the stream and decodes its data
+// into v.
+func readStreamEvent(t *testing.T, reader *bufio.Reader, v interface{}) string {
+	t.Helper()
+	var eventType, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && eventType != "":
+			if err := json.Unmarshal([]byte(data), v); err != nil {
+				t.Fatalf("Failed to decode %s event: %v", eventType, err)
+			}
+			return eventType
+		}
+	}
+}
+
+func TestStreamHandler_Stream(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	followHandler := NewFollowHandler(manager)
+	reactionHandler := NewReactionHandler(manager)
+	commentHandler := NewCommentHandler(manager)
+	handler := NewStreamHandler(manager)
+	reader, _ := loginTestUser(t, manager, userHandler, "reader@example.com")
+	chef, _ := loginTestUser(t, manager, userHandler, "chef@example.com")
+	fan, _ := loginTestUser(t, manager, userHandler, "fan@example.com")
+	followRequest(t, followHandler.FollowUser, http.MethodPost, reader.Id, chef.Id.String())
+	readerPost := createFeedPost(t, manager, reader.Id, time.Now(), nil)
+	chefPost := createFeedPost(t, manager, chef.Id, time.Now(), nil)
+
+	stream := openStream(t, handler, reader.Id, "?watch=post:"+chefPost.String())
+
+	// A notification for the reader
+	reactionRequest(t, reactionHandler.React(schema.TargetPost), http.MethodPut, fan.Id, readerPost.String(), "like")
+	var notification schema.Notification
+	if event := readStreamEvent(t, stream, &notification); event != "notification" {
+		t.Fatalf("Expected notification event, got %s", event)
+	}
+	if notification.Message != "fan liked your post" {
+		t.Errorf("Unexpected notification message %q", notification.Message)
+	}
+
+	// A comment on a watched post, by someone else
+	createComment(t, commentHandler, fan.Id, chefPost, map[string]interface{}{"body": "Looks great"})
+	var comment schema.Comment
+	if event := readStreamEvent(t, stream, &comment); event != "comment" {
+		t.Fatalf("Expected comment event, got %s", event)
+	}
+	if comment.Body != "Looks great" || comment.TargetId != chefPost {
+		t.Errorf("Unexpected comment: %+v", comment)
+	}
+
+	// A new post by a followed account
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("title", "Fresh bread")
+	writer.WriteField("body", "Out of the oven")
+	part, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="media"; filename="bread.jpg"`},
+		"Content-Type":        {"image/jpeg"},
+	})
+	part.Write([]byte("fake image content"))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/posts", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	NewPostHandler(manager).CreatePost(w, setupTestContext(req, chef.Id))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var post schema.Post
+	if event := readStreamEvent(t, stream, &post); event != "post" {
+		t.Fatalf("Expected post event, got %s", event)
+	}
+	if post.Title != "Fresh bread" || post.AuthorId != chef.Id {
+		t.Errorf("Unexpected post: %+v", post)
+	}
+}
+
+func TestStreamHandler_InvalidWatch(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewStreamHandler(manager)
+	userID := uuid.New()
+
+	many := make([]string, maxStreamWatches+1)
+	for i := range many {
+		many[i] = "post:" + uuid.New().String()
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		watch          string
+		expectedStatus int
+	}{
+		{
+			name:           "Unknown item type",
+			watch:          "meal_plan:" + uuid.New().String(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid item ID",
+			watch:          "post:not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too many items",
+			watch:          strings.Join(many, ","),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/stream?watch="+tt.watch, nil)
+			w := httptest.NewRecorder()
+
+			handler.Stream(w, setupTestContext(req, userID))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestStreamHandler_EndsWhenAuthLost(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	userHandler := NewUserHandler(manager)
+	handler := NewStreamHandler(manager)
+	handler.KeepAlive = 10 * time.Millisecond
+	auth := middleware.AuthMiddleware(manager)
+
+	// Test cases
+	tests := []struct {
+		name  string
+		email string
+		// connect returns the bearer token the stream is opened with
+		connect func(t *testing.T, user schema.User, login LoginResponse) string
+		// revoke takes away the access the stream was opened with
+		revoke func(manager *repository.Manager, user schema.User)
+		// expire shortens the token lifetime seen by the stream
+		expire bool
+	}{
+		{
+			name:    "Session logged out",
+			email:   "logout@example.com",
+			connect: func(t *testing.T, user schema.User, login LoginResponse) string { return login.Token },
+			revoke: func(manager *repository.Manager, user schema.User) {
+				manager.SessionRepo.RevokeUserSessions(user.Id)
+			},
+		},
+		{
+			name:    "Account suspended",
+			email:   "suspended@example.com",
+			connect: func(t *testing.T, user schema.User, login LoginResponse) string { return login.Token },
+			revoke: func(manager *repository.Manager, user schema.User) {
+				manager.UserRepo.SetSuspended(user.Id, true)
+			},
+		},
+		{
+			name:  "API key revoked",
+			email: "apikey@example.com",
+			connect: func(t *testing.T, user schema.User, login LoginResponse) string {
+				return createTestAPIKey(t, userHandler, user.Id, nil).Key
+			},
+			revoke: func(manager *repository.Manager, user schema.User) {
+				keys, _ := manager.APIKeyRepo.GetAPIKeysByUserID(user.Id)
+				for _, key := range keys {
+					manager.APIKeyRepo.RevokeAPIKey(user.Id, key.Id)
+				}
+			},
+		},
+		{
+			name:    "Access token expired",
+			email:   "expired@example.com",
+			connect: func(t *testing.T, user schema.User, login LoginResponse) string { return login.Token },
+			expire:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, login := loginTestUser(t, manager, userHandler, tt.email)
+			token := tt.connect(t, user, login)
+
+			expiresAt := time.Now().Add(100 * time.Millisecond)
+			server := httptest.NewServer(auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.expire {
+					r = r.WithContext(context.WithValue(r.Context(), "token_expires_at", expiresAt))
+				}
+				handler.Stream(w, r)
+			})))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/stream", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to open stream: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+
+			// The stream stays open while the credentials are valid
+			reader := bufio.NewReader(resp.Body)
+			for _, want := range []string{": connected\n", "\n", ": keep-alive\n"} {
+				if line, err := reader.ReadString('\n'); err != nil || line != want {
+					t.Fatalf("Expected %q, got %q (%v)", want, line, err)
+				}
+			}
+
+			if tt.revoke != nil {
+				tt.revoke(manager, user)
+			}
+			if _, err := io.Copy(io.Discard, reader); err != nil {
+				t.Errorf("Expected the stream to end, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	defaultTagPostsPageSize  = 20
+	defaultTagSuggestions    = 10
+	maxTagSuggestions        = 20
+	defaultTrendingTagsCount = 10
+	defaultTrendingWindow    = 24 * time.Hour
+	maxTrendingWindow        = 7 * 24 * time.Hour
+)
+
+// hashtagPattern matches #tag where the # does not follow a word character,
+// so URL fragments and HTML entities are not taken for tags.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])#([\p{L}\p{N}_]+)`)
+
+// TagHandler serves posts by hashtag, tag autocomplete and trending tags.
+type TagHandler struct {
+	Manager *repository.Manager
+}
+
+func NewTagHandler(manager *repository.Manager) *TagHandler {
+	return &TagHandler{
+		Manager: manager,
+	}
+}
+
+// normalizeTag lowercases tag and strips a leading #. Tags are letters,
+// digits and underscores of at most schema.MaxTagLength characters.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" {
+		return "", errors.New("Tags cannot be empty")
+	}
+	if utf8.RuneCountInString(tag) > schema.MaxTagLength {
+		return "", fmt.Errorf("Tags cannot be longer than %d characters", schema.MaxTagLength)
+	}
+	for _, c := range tag {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			return "", fmt.Errorf("Invalid tag: %s", tag)
+		}
+	}
+	return tag, nil
+}
+
+// parseTags returns the normalized, distinct tags of a post: those listed
+// in tags, separated by commas or spaces, followed by the hashtags in body.
+// Invalid or too many listed tags are an error; hashtags in body that are
+// invalid or past the limit are left out.
+func parseTags(tags, body string) ([]string, error) {
+	parsed := make([]string, 0)
+	seen := make(map[string]bool)
+
+	fields := strings.FieldsFunc(tags, func(c rune) bool { return c == ',' || unicode.IsSpace(c) })
+	for _, field := range fields {
+		tag, err := normalizeTag(field)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		parsed = append(parsed, tag)
+	}
+	if len(parsed) > schema.MaxTagsPerPost {
+		return nil, fmt.Errorf("A post can have at most %d tags", schema.MaxTagsPerPost)
+	}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		if len(parsed) == schema.MaxTagsPerPost {
+			break
+		}
+		tag, err := normalizeTag(match[1])
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		parsed = append(parsed, tag)
+	}
+	return parsed, nil
+}
+
+// GetTagPosts lists the posts carrying the tag in the URL, newest first.
+func (h *TagHandler) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	tag, err := normalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := pageParams(r, defaultTagPostsPageSize)
+	posts, err := h.Manager.TagRepo.GetPostsByTag(tag, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+	total, err := h.Manager.TagRepo.CountPostsByTag(tag)
+	if err != nil {
+		http.Error(w, "Failed to count posts", http.StatusInternalServerError)
+		return
+	}
+
+	if posts == nil {
+		posts = []schema.PostWithMedia{}
+	}
+	ids := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].Id
+	}
+	reactions := reactionSummaries(h.Manager, r, schema.TargetPost, ids)
+	comments := commentCounts(h.Manager, schema.TargetPost, ids)
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].Id]
+		posts[i].CommentCount = comments[posts[i].Id]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Tag        string                 `json:"tag"`
+		Posts      []schema.PostWithMedia `json:"posts"`
+		Pagination Pagination             `json:"pagination"`
+	}{
+		Tag:        tag,
+		Posts:      posts,
+		Pagination: Pagination{Total: total, Limit: limit, Offset: offset},
+	})
+}
+
+// SearchTags suggests tags starting with ?q=, most used first, for
+// autocomplete.
+func (h *TagHandler) SearchTags(w http.ResponseWriter, r *http.Request) {
+	prefix, err := normalizeTag(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultTagSuggestions
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxTagSuggestions)
+	}
+
+	tags, err := h.Manager.TagRepo.SearchTags(prefix, limit)
+	if err != nil {
+		http.Error(w, "Failed to search tags", http.StatusInternalServerError)
+		return
+	}
+	writeTags(w, tags)
+}
+
+// GetTrendingTags lists the tags put on the most posts within the sliding
+// ?window= duration, 24h by default and at most a week.
+func (h *TagHandler) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			http.Error(w, "Invalid window, use a duration of up to 168h", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	limit := defaultTrendingTagsCount
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxPageSize)
+	}
+
+	tags, err := h.Manager.TagRepo.GetTrendingTags(time.Now().Add(-window), limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch trending tags", http.StatusInternalServerError)
+		return
+	}
+	writeTags(w, tags)
+}
+
+func writeTags(w http.ResponseWriter, tags []schema.Tag) {
+	if tags == nil {
+		tags = []schema.Tag{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]schema.Tag{"tags": tags})
+}
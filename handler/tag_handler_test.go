@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// createTaggedPost stores a post by authorID and tags it through
+// UpdatePost.
+func createTaggedPost(t *testing.T, manager *repository.Manager, authorID uuid.UUID, body string, tags ...string) uuid.UUID {
+	t.Helper()
+	postID := createFeedPost(t, manager, authorID, time.Now(), nil)
+	tagPost(t, manager, authorID, postID, body, tags...)
+	return postID
+}
+
+// tagPost edits a post through UpdatePost, replacing its body and tags.
+func tagPost(t *testing.T, manager *repository.Manager, authorID, postID uuid.UUID, body string, tags ...string) {
+	t.Helper()
+	req := setupTestRequest(t, http.MethodPut, "/posts?id="+postID.String(), map[string]interface{}{
+		"title": "Post",
+		"body":  body,
+		"tags":  tags,
+	})
+	w := httptest.NewRecorder()
+	NewPostHandler(manager).UpdatePost(w, setupTestContext(req, authorID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to tag post: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     string
+		body     string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "No tags", expected: []string{}},
+		{name: "Comma separated", tags: "food,recipe", expected: []string{"food", "recipe"}},
+		{name: "Hashes and spaces", tags: "#Pasta  #DINNER, pasta", expected: []string{"pasta", "dinner"}},
+		{name: "Unicode letters", tags: "Crème_Brûlée", expected: []string{"crème_brûlée"}},
+		{name: "Hashtags in body", tags: "food", body: "Sunday #Baking with #food", expected: []string{"food", "baking"}},
+		{name: "Anchors and entities are not hashtags", body: "see example.com/#top and &#39;s", expected: []string{}},
+		{name: "Invalid characters", tags: "fish-and-chips", wantErr: true},
+		{name: "Too long", tags: strings.Repeat("a", schema.MaxTagLength+1), wantErr: true},
+		{name: "Too many", tags: "a,b,c,d,e,f,g,h,i,j,k", wantErr: true},
+		{name: "Body hashtags past the limit are dropped", tags: "a,b,c,d,e,f,g,h,i", body: "#j #k", expected: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+		{name: "Invalid body hashtags are skipped", body: "#" + strings.Repeat("a", schema.MaxTagLength+1) + " #ok", expected: []string{"ok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := parseTags(tt.tags, tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", tags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tags, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tags)
+			}
+		})
+	}
+}
+
+func TestTagHandler_GetTagPosts(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewTagHandler(manager)
+	authorID := uuid.New()
+	first := createTaggedPost(t, manager, authorID, "", "Pasta", "dinner")
+	second := createTaggedPost(t, manager, authorID, "More #pasta")
+	createTaggedPost(t, manager, authorID, "", "dessert")
+	config.Get().DB.(*MockRepositoryManager).Posts[first].CreatedAt = time.Now().Add(-time.Hour)
+
+	// Retagging removes the old tags
+	retagged := createTaggedPost(t, manager, authorID, "", "pasta")
+	createTaggedPost(t, manager, authorID, "", "")
+	req := setupTestRequest(t, http.MethodPut, "/posts?id="+retagged.String(), map[string]interface{}{"title": "Post", "tags": []string{"soup"}})
+	NewPostHandler(manager).UpdatePost(httptest.NewRecorder(), setupTestContext(req, authorID))
+
+	// Test cases
+	tests := []struct {
+		name           string
+		tag            string
+		expectedStatus int
+		expectedPosts  []uuid.UUID
+	}{
+		{
+			name:           "Posts with tag, newest first",
+			tag:            "pasta",
+			expectedStatus: http.StatusOK,
+			expectedPosts:  []uuid.UUID{second, first},
+		},
+		{
+			name:           "Tag is normalized",
+			tag:            "#PASTA",
+			expectedStatus: http.StatusOK,
+			expectedPosts:  []uuid.UUID{second, first},
+		},
+		{
+			name:           "Unused tag",
+			tag:            "breakfast",
+			expectedStatus: http.StatusOK,
+			expectedPosts:  []uuid.UUID{},
+		},
+		{
+			name:           "Invalid tag",
+			tag:            "no-dashes",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/tags/"+url.PathEscape(tt.tag)+"/posts", nil)
+			req = setupURLParams(setupTestContext(req, uuid.New()), map[string]string{"tag": tt.tag})
+			w := httptest.NewRecorder()
+
+			handler.GetTagPosts(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var response struct {
+				Posts      []schema.PostWithMedia `json:"posts"`
+				Pagination Pagination             `json:"pagination"`
+			}
+			readResponseBody(t, w, &response)
+			if len(response.Posts) != len(tt.expectedPosts) || response.Pagination.Total != len(tt.expectedPosts) {
+				t.Fatalf("Expected %d posts, got %d", len(tt.expectedPosts), len(response.Posts))
+			}
+			for i, post := range response.Posts {
+				if post.Id != tt.expectedPosts[i] {
+					t.Errorf("Expected post %d to be %s, got %s", i, tt.expectedPosts[i], post.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestTagHandler_SearchTags(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewTagHandler(manager)
+	authorID := uuid.New()
+	createTaggedPost(t, manager, authorID, "", "pasta", "pastry")
+	createTaggedPost(t, manager, authorID, "", "pastry", "pizza")
+
+	search := func(query string) (int, []schema.Tag) {
+		req := setupTestRequest(t, http.MethodGet, "/api/tags?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.SearchTags(w, req)
+		var response struct {
+			Tags []schema.Tag `json:"tags"`
+		}
+		if w.Code == http.StatusOK {
+			readResponseBody(t, w, &response)
+		}
+		return w.Code, response.Tags
+	}
+
+	code, tags := search("q=%23PAST")
+	expected := []schema.Tag{{Name: "pastry", PostCount: 2}, {Name: "pasta", PostCount: 1}}
+	if code != http.StatusOK || !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected %v, got %d %v", expected, code, tags)
+	}
+	if code, tags := search("q=past&limit=1"); code != http.StatusOK || len(tags) != 1 {
+		t.Errorf("Expected 1 suggestion, got %d %v", code, tags)
+	}
+	if code, tags := search("q=soup"); code != http.StatusOK || tags == nil || len(tags) != 0 {
+		t.Errorf("Expected no suggestions, got %d %v", code, tags)
+	}
+	if code, _ := search(""); code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestTagHandler_GetTrendingTags(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewTagHandler(manager)
+	authorID := uuid.New()
+	createTaggedPost(t, manager, authorID, "", "soup", "bread")
+	createTaggedPost(t, manager, authorID, "", "soup")
+	createTaggedPost(t, manager, authorID, "", "salad")
+	var pumpkinPost uuid.UUID
+	for i := 0; i < 3; i++ {
+		pumpkinPost = createTaggedPost(t, manager, authorID, "", "pumpkin")
+	}
+	// Pumpkin was popular, but two days ago
+	for _, postTag := range config.Get().DB.(*MockRepositoryManager).PostTags {
+		if postTag.Tag == "pumpkin" {
+			postTag.CreatedAt = time.Now().Add(-48 * time.Hour)
+		}
+	}
+	// Editing an old post does not make its tags trend again
+	tagPost(t, manager, authorID, pumpkinPost, "Roasted this time", "pumpkin")
+
+	// Test cases
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedTags   []string
+	}{
+		{
+			name:           "Default window",
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"soup", "salad", "bread"},
+		},
+		{
+			name:           "Wider window",
+			query:          "?window=72h&limit=2",
+			expectedStatus: http.StatusOK,
+			expectedTags:   []string{"pumpkin", "soup"},
+		},
+		{
+			name:           "Invalid window",
+			query:          "?window=forever",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Window too wide",
+			query:          "?window=720h",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestRequest(t, http.MethodGet, "/api/tags/trending"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetTrendingTags(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var response struct {
+				Tags []schema.Tag `json:"tags"`
+			}
+			readResponseBody(t, w, &response)
+			var names []string
+			for _, tag := range response.Tags {
+				names = append(names, tag.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expectedTags) {
+				t.Errorf("Expected %v, got %v", tt.expectedTags, names)
+			}
+		})
+	}
+}
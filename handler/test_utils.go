@@ -8,10 +8,13 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/smilecs/foody/config"
 	"github.com/smilecs/foody/data"
@@ -35,11 +38,18 @@ func TestMain(m *testing.M) {
 
 	// Create a mock database
 	mockDB := &MockRepositoryManager{
-		Users:     make(map[uuid.UUID]*schema.User),
-		Posts:     make(map[uuid.UUID]*repository.PostWithMedia),
-		Recipes:   make(map[uuid.UUID]*repository.RecipeWithMedia),
-		MealPlans: make(map[uuid.UUID]*repository.MealPlanWithMedia),
-		Media:     make(map[uuid.UUID]*schema.Media),
+		Users:         make(map[uuid.UUID]*schema.User),
+		Posts:         make(map[uuid.UUID]*repository.PostWithMedia),
+		Recipes:       make(map[uuid.UUID]*repository.RecipeWithMedia),
+		MealPlans:     make(map[uuid.UUID]*repository.MealPlanWithMedia),
+		Media:         make(map[uuid.UUID]*schema.Media),
+		Sessions:      make(map[uuid.UUID]*schema.Session),
+		Tokens:        make(map[string]*schema.UserToken),
+		TOTP:          make(map[uuid.UUID]*schema.TOTPSecret),
+		RecoveryCodes: make(map[uuid.UUID][]*schema.RecoveryCode),
+		APIKeys:       make(map[uuid.UUID]*schema.APIKey),
+		Identities:    make(map[string]*schema.UserIdentity),
+		AuthRequests:  make(map[string]*schema.OIDCAuthRequest),
 	}
 
 	// Set the mock config with a dummy session and bucket
@@ -68,6 +78,18 @@ func setupTestRequest(t *testing.T, method, path string, body interface{}) *http
 	return req
 }
 
+// setupFormRequest creates a test URL-encoded form request
+func setupFormRequest(t *testing.T, method, path string, formFields map[string]string) *http.Request {
+	form := url.Values{}
+	for key, value := range formFields {
+		form.Set(key, value)
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
 // setupMultipartRequest creates a test multipart form request
 func setupMultipartRequest(t *testing.T, method, path string, formFields map[string]string, fileField, filename string, fileContent []byte) *http.Request {
 	body := &bytes.Buffer{}
@@ -107,6 +129,29 @@ func setupTestContext(req *http.Request, userID uuid.UUID) *http.Request {
 	return req.WithContext(ctx)
 }
 
+// setupSessionContext adds a user ID and session ID to the request context
+func setupSessionContext(req *http.Request, userID, sessionID uuid.UUID) *http.Request {
+	ctx := context.WithValue(req.Context(), "user_id", userID)
+	ctx = context.WithValue(ctx, "session_id", sessionID)
+	return req.WithContext(ctx)
+}
+
+// setupRoleContext adds a user ID and role to the request context
+func setupRoleContext(req *http.Request, userID uuid.UUID, role schema.Role) *http.Request {
+	ctx := context.WithValue(req.Context(), "user_id", userID)
+	ctx = context.WithValue(ctx, "role", role)
+	return req.WithContext(ctx)
+}
+
+// setupURLParams sets chi URL parameters on the request as the router would
+func setupURLParams(req *http.Request, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for key, value := range params {
+		rctx.URLParams.Add(key, value)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
 // readResponseBody reads and unmarshals the response body
 func readResponseBody(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
 	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
@@ -121,11 +166,18 @@ func mockRepositoryManager() *repository.Manager {
 
 	// Create a mock database
 	mockDB := &MockRepositoryManager{
-		Users:     make(map[uuid.UUID]*schema.User),
-		Posts:     make(map[uuid.UUID]*repository.PostWithMedia),
-		Recipes:   make(map[uuid.UUID]*repository.RecipeWithMedia),
-		MealPlans: make(map[uuid.UUID]*repository.MealPlanWithMedia),
-		Media:     make(map[uuid.UUID]*schema.Media),
+		Users:         make(map[uuid.UUID]*schema.User),
+		Posts:         make(map[uuid.UUID]*repository.PostWithMedia),
+		Recipes:       make(map[uuid.UUID]*repository.RecipeWithMedia),
+		MealPlans:     make(map[uuid.UUID]*repository.MealPlanWithMedia),
+		Media:         make(map[uuid.UUID]*schema.Media),
+		Sessions:      make(map[uuid.UUID]*schema.Session),
+		Tokens:        make(map[string]*schema.UserToken),
+		TOTP:          make(map[uuid.UUID]*schema.TOTPSecret),
+		RecoveryCodes: make(map[uuid.UUID][]*schema.RecoveryCode),
+		APIKeys:       make(map[uuid.UUID]*schema.APIKey),
+		Identities:    make(map[string]*schema.UserIdentity),
+		AuthRequests:  make(map[string]*schema.OIDCAuthRequest),
 	}
 
 	// Create a mock AWS session
@@ -138,10 +190,22 @@ func mockRepositoryManager() *repository.Manager {
 
 	// Return the same manager instance that is set in the config
 	return &repository.Manager{
-		UserRepo:     &MockUserRepository{manager: mockDB},
-		PostRepo:     &MockPostRepository{manager: mockDB},
-		RecipeRepo:   &MockRecipeRepository{manager: mockDB},
-		MealPlanRepo: &MockMealPlanRepository{manager: mockDB},
-		MediaRepo:    &MockMediaRepository{manager: mockDB},
+		UserRepo:         &MockUserRepository{manager: mockDB},
+		PostRepo:         &MockPostRepository{manager: mockDB},
+		RecipeRepo:       &MockRecipeRepository{manager: mockDB},
+		MealPlanRepo:     &MockMealPlanRepository{manager: mockDB},
+		SessionRepo:      &MockSessionRepository{manager: mockDB},
+		TokenRepo:        &MockUserTokenRepository{manager: mockDB},
+		MediaRepo:        &MockMediaRepository{manager: mockDB},
+		TwoFactorRepo:    &MockTwoFactorRepository{manager: mockDB},
+		LoginAttemptRepo: &MockLoginAttemptRepository{manager: mockDB},
+		APIKeyRepo:       &MockAPIKeyRepository{manager: mockDB},
+		IdentityRepo:     &MockIdentityRepository{manager: mockDB},
+		FollowRepo:       &MockFollowRepository{manager: mockDB},
+		InteractionRepo:  &MockInteractionRepository{manager: mockDB},
+		CommentRepo:      &MockCommentRepository{manager: mockDB},
+		CollectionRepo:   &MockCollectionRepository{manager: mockDB},
+		NotificationRepo: &MockNotificationRepository{manager: mockDB},
+		TagRepo:          &MockTagRepository{manager: mockDB},
 	}
 }
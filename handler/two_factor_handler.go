@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/routes/requests"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+const (
+	totpIssuer        = "foody"
+	loginChallengeTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// TwoFactorChallenge is returned by Login instead of a token pair when the
+// account has two-factor authentication enabled.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// LoginTwoFactor finishes a login started with a password by checking an
+// authenticator code or a recovery code. A challenge token can only be used
+// once, so a wrong code means starting over with the password.
+func (u *UserHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	challenge := r.FormValue("challenge_token")
+	code := r.FormValue("code")
+	recoveryCode := r.FormValue("recovery_code")
+	if challenge == "" || (code == "" && recoveryCode == "") {
+		http.Error(w, "Challenge token and code or recovery code are required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := u.Manager.TokenRepo.ConsumeUserToken(utils.HashToken(challenge), schema.LoginChallengeToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		http.Error(w, "Account is suspended", http.StatusForbidden)
+		return
+	}
+
+	totp, err := u.Manager.TwoFactorRepo.GetTOTP(userID)
+	if err != nil || !totp.Enabled() {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	// Wrong codes count towards the account lockout like wrong passwords
+	failed := func(message string) {
+		reason := schema.InvalidSecondFactor
+		u.recordLoginAttempt(r, normalizeEmail(user.Email), &user.Id, &reason)
+		http.Error(w, message, http.StatusUnauthorized)
+	}
+
+	if code != "" {
+		step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now())
+		// Each code is accepted once even though it stays valid for a while
+		if !ok || u.Manager.TwoFactorRepo.UseTOTPStep(userID, step) != nil {
+			failed("Invalid code")
+			return
+		}
+	} else {
+		hash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+		if err := u.Manager.TwoFactorRepo.ConsumeRecoveryCode(userID, hash); err != nil {
+			failed("Invalid recovery code")
+			return
+		}
+	}
+
+	u.completeLogin(w, r, user)
+}
+
+// GetTwoFactor reports whether two-factor authentication is enabled for the
+// current user and how many recovery codes are left.
+func (u *UserHandler) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	response := struct {
+		Enabled                bool `json:"enabled"`
+		RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	}{}
+
+	totp, err := u.Manager.TwoFactorRepo.GetTOTP(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to load two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	if totp != nil && totp.Enabled() {
+		response.Enabled = true
+		response.RecoveryCodesRemaining, err = u.Manager.TwoFactorRepo.CountRecoveryCodes(userID)
+		if err != nil {
+			http.Error(w, "Failed to count recovery codes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EnrollTwoFactor creates a new authenticator secret for the current user.
+// It only takes effect once confirmed with ConfirmTwoFactor.
+func (u *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if totp, err := u.Manager.TwoFactorRepo.GetTOTP(userID); err == nil && totp.Enabled() {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	if err := u.Manager.TwoFactorRepo.SavePendingTOTP(userID, secret); err != nil {
+		http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"otpauth_uri"`
+	}{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ConfirmTwoFactor enables a pending secret after checking a code from the
+// authenticator app, and returns the initial recovery codes.
+func (u *UserHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req requests.TwoFactorCodeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	totp, err := u.Manager.TwoFactorRepo.GetTOTP(userID)
+	if err != nil {
+		http.Error(w, "Start two-factor enrollment first", http.StatusNotFound)
+		return
+	}
+	if totp.Enabled() {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(totp.Secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	if err := u.Manager.TwoFactorRepo.EnableTOTP(userID, step); err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	u.writeRecoveryCodes(w, userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after the user
+// confirms their password.
+func (u *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := u.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+
+	if totp, err := u.Manager.TwoFactorRepo.GetTOTP(user.Id); err != nil || !totp.Enabled() {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+
+	u.writeRecoveryCodes(w, user.Id)
+}
+
+// DisableTwoFactor turns off two-factor authentication after the user
+// confirms their password.
+func (u *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := u.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+
+	if err := u.Manager.TwoFactorRepo.DisableTOTP(user.Id); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeRecoveryCodes issues a new set of recovery codes and returns them.
+// This is the only time the plain codes are available.
+func (u *UserHandler) writeRecoveryCodes(w http.ResponseWriter, userID uuid.UUID) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+			return
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	if err := u.Manager.TwoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		http.Error(w, "Failed to save recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// confirmPassword checks the password in a PasswordConfirmReq body against
+// the current user, writing an error response if it does not match.
+func (u *UserHandler) confirmPassword(w http.ResponseWriter, r *http.Request) (*schema.User, bool) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var req requests.PasswordConfirmReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	authenticated, err := u.Manager.UserRepo.AuthUser(user.Email, req.Password)
+	if err != nil || !authenticated {
+		http.Error(w, "Invalid password", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
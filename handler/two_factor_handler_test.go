@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+// enrollTwoFactor enables 2FA for the user and returns the secret and
+// recovery codes.
+func enrollTwoFactor(t *testing.T, handler *UserHandler, userID uuid.UUID) (string, []string) {
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa", nil), userID)
+	w := httptest.NewRecorder()
+	handler.EnrollTwoFactor(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to enroll: expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var enrollment struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"otpauth_uri"`
+	}
+	readResponseBody(t, w, &enrollment)
+
+	// Confirm with the code for the previous period so that login tests
+	// can use the current one
+	code, _ := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now())-1)
+	req = setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa/confirm", map[string]string{"code": code}), userID)
+	w = httptest.NewRecorder()
+	handler.ConfirmTwoFactor(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to confirm: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var confirmation struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	readResponseBody(t, w, &confirmation)
+	return enrollment.Secret, confirmation.RecoveryCodes
+}
+
+// startTwoFactorLogin submits the password step and returns the challenge.
+func startTwoFactorLogin(t *testing.T, handler *UserHandler, email string) TwoFactorChallenge {
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    email,
+		"password": "password123",
+	})
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to login: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var challenge TwoFactorChallenge
+	readResponseBody(t, w, &challenge)
+	if !challenge.TwoFactorRequired || challenge.ChallengeToken == "" {
+		t.Fatalf("Expected a two-factor challenge, got %+v", challenge)
+	}
+	return challenge
+}
+
+func TestUserHandler_EnrollTwoFactor(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "enroll@example.com")
+
+	req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa", nil), user.Id)
+	w := httptest.NewRecorder()
+	handler.EnrollTwoFactor(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var enrollment struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"otpauth_uri"`
+	}
+	readResponseBody(t, w, &enrollment)
+	if !strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/") || !strings.Contains(enrollment.ProvisioningURI, enrollment.Secret) {
+		t.Errorf("Unexpected provisioning URI: %s", enrollment.ProvisioningURI)
+	}
+
+	// A pending secret does not affect login yet
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	if mgr.TOTP[user.Id].Enabled() {
+		t.Fatal("Expected secret to stay pending until confirmed")
+	}
+	w = httptest.NewRecorder()
+	handler.Login(w, setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    user.Email,
+		"password": "password123",
+	}))
+	var login LoginResponse
+	readResponseBody(t, w, &login)
+	if login.Token == "" {
+		t.Error("Expected pending enrollment not to require a second factor")
+	}
+
+	code, _ := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now()))
+
+	// Test cases
+	tests := []struct {
+		name           string
+		code           string
+		expectedStatus int
+	}{
+		{
+			name:           "Missing code",
+			code:           "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong code",
+			code:           "12345",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Valid code",
+			code:           code,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Already enabled",
+			code:           code,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa/confirm", map[string]string{"code": tt.code}), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.ConfirmTwoFactor(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if len(mgr.RecoveryCodes[user.Id]) != recoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %d", recoveryCodeCount, len(mgr.RecoveryCodes[user.Id]))
+	}
+	for _, code := range mgr.RecoveryCodes[user.Id] {
+		if len(code.CodeHash) != 64 {
+			t.Error("Expected recovery codes to be stored hashed")
+		}
+	}
+
+	// Enrolling again while enabled is refused
+	w = httptest.NewRecorder()
+	handler.EnrollTwoFactor(w, setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa", nil), user.Id))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Status reports the remaining codes
+	w = httptest.NewRecorder()
+	handler.GetTwoFactor(w, setupTestContext(setupTestRequest(t, http.MethodGet, "/api/me/2fa", nil), user.Id))
+	var status struct {
+		Enabled                bool `json:"enabled"`
+		RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	}
+	readResponseBody(t, w, &status)
+	if !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestUserHandler_LoginTwoFactor(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "twofactor@example.com")
+	secret, recoveryCodes := enrollTwoFactor(t, handler, user.Id)
+	code, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+
+	// Test cases
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "Missing code",
+			fields:         map[string]string{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong code",
+			fields:         map[string]string{"code": "000000"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Valid code",
+			fields:         map[string]string{"code": code},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Code cannot be replayed",
+			fields:         map[string]string{"code": code},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Recovery code",
+			fields:         map[string]string{"recovery_code": strings.ToUpper(recoveryCodes[0])},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Recovery code is single use",
+			fields:         map[string]string{"recovery_code": recoveryCodes[0]},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := startTwoFactorLogin(t, handler, user.Email)
+			tt.fields["challenge_token"] = challenge.ChallengeToken
+			req := setupFormRequest(t, http.MethodPost, "/login/2fa", tt.fields)
+			w := httptest.NewRecorder()
+
+			handler.LoginTwoFactor(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusOK {
+				var response LoginResponse
+				readResponseBody(t, w, &response)
+				if response.Token == "" || response.RefreshToken == "" {
+					t.Error("Expected a token pair after the second step")
+				}
+			}
+		})
+	}
+
+	// A challenge token is single use
+	challenge := startTwoFactorLogin(t, handler, user.Email)
+	for i, expected := range []int{http.StatusOK, http.StatusUnauthorized} {
+		req := setupFormRequest(t, http.MethodPost, "/login/2fa", map[string]string{
+			"challenge_token": challenge.ChallengeToken,
+			"recovery_code":   recoveryCodes[1+i],
+		})
+		w := httptest.NewRecorder()
+		handler.LoginTwoFactor(w, req)
+		if w.Code != expected {
+			t.Errorf("expected status %d, got %d", expected, w.Code)
+		}
+	}
+}
+
+func TestUserHandler_LoginTwoFactorExpiredChallenge(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "expired2fa@example.com")
+	_, recoveryCodes := enrollTwoFactor(t, handler, user.Id)
+	challenge := startTwoFactorLogin(t, handler, user.Email)
+
+	token := config.Get().DB.(*MockRepositoryManager).Tokens[utils.HashToken(challenge.ChallengeToken)]
+	if token.Purpose != schema.LoginChallengeToken {
+		t.Fatalf("Unexpected challenge purpose %q", token.Purpose)
+	}
+	token.ExpiresAt = time.Now().Add(-time.Second)
+
+	req := setupFormRequest(t, http.MethodPost, "/login/2fa", map[string]string{
+		"challenge_token": challenge.ChallengeToken,
+		"recovery_code":   recoveryCodes[0],
+	})
+	w := httptest.NewRecorder()
+	handler.LoginTwoFactor(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestUserHandler_DisableTwoFactor(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "disable2fa@example.com")
+	_, recoveryCodes := enrollTwoFactor(t, handler, user.Id)
+
+	// Regenerating codes requires the password and invalidates the old ones
+	w := httptest.NewRecorder()
+	handler.RegenerateRecoveryCodes(w, setupTestContext(setupTestRequest(t, http.MethodPost, "/api/me/2fa/recovery-codes", map[string]string{"password": "password123"}), user.Id))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	challenge := startTwoFactorLogin(t, handler, user.Email)
+	w = httptest.NewRecorder()
+	handler.LoginTwoFactor(w, setupFormRequest(t, http.MethodPost, "/login/2fa", map[string]string{
+		"challenge_token": challenge.ChallengeToken,
+		"recovery_code":   recoveryCodes[0],
+	}))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected old recovery code to be rejected, got status %d", w.Code)
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		password       string
+		expectedStatus int
+	}{
+		{
+			name:           "Missing password",
+			password:       "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong password",
+			password:       "wrong-password",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Correct password",
+			password:       "password123",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupTestContext(setupTestRequest(t, http.MethodDelete, "/api/me/2fa", map[string]string{"password": tt.password}), user.Id)
+			w := httptest.NewRecorder()
+
+			handler.DisableTwoFactor(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	// Password alone logs in again
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    user.Email,
+		"password": "password123",
+	})
+	w = httptest.NewRecorder()
+	handler.Login(w, req)
+	var response LoginResponse
+	readResponseBody(t, w, &response)
+	if response.Token == "" {
+		t.Error("Expected login without second factor after disabling 2FA")
+	}
+}
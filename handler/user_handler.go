@@ -1,14 +1,18 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smilecs/foody/config"
-	"github.com/smilecs/foody/data"
 	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
 	"github.com/smilecs/foody/utils"
@@ -61,30 +65,12 @@ func (u *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := config.Get()
-	bucket := cfg.S3_Bucket
-	key := fmt.Sprintf("users/%s/%s", username, header.Filename)
-
-	url, err := data.UploadFileAndGetUrl(cfg.AWSSess, bucket, key, file, header.Size, header.Header.Get("Content-Type"))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Upload error: %v", err), http.StatusInternalServerError)
-		return
-	}
-
 	user_id := uuid.New()
-	media_id := uuid.New()
 
 	// Create media first
-	media := schema.Media{
-		Id:        media_id,
-		URL:       url,
-		MediaType: schema.Image,
-		AuthorId:  user_id,
-	}
-
-	mediaID, err := u.Manager.MediaRepo.CreateMedia(media)
+	media, err := uploadMedia(u.Manager, file, header, "users/"+username, schema.Image, user_id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating media: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -95,20 +81,30 @@ func (u *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Name:     username,
 		Username: username,
 		DOB:      dob,
+		Role:     schema.RoleUser,
+		MediaId:  &media.Id,
+		Media:    *media,
 	}
 
-	_, err = u.Manager.UserRepo.CreateUser(user, password, mediaID)
+	_, err = u.Manager.UserRepo.CreateUser(user, password, media.Id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error creating user: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// The account is usable right away; a failed mail can be resent later
+	if err := u.sendVerificationEmail(&user); err != nil {
+		log.Printf("error sending verification email: %v\n", err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -126,13 +122,32 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Back off after repeated failures for this account or address
+	retryAfter, err := u.loginRetryAfter(normalizeEmail(email), clientIP(r), time.Now())
+	if err != nil {
+		log.Printf("error checking login attempts: %v\n", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		reason := schema.LoginLocked
+		u.recordLoginAttempt(r, normalizeEmail(email), nil, &reason)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	// Unknown emails and wrong passwords get the same response
 	authenticated, err := u.Manager.UserRepo.AuthUser(email, password)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Authentication error: %v", err), http.StatusInternalServerError)
+		log.Printf("error authenticating user: %v\n", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
 		return
 	}
 
 	if !authenticated {
+		reason := schema.InvalidCredentials
+		u.recordLoginAttempt(r, normalizeEmail(email), nil, &reason)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -144,19 +159,224 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.Id, user.Email)
+	u.loginUser(w, r, user)
+}
+
+// loginUser continues a login once the user has proven who they are with a
+// password or an identity provider: accounts with two-factor authentication
+// get a challenge, everyone else a new session.
+func (u *UserHandler) loginUser(w http.ResponseWriter, r *http.Request, user *schema.User) {
+	if user.SuspendedAt != nil {
+		http.Error(w, "Account is suspended", http.StatusForbidden)
+		return
+	}
+
+	// Accounts with two-factor authentication finish at LoginTwoFactor
+	totp, err := u.Manager.TwoFactorRepo.GetTOTP(user.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to load two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	if totp != nil && totp.Enabled() {
+		challenge, err := u.issueUserToken(user.Id, schema.LoginChallengeToken, loginChallengeTTL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error generating token: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TwoFactorChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(loginChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	u.completeLogin(w, r, user)
+}
+
+// completeLogin starts a session for a user who passed every login step.
+func (u *UserHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *schema.User) {
+	u.recordLoginAttempt(r, normalizeEmail(user.Email), &user.Id, nil)
+
+	// Logging in during the grace period restores a deleted account
+	if user.DeletionScheduledAt != nil {
+		if err := u.Manager.UserRepo.CancelDeletion(user.Id); err != nil {
+			http.Error(w, "Failed to restore account", http.StatusInternalServerError)
+			return
+		}
+		user.DeletionScheduledAt = nil
+	}
+
+	// Start a new session and issue its token pair
+	response, err := u.startSession(user, r.UserAgent())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// startSession records a new session for the user and returns an access
+// token bound to it together with the session's first refresh token.
+func (u *UserHandler) startSession(user *schema.User, userAgent string) (*LoginResponse, error) {
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := schema.Session{
+		Id:               uuid.New(),
+		UserId:           user.Id,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		UserAgent:        userAgent,
+		ExpiresAt:        time.Now().Add(utils.RefreshTokenTTL),
+	}
+	if err := u.Manager.SessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	token, err := config.Get().JWTKeys.GenerateToken(user.Id, user.Email, user.Role, session.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// issueUserToken replaces any outstanding token of the given purpose with a
+// new one and returns it. Only the newest emailed link is ever valid.
+func (u *UserHandler) issueUserToken(userID uuid.UUID, purpose schema.TokenPurpose, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.Manager.TokenRepo.InvalidateUserTokens(userID, purpose); err != nil {
+		return "", err
+	}
+
+	err = u.Manager.TokenRepo.CreateUserToken(schema.UserToken{
+		TokenHash: utils.HashToken(token),
+		UserId:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token. The refresh
+// token is rotated on every use; presenting an already rotated token revokes
+// the whole session since it means the token was copied.
+func (u *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken := r.FormValue("refresh_token")
+	if refreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	hash := utils.HashToken(refreshToken)
+	session, err := u.Manager.SessionRepo.GetSessionByTokenHash(hash)
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	if session.RefreshTokenHash != hash {
+		if err := u.Manager.SessionRepo.RevokeSession(session.Id); err != nil {
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	if !session.Active(time.Now()) {
+		http.Error(w, "Session has expired", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(session.UserId)
+	if err != nil || user.SuspendedAt != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	newRefreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = u.Manager.SessionRepo.RotateSession(session.Id, hash, utils.HashToken(newRefreshToken), time.Now().Add(utils.RefreshTokenTTL))
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := config.Get().JWTKeys.GenerateToken(user.Id, user.Email, user.Role, session.Id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error generating token: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Return token in response
 	response := LoginResponse{
-		Token: token,
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// Logout revokes the session the current access token belongs to.
+func (u *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := r.Context().Value("session_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Session not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	if err := u.Manager.SessionRepo.RevokeSession(sessionID); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every session of the current user, logging out all
+// devices including this one.
+func (u *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	if err := u.Manager.SessionRepo.RevokeUserSessions(userID); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
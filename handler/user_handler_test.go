@@ -3,11 +3,15 @@ package handler
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
 	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
 )
 
 // Add a package-level variable for userRepo access in checkResponse
@@ -253,3 +257,175 @@ func TestUserHandler_Login(t *testing.T) {
 		})
 	}
 }
+
+// loginTestUser creates a user and logs them in through the handler,
+// returning the issued token pair.
+func loginTestUser(t *testing.T, manager *repository.Manager, handler *UserHandler, email string) (schema.User, LoginResponse) {
+	user := schema.User{
+		Id:       uuid.New(),
+		Username: strings.Split(email, "@")[0],
+		Email:    email,
+		DOB:      "1990-01-01",
+	}
+	if _, err := manager.UserRepo.CreateUser(user, "password123", uuid.New()); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    email,
+		"password": "password123",
+	})
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to login: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response LoginResponse
+	readResponseBody(t, w, &response)
+	return user, response
+}
+
+func TestUserHandler_RefreshToken(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	_, login := loginTestUser(t, manager, handler, "refresh@example.com")
+
+	if login.RefreshToken == "" {
+		t.Fatal("Expected refresh token in login response")
+	}
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := setupFormRequest(t, http.MethodPost, "/token/refresh", map[string]string{"refresh_token": token})
+		w := httptest.NewRecorder()
+		handler.RefreshToken(w, req)
+		return w
+	}
+
+	// A valid refresh token is rotated
+	w := refresh(login.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var rotated LoginResponse
+	readResponseBody(t, w, &rotated)
+	if rotated.Token == "" || rotated.RefreshToken == "" {
+		t.Fatal("Expected a new token pair")
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Error("Expected refresh token to be rotated")
+	}
+
+	// Replaying the old refresh token revokes the session
+	if w := refresh(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d for replayed token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d after session revocation, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// Unknown and missing tokens are rejected
+	if w := refresh("not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := refresh(""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUserHandler_Logout(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "logout@example.com")
+	_, second := loginTestUser(t, manager, handler, "other@example.com")
+
+	// Log in a second time as the same user from another device
+	req := setupFormRequest(t, http.MethodPost, "/login", map[string]string{
+		"email":    user.Email,
+		"password": "password123",
+	})
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to login: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	claimsFor := func(token string) *utils.Claims {
+		claims, err := config.Get().JWTKeys.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("Failed to validate token: %v", err)
+		}
+		return claims
+	}
+
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	var userSessions []uuid.UUID
+	for id, session := range mgr.Sessions {
+		if session.UserId == user.Id {
+			userSessions = append(userSessions, id)
+		}
+	}
+	if len(userSessions) != 2 {
+		t.Fatalf("Expected 2 sessions for user, got %d", len(userSessions))
+	}
+
+	// Logout only revokes the current session
+	req = setupSessionContext(setupTestRequest(t, http.MethodPost, "/logout", nil), user.Id, userSessions[0])
+	w = httptest.NewRecorder()
+	handler.Logout(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if mgr.Sessions[userSessions[0]].RevokedAt == nil {
+		t.Error("Expected current session to be revoked")
+	}
+	if mgr.Sessions[userSessions[1]].RevokedAt != nil {
+		t.Error("Expected other session to stay active")
+	}
+
+	// Logout from all devices revokes the rest, but not other users' sessions
+	req = setupSessionContext(setupTestRequest(t, http.MethodPost, "/logout/all", nil), user.Id, userSessions[1])
+	w = httptest.NewRecorder()
+	handler.LogoutAll(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if mgr.Sessions[userSessions[1]].RevokedAt == nil {
+		t.Error("Expected all user sessions to be revoked")
+	}
+	if mgr.Sessions[claimsFor(second.Token).SessionID].RevokedAt != nil {
+		t.Error("Expected other user's session to stay active")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	_, login := loginTestUser(t, manager, handler, "jwks@example.com")
+
+	req := setupTestRequest(t, http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	JWKS(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response utils.JWKSet
+	readResponseBody(t, w, &response)
+	if len(response.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(response.Keys))
+	}
+
+	// The published key is the one access tokens name in their header
+	token, _, err := jwt.NewParser().ParseUnverified(login.Token, &utils.Claims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if token.Header["kid"] != response.Keys[0].KeyID {
+		t.Errorf("Expected kid %q, got %v", response.Keys[0].KeyID, token.Header["kid"])
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/mailer"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	// verificationResendCooldown is the minimum time between two
+	// verification emails for the same account.
+	verificationResendCooldown = time.Minute
+)
+
+func (u *UserHandler) sendVerificationEmail(user *schema.User) error {
+	token, err := u.issueUserToken(user.Id, schema.EmailVerificationToken, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	return cfg.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your foody email address",
+		Body: fmt.Sprintf(
+			"Welcome to foody!\n\n"+
+				"Confirm your email address to start sharing posts, recipes and meal plans:\n%s/verify-email?token=%s\n",
+			cfg.FrontendURL, token,
+		),
+	})
+}
+
+// VerifyEmail marks the account owning the given verification token as
+// verified.
+func (u *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := u.Manager.TokenRepo.ConsumeUserToken(utils.HashToken(token), schema.EmailVerificationToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+
+	if err := u.Manager.UserRepo.MarkEmailVerified(userID); err != nil {
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification sends a fresh verification email to the current user,
+// at most once per verificationResendCooldown.
+func (u *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := u.Manager.UserRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.EmailVerified {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	latest, err := u.Manager.TokenRepo.GetLatestUserToken(userID, schema.EmailVerificationToken)
+	if err == nil {
+		if wait := time.Until(latest.CreatedAt.Add(verificationResendCooldown)); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "Verification email was sent recently", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if err := u.sendVerificationEmail(user); err != nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/mailer"
+	"github.com/smilecs/foody/middleware"
+	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/utils"
+)
+
+func TestUserHandler_VerifyEmail(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+
+	mailDir := t.TempDir()
+	config.Get().Mailer = &mailer.FileMailer{Dir: mailDir}
+
+	// Signing up sends a verification email
+	formData := map[string]string{
+		"username":      "verifyme",
+		"email":         "verify@example.com",
+		"date_of_birth": "1990-01-01",
+		"password":      "password123",
+	}
+	req := setupMultipartRequest(t, http.MethodPost, "/signup", formData, "media", "profile.jpg", []byte("fake image content"))
+	w := httptest.NewRecorder()
+	handler.CreateUser(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create user: expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	tokens := readSentTokens(t, mailDir)
+	if len(tokens) != 1 {
+		t.Fatalf("expected 1 verification mail, got %d", len(tokens))
+	}
+	// The link opens the web app, which POSTs the token back to the API
+	if sentMailLinks(t, mailDir, config.Get().FrontendURL+"/verify-email?token=") != 1 {
+		t.Error("expected the verification link to point at the frontend")
+	}
+
+	user, _ := manager.UserRepo.GetUserByEmail("verify@example.com")
+	if user.EmailVerified {
+		t.Fatal("Expected new user to be unverified")
+	}
+
+	// Test cases
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{
+			name:           "Missing token",
+			token:          "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown token",
+			token:          "not-a-token",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Valid token",
+			token:          tokens[0],
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Token cannot be reused",
+			token:          tokens[0],
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := setupFormRequest(t, http.MethodPost, "/verify-email", map[string]string{"token": tt.token})
+			w := httptest.NewRecorder()
+
+			handler.VerifyEmail(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if !user.EmailVerified {
+		t.Error("Expected user to be verified")
+	}
+}
+
+func TestUserHandler_ResendVerification(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "resend@example.com")
+
+	mailDir := t.TempDir()
+	config.Get().Mailer = &mailer.FileMailer{Dir: mailDir}
+
+	resend := func() *httptest.ResponseRecorder {
+		req := setupTestContext(setupTestRequest(t, http.MethodPost, "/verify-email/resend", nil), user.Id)
+		w := httptest.NewRecorder()
+		handler.ResendVerification(w, req)
+		return w
+	}
+
+	if w := resend(); w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	// A second resend within the cooldown is throttled
+	w := resend()
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on throttled resend")
+	}
+	if sent := len(readSentTokens(t, mailDir)); sent != 1 {
+		t.Errorf("expected 1 mail, got %d", sent)
+	}
+
+	// Once the cooldown has passed another mail can be sent
+	mgr := config.Get().DB.(*MockRepositoryManager)
+	for _, token := range mgr.Tokens {
+		token.CreatedAt = token.CreatedAt.Add(-verificationResendCooldown)
+	}
+	if w := resend(); w.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	// Verified users have nothing to resend
+	manager.UserRepo.MarkEmailVerified(user.Id)
+	if w := resend(); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "unverified@example.com")
+
+	created := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	protected := middleware.RequireVerifiedEmail(manager)(created)
+
+	// Test cases
+	tests := []struct {
+		name           string
+		policy         bool
+		verified       bool
+		expectedStatus int
+	}{
+		{
+			name:           "Policy disabled allows unverified users",
+			policy:         false,
+			verified:       false,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Policy enabled blocks unverified users",
+			policy:         true,
+			verified:       false,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Policy enabled allows verified users",
+			policy:         true,
+			verified:       true,
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Get().RequireVerifiedEmail = tt.policy
+			config.Get().DB.(*MockRepositoryManager).Users[user.Id].EmailVerified = tt.verified
+
+			req := setupTestContext(setupTestRequest(t, http.MethodPost, "/posts", nil), user.Id)
+			w := httptest.NewRecorder()
+
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_VerifyEmailExpired(t *testing.T) {
+	// Setup
+	manager := mockRepositoryManager()
+	handler := NewUserHandler(manager)
+	user, _ := loginTestUser(t, manager, handler, "expired@example.com")
+
+	token, _ := utils.GenerateOpaqueToken()
+	manager.TokenRepo.CreateUserToken(schema.UserToken{
+		TokenHash: utils.HashToken(token),
+		UserId:    user.Id,
+		Purpose:   schema.EmailVerificationToken,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	req := setupFormRequest(t, http.MethodPost, "/verify-email", map[string]string{"token": token})
+	w := httptest.NewRecorder()
+	handler.VerifyEmail(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
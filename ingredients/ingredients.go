@@ -0,0 +1,194 @@
+// Package ingredients normalizes ingredient names so that differently
+// written names of the same ingredient compare equal, and matches recipe
+// ingredients against what a user has at hand.
+package ingredients
+
+import (
+	"strings"
+	"unicode"
+)
+
+// descriptors are words that describe how an ingredient is prepared or
+// bought rather than what it is. They are dropped when normalizing.
+var descriptors = map[string]bool{
+	"fresh": true, "freshly": true, "chopped": true, "diced": true, "minced": true,
+	"sliced": true, "grated": true, "shredded": true, "peeled": true, "crushed": true,
+	"finely": true, "roughly": true, "thinly": true, "large": true, "small": true,
+	"medium": true, "ripe": true, "organic": true, "whole": true, "dried": true,
+	"frozen": true, "canned": true, "boneless": true, "skinless": true, "extra": true,
+	"virgin": true, "raw": true, "cooked": true, "unsalted": true, "of": true,
+	"and": true, "or": true, "to": true, "taste": true, "optional": true,
+}
+
+// irregular maps plurals the suffix rules in Singular get wrong.
+var irregular = map[string]string{
+	"leaves": "leaf", "loaves": "loaf", "halves": "half", "knives": "knife",
+	"calves": "calf", "cookies": "cookie", "brownies": "brownie", "veggies": "veggie",
+	"smoothies": "smoothie", "pies": "pie", "children": "child", "teeth": "tooth",
+	"geese": "goose", "mice": "mouse",
+}
+
+// uncountable words end in s without being plurals.
+var uncountable = map[string]bool{
+	"molasses": true, "asparagus": true, "hummus": true, "couscous": true,
+	"swiss": true, "lemongrass": true, "grass": true, "bass": true, "citrus": true,
+	"octopus": true, "series": true, "species": true, "quinoa": true, "harissa": true,
+	"anise": true, "tapas": true, "gras": true, "mayonnaise": true, "bitters": true,
+}
+
+// staples are assumed to be in every kitchen.
+var staples = map[string]bool{
+	"water": true, "salt": true, "pepper": true, "black pepper": true, "ice": true,
+	"salt pepper": true,
+}
+
+// Singular returns the singular of an English food word, such as
+// "tomato" for "tomatoes" and "berry" for "berries". Words that are not
+// plurals are returned unchanged.
+func Singular(word string) string {
+	if singular, ok := irregular[word]; ok {
+		return singular
+	}
+	if uncountable[word] || len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "oes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "zes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// Normalize reduces an ingredient name to a canonical form: lowercase,
+// without punctuation, notes in parentheses or preparation words, and with
+// every word singular. "2 Ripe Tomatoes, diced (about 300g)" and "tomato"
+// both normalize to "tomato".
+func Normalize(name string) string {
+	name = strings.ToLower(name)
+
+	// Drop notes in parentheses and anything after a comma, which is
+	// usually preparation
+	var b strings.Builder
+	depth := 0
+	for _, c := range name {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case c == ',' || c == ';':
+			b.WriteRune(',')
+		case unicode.IsLetter(c):
+			b.WriteRune(c)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	name, _, _ = strings.Cut(b.String(), ",")
+
+	var words []string
+	for _, word := range strings.Fields(name) {
+		if descriptors[word] {
+			continue
+		}
+		words = append(words, Singular(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// IsStaple reports whether the normalized ingredient is assumed to be at
+// hand, like salt and water.
+func IsStaple(normalized string) bool {
+	return staples[normalized]
+}
+
+// Covers reports whether having the normalized ingredient have is enough
+// for the normalized ingredient need. An ingredient covers itself and any
+// more specific kind of it: "tomato" covers "cherry tomato" and "oil"
+// covers "olive oil", but "tomato" does not cover "tomato paste".
+func Covers(have, need string) bool {
+	if have == "" || need == "" {
+		return false
+	}
+	return have == need || strings.HasSuffix(need, " "+have)
+}
+
+// Pantry is what a user has at hand.
+type Pantry struct {
+	items []string
+	// Staples makes salt, water and the like count as at hand.
+	Staples bool
+}
+
+// NewPantry returns a pantry holding the named ingredients, with staples
+// assumed to be at hand.
+func NewPantry(names []string) *Pantry {
+	pantry := &Pantry{Staples: true}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		normalized := Normalize(name)
+		if normalized != "" && !seen[normalized] {
+			seen[normalized] = true
+			pantry.items = append(pantry.items, normalized)
+		}
+	}
+	return pantry
+}
+
+// Items returns the normalized ingredients in the pantry.
+func (p *Pantry) Items() []string {
+	return p.items
+}
+
+// Words returns the distinct words of the pantry's ingredients.
+func (p *Pantry) Words() []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, item := range p.items {
+		for _, word := range strings.Fields(item) {
+			if !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// Has reports whether the pantry covers the named ingredient.
+func (p *Pantry) Has(name string) bool {
+	need := Normalize(name)
+	if p.Staples && IsStaple(need) {
+		return true
+	}
+	for _, have := range p.items {
+		if Covers(have, need) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the names of the ingredients the pantry does not cover,
+// in the order given.
+func (p *Pantry) Missing(names []string) []string {
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !p.Has(name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,80 @@
+package ingredients
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"tomato", "tomato"},
+		{"Tomatoes", "tomato"},
+		{"2 Ripe Tomatoes, diced (about 300g)", "tomato"},
+		{"Cherry tomatoes", "cherry tomato"},
+		{"Fresh basil leaves", "basil leaf"},
+		{"blueberries", "blueberry"},
+		{"Peaches", "peach"},
+		{"Extra-virgin olive oil", "olive oil"},
+		{"Olives", "olive"},
+		{"Cloves", "clove"},
+		{"Eggs", "egg"},
+		{"Asparagus", "asparagus"},
+		{"Hummus", "hummus"},
+		{"Molasses", "molasses"},
+		{"Cookies", "cookie"},
+		{"Salt and pepper, to taste", "salt pepper"},
+		{"  ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.name); got != tt.expected {
+				t.Errorf("Normalize(%q) = %q, expected %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		have, need string
+		expected   bool
+	}{
+		{"tomato", "tomato", true},
+		{"tomato", "cherry tomato", true},
+		{"oil", "olive oil", true},
+		{"tomato", "tomato paste", false},
+		{"cherry tomato", "tomato", false},
+		{"pea", "chickpea", false},
+		{"", "tomato", false},
+	}
+
+	for _, tt := range tests {
+		if got := Covers(tt.have, tt.need); got != tt.expected {
+			t.Errorf("Covers(%q, %q) = %v, expected %v", tt.have, tt.need, got, tt.expected)
+		}
+	}
+}
+
+func TestPantry(t *testing.T) {
+	pantry := NewPantry([]string{"Tomatoes", "tomato", "spaghetti", " ", "Olive Oil"})
+	if items := pantry.Items(); !slices.Equal(items, []string{"tomato", "spaghetti", "olive oil"}) {
+		t.Errorf("Unexpected pantry items: %v", items)
+	}
+	if words := pantry.Words(); !slices.Equal(words, []string{"tomato", "spaghetti", "olive", "oil"}) {
+		t.Errorf("Unexpected pantry words: %v", words)
+	}
+
+	recipe := []string{"Spaghetti", "Cherry Tomatoes", "Salt", "Basil", "Extra virgin olive oil", "Garlic cloves"}
+	if missing := pantry.Missing(recipe); !slices.Equal(missing, []string{"Basil", "Garlic cloves"}) {
+		t.Errorf("Unexpected missing ingredients: %v", missing)
+	}
+
+	pantry.Staples = false
+	if !slices.Contains(pantry.Missing(recipe), "Salt") {
+		t.Error("Expected salt to be missing without staples")
+	}
+}
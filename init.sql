@@ -7,7 +7,15 @@ CREATE TABLE users (
     email VARCHAR(255) NOT NULL UNIQUE,
     media_id UUID,
     date_of_birth DATE,
+    bio TEXT NOT NULL DEFAULT '',
     password VARCHAR(255) NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verified_at TIMESTAMP WITH TIME ZONE,
+    username_changed_at TIMESTAMP WITH TIME ZONE,
+    deletion_scheduled_at TIMESTAMP WITH TIME ZONE,
+    role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+    suspended_at TIMESTAMP WITH TIME ZONE,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -41,6 +49,9 @@ CREATE TABLE recipe (
     cook_time INTERVAL,
     total_time INTERVAL,
     servings INT,
+    -- search_vector indexes the title, description, ingredients and steps;
+    -- the repository refreshes it whenever the recipe is saved
+    search_vector TSVECTOR,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE CASCADE,
@@ -70,6 +81,7 @@ CREATE TABLE recipe_ingredients (
     id SERIAL PRIMARY KEY,
     recipe_id UUID NOT NULL,
     name VARCHAR(255) NOT NULL,
+    normalized_name VARCHAR(255) NOT NULL DEFAULT '',
     quantity DECIMAL(10,2) NOT NULL,
     unit VARCHAR(50) NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
@@ -106,6 +118,7 @@ CREATE TABLE meal_plan (
 -- Create indexes for better query performance
 CREATE INDEX idx_users_email ON users(email);
 CREATE INDEX idx_users_username ON users(username);
+CREATE INDEX idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
 CREATE INDEX idx_media_author_id ON media(author_id);
 CREATE INDEX idx_post_author_id ON post(author_id);
 CREATE INDEX idx_post_recipe_id ON post(recipe_id);
@@ -114,4 +127,275 @@ CREATE INDEX idx_recipe_ingredients_recipe_id ON recipe_ingredients(recipe_id);
 CREATE INDEX idx_recipe_steps_recipe_id ON recipe_steps(recipe_id);
 CREATE INDEX idx_meal_plan_author_id ON meal_plan(author_id);
 CREATE INDEX idx_meal_plan_date ON meal_plan(date);
-CREATE INDEX idx_meal_plan_recipe_id ON meal_plan(recipe_id); 
\ No newline at end of file
+CREATE INDEX idx_meal_plan_recipe_id ON meal_plan(recipe_id); 
+-- Create sessions table
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    session_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    previous_token_hash VARCHAR(64),
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_previous_token_hash ON sessions(previous_token_hash);
+
+-- Create user_tokens table for single-use tokens sent by email
+CREATE TABLE user_tokens (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    purpose VARCHAR(50) NOT NULL CHECK (purpose IN ('password_reset', 'email_verification', 'login_challenge')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
+
+-- Create user_totp table for authenticator app secrets
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create recovery_codes table for single-use 2FA recovery codes
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create login_attempts table as an audit log and for brute-force lockout
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_id UUID,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) CHECK (reason IN ('invalid_credentials', 'invalid_second_factor', 'locked')),
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created_at);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created_at);
+
+-- Create api_keys table for personal API keys
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    api_key_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+
+-- Create user_identities table linking accounts at OpenID Connect providers
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create oidc_auth_requests table for logins in progress at a provider
+CREATE TABLE oidc_auth_requests (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    link_user_id UUID,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create follows table; follows of private accounts start out pending
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'accepted')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id),
+    FOREIGN KEY (follower_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (followee_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_follows_followee ON follows(followee_id, status, created_at);
+CREATE INDEX idx_follows_follower ON follows(follower_id, status, created_at);
+
+-- Serve each author's newest posts first when assembling feeds
+CREATE INDEX idx_post_author_created ON post(author_id, created_at DESC, post_id DESC);
+
+-- Create interactions table for reactions to posts and recipes; each row
+-- belongs to exactly one of the two
+CREATE TABLE interactions (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    post_id UUID,
+    recipe_id UUID,
+    reaction VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) <> (recipe_id IS NULL)),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES post(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipe(recipe_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_interactions_post ON interactions(post_id, user_id, reaction) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_interactions_recipe ON interactions(recipe_id, user_id, reaction) WHERE recipe_id IS NOT NULL;
+
+-- Create comments table; replies point at a top-level comment on the same
+-- post or recipe
+CREATE TABLE comments (
+    id SERIAL PRIMARY KEY,
+    comment_id UUID NOT NULL UNIQUE,
+    post_id UUID,
+    recipe_id UUID,
+    parent_id UUID,
+    author_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP WITH TIME ZONE,
+    CHECK ((post_id IS NULL) <> (recipe_id IS NULL)),
+    FOREIGN KEY (post_id) REFERENCES post(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipe(recipe_id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comments_post ON comments(post_id, created_at) WHERE post_id IS NOT NULL;
+CREATE INDEX idx_comments_recipe ON comments(recipe_id, created_at) WHERE recipe_id IS NOT NULL;
+CREATE INDEX idx_comments_parent ON comments(parent_id, created_at);
+
+-- Create comment_mentions table of the users an @username in a comment names
+CREATE TABLE comment_mentions (
+    comment_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create collections table of user-curated recipe lists; each user has at
+-- most one default "Saved" collection
+CREATE TABLE collections (
+    id SERIAL PRIMARY KEY,
+    collection_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_collections_user_id ON collections(user_id);
+CREATE UNIQUE INDEX idx_collections_default ON collections(user_id) WHERE is_default;
+
+-- Create collection_items table
+CREATE TABLE collection_items (
+    collection_id UUID NOT NULL,
+    recipe_id UUID NOT NULL,
+    position INT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, recipe_id),
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipe(recipe_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_collection_items_position ON collection_items(collection_id, position);
+
+-- Create notifications table; unread notifications with the same group_key
+-- are merged, collecting their actors newest first
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    notification_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    target_type VARCHAR(20),
+    target_id UUID,
+    group_key VARCHAR(100) NOT NULL,
+    actor_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, updated_at DESC);
+CREATE UNIQUE INDEX idx_notifications_unread_group ON notifications(user_id, group_key) WHERE read_at IS NULL;
+
+-- Create notification_preferences table; types without a row are enabled
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Create tags table; names are stored lowercase without the leading #
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_tags_name_prefix ON tags(name text_pattern_ops);
+
+-- Create post_tags table linking posts to their tags; post.tags keeps a
+-- copy for display
+CREATE TABLE post_tags (
+    post_id UUID NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, tag),
+    FOREIGN KEY (post_id) REFERENCES post(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (tag) REFERENCES tags(name) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_tags_tag ON post_tags(tag, created_at DESC);
+CREATE INDEX idx_post_tags_created_at ON post_tags(created_at);
+
+-- Indexes for recipe search
+CREATE INDEX idx_recipe_search ON recipe USING GIN (search_vector);
+
+-- Indexes for pantry matching
+CREATE INDEX idx_recipe_ingredients_words ON recipe_ingredients USING GIN (string_to_array(normalized_name, ' '));
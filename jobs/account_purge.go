@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/repository"
+)
+
+// StartAccountPurger runs PurgeDeletedAccounts every interval until the
+// process exits.
+func StartAccountPurger(manager *repository.Manager, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := PurgeDeletedAccounts(manager, now); err != nil {
+				log.Printf("error purging deleted accounts: %v\n", err)
+			}
+		}
+	}()
+}
+
+// PurgeDeletedAccounts permanently removes accounts whose deletion grace
+// period has ended. Stored media objects are deleted first; if that fails
+// the account is kept so the next run can retry.
+func PurgeDeletedAccounts(manager *repository.Manager, now time.Time) error {
+	userIDs, err := manager.UserRepo.GetUsersDueForDeletion(now)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := deleteUserMedia(manager, userID); err != nil {
+			log.Printf("error deleting media of user %s: %v\n", userID, err)
+			continue
+		}
+		if err := manager.UserRepo.DeleteUser(userID); err != nil {
+			log.Printf("error deleting user %s: %v\n", userID, err)
+			continue
+		}
+		log.Printf("purged account %s\n", userID)
+	}
+	return nil
+}
+
+func deleteUserMedia(manager *repository.Manager, userID uuid.UUID) error {
+	mediaList, err := manager.MediaRepo.GetMediaByAuthorID(userID)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	for _, media := range mediaList {
+		key, err := data.KeyFromURL(media.URL)
+		if err != nil {
+			return err
+		}
+		if err := data.DeleteFile(cfg.AWSSess, cfg.S3_Bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
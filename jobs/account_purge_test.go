@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/data"
+	"github.com/smilecs/foody/handler"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	config.Reset()
+	config.SetTestInstance(nil)
+
+	var deletedKeys []string
+	failKey := ""
+	originalDeleteFile := data.DeleteFile
+	t.Cleanup(func() { data.DeleteFile = originalDeleteFile })
+	data.DeleteFile = func(sess *session.Session, bucket, key string) error {
+		if key == failKey {
+			return errors.New("s3 unavailable")
+		}
+		deletedKeys = append(deletedKeys, key)
+		return nil
+	}
+
+	manager := handler.NewMockRepositoryManager()
+	now := time.Now()
+
+	newUser := func(username string, deleteAt *time.Time) uuid.UUID {
+		user := schema.User{Id: uuid.New(), Username: username, Email: username + "@example.com"}
+		mediaID, _ := manager.MediaRepo.CreateMedia(schema.Media{
+			Id:       uuid.New(),
+			URL:      "https://test-bucket.s3.us-east-1.amazonaws.com/users/" + username + "/profile.jpg",
+			AuthorId: user.Id,
+		})
+		manager.UserRepo.CreateUser(user, "password123", mediaID)
+		if deleteAt != nil {
+			manager.UserRepo.ScheduleDeletion(user.Id, *deleteAt)
+		}
+		return user.Id
+	}
+
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	due := newUser("due", &past)
+	pending := newUser("pending", &future)
+	active := newUser("active", nil)
+	failing := newUser("failing", &past)
+	failKey = "users/failing/profile.jpg"
+
+	manager.PostRepo.CreatePost(schema.Post{Id: uuid.New(), AuthorId: due}, uuid.New(), "")
+
+	if err := PurgeDeletedAccounts(manager, now); err != nil {
+		t.Fatalf("PurgeDeletedAccounts returned error: %v", err)
+	}
+
+	if _, err := manager.UserRepo.GetUserByID(due); err == nil {
+		t.Error("Expected account past its grace period to be deleted")
+	}
+	if posts, _ := manager.PostRepo.GetPostsByAuthorID(due); len(posts) != 0 {
+		t.Error("Expected posts of deleted account to be removed")
+	}
+	for _, id := range []uuid.UUID{pending, active, failing} {
+		if _, err := manager.UserRepo.GetUserByID(id); err != nil {
+			t.Errorf("Expected account %s to be kept", id)
+		}
+	}
+	if len(deletedKeys) != 1 || deletedKeys[0] != "users/due/profile.jpg" {
+		t.Errorf("Expected only the deleted account's media to be removed, got %v", deletedKeys)
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/smilecs/foody/config"
 	"github.com/smilecs/foody/handler"
+	"github.com/smilecs/foody/jobs"
 	"github.com/smilecs/foody/middleware"
 	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
 )
 
 func main() {
@@ -18,46 +21,206 @@ func main() {
 	// Initialize manager with all repositories
 	manager := repository.NewManager(cfg.DB)
 
+	// Purge accounts whose deletion grace period has ended
+	jobs.StartAccountPurger(manager, time.Hour)
+
 	// Initialize handlers with manager
 	userHandler := handler.NewUserHandler(manager)
 	postHandler := handler.NewPostHandler(manager)
 	recipeHandler := handler.NewRecipeHandler(manager)
 	mealPlanHandler := handler.NewMealPlanHandler(manager)
+	adminHandler := handler.NewAdminHandler(manager)
+	followHandler := handler.NewFollowHandler(manager)
+	reactionHandler := handler.NewReactionHandler(manager)
+	commentHandler := handler.NewCommentHandler(manager)
+	collectionHandler := handler.NewCollectionHandler(manager)
+	notificationHandler := handler.NewNotificationHandler(manager)
+	streamHandler := handler.NewStreamHandler(manager)
+	tagHandler := handler.NewTagHandler(manager)
 
 	router := chi.NewRouter()
 
 	// Public routes
 	router.Post("/signup", userHandler.CreateUser)
 	router.Post("/login", userHandler.Login)
+	router.Post("/login/2fa", userHandler.LoginTwoFactor)
+	router.Post("/token/refresh", userHandler.RefreshToken)
+	router.Post("/password/forgot", userHandler.ForgotPassword)
+	router.Post("/password/reset", userHandler.ResetPassword)
+	router.Post("/verify-email", userHandler.VerifyEmail)
+	router.Get("/.well-known/jwks.json", handler.JWKS)
+	router.Get("/auth/oidc/{provider}/login", userHandler.OIDCLogin)
+	router.Get("/auth/oidc/{provider}/callback", userHandler.OIDCCallback)
 
 	// Protected routes
 	router.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware)
+		r.Use(middleware.AuthMiddleware(manager))
+		requireVerified := middleware.RequireVerifiedEmail(manager)
+
+		// Session routes
+		r.With(middleware.RequireSession).Post("/logout", userHandler.Logout)
+		r.With(middleware.RequireSession).Post("/logout/all", userHandler.LogoutAll)
+		r.With(middleware.RequireSession).Post("/verify-email/resend", userHandler.ResendVerification)
+
+		// Profile routes
+		r.Route("/api/users/{id}", func(r chi.Router) {
+			read := middleware.RequireScope(schema.ScopeFollowsRead)
+			write := middleware.RequireScope(schema.ScopeFollowsWrite)
+			r.With(read).Get("/", userHandler.GetUser)
+			r.With(write).Post("/follow", followHandler.FollowUser)
+			r.With(write).Delete("/follow", followHandler.UnfollowUser)
+			r.With(read).Get("/followers", followHandler.GetFollowers)
+			r.With(read).Get("/following", followHandler.GetFollowing)
+			r.With(middleware.RequireScope(schema.ScopeRecipesRead)).Get("/collections", collectionHandler.GetUserCollections)
+		})
+		r.Route("/api/me", func(r chi.Router) {
+			// Account management needs an interactive login
+			r.Use(middleware.RequireSession)
+			r.Get("/", userHandler.GetMe)
+			r.Patch("/", userHandler.UpdateMe)
+			r.Put("/avatar", userHandler.UpdateAvatar)
+			r.Put("/username", userHandler.UpdateUsername)
+			r.Get("/export", userHandler.ExportData)
+			r.Delete("/", userHandler.DeleteAccount)
+
+			// Two-factor authentication
+			r.Get("/2fa", userHandler.GetTwoFactor)
+			r.Post("/2fa", userHandler.EnrollTwoFactor)
+			r.Post("/2fa/confirm", userHandler.ConfirmTwoFactor)
+			r.Post("/2fa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+			r.Delete("/2fa", userHandler.DisableTwoFactor)
+
+			// Personal API keys
+			r.Get("/api-keys", userHandler.GetAPIKeys)
+			r.Post("/api-keys", userHandler.CreateAPIKey)
+			r.Delete("/api-keys/{id}", userHandler.RevokeAPIKey)
+
+			// Identity providers
+			r.Get("/identities", userHandler.GetIdentities)
+			r.Post("/identities/{provider}", userHandler.LinkIdentity)
+			r.Delete("/identities/{provider}", userHandler.UnlinkIdentity)
+
+			// Follow requests and followers
+			r.Get("/follow-requests", followHandler.GetFollowRequests)
+			r.Post("/follow-requests/{id}/accept", followHandler.AcceptFollowRequest)
+			r.Delete("/follow-requests/{id}", followHandler.RemoveFollower)
+			r.Delete("/followers/{id}", followHandler.RemoveFollower)
+		})
 
 		// Post routes
-		r.Post("/posts", postHandler.CreatePost)
-		r.Get("/posts", postHandler.GetPosts)
-		r.Get("/posts/{id}", postHandler.GetPostByID)
-		r.Put("/posts/{id}", postHandler.UpdatePost)
-		r.Delete("/posts/{id}", postHandler.DeletePost)
+		readPosts := middleware.RequireScope(schema.ScopePostsRead)
+		writePosts := middleware.RequireScope(schema.ScopePostsWrite)
+		readComments := middleware.RequireScope(schema.ScopeCommentsRead)
+		writeComments := middleware.RequireScope(schema.ScopeCommentsWrite)
+		r.With(writePosts, requireVerified).Post("/posts", postHandler.CreatePost)
+		r.With(readPosts).Get("/posts", postHandler.GetPosts)
+		r.With(readPosts).Get("/posts/{id}", postHandler.GetPostByID)
+		r.With(writePosts).Put("/posts/{id}", postHandler.UpdatePost)
+		r.With(writePosts).Delete("/posts/{id}", postHandler.DeletePost)
+		r.With(readPosts).Get("/posts/{id}/reactions", reactionHandler.GetReactions(schema.TargetPost))
+		r.With(writePosts).Put("/posts/{id}/reactions/{reaction}", reactionHandler.React(schema.TargetPost))
+		r.With(writePosts).Delete("/posts/{id}/reactions/{reaction}", reactionHandler.Unreact(schema.TargetPost))
+		r.With(readComments).Get("/posts/{id}/comments", commentHandler.GetComments(schema.TargetPost))
+		r.With(writeComments, requireVerified).Post("/posts/{id}/comments", commentHandler.CreateComment(schema.TargetPost))
+		r.With(readPosts).Get("/api/feed", postHandler.GetFeed)
+
+		// Tag routes
+		r.Route("/api/tags", func(r chi.Router) {
+			r.Use(readPosts)
+			r.Get("/", tagHandler.SearchTags)
+			r.Get("/trending", tagHandler.GetTrendingTags)
+			r.Get("/{tag}/posts", tagHandler.GetTagPosts)
+		})
+
+		// Comment routes
+		r.Route("/api/comments/{id}", func(r chi.Router) {
+			r.With(readComments).Get("/replies", commentHandler.GetReplies)
+			r.With(writeComments).Patch("/", commentHandler.UpdateComment)
+			r.With(writeComments).Delete("/", commentHandler.DeleteComment)
+		})
 
 		// Recipe routes
 		r.Route("/api/recipes", func(r chi.Router) {
-			r.Post("/", recipeHandler.CreateRecipe)
-			r.Get("/", recipeHandler.GetRecipes)
-			r.Get("/{id}", recipeHandler.GetRecipeByID)
-			r.Get("/author/{author_id}", recipeHandler.GetRecipesByAuthorID)
-			r.Put("/{id}", recipeHandler.UpdateRecipe)
-			r.Delete("/{id}", recipeHandler.DeleteRecipe)
+			read := middleware.RequireScope(schema.ScopeRecipesRead)
+			write := middleware.RequireScope(schema.ScopeRecipesWrite)
+			r.With(write, requireVerified).Post("/", recipeHandler.CreateRecipe)
+			r.With(read).Get("/", recipeHandler.GetRecipes)
+			r.With(read).Get("/search", recipeHandler.SearchRecipes)
+			r.With(read).Post("/match", recipeHandler.MatchPantry)
+			r.With(read).Post("/parse-ingredients", recipeHandler.ParseIngredients)
+			r.With(read).Get("/{id}", recipeHandler.GetRecipeByID)
+			r.With(read).Get("/author/{author_id}", recipeHandler.GetRecipesByAuthorID)
+			r.With(write).Put("/{id}", recipeHandler.UpdateRecipe)
+			r.With(write).Delete("/{id}", recipeHandler.DeleteRecipe)
+			r.With(read).Get("/{id}/reactions", reactionHandler.GetReactions(schema.TargetRecipe))
+			r.With(write).Put("/{id}/reactions/{reaction}", reactionHandler.React(schema.TargetRecipe))
+			r.With(write).Delete("/{id}/reactions/{reaction}", reactionHandler.Unreact(schema.TargetRecipe))
+			r.With(readComments).Get("/{id}/comments", commentHandler.GetComments(schema.TargetRecipe))
+			r.With(writeComments, requireVerified).Post("/{id}/comments", commentHandler.CreateComment(schema.TargetRecipe))
+			r.With(write).Put("/{id}/save", collectionHandler.SaveRecipe)
+			r.With(write).Delete("/{id}/save", collectionHandler.UnsaveRecipe)
+		})
+
+		// Collection routes
+		r.Route("/api/collections", func(r chi.Router) {
+			read := middleware.RequireScope(schema.ScopeRecipesRead)
+			write := middleware.RequireScope(schema.ScopeRecipesWrite)
+			r.With(read).Get("/", collectionHandler.GetMyCollections)
+			r.With(write).Post("/", collectionHandler.CreateCollection)
+			r.With(read).Get("/{id}", collectionHandler.GetCollection)
+			r.With(write).Patch("/{id}", collectionHandler.UpdateCollection)
+			r.With(write).Delete("/{id}", collectionHandler.DeleteCollection)
+			r.With(write).Post("/{id}/items", collectionHandler.AddItem)
+			r.With(write).Put("/{id}/items/order", collectionHandler.ReorderItems)
+			r.With(write).Patch("/{id}/items/{recipe_id}", collectionHandler.UpdateItem)
+			r.With(write).Delete("/{id}/items/{recipe_id}", collectionHandler.RemoveItem)
+		})
+
+		// Notification routes
+		r.Route("/api/notifications", func(r chi.Router) {
+			read := middleware.RequireScope(schema.ScopeNotificationsRead)
+			write := middleware.RequireScope(schema.ScopeNotificationsWrite)
+			r.With(read).Get("/", notificationHandler.GetNotifications)
+			r.With(read).Get("/unread-count", notificationHandler.GetUnreadCount)
+			r.With(write).Post("/read", notificationHandler.MarkAllRead)
+			r.With(write).Post("/{id}/read", notificationHandler.MarkRead)
+			r.With(read).Get("/preferences", notificationHandler.GetPreferences)
+			r.With(write).Put("/preferences", notificationHandler.UpdatePreferences)
 		})
 
+		// Real-time events
+		r.With(middleware.RequireScope(schema.ScopeNotificationsRead)).Get("/api/stream", streamHandler.Stream)
+
 		// Meal Plan routes
 		r.Route("/api/meal-plans", func(r chi.Router) {
-			r.Post("/", mealPlanHandler.CreateMealPlan)
-			r.Get("/author/{author_id}", mealPlanHandler.GetMealPlansByAuthorID)
-			r.Get("/{id}", mealPlanHandler.GetMealPlanByID)
-			r.Put("/{id}", mealPlanHandler.UpdateMealPlan)
-			r.Delete("/{id}", mealPlanHandler.DeleteMealPlan)
+			read := middleware.RequireScope(schema.ScopeMealPlansRead)
+			write := middleware.RequireScope(schema.ScopeMealPlansWrite)
+			r.With(write, requireVerified).Post("/", mealPlanHandler.CreateMealPlan)
+			r.With(read).Get("/author/{author_id}", mealPlanHandler.GetMealPlansByAuthorID)
+			r.With(read).Get("/{id}", mealPlanHandler.GetMealPlanByID)
+			r.With(write).Put("/{id}", mealPlanHandler.UpdateMealPlan)
+			r.With(write).Delete("/{id}", mealPlanHandler.DeleteMealPlan)
+		})
+
+		// Admin routes
+		r.Route("/api/admin", func(r chi.Router) {
+			r.Use(middleware.RequireSession)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(schema.RoleAdmin))
+				r.Get("/users", adminHandler.ListUsers)
+				r.Post("/users/{id}/suspend", adminHandler.SuspendUser)
+				r.Delete("/users/{id}/suspend", adminHandler.UnsuspendUser)
+				r.Put("/users/{id}/role", adminHandler.UpdateUserRole)
+			})
+
+			// Content removal is also open to moderators
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(schema.RoleModerator, schema.RoleAdmin))
+				r.Delete("/posts/{id}", adminHandler.DeletePost)
+				r.Delete("/recipes/{id}", adminHandler.DeleteRecipe)
+				r.Delete("/media/{id}", adminHandler.DeleteMedia)
+			})
 		})
 	})
 
@@ -4,39 +4,138 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
 	"github.com/smilecs/foody/utils"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
+// AuthMiddleware validates the bearer access token and checks that the
+// session it was issued for has not been revoked. Personal API keys are
+// accepted in place of an access token.
+func AuthMiddleware(manager *repository.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				return
+			}
 
-		// Check if the header has the Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
-		}
+			// Check if the header has the Bearer prefix
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				return
+			}
 
-		// Validate the token
-		claims, err := utils.ValidateToken(parts[1])
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+			if strings.HasPrefix(parts[1], schema.APIKeyPrefix) {
+				authenticateAPIKey(manager, w, r, next, parts[1])
+				return
+			}
+
+			// Validate the token
+			claims, err := config.Get().JWTKeys.ValidateToken(parts[1])
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			// Reject tokens whose session was logged out or revoked
+			session, err := manager.SessionRepo.GetSessionByID(claims.SessionID)
+			if err != nil || session.UserId != claims.UserID || !session.Active(time.Now()) {
+				http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			// Add the claims to the request context
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, "user_id", claims.UserID)
+			ctx = context.WithValue(ctx, "email", claims.Email)
+			ctx = context.WithValue(ctx, "role", claims.Role)
+			ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+			if claims.ExpiresAt != nil {
+				ctx = context.WithValue(ctx, "token_expires_at", claims.ExpiresAt.Time)
+			}
+
+			// Call the next handler with the updated context
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-		// Add the claims to the request context
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "user_id", claims.UserID)
-		ctx = context.WithValue(ctx, "email", claims.Email)
+// authenticateAPIKey serves the request as the owner of key. The key itself
+// is put in the context so RequireScope can check what it may do.
+func authenticateAPIKey(manager *repository.Manager, w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	now := time.Now()
+	apiKey, err := manager.APIKeyRepo.GetAPIKeyByHash(utils.HashToken(key))
+	if err != nil || !apiKey.Active(now) {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
 
-		// Call the next handler with the updated context
-		next.ServeHTTP(w, r.WithContext(ctx))
+	// Keys stop working while the account is suspended or being deleted
+	user, err := manager.UserRepo.GetUserByID(apiKey.UserId)
+	if err != nil || user.SuspendedAt != nil || user.DeletionScheduledAt != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	// A failed timestamp update should not fail the request
+	manager.APIKeyRepo.TouchAPIKey(apiKey.Id, now)
+
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, "user_id", user.Id)
+	ctx = context.WithValue(ctx, "email", user.Email)
+	ctx = context.WithValue(ctx, "role", user.Role)
+	ctx = context.WithValue(ctx, "api_key", apiKey)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireScope rejects requests made with an API key that was not granted
+// scope. Requests made with an access token are let through. It must run
+// after AuthMiddleware.
+func RequireScope(scope schema.APIScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey, ok := r.Context().Value("api_key").(*schema.APIKey); ok && !apiKey.Allows(scope) {
+				http.Error(w, "API key is missing scope "+string(scope), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects requests made with an API key, keeping account
+// management to interactive logins. It must run after AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("api_key").(*schema.APIKey); ok {
+			http.Error(w, "API keys cannot be used for this endpoint", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireRole only lets requests through whose token carries one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...schema.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("role").(schema.Role)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		})
+	}
+}
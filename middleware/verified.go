@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/repository"
+)
+
+// RequireVerifiedEmail rejects requests from users who have not verified
+// their email yet, when the policy is enabled in config. It must run after
+// AuthMiddleware.
+func RequireVerifiedEmail(manager *repository.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !config.Get().RequireVerifiedEmail {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, ok := r.Context().Value("user_id").(uuid.UUID)
+			if !ok {
+				http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := manager.UserRepo.GetUserByID(userID)
+			if err != nil {
+				http.Error(w, "User not found", http.StatusUnauthorized)
+				return
+			}
+
+			if !user.EmailVerified {
+				http.Error(w, "Verify your email address to continue", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
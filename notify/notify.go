@@ -0,0 +1,119 @@
+// Package notify records in-app notifications. Handlers emit an Event once
+// an action has succeeded; the service drops events the recipient does not
+// want and merges unread events about the same item.
+package notify
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/pubsub"
+	"github.com/smilecs/foody/repository"
+	"github.com/smilecs/foody/schema"
+)
+
+// Event is something a user should hear about. TargetType and TargetId are
+// left empty for events about the recipient themselves, such as follows.
+type Event struct {
+	Type        schema.NotificationType
+	RecipientId uuid.UUID
+	ActorId     uuid.UUID
+	TargetType  schema.TargetType
+	TargetId    uuid.UUID
+}
+
+// GroupKey returns the key unread notifications are merged on. Events of
+// types that do not aggregate get a key of their own.
+func (e Event) GroupKey() string {
+	if !e.Type.Aggregates() {
+		return string(e.Type) + ":" + uuid.New().String()
+	}
+	if e.TargetType == "" {
+		return string(e.Type)
+	}
+	return string(e.Type) + ":" + string(e.TargetType) + ":" + e.TargetId.String()
+}
+
+type Service struct {
+	Manager *repository.Manager
+}
+
+func New(manager *repository.Manager) *Service {
+	return &Service{Manager: manager}
+}
+
+// Notify records event for its recipient. Nobody is notified about their
+// own actions, and types the recipient turned off are dropped. Errors are
+// logged rather than returned: a lost notification must not fail the
+// action that caused it.
+func (s *Service) Notify(event Event) {
+	if event.RecipientId == event.ActorId {
+		return
+	}
+
+	enabled, err := s.Enabled(event.RecipientId, event.Type)
+	if err != nil {
+		log.Printf("error loading notification preferences: %v\n", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	notification := schema.Notification{
+		Id:       uuid.New(),
+		UserId:   event.RecipientId,
+		Type:     event.Type,
+		GroupKey: event.GroupKey(),
+	}
+	if event.TargetType != "" {
+		notification.TargetType = &event.TargetType
+		notification.TargetId = &event.TargetId
+	}
+	if err := s.Manager.NotificationRepo.AddNotification(notification, event.ActorId); err != nil {
+		log.Printf("error recording %s notification: %v\n", event.Type, err)
+		return
+	}
+	s.publish(event.RecipientId)
+}
+
+// publish pushes the user's most recently updated notification, which is
+// the one just recorded, to their open streams.
+func (s *Service) publish(userID uuid.UUID) {
+	notifications, err := s.Manager.NotificationRepo.GetNotifications(userID, true, 1, 0)
+	if err != nil || len(notifications) == 0 {
+		return
+	}
+	notification := notifications[0]
+	notification.Message = notification.Summary()
+	config.Get().Hub.Publish(pubsub.UserTopic(userID), pubsub.Event{Type: pubsub.EventNotification, Data: notification})
+}
+
+// Enabled reports whether userID wants notifications of type t.
+func (s *Service) Enabled(userID uuid.UUID, t schema.NotificationType) (bool, error) {
+	preferences, err := s.Preferences(userID)
+	if err != nil {
+		return false, err
+	}
+	return preferences[t], nil
+}
+
+// Preferences returns whether userID wants each type of notification.
+func (s *Service) Preferences(userID uuid.UUID) (map[schema.NotificationType]bool, error) {
+	stored, err := s.Manager.NotificationRepo.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[schema.NotificationType]bool, len(schema.NotificationTypes))
+	for _, t := range schema.NotificationTypes {
+		preferences[t] = true
+	}
+	for _, preference := range stored {
+		if preference.Type.Valid() {
+			preferences[preference.Type] = preference.Enabled
+		}
+	}
+	return preferences, nil
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestEvent_GroupKey(t *testing.T) {
+	postID := uuid.New()
+	like := Event{Type: schema.NotifyLike, ActorId: uuid.New(), TargetType: schema.TargetPost, TargetId: postID}
+	otherLike := Event{Type: schema.NotifyLike, ActorId: uuid.New(), TargetType: schema.TargetPost, TargetId: postID}
+	if like.GroupKey() != otherLike.GroupKey() {
+		t.Error("Expected likes on the same post to share a group key")
+	}
+
+	comment := Event{Type: schema.NotifyComment, TargetType: schema.TargetPost, TargetId: postID}
+	if like.GroupKey() == comment.GroupKey() {
+		t.Error("Expected different types to have different group keys")
+	}
+
+	otherPost := Event{Type: schema.NotifyLike, TargetType: schema.TargetPost, TargetId: uuid.New()}
+	if like.GroupKey() == otherPost.GroupKey() {
+		t.Error("Expected likes on different posts to have different group keys")
+	}
+
+	follow := Event{Type: schema.NotifyFollow, ActorId: uuid.New()}
+	if follow.GroupKey() != (Event{Type: schema.NotifyFollow, ActorId: uuid.New()}).GroupKey() {
+		t.Error("Expected follows to share a group key")
+	}
+
+	mention := Event{Type: schema.NotifyMention, TargetType: schema.TargetComment, TargetId: postID}
+	if mention.GroupKey() == mention.GroupKey() {
+		t.Error("Expected mentions never to be merged")
+	}
+}
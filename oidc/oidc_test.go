@@ -0,0 +1,209 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/smilecs/foody/oidc/oidctest"
+)
+
+const testRedirectURL = "http://localhost:8080/auth/oidc/test/callback"
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *Provider) {
+	t.Helper()
+	server := oidctest.NewServer("client", "secret")
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{
+		Name:         "test",
+		Issuer:       server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+	})
+	return server, provider
+}
+
+func TestLoginFlow(t *testing.T) {
+	server, provider := newTestProvider(t)
+	server.SetUser(oidctest.User{Subject: "abc123", Email: "cook@example.com", EmailVerified: true, Name: "Cook"})
+	ctx := context.Background()
+
+	verifier, _ := RandomString()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	for _, scope := range []string{"openid", "email", "profile"} {
+		if !strings.Contains(parsed.Query().Get("scope"), scope) {
+			t.Errorf("Expected scope %q in %q", scope, parsed.Query().Get("scope"))
+		}
+	}
+
+	callback, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if callback.Query().Get("state") != "state-1" {
+		t.Errorf("Expected state to round-trip, got %q", callback.Query().Get("state"))
+	}
+	code := callback.Query().Get("code")
+
+	// The code is bound to the PKCE verifier
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("Expected exchange with the wrong verifier to fail")
+	}
+
+	callback, _ = server.Authorize(authURL)
+	tokens, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if idToken.Subject != "abc123" || idToken.Email != "cook@example.com" || !bool(idToken.EmailVerified) || idToken.Name != "Cook" {
+		t.Errorf("Unexpected claims: %+v", idToken)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, tokens.IDToken, "other-nonce"); err == nil {
+		t.Error("Expected nonce mismatch to fail")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newTestProvider(t)
+	ctx := context.Background()
+	user := oidctest.User{Subject: "abc123", Email: "cook@example.com"}
+
+	tests := []struct {
+		name    string
+		modify  func(jwt.MapClaims)
+		wantErr bool
+	}{
+		{
+			name:   "Valid token",
+			modify: func(jwt.MapClaims) {},
+		},
+		{
+			name:    "Wrong issuer",
+			modify:  func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "Wrong audience",
+			modify:  func(c jwt.MapClaims) { c["aud"] = "other-client" },
+			wantErr: true,
+		},
+		{
+			name:    "Expired",
+			modify:  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			wantErr: true,
+		},
+		{
+			name:    "Missing subject",
+			modify:  func(c jwt.MapClaims) { delete(c, "sub") },
+			wantErr: true,
+		},
+		{
+			name:    "Several audiences without authorized party",
+			modify:  func(c jwt.MapClaims) { c["aud"] = []string{"client", "other-client"} },
+			wantErr: true,
+		},
+		{
+			name: "Several audiences issued to us",
+			modify: func(c jwt.MapClaims) {
+				c["aud"] = []string{"client", "other-client"}
+				c["azp"] = "client"
+			},
+		},
+		{
+			name:   "Email verified sent as a string",
+			modify: func(c jwt.MapClaims) { c["email_verified"] = "true" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := server.IDTokenClaims(user, "nonce")
+			tt.modify(claims)
+
+			_, err := provider.VerifyIDToken(ctx, server.SignIDToken(claims), "nonce")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Tokens signed by anyone else are rejected
+	other, _ := newTestProvider(t)
+	if _, err := provider.VerifyIDToken(ctx, other.SignIDToken(server.IDTokenClaims(user, "nonce")), "nonce"); err == nil {
+		t.Error("Expected token signed with a foreign key to fail")
+	}
+}
+
+func TestVerifyIDToken_KeyRotation(t *testing.T) {
+	server, provider := newTestProvider(t)
+	ctx := context.Background()
+	user := oidctest.User{Subject: "abc123"}
+
+	if _, err := provider.VerifyIDToken(ctx, server.SignIDToken(server.IDTokenClaims(user, "n")), "n"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	server.RotateKey()
+	rotated := server.SignIDToken(server.IDTokenClaims(user, "n"))
+
+	// Unknown kids only trigger a fetch once per interval
+	if _, err := provider.VerifyIDToken(ctx, rotated, "n"); err == nil {
+		t.Error("Expected the key set not to be refetched right away")
+	}
+
+	defer func(interval time.Duration) { keyRefreshInterval = interval }(keyRefreshInterval)
+	keyRefreshInterval = 0
+	if _, err := provider.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Errorf("Expected rotated key to be picked up: %v", err)
+	}
+}
+
+func TestDiscover_IssuerMismatch(t *testing.T) {
+	server, _ := newTestProvider(t)
+	provider := NewProvider(Config{Issuer: server.URL + "/", ClientID: "client"})
+
+	if _, err := provider.Discover(context.Background()); err == nil {
+		t.Error("Expected discovery to reject a different issuer")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "Google, ")
+	t.Setenv("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "client")
+
+	providers, err := FromEnv("https://foody.example.com/")
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	google, ok := providers["google"]
+	if !ok || len(providers) != 1 {
+		t.Fatalf("Expected only the google provider, got %v", providers)
+	}
+	if google.RedirectURL != "https://foody.example.com/auth/oidc/google/callback" {
+		t.Errorf("Unexpected redirect URL %q", google.RedirectURL)
+	}
+	if len(google.Scopes) != 3 {
+		t.Errorf("Expected default scopes, got %v", google.Scopes)
+	}
+
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "")
+	if _, err := FromEnv(""); err == nil {
+		t.Error("Expected missing client ID to fail")
+	}
+}
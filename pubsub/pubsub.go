@@ -0,0 +1,120 @@
+// Package pubsub delivers real-time events to connected clients. Events are
+// published to topics such as a user or an item, and every subscriber of
+// the topic receives them. Delivery is best effort: subscribers that fall
+// behind miss events rather than slowing down publishers.
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is a message pushed to subscribers. Type names the kind of event,
+// such as "notification", and Data is sent to clients as JSON.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Event types pushed to clients.
+const (
+	EventNotification = "notification"
+	EventComment      = "comment"
+	EventPost         = "post"
+)
+
+// Hub routes published events to the subscribers of their topic. The
+// in-process MemoryHub only reaches clients connected to the same server;
+// a broker-backed Hub can replace it when running several instances.
+type Hub interface {
+	// Publish sends event to everyone subscribed to topic.
+	Publish(topic string, event Event)
+	// Subscribe starts receiving events published to any of topics. The
+	// returned function ends the subscription and closes the channel.
+	Subscribe(topics ...string) (<-chan Event, func())
+}
+
+// UserTopic carries events addressed to a single user, such as their
+// notifications.
+func UserTopic(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+// AuthorTopic carries the new posts of an author, for their followers'
+// feeds.
+func AuthorTopic(authorID uuid.UUID) string {
+	return "author:" + authorID.String()
+}
+
+// ItemTopic carries activity on a post or recipe, such as new comments, for
+// clients that have it open.
+func ItemTopic(itemType string, itemID uuid.UUID) string {
+	return itemType + ":" + itemID.String()
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind by
+// before events are dropped.
+const subscriberBuffer = 32
+
+type subscriber struct {
+	events chan Event
+}
+
+// MemoryHub is a Hub that keeps subscriptions in memory.
+type MemoryHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*subscriber]struct{}
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{topics: make(map[string]map[*subscriber]struct{})}
+}
+
+func (h *MemoryHub) Publish(topic string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.topics[topic] {
+		select {
+		case sub.events <- event:
+		default:
+			// The subscriber is not keeping up; drop the event
+		}
+	}
+}
+
+func (h *MemoryHub) Subscribe(topics ...string) (<-chan Event, func()) {
+	sub := &subscriber{events: make(chan Event, subscriberBuffer)}
+
+	h.mu.Lock()
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*subscriber]struct{})
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			for _, topic := range topics {
+				delete(h.topics[topic], sub)
+				if len(h.topics[topic]) == 0 {
+					delete(h.topics, topic)
+				}
+			}
+			close(sub.events)
+		})
+	}
+	return sub.events, cancel
+}
+
+// Subscribers returns how many subscriptions topic has.
+func (h *MemoryHub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryHub(t *testing.T) {
+	hub := NewMemoryHub()
+	userID := uuid.New()
+	postTopic := ItemTopic("post", uuid.New())
+
+	events, cancel := hub.Subscribe(UserTopic(userID), postTopic)
+	other, cancelOther := hub.Subscribe(postTopic)
+	defer cancelOther()
+
+	hub.Publish(UserTopic(userID), Event{Type: EventNotification, Data: "hello"})
+	hub.Publish(postTopic, Event{Type: EventComment, Data: "nice"})
+	hub.Publish(UserTopic(uuid.New()), Event{Type: EventNotification, Data: "not for you"})
+
+	if event := <-events; event.Type != EventNotification || event.Data != "hello" {
+		t.Errorf("Unexpected first event: %+v", event)
+	}
+	if event := <-events; event.Type != EventComment {
+		t.Errorf("Unexpected second event: %+v", event)
+	}
+	if event := <-other; event.Type != EventComment {
+		t.Errorf("Expected other subscriber to get the comment, got %+v", event)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event for another user: %+v", event)
+	default:
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	if n := hub.Subscribers(UserTopic(userID)); n != 0 {
+		t.Errorf("Expected no subscribers left, got %d", n)
+	}
+	if n := hub.Subscribers(postTopic); n != 1 {
+		t.Errorf("Expected 1 subscriber left, got %d", n)
+	}
+	// Publishing after cancel must not panic
+	hub.Publish(postTopic, Event{Type: EventComment})
+}
+
+func TestMemoryHub_SlowSubscriber(t *testing.T) {
+	hub := NewMemoryHub()
+	events, cancel := hub.Subscribe("topic")
+	defer cancel()
+
+	// A subscriber that stops reading does not block publishers
+	for i := 0; i < subscriberBuffer*2; i++ {
+		hub.Publish("topic", Event{Type: EventPost, Data: i})
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("Expected %d buffered events, got %d", subscriberBuffer, len(events))
+	}
+	if event := <-events; event.Data != 0 {
+		t.Errorf("Expected oldest event first, got %+v", event)
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type APIKeyRepository struct {
+	Database config.Database
+}
+
+func NewAPIKeyRepository(db config.Database) *APIKeyRepository {
+	return &APIKeyRepository{Database: db}
+}
+
+const apiKeyColumns = `api_key_id, user_id, name, prefix, key_hash, scopes, last_used_at, expires_at, revoked_at, created_at`
+
+func (r *APIKeyRepository) CreateAPIKey(key schema.APIKey) error {
+	query := `
+		INSERT INTO api_keys (api_key_id, user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.Database.Exec(query, key.Id, key.UserId, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt)
+	if err != nil {
+		log.Printf("error creating api key: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(hash string) (*schema.APIKey, error) {
+	var key schema.APIKey
+	err := r.Database.QueryRowx("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash).StructScan(&key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeysByUserID lists a user's keys that have not been revoked.
+func (r *APIKeyRepository) GetAPIKeysByUserID(userID uuid.UUID) ([]schema.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC"
+	rows, err := r.Database.Queryx(query, userID)
+	if err != nil {
+		log.Printf("error fetching api keys: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []schema.APIKey
+	for rows.Next() {
+		var key schema.APIKey
+		if err := rows.StructScan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes one of the user's keys. It returns sql.ErrNoRows if
+// the user has no such active key.
+func (r *APIKeyRepository) RevokeAPIKey(userID, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys SET revoked_at = $1
+		WHERE api_key_id = $2 AND user_id = $3 AND revoked_at IS NULL
+		RETURNING api_key_id
+	`
+	var revoked uuid.UUID
+	return r.Database.QueryRowx(query, time.Now(), id, userID).Scan(&revoked)
+}
+
+// TouchAPIKey records that the key was used. Writes are limited to one a
+// minute per key so busy scripts don't turn every request into an update.
+func (r *APIKeyRepository) TouchAPIKey(id uuid.UUID, now time.Time) error {
+	query := `
+		UPDATE api_keys SET last_used_at = $1
+		WHERE api_key_id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+	_, err := r.Database.Exec(query, now, id, now.Add(-time.Minute))
+	if err != nil {
+		log.Printf("error updating api key last use: %v\n", err)
+		return err
+	}
+	return nil
+}
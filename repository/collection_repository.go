@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// ErrCollectionOrder is returned by ReorderItems when the new order does
+// not list every recipe of the collection exactly once.
+var ErrCollectionOrder = errors.New("order must list every recipe in the collection exactly once")
+
+type CollectionRepository struct {
+	Database config.Database
+}
+
+func NewCollectionRepository(db config.Database) *CollectionRepository {
+	return &CollectionRepository{Database: db}
+}
+
+const collectionSelect = `
+	SELECT c.collection_id, c.user_id, c.name, c.description, c.is_public, c.is_default,
+		(SELECT COUNT(*) FROM collection_items i WHERE i.collection_id = c.collection_id) AS item_count,
+		c.created_at, c.updated_at
+	FROM collections c
+`
+
+func (r *CollectionRepository) CreateCollection(collection schema.Collection) error {
+	query := `
+		INSERT INTO collections (collection_id, user_id, name, description, is_public, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.Database.Exec(query, collection.Id, collection.UserId, collection.Name, collection.Description, collection.IsPublic, collection.IsDefault)
+	if err != nil {
+		log.Printf("error creating collection: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *CollectionRepository) GetCollectionByID(id uuid.UUID) (*schema.Collection, error) {
+	var collection schema.Collection
+	if err := r.Database.QueryRowx(collectionSelect+" WHERE c.collection_id = $1", id).StructScan(&collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetDefaultCollection returns the user's "Saved" collection, creating it
+// the first time it is needed.
+func (r *CollectionRepository) GetDefaultCollection(userID uuid.UUID) (*schema.Collection, error) {
+	query := `
+		INSERT INTO collections (collection_id, user_id, name, is_default)
+		VALUES ($1, $2, $3, TRUE)
+		ON CONFLICT (user_id) WHERE is_default DO NOTHING
+	`
+	if _, err := r.Database.Exec(query, uuid.New(), userID, schema.DefaultCollectionName); err != nil {
+		log.Printf("error creating default collection: %v\n", err)
+		return nil, err
+	}
+
+	var collection schema.Collection
+	if err := r.Database.QueryRowx(collectionSelect+" WHERE c.user_id = $1 AND c.is_default", userID).StructScan(&collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetCollectionsByUserID lists a user's collections, the default one first
+// and the rest by name. Private collections are left out unless
+// includePrivate is set.
+func (r *CollectionRepository) GetCollectionsByUserID(userID uuid.UUID, includePrivate bool) ([]schema.Collection, error) {
+	query := collectionSelect + `
+		WHERE c.user_id = $1 AND (c.is_public OR $2)
+		ORDER BY c.is_default DESC, LOWER(c.name), c.created_at
+	`
+	rows, err := r.Database.Queryx(query, userID, includePrivate)
+	if err != nil {
+		log.Printf("error fetching collections: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []schema.Collection
+	for rows.Next() {
+		var collection schema.Collection
+		if err := rows.StructScan(&collection); err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// UpdateCollection saves the name, description and visibility of a
+// collection. It returns sql.ErrNoRows if there is no such collection.
+func (r *CollectionRepository) UpdateCollection(collection schema.Collection) error {
+	query := `
+		UPDATE collections SET name = $1, description = $2, is_public = $3, updated_at = $4
+		WHERE collection_id = $5
+		RETURNING collection_id
+	`
+	var updated uuid.UUID
+	return r.Database.QueryRowx(query, collection.Name, collection.Description, collection.IsPublic, time.Now(), collection.Id).Scan(&updated)
+}
+
+// DeleteCollection removes a collection and its items. It returns
+// sql.ErrNoRows if there is no such collection.
+func (r *CollectionRepository) DeleteCollection(id uuid.UUID) error {
+	var deleted uuid.UUID
+	return r.Database.QueryRowx(`DELETE FROM collections WHERE collection_id = $1 RETURNING collection_id`, id).Scan(&deleted)
+}
+
+// AddItem appends a recipe to the end of a collection and reports whether
+// it was added. Adding a recipe that is already there changes nothing.
+func (r *CollectionRepository) AddItem(item schema.CollectionItem) (added bool, err error) {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Concurrent adds would otherwise read the same last position
+	if err = lockCollection(tx, item.CollectionId); err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO collection_items (collection_id, recipe_id, position, note)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0), $3
+		FROM collection_items WHERE collection_id = $1
+		ON CONFLICT (collection_id, recipe_id) DO NOTHING
+	`
+	result, err := tx.Exec(query, item.CollectionId, item.RecipeId, item.Note)
+	if err != nil {
+		log.Printf("error adding collection item: %v\n", err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, tx.Commit()
+}
+
+// lockCollection locks a collection's row until tx ends, so changes to the
+// positions of its items happen one at a time. It returns sql.ErrNoRows if
+// there is no such collection.
+func lockCollection(tx *sqlx.Tx, collectionID uuid.UUID) error {
+	var locked uuid.UUID
+	return tx.QueryRowx(`SELECT collection_id FROM collections WHERE collection_id = $1 FOR UPDATE`, collectionID).Scan(&locked)
+}
+
+func (r *CollectionRepository) GetItem(collectionID, recipeID uuid.UUID) (*schema.CollectionItem, error) {
+	var item schema.CollectionItem
+	query := `
+		SELECT collection_id, recipe_id, position, note, added_at
+		FROM collection_items WHERE collection_id = $1 AND recipe_id = $2
+	`
+	if err := r.Database.QueryRowx(query, collectionID, recipeID).StructScan(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetItems lists the items of a collection in order.
+func (r *CollectionRepository) GetItems(collectionID uuid.UUID, limit, offset int) ([]schema.CollectionItem, error) {
+	query := `
+		SELECT collection_id, recipe_id, position, note, added_at
+		FROM collection_items
+		WHERE collection_id = $1
+		ORDER BY position, added_at
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.Database.Queryx(query, collectionID, limit, offset)
+	if err != nil {
+		log.Printf("error fetching collection items: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []schema.CollectionItem
+	for rows.Next() {
+		var item schema.CollectionItem
+		if err := rows.StructScan(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// UpdateItemNote replaces the note on a recipe in a collection. It returns
+// sql.ErrNoRows if the recipe is not in the collection.
+func (r *CollectionRepository) UpdateItemNote(collectionID, recipeID uuid.UUID, note string) error {
+	query := `
+		UPDATE collection_items SET note = $1
+		WHERE collection_id = $2 AND recipe_id = $3
+		RETURNING recipe_id
+	`
+	var updated uuid.UUID
+	return r.Database.QueryRowx(query, note, collectionID, recipeID).Scan(&updated)
+}
+
+// RemoveItem takes a recipe out of a collection. It returns sql.ErrNoRows
+// if the recipe is not in the collection.
+func (r *CollectionRepository) RemoveItem(collectionID, recipeID uuid.UUID) error {
+	query := `DELETE FROM collection_items WHERE collection_id = $1 AND recipe_id = $2 RETURNING recipe_id`
+	var removed uuid.UUID
+	return r.Database.QueryRowx(query, collectionID, recipeID).Scan(&removed)
+}
+
+// ReorderItems puts the recipes of a collection in the given order, which
+// must list each of them exactly once.
+func (r *CollectionRepository) ReorderItems(collectionID uuid.UUID, recipeIDs []uuid.UUID) error {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the collection so a concurrent add cannot slip past the check
+	if err = lockCollection(tx, collectionID); err != nil {
+		return err
+	}
+	var current []uuid.UUID
+	err = tx.Select(&current, `SELECT recipe_id FROM collection_items WHERE collection_id = $1 FOR UPDATE`, collectionID)
+	if err != nil {
+		log.Printf("error fetching collection items: %v\n", err)
+		return err
+	}
+	if !samePermutation(current, recipeIDs) {
+		err = ErrCollectionOrder
+		return err
+	}
+
+	for position, recipeID := range recipeIDs {
+		_, err = tx.Exec(`UPDATE collection_items SET position = $1 WHERE collection_id = $2 AND recipe_id = $3`, position, collectionID, recipeID)
+		if err != nil {
+			log.Printf("error reordering collection items: %v\n", err)
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE collections SET updated_at = $1 WHERE collection_id = $2`, time.Now(), collectionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// samePermutation reports whether order lists every id in ids exactly once.
+func samePermutation(ids, order []uuid.UUID) bool {
+	if len(ids) != len(order) {
+		return false
+	}
+	remaining := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for _, id := range order {
+		if !remaining[id] {
+			return false
+		}
+		delete(remaining, id)
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+func TestCollectionRepository_AddItem(t *testing.T) {
+	collectionID := uuid.New()
+	item := schema.CollectionItem{CollectionId: collectionID, RecipeId: uuid.New(), Note: "Sunday"}
+
+	tests := []struct {
+		name          string
+		collection    [][]driver.Value
+		expectedErr   error
+		expectedAdded bool
+		expected      []string
+	}{
+		{
+			name:          "Collection is locked before the position is read",
+			collection:    [][]driver.Value{{collectionID.String()}},
+			expectedAdded: true,
+			expected:      []string{"BEGIN", "SELECT collection_id FROM", "INSERT INTO collection_items", "COMMIT"},
+		},
+		{
+			name:        "Missing collection",
+			expectedErr: sql.ErrNoRows,
+			expected:    []string{"BEGIN", "SELECT collection_id FROM", "ROLLBACK"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			repo := NewCollectionRepository(db)
+			fake.queue([]string{"collection_id"}, tt.collection...)
+
+			added, err := repo.AddItem(item)
+			if err != tt.expectedErr {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if added != tt.expectedAdded {
+				t.Errorf("Expected added %v, got %v", tt.expectedAdded, added)
+			}
+
+			got := statementPrefixes(fake.statements)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Fatalf("Expected statements %v, got %v", tt.expected, got)
+			}
+			if !strings.Contains(fake.statements[1], "FOR UPDATE") {
+				t.Errorf("Expected the collection row to be locked, got %q", fake.statements[1])
+			}
+		})
+	}
+}
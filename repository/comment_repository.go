@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type CommentRepository struct {
+	Database config.Database
+}
+
+func NewCommentRepository(db config.Database) *CommentRepository {
+	return &CommentRepository{Database: db}
+}
+
+// commentSelect loads comments together with their author's username, the
+// usernames they mention and how many replies they have.
+const commentSelect = `
+	SELECT c.comment_id,
+		CASE WHEN c.post_id IS NOT NULL THEN 'post' ELSE 'recipe' END AS target_type,
+		COALESCE(c.post_id, c.recipe_id) AS target_id,
+		c.parent_id, c.author_id, u.username AS author_username, c.body,
+		ARRAY(
+			SELECT mu.username FROM comment_mentions cm
+			JOIN users mu ON mu.user_id = cm.user_id
+			WHERE cm.comment_id = c.comment_id
+			ORDER BY mu.username
+		) AS mentions,
+		(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.comment_id) AS reply_count,
+		c.created_at, c.updated_at, c.edited_at
+	FROM comments c
+	JOIN users u ON u.user_id = c.author_id
+`
+
+// CreateComment stores a comment and the users it mentions.
+func (r *CommentRepository) CreateComment(comment schema.Comment, mentions []uuid.UUID) error {
+	column, err := targetColumn(comment.TargetType)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := fmt.Sprintf(`
+		INSERT INTO comments (comment_id, %s, parent_id, author_id, body)
+		VALUES ($1, $2, $3, $4, $5)
+	`, column)
+	_, err = tx.Exec(query, comment.Id, comment.TargetId, comment.ParentId, comment.AuthorId, comment.Body)
+	if err != nil {
+		log.Printf("error creating comment: %v\n", err)
+		return err
+	}
+
+	for _, userID := range mentions {
+		_, err = tx.Exec(`INSERT INTO comment_mentions (comment_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, comment.Id, userID)
+		if err != nil {
+			log.Printf("error storing comment mention: %v\n", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *CommentRepository) GetCommentByID(id uuid.UUID) (*schema.Comment, error) {
+	var comment schema.Comment
+	if err := r.Database.QueryRowx(commentSelect+" WHERE c.comment_id = $1", id).StructScan(&comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetComments lists the top-level comments on an item, oldest first.
+func (r *CommentRepository) GetComments(targetType schema.TargetType, targetID uuid.UUID, limit, offset int) ([]schema.Comment, error) {
+	column, err := targetColumn(targetType)
+	if err != nil {
+		return nil, err
+	}
+	query := commentSelect + fmt.Sprintf(`
+		WHERE c.%s = $1 AND c.parent_id IS NULL
+		ORDER BY c.created_at, c.id
+		LIMIT $2 OFFSET $3
+	`, column)
+	return r.listComments(query, targetID, limit, offset)
+}
+
+// CountComments counts the top-level comments on an item.
+func (r *CommentRepository) CountComments(targetType schema.TargetType, targetID uuid.UUID) (int, error) {
+	column, err := targetColumn(targetType)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM comments WHERE %s = $1 AND parent_id IS NULL`, column)
+	if err := r.Database.QueryRowx(query, targetID).Scan(&count); err != nil {
+		log.Printf("error counting comments: %v\n", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetReplies lists the replies to a comment, oldest first.
+func (r *CommentRepository) GetReplies(parentID uuid.UUID, limit, offset int) ([]schema.Comment, error) {
+	query := commentSelect + `
+		WHERE c.parent_id = $1
+		ORDER BY c.created_at, c.id
+		LIMIT $2 OFFSET $3
+	`
+	return r.listComments(query, parentID, limit, offset)
+}
+
+func (r *CommentRepository) listComments(query string, args ...interface{}) ([]schema.Comment, error) {
+	rows, err := r.Database.Queryx(query, args...)
+	if err != nil {
+		log.Printf("error fetching comments: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []schema.Comment
+	for rows.Next() {
+		var comment schema.Comment
+		if err := rows.StructScan(&comment); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// UpdateComment replaces the body of a comment and the users it mentions.
+func (r *CommentRepository) UpdateComment(id uuid.UUID, body string, mentions []uuid.UUID) error {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	var updated uuid.UUID
+	err = tx.QueryRowx(`
+		UPDATE comments SET body = $1, edited_at = $2, updated_at = $2
+		WHERE comment_id = $3
+		RETURNING comment_id
+	`, body, now, id).Scan(&updated)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM comment_mentions WHERE comment_id = $1`, id); err != nil {
+		log.Printf("error clearing comment mentions: %v\n", err)
+		return err
+	}
+	for _, userID := range mentions {
+		_, err = tx.Exec(`INSERT INTO comment_mentions (comment_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, id, userID)
+		if err != nil {
+			log.Printf("error storing comment mention: %v\n", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteComment removes a comment together with its replies. It returns
+// sql.ErrNoRows if there is no such comment.
+func (r *CommentRepository) DeleteComment(id uuid.UUID) error {
+	var deleted uuid.UUID
+	return r.Database.QueryRowx(`DELETE FROM comments WHERE comment_id = $1 RETURNING comment_id`, id).Scan(&deleted)
+}
+
+// GetCommentCounts counts the comments, replies included, on each of the
+// given items. Every requested item gets a count.
+func (r *CommentRepository) GetCommentCounts(targetType schema.TargetType, targetIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	counts := make(map[uuid.UUID]int, len(targetIDs))
+	for _, id := range targetIDs {
+		counts[id] = 0
+	}
+	if len(targetIDs) == 0 {
+		return counts, nil
+	}
+
+	column, err := targetColumn(targetType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`
+		SELECT %[1]s AS target_id, COUNT(*) AS count
+		FROM comments
+		WHERE %[1]s = ANY($1)
+		GROUP BY %[1]s
+	`, column)
+
+	rows, err := r.Database.Queryx(query, pq.Array(targetIDs))
+	if err != nil {
+		log.Printf("error counting comments: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/smilecs/foody/config"
+)
+
+// fakeRows is a result set a fakeDB hands out, shaped like Postgres
+// returns it: named columns with driver values.
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// fakeDB is a database/sql driver that answers queries with queued result
+// sets and records every statement, so repositories can be tested against
+// the real scanning code without a Postgres server.
+type fakeDB struct {
+	mu         sync.Mutex
+	results    []fakeRows
+	statements []string
+	args       [][]driver.Value
+}
+
+// newFakeDB returns a database backed by a fakeDB.
+func newFakeDB(t *testing.T) (config.Database, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{}
+	db := sqlx.NewDb(sql.OpenDB(fake), "postgres")
+	t.Cleanup(func() { db.Close() })
+	return &config.SQLDatabase{DB: db}, fake
+}
+
+// queue adds a result set for the next query.
+func (f *fakeDB) queue(columns []string, values ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, fakeRows{columns: columns, values: values})
+}
+
+func (f *fakeDB) record(query string, args []driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, query)
+	f.args = append(f.args, args)
+}
+
+func (f *fakeDB) next() (*fakeRows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.results) == 0 {
+		return nil, errors.New("fakedb: no result queued")
+	}
+	rows := f.results[0]
+	f.results = f.results[1:]
+	return &rows, nil
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+
+// Transactions are recorded as BEGIN, COMMIT and ROLLBACK statements.
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.record("COMMIT", nil)
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.record(s.query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.record(s.query, args)
+	rows, err := s.db.next()
+	if err != nil {
+		return nil, err
+	}
+	return &fakeCursor{rows: rows}, nil
+}
+
+type fakeCursor struct {
+	rows *fakeRows
+	pos  int
+}
+
+func (c *fakeCursor) Columns() []string { return c.rows.columns }
+func (c *fakeCursor) Close() error      { return nil }
+
+func (c *fakeCursor) Next(dest []driver.Value) error {
+	if c.pos == len(c.rows.values) {
+		return io.EOF
+	}
+	copy(dest, c.rows.values[c.pos])
+	c.pos++
+	return nil
+}
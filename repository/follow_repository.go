@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type FollowRepository struct {
+	Database config.Database
+}
+
+func NewFollowRepository(db config.Database) *FollowRepository {
+	return &FollowRepository{Database: db}
+}
+
+const followColumns = `follower_id, followee_id, status, created_at, accepted_at`
+
+// CreateFollow records a follow or follow request and returns the stored
+// follow. Following someone twice keeps the existing follow unchanged.
+func (r *FollowRepository) CreateFollow(follow schema.Follow) (*schema.Follow, error) {
+	query := `
+		INSERT INTO follows (follower_id, followee_id, status, accepted_at)
+		VALUES ($1, $2, $3, CASE WHEN $3 = 'accepted' THEN CURRENT_TIMESTAMP END)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING
+	`
+	_, err := r.Database.Exec(query, follow.FollowerId, follow.FolloweeId, follow.Status)
+	if err != nil {
+		log.Printf("error creating follow: %v\n", err)
+		return nil, err
+	}
+	return r.GetFollow(follow.FollowerId, follow.FolloweeId)
+}
+
+func (r *FollowRepository) GetFollow(followerID, followeeID uuid.UUID) (*schema.Follow, error) {
+	var follow schema.Follow
+	query := "SELECT " + followColumns + " FROM follows WHERE follower_id = $1 AND followee_id = $2"
+	if err := r.Database.QueryRowx(query, followerID, followeeID).StructScan(&follow); err != nil {
+		return nil, err
+	}
+	return &follow, nil
+}
+
+// DeleteFollow removes a follow or withdraws a request. It returns
+// sql.ErrNoRows if there is none.
+func (r *FollowRepository) DeleteFollow(followerID, followeeID uuid.UUID) error {
+	var deleted uuid.UUID
+	query := `DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2 RETURNING follower_id`
+	return r.Database.QueryRowx(query, followerID, followeeID).Scan(&deleted)
+}
+
+// AcceptFollow approves a pending request. It returns sql.ErrNoRows if
+// there is no such request.
+func (r *FollowRepository) AcceptFollow(followerID, followeeID uuid.UUID) error {
+	query := `
+		UPDATE follows SET status = 'accepted', accepted_at = $1
+		WHERE follower_id = $2 AND followee_id = $3 AND status = 'pending'
+		RETURNING follower_id
+	`
+	var accepted uuid.UUID
+	return r.Database.QueryRowx(query, time.Now(), followerID, followeeID).Scan(&accepted)
+}
+
+// AcceptAllFollows approves every pending request to followeeID, for when
+// an account stops being private.
+func (r *FollowRepository) AcceptAllFollows(followeeID uuid.UUID) error {
+	query := `UPDATE follows SET status = 'accepted', accepted_at = $1 WHERE followee_id = $2 AND status = 'pending'`
+	_, err := r.Database.Exec(query, time.Now(), followeeID)
+	if err != nil {
+		log.Printf("error accepting follow requests: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetFollowers lists the users following userID with the given status,
+// newest first.
+func (r *FollowRepository) GetFollowers(userID uuid.UUID, status schema.FollowStatus, limit, offset int) ([]schema.FollowListEntry, error) {
+	query := `
+		SELECT u.user_id, u.username, u.name, f.status, f.created_at
+		FROM follows f
+		JOIN users u ON u.user_id = f.follower_id
+		WHERE f.followee_id = $1 AND f.status = $2
+		ORDER BY f.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	return r.listFollows(query, userID, status, limit, offset)
+}
+
+// GetFollowing lists the accounts userID follows, newest first.
+func (r *FollowRepository) GetFollowing(userID uuid.UUID, limit, offset int) ([]schema.FollowListEntry, error) {
+	query := `
+		SELECT u.user_id, u.username, u.name, f.status, f.created_at
+		FROM follows f
+		JOIN users u ON u.user_id = f.followee_id
+		WHERE f.follower_id = $1 AND f.status = $2
+		ORDER BY f.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	return r.listFollows(query, userID, schema.FollowAccepted, limit, offset)
+}
+
+func (r *FollowRepository) listFollows(query string, args ...interface{}) ([]schema.FollowListEntry, error) {
+	rows, err := r.Database.Queryx(query, args...)
+	if err != nil {
+		log.Printf("error fetching follows: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []schema.FollowListEntry
+	for rows.Next() {
+		var entry schema.FollowListEntry
+		if err := rows.StructScan(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *FollowRepository) GetFollowCounts(userID uuid.UUID) (*schema.FollowCounts, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE followee_id = $1 AND status = 'accepted') AS followers,
+			COUNT(*) FILTER (WHERE follower_id = $1 AND status = 'accepted') AS following,
+			COUNT(*) FILTER (WHERE followee_id = $1 AND status = 'pending') AS requests
+		FROM follows
+		WHERE followee_id = $1 OR follower_id = $1
+	`
+	var counts schema.FollowCounts
+	if err := r.Database.QueryRowx(query, userID).StructScan(&counts); err != nil {
+		log.Printf("error counting follows: %v\n", err)
+		return nil, err
+	}
+	return &counts, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+// ErrIdentityLinked is returned when an external identity is already linked
+// to an account, or the account already has one from that provider.
+var ErrIdentityLinked = errors.New("identity is already linked")
+
+type IdentityRepository struct {
+	Database config.Database
+}
+
+func NewIdentityRepository(db config.Database) *IdentityRepository {
+	return &IdentityRepository{Database: db}
+}
+
+func (r *IdentityRepository) CreateAuthRequest(req schema.OIDCAuthRequest) error {
+	query := `
+		INSERT INTO oidc_auth_requests (state_hash, provider, nonce, code_verifier, link_user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.Database.Exec(query, req.StateHash, req.Provider, req.Nonce, req.CodeVerifier, req.LinkUserId, req.ExpiresAt)
+	if err != nil {
+		log.Printf("error creating oidc auth request: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeAuthRequest removes and returns the unexpired request for the
+// state hash. It returns sql.ErrNoRows if there is none.
+func (r *IdentityRepository) ConsumeAuthRequest(stateHash string) (*schema.OIDCAuthRequest, error) {
+	query := `
+		DELETE FROM oidc_auth_requests
+		WHERE state_hash = $1 AND expires_at > $2
+		RETURNING state_hash, provider, nonce, code_verifier, link_user_id, expires_at
+	`
+	var req schema.OIDCAuthRequest
+	if err := r.Database.QueryRowx(query, stateHash, time.Now()).StructScan(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *IdentityRepository) GetIdentity(provider, subject string) (*schema.UserIdentity, error) {
+	query := `SELECT provider, subject, user_id, email, created_at FROM user_identities WHERE provider = $1 AND subject = $2`
+	var identity schema.UserIdentity
+	if err := r.Database.QueryRowx(query, provider, subject).StructScan(&identity); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) GetIdentitiesByUserID(userID uuid.UUID) ([]schema.UserIdentity, error) {
+	query := `SELECT provider, subject, user_id, email, created_at FROM user_identities WHERE user_id = $1 ORDER BY provider`
+	rows, err := r.Database.Queryx(query, userID)
+	if err != nil {
+		log.Printf("error fetching identities: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []schema.UserIdentity
+	for rows.Next() {
+		var identity schema.UserIdentity
+		if err := rows.StructScan(&identity); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (r *IdentityRepository) CreateIdentity(identity schema.UserIdentity) error {
+	query := `INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)`
+	_, err := r.Database.Exec(query, identity.Provider, identity.Subject, identity.UserId, identity.Email)
+	if isUniqueViolation(err) {
+		return ErrIdentityLinked
+	}
+	if err != nil {
+		log.Printf("error creating identity: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// CreateUserWithIdentity creates an account without a password or profile
+// image for someone signing in with a provider for the first time.
+func (r *IdentityRepository) CreateUserWithIdentity(user schema.User, identity schema.UserIdentity) (err error) {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// An empty password never matches a bcrypt comparison
+	_, err = tx.Exec(`
+		INSERT INTO users (user_id, name, username, email, email_verified, email_verified_at, password)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 THEN CURRENT_TIMESTAMP END, '')
+	`, user.Id, user.Name, user.Username, user.Email, user.EmailVerified)
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		log.Printf("error inserting user: %v\n", err)
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)`,
+		identity.Provider, identity.Subject, user.Id, identity.Email)
+	if isUniqueViolation(err) {
+		return ErrIdentityLinked
+	}
+	if err != nil {
+		log.Printf("error creating identity: %v\n", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteIdentity unlinks the user's identity at provider. It returns
+// sql.ErrNoRows if there is none.
+func (r *IdentityRepository) DeleteIdentity(userID uuid.UUID, provider string) error {
+	var deleted string
+	return r.Database.QueryRowx(`DELETE FROM user_identities WHERE user_id = $1 AND provider = $2 RETURNING provider`, userID, provider).Scan(&deleted)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
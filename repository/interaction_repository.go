@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type InteractionRepository struct {
+	Database config.Database
+}
+
+func NewInteractionRepository(db config.Database) *InteractionRepository {
+	return &InteractionRepository{Database: db}
+}
+
+// targetColumn returns the interactions column referencing items of type t.
+func targetColumn(t schema.TargetType) (string, error) {
+	switch t {
+	case schema.TargetPost:
+		return "post_id", nil
+	case schema.TargetRecipe:
+		return "recipe_id", nil
+	}
+	return "", fmt.Errorf("unknown interaction target %q", t)
+}
+
+// AddReaction records a reaction and reports whether it was new. Adding a
+// reaction the user already left does nothing.
+func (r *InteractionRepository) AddReaction(interaction schema.Interaction) (bool, error) {
+	column, err := targetColumn(interaction.TargetType)
+	if err != nil {
+		return false, err
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO interactions (user_id, %[1]s, reaction)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (%[1]s, user_id, reaction) WHERE %[1]s IS NOT NULL DO NOTHING
+	`, column)
+	result, err := r.Database.Exec(query, interaction.UserId, interaction.TargetId, interaction.Reaction)
+	if err != nil {
+		log.Printf("error adding reaction: %v\n", err)
+		return false, err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return added > 0, nil
+}
+
+// RemoveReaction takes back a reaction. Removing a reaction the user never
+// left does nothing.
+func (r *InteractionRepository) RemoveReaction(interaction schema.Interaction) error {
+	column, err := targetColumn(interaction.TargetType)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`DELETE FROM interactions WHERE %s = $1 AND user_id = $2 AND reaction = $3`, column)
+	_, err = r.Database.Exec(query, interaction.TargetId, interaction.UserId, interaction.Reaction)
+	if err != nil {
+		log.Printf("error removing reaction: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetReactionSummaries summarizes the reactions on each of the given items
+// as seen by viewerID. Every requested item gets a summary.
+func (r *InteractionRepository) GetReactionSummaries(targetType schema.TargetType, targetIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]*schema.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID]*schema.ReactionSummary, len(targetIDs))
+	for _, id := range targetIDs {
+		summaries[id] = schema.NewReactionSummary()
+	}
+	if len(targetIDs) == 0 {
+		return summaries, nil
+	}
+
+	column, err := targetColumn(targetType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`
+		SELECT %[1]s AS target_id, reaction, COUNT(*) AS count, BOOL_OR(user_id = $2) AS mine
+		FROM interactions
+		WHERE %[1]s = ANY($1)
+		GROUP BY %[1]s, reaction
+		ORDER BY %[1]s, reaction
+	`, column)
+
+	rows, err := r.Database.Queryx(query, pq.Array(targetIDs), viewerID)
+	if err != nil {
+		log.Printf("error counting reactions: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row struct {
+			TargetId uuid.UUID       `db:"target_id"`
+			Reaction schema.Reaction `db:"reaction"`
+			Count    int             `db:"count"`
+			Mine     bool            `db:"mine"`
+		}
+		if err := rows.StructScan(&row); err != nil {
+			return nil, err
+		}
+		if summary, ok := summaries[row.TargetId]; ok {
+			summary.Add(row.Reaction, row.Count, row.Mine)
+		}
+	}
+	return summaries, rows.Err()
+}
+
+// GetReactors lists who reacted to an item, newest first. An empty reaction
+// lists reactions of every kind.
+func (r *InteractionRepository) GetReactors(targetType schema.TargetType, targetID uuid.UUID, reaction schema.Reaction, limit, offset int) ([]schema.Reactor, error) {
+	column, err := targetColumn(targetType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`
+		SELECT u.user_id, u.username, u.name, i.reaction, i.created_at
+		FROM interactions i
+		JOIN users u ON u.user_id = i.user_id
+		WHERE i.%s = $1 AND ($2 = '' OR i.reaction = $2)
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT $3 OFFSET $4
+	`, column)
+
+	rows, err := r.Database.Queryx(query, targetID, reaction, limit, offset)
+	if err != nil {
+		log.Printf("error fetching reactors: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactors []schema.Reactor
+	for rows.Next() {
+		var reactor schema.Reactor
+		if err := rows.StructScan(&reactor); err != nil {
+			return nil, err
+		}
+		reactors = append(reactors, reactor)
+	}
+	return reactors, rows.Err()
+}
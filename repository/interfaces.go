@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/smilecs/foody/schema"
 )
@@ -12,15 +14,31 @@ type UserRepositoryInterface interface {
 	CreateUser(user schema.User, password string, mediaID uuid.UUID) (string, error)
 	AuthUser(email, password string) (bool, error)
 	GetUserByEmail(email string) (*schema.User, error)
+	UpdatePassword(userID uuid.UUID, password string) error
+	MarkEmailVerified(userID uuid.UUID) error
+	GetUserByUsername(username string) (*schema.User, error)
+	UpdateProfile(user schema.User) error
+	UpdateAvatar(userID, mediaID uuid.UUID) error
+	UpdateUsername(userID uuid.UUID, username string) error
+	ScheduleDeletion(userID uuid.UUID, at time.Time) error
+	CancelDeletion(userID uuid.UUID) error
+	GetUsersDueForDeletion(now time.Time) ([]uuid.UUID, error)
+	DeleteUser(userID uuid.UUID) error
+	ListUsers(limit, offset int) ([]schema.User, error)
+	GetTotalUsersCount() (int, error)
+	SetSuspended(userID uuid.UUID, suspended bool) error
+	UpdateRole(userID uuid.UUID, role schema.Role) error
 }
 
 type PostRepositoryInterface interface {
 	CreatePost(post schema.Post, mediaID uuid.UUID, mediaURL string) error
 	GetPostByID(id uuid.UUID) (*PostWithMedia, error)
 	GetPosts(limit, offset int) ([]PostWithMedia, error)
+	GetPostsByAuthorID(authorID uuid.UUID) ([]schema.PostWithMedia, error)
 	UpdatePost(post schema.Post) error
 	DeletePost(id uuid.UUID) error
 	GetTotalPostsCount() (int, error)
+	GetFeed(userID uuid.UUID, after *FeedCursor, limit int) ([]schema.PostWithMedia, error)
 }
 
 type RecipeRepositoryInterface interface {
@@ -30,6 +48,9 @@ type RecipeRepositoryInterface interface {
 	GetRecipesByAuthorID(authorID uuid.UUID) ([]RecipeWithMedia, error)
 	UpdateRecipe(recipe schema.Recipe) error
 	DeleteRecipe(id uuid.UUID) error
+	SearchRecipes(search RecipeSearch, limit, offset int) ([]RecipeSearchResult, error)
+	CountRecipeSearch(search RecipeSearch) (int, error)
+	GetRecipesByIngredientWords(words []string, limit int) ([]RecipeIngredientNames, error)
 }
 
 type MealPlanRepositoryInterface interface {
@@ -44,4 +65,117 @@ type MediaRepositoryInterface interface {
 	CreateMedia(media schema.Media) (uuid.UUID, error)
 	GetMediaByID(id uuid.UUID) (*schema.Media, error)
 	GetMediaByAuthorID(authorID uuid.UUID) ([]schema.Media, error)
+	DeleteMedia(id uuid.UUID) error
+}
+
+type SessionRepositoryInterface interface {
+	CreateSession(session schema.Session) error
+	GetSessionByID(id uuid.UUID) (*schema.Session, error)
+	GetSessionByTokenHash(hash string) (*schema.Session, error)
+	RotateSession(id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error
+	RevokeSession(id uuid.UUID) error
+	RevokeUserSessions(userID uuid.UUID) error
+}
+
+type UserTokenRepositoryInterface interface {
+	CreateUserToken(token schema.UserToken) error
+	ConsumeUserToken(hash string, purpose schema.TokenPurpose) (uuid.UUID, error)
+	InvalidateUserTokens(userID uuid.UUID, purpose schema.TokenPurpose) error
+	GetLatestUserToken(userID uuid.UUID, purpose schema.TokenPurpose) (*schema.UserToken, error)
+}
+
+type TwoFactorRepositoryInterface interface {
+	GetTOTP(userID uuid.UUID) (*schema.TOTPSecret, error)
+	SavePendingTOTP(userID uuid.UUID, secret string) error
+	EnableTOTP(userID uuid.UUID, step int64) error
+	UseTOTPStep(userID uuid.UUID, step int64) error
+	DisableTOTP(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error
+	ConsumeRecoveryCode(userID uuid.UUID, hash string) error
+	CountRecoveryCodes(userID uuid.UUID) (int, error)
+}
+
+type LoginAttemptRepositoryInterface interface {
+	RecordLoginAttempt(attempt schema.LoginAttempt) error
+	GetAccountFailures(email string, since time.Time) (*schema.LoginFailures, error)
+	GetIPFailures(ip string, since time.Time) (*schema.LoginFailures, error)
+}
+
+type APIKeyRepositoryInterface interface {
+	CreateAPIKey(key schema.APIKey) error
+	GetAPIKeyByHash(hash string) (*schema.APIKey, error)
+	GetAPIKeysByUserID(userID uuid.UUID) ([]schema.APIKey, error)
+	RevokeAPIKey(userID, id uuid.UUID) error
+	TouchAPIKey(id uuid.UUID, now time.Time) error
+}
+
+type IdentityRepositoryInterface interface {
+	CreateAuthRequest(req schema.OIDCAuthRequest) error
+	ConsumeAuthRequest(stateHash string) (*schema.OIDCAuthRequest, error)
+	GetIdentity(provider, subject string) (*schema.UserIdentity, error)
+	GetIdentitiesByUserID(userID uuid.UUID) ([]schema.UserIdentity, error)
+	CreateIdentity(identity schema.UserIdentity) error
+	CreateUserWithIdentity(user schema.User, identity schema.UserIdentity) error
+	DeleteIdentity(userID uuid.UUID, provider string) error
+}
+
+type FollowRepositoryInterface interface {
+	CreateFollow(follow schema.Follow) (*schema.Follow, error)
+	GetFollow(followerID, followeeID uuid.UUID) (*schema.Follow, error)
+	DeleteFollow(followerID, followeeID uuid.UUID) error
+	AcceptFollow(followerID, followeeID uuid.UUID) error
+	AcceptAllFollows(followeeID uuid.UUID) error
+	GetFollowers(userID uuid.UUID, status schema.FollowStatus, limit, offset int) ([]schema.FollowListEntry, error)
+	GetFollowing(userID uuid.UUID, limit, offset int) ([]schema.FollowListEntry, error)
+	GetFollowCounts(userID uuid.UUID) (*schema.FollowCounts, error)
+}
+
+type InteractionRepositoryInterface interface {
+	AddReaction(interaction schema.Interaction) (bool, error)
+	RemoveReaction(interaction schema.Interaction) error
+	GetReactionSummaries(targetType schema.TargetType, targetIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]*schema.ReactionSummary, error)
+	GetReactors(targetType schema.TargetType, targetID uuid.UUID, reaction schema.Reaction, limit, offset int) ([]schema.Reactor, error)
+}
+
+type CommentRepositoryInterface interface {
+	CreateComment(comment schema.Comment, mentions []uuid.UUID) error
+	GetCommentByID(id uuid.UUID) (*schema.Comment, error)
+	GetComments(targetType schema.TargetType, targetID uuid.UUID, limit, offset int) ([]schema.Comment, error)
+	CountComments(targetType schema.TargetType, targetID uuid.UUID) (int, error)
+	GetReplies(parentID uuid.UUID, limit, offset int) ([]schema.Comment, error)
+	UpdateComment(id uuid.UUID, body string, mentions []uuid.UUID) error
+	DeleteComment(id uuid.UUID) error
+	GetCommentCounts(targetType schema.TargetType, targetIDs []uuid.UUID) (map[uuid.UUID]int, error)
+}
+
+type CollectionRepositoryInterface interface {
+	CreateCollection(collection schema.Collection) error
+	GetCollectionByID(id uuid.UUID) (*schema.Collection, error)
+	GetDefaultCollection(userID uuid.UUID) (*schema.Collection, error)
+	GetCollectionsByUserID(userID uuid.UUID, includePrivate bool) ([]schema.Collection, error)
+	UpdateCollection(collection schema.Collection) error
+	DeleteCollection(id uuid.UUID) error
+	AddItem(item schema.CollectionItem) (bool, error)
+	GetItem(collectionID, recipeID uuid.UUID) (*schema.CollectionItem, error)
+	GetItems(collectionID uuid.UUID, limit, offset int) ([]schema.CollectionItem, error)
+	UpdateItemNote(collectionID, recipeID uuid.UUID, note string) error
+	RemoveItem(collectionID, recipeID uuid.UUID) error
+	ReorderItems(collectionID uuid.UUID, recipeIDs []uuid.UUID) error
+}
+
+type NotificationRepositoryInterface interface {
+	AddNotification(notification schema.Notification, actorID uuid.UUID) error
+	GetNotifications(userID uuid.UUID, unreadOnly bool, limit, offset int) ([]schema.Notification, error)
+	CountNotifications(userID uuid.UUID, unreadOnly bool) (int, error)
+	MarkNotificationRead(userID, id uuid.UUID) error
+	MarkAllNotificationsRead(userID uuid.UUID) error
+	GetNotificationPreferences(userID uuid.UUID) ([]schema.NotificationPreference, error)
+	SetNotificationPreference(preference schema.NotificationPreference) error
+}
+
+type TagRepositoryInterface interface {
+	GetPostsByTag(tag string, limit, offset int) ([]schema.PostWithMedia, error)
+	CountPostsByTag(tag string) (int, error)
+	SearchTags(prefix string, limit int) ([]schema.Tag, error)
+	GetTrendingTags(since time.Time, limit int) ([]schema.Tag, error)
 }
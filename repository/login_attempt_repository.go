@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type LoginAttemptRepository struct {
+	Database config.Database
+}
+
+func NewLoginAttemptRepository(db config.Database) *LoginAttemptRepository {
+	return &LoginAttemptRepository{Database: db}
+}
+
+func (r *LoginAttemptRepository) RecordLoginAttempt(attempt schema.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, ip, user_id, success, reason, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.Database.Exec(query, attempt.Email, attempt.IP, attempt.UserId, attempt.Success, attempt.Reason, attempt.UserAgent)
+	if err != nil {
+		log.Printf("error recording login attempt: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetAccountFailures counts the failed attempts for an email since the last
+// successful login, ignoring anything before since.
+func (r *LoginAttemptRepository) GetAccountFailures(email string, since time.Time) (*schema.LoginFailures, error) {
+	var failures schema.LoginFailures
+	query := `
+		SELECT COUNT(*) AS count, MAX(created_at) AS last_failure
+		FROM login_attempts
+		WHERE email = $1 AND NOT success AND reason <> 'locked' AND created_at > $2
+		AND created_at > COALESCE(
+			(SELECT MAX(created_at) FROM login_attempts WHERE email = $1 AND success),
+			'-infinity'
+		)
+	`
+	err := r.Database.QueryRowx(query, email, since).StructScan(&failures)
+	if err != nil {
+		return nil, err
+	}
+	return &failures, nil
+}
+
+// GetIPFailures counts the failed attempts from an IP address since the
+// given time, across all accounts.
+func (r *LoginAttemptRepository) GetIPFailures(ip string, since time.Time) (*schema.LoginFailures, error) {
+	var failures schema.LoginFailures
+	query := `
+		SELECT COUNT(*) AS count, MAX(created_at) AS last_failure
+		FROM login_attempts
+		WHERE ip = $1 AND NOT success AND reason <> 'locked' AND created_at > $2
+	`
+	err := r.Database.QueryRowx(query, ip, since).StructScan(&failures)
+	if err != nil {
+		return nil, err
+	}
+	return &failures, nil
+}
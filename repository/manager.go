@@ -5,19 +5,43 @@ import "github.com/smilecs/foody/config"
 // Use interfaces from interfaces.go
 
 type Manager struct {
-	UserRepo     UserRepositoryInterface
-	PostRepo     PostRepositoryInterface
-	MediaRepo    MediaRepositoryInterface
-	RecipeRepo   RecipeRepositoryInterface
-	MealPlanRepo MealPlanRepositoryInterface
+	UserRepo         UserRepositoryInterface
+	PostRepo         PostRepositoryInterface
+	MediaRepo        MediaRepositoryInterface
+	RecipeRepo       RecipeRepositoryInterface
+	MealPlanRepo     MealPlanRepositoryInterface
+	SessionRepo      SessionRepositoryInterface
+	TokenRepo        UserTokenRepositoryInterface
+	TwoFactorRepo    TwoFactorRepositoryInterface
+	LoginAttemptRepo LoginAttemptRepositoryInterface
+	APIKeyRepo       APIKeyRepositoryInterface
+	IdentityRepo     IdentityRepositoryInterface
+	FollowRepo       FollowRepositoryInterface
+	InteractionRepo  InteractionRepositoryInterface
+	CommentRepo      CommentRepositoryInterface
+	CollectionRepo   CollectionRepositoryInterface
+	NotificationRepo NotificationRepositoryInterface
+	TagRepo          TagRepositoryInterface
 }
 
 func NewManager(database config.Database) *Manager {
 	return &Manager{
-		UserRepo:     &UserRepository{Database: database},
-		PostRepo:     &PostRepository{Database: database},
-		MediaRepo:    &MediaRepository{Database: database},
-		RecipeRepo:   &RecipeRepository{Database: database},
-		MealPlanRepo: &MealPlanRepository{Database: database},
+		UserRepo:         &UserRepository{Database: database},
+		PostRepo:         &PostRepository{Database: database},
+		MediaRepo:        &MediaRepository{Database: database},
+		RecipeRepo:       &RecipeRepository{Database: database},
+		MealPlanRepo:     &MealPlanRepository{Database: database},
+		SessionRepo:      &SessionRepository{Database: database},
+		TokenRepo:        &UserTokenRepository{Database: database},
+		TwoFactorRepo:    &TwoFactorRepository{Database: database},
+		LoginAttemptRepo: &LoginAttemptRepository{Database: database},
+		APIKeyRepo:       &APIKeyRepository{Database: database},
+		IdentityRepo:     &IdentityRepository{Database: database},
+		FollowRepo:       &FollowRepository{Database: database},
+		InteractionRepo:  &InteractionRepository{Database: database},
+		CommentRepo:      &CommentRepository{Database: database},
+		CollectionRepo:   &CollectionRepository{Database: database},
+		NotificationRepo: &NotificationRepository{Database: database},
+		TagRepo:          &TagRepository{Database: database},
 	}
 }
@@ -14,6 +14,8 @@ func NewMediaRepository(db config.Database) *MediaRepository {
 	return &MediaRepository{Database: db}
 }
 
+const mediaColumns = `media_id, url, media_type, author_id`
+
 func (r *MediaRepository) CreateMedia(media schema.Media) (uuid.UUID, error) {
 	query := `
 		INSERT INTO media (media_id, url, media_type, author_id)
@@ -32,7 +34,7 @@ func (r *MediaRepository) CreateMedia(media schema.Media) (uuid.UUID, error) {
 
 func (r *MediaRepository) GetMediaByID(id uuid.UUID) (*schema.Media, error) {
 	var media schema.Media
-	err := r.Database.QueryRowx("SELECT * FROM media WHERE media_id = $1", id).StructScan(&media)
+	err := r.Database.QueryRowx("SELECT "+mediaColumns+" FROM media WHERE media_id = $1", id).StructScan(&media)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +43,7 @@ func (r *MediaRepository) GetMediaByID(id uuid.UUID) (*schema.Media, error) {
 
 func (r *MediaRepository) GetMediaByAuthorID(authorID uuid.UUID) ([]schema.Media, error) {
 	var mediaList []schema.Media
-	rows, err := r.Database.Queryx("SELECT * FROM media WHERE author_id = $1", authorID)
+	rows, err := r.Database.Queryx("SELECT "+mediaColumns+" FROM media WHERE author_id = $1", authorID)
 	if err != nil {
 		return nil, err
 	}
@@ -57,3 +59,11 @@ func (r *MediaRepository) GetMediaByAuthorID(authorID uuid.UUID) ([]schema.Media
 
 	return mediaList, nil
 }
+
+func (r *MediaRepository) DeleteMedia(id uuid.UUID) error {
+	_, err := r.Database.Exec("DELETE FROM media WHERE media_id = $1", id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
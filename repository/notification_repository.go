@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type NotificationRepository struct {
+	Database config.Database
+}
+
+func NewNotificationRepository(db config.Database) *NotificationRepository {
+	return &NotificationRepository{Database: db}
+}
+
+// notificationSelect loads notifications with their three most recent
+// actors and the number of actors in total.
+const notificationSelect = `
+	SELECT n.notification_id, n.user_id, n.type, n.target_type, n.target_id, n.group_key,
+		n.actor_ids[1:3]::text[] AS actor_ids,
+		ARRAY(
+			SELECT u.username
+			FROM unnest(n.actor_ids[1:3]) WITH ORDINALITY AS a(user_id, ord)
+			JOIN users u ON u.user_id = a.user_id
+			ORDER BY a.ord
+		) AS actor_usernames,
+		cardinality(n.actor_ids) AS actor_count,
+		n.created_at, n.updated_at, n.read_at
+	FROM notifications n
+`
+
+// AddNotification records that actorID caused notification. If an unread
+// notification with the same group key exists the actor is added to it and
+// it moves to the top instead.
+func (r *NotificationRepository) AddNotification(notification schema.Notification, actorID uuid.UUID) error {
+	query := `
+		INSERT INTO notifications (notification_id, user_id, type, target_type, target_id, group_key, actor_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, ARRAY[$7::uuid])
+		ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE
+		SET actor_ids = ARRAY[$7::uuid] || array_remove(notifications.actor_ids, $7::uuid),
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.Database.Exec(query, notification.Id, notification.UserId, notification.Type,
+		notification.TargetType, notification.TargetId, notification.GroupKey, actorID)
+	if err != nil {
+		log.Printf("error adding notification: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetNotifications lists a user's notifications, most recently updated
+// first.
+func (r *NotificationRepository) GetNotifications(userID uuid.UUID, unreadOnly bool, limit, offset int) ([]schema.Notification, error) {
+	query := notificationSelect + `
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+		ORDER BY n.updated_at DESC, n.notification_id DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.Database.Queryx(query, userID, unreadOnly, limit, offset)
+	if err != nil {
+		log.Printf("error fetching notifications: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []schema.Notification
+	for rows.Next() {
+		var notification schema.Notification
+		if err := rows.StructScan(&notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *NotificationRepository) CountNotifications(userID uuid.UUID, unreadOnly bool) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)`
+	if err := r.Database.QueryRowx(query, userID, unreadOnly).Scan(&count); err != nil {
+		log.Printf("error counting notifications: %v\n", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications as read. It
+// returns sql.ErrNoRows if the user has no such notification.
+func (r *NotificationRepository) MarkNotificationRead(userID, id uuid.UUID) error {
+	query := `
+		UPDATE notifications SET read_at = COALESCE(read_at, $1)
+		WHERE notification_id = $2 AND user_id = $3
+		RETURNING notification_id
+	`
+	var updated uuid.UUID
+	return r.Database.QueryRowx(query, time.Now(), id, userID).Scan(&updated)
+}
+
+func (r *NotificationRepository) MarkAllNotificationsRead(userID uuid.UUID) error {
+	query := `UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL`
+	if _, err := r.Database.Exec(query, time.Now(), userID); err != nil {
+		log.Printf("error marking notifications read: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetNotificationPreferences returns the preferences the user has set.
+// Types without one are enabled.
+func (r *NotificationRepository) GetNotificationPreferences(userID uuid.UUID) ([]schema.NotificationPreference, error) {
+	query := `SELECT user_id, type, enabled FROM notification_preferences WHERE user_id = $1`
+	rows, err := r.Database.Queryx(query, userID)
+	if err != nil {
+		log.Printf("error fetching notification preferences: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []schema.NotificationPreference
+	for rows.Next() {
+		var preference schema.NotificationPreference
+		if err := rows.StructScan(&preference); err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, rows.Err()
+}
+
+func (r *NotificationRepository) SetNotificationPreference(preference schema.NotificationPreference) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, type, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+	`
+	if _, err := r.Database.Exec(query, preference.UserId, preference.Type, preference.Enabled); err != nil {
+		log.Printf("error saving notification preference: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"database/sql"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/smilecs/foody/config"
 	"github.com/smilecs/foody/schema"
 )
@@ -24,9 +26,13 @@ type PostWithMedia struct {
 	RecipeID  *uuid.UUID `db:"recipe_id"`
 	CreatedAt time.Time  `db:"created_at"`
 	UpdatedAt time.Time  `db:"updated_at"`
+	// Reactions and CommentCount are filled in by handlers.
+	Reactions    *schema.ReactionSummary `db:"-" json:"reactions,omitempty"`
+	CommentCount int                     `db:"-" json:"comment_count"`
 }
 
-func (r *PostRepository) CreatePost(post schema.Post, mediaID uuid.UUID, mediaURL string) error {
+// CreatePost stores a post and its tags together.
+func (r *PostRepository) CreatePost(post schema.Post, mediaID uuid.UUID, mediaURL string) (err error) {
 	query := `
 		INSERT INTO post (post_id, author_id, media_id, media_url, title, body, tags, recipe_id)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -38,33 +44,70 @@ func (r *PostRepository) CreatePost(post schema.Post, mediaID uuid.UUID, mediaUR
 		recipeID = &post.Recipe.Id
 	}
 
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	var userID int
-	err := r.Database.QueryRowx(query, post.Id, post.AuthorId, mediaID, mediaURL, post.Title, post.Body, post.Tags, recipeID).Scan(&userID)
+	err = tx.QueryRowx(query, post.Id, post.AuthorId, mediaID, mediaURL, post.Title, post.Body, post.Tags, recipeID).Scan(&userID)
 
 	if err != nil {
 		log.Println("error creating post: ", err)
 		return err
 	}
 
-	return nil
+	if err = setPostTags(tx, post.Id, post.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *PostRepository) GetPostByUserID(id uuid.UUID) (*PostWithMedia, error) {
-	var post PostWithMedia
-	err := r.Database.QueryRowx("SELECT * FROM post WHERE author_id = $1", id).StructScan(&post)
+	query := `
+		SELECT ` + postColumns + `
+		FROM post
+		WHERE author_id = $1
+		ORDER BY created_at DESC, post_id DESC
+		LIMIT 1
+	`
+	post, err := scanPost(r.Database.QueryRowx(query, id))
 	if err != nil {
 		return nil, err
 	}
 	return &post, nil
 }
 
-func (r *PostRepository) GetPosts(limit, offset int) ([]PostWithMedia, error) {
-	var posts []PostWithMedia
+// GetPostsByAuthorID lists an author's posts, newest first.
+func (r *PostRepository) GetPostsByAuthorID(authorID uuid.UUID) ([]schema.PostWithMedia, error) {
+	query := `
+		SELECT p.post_id, p.author_id, p.media_id, p.media_url, p.title, p.body, p.tags, p.recipe_id, p.created_at, p.updated_at
+		FROM post p
+		WHERE p.author_id = $1
+		ORDER BY p.created_at DESC, p.post_id DESC
+	`
+
+	rows, err := r.Database.Queryx(query, authorID)
+	if err != nil {
+		log.Printf("error retrieving posts: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
 
+func (r *PostRepository) GetPosts(limit, offset int) ([]PostWithMedia, error) {
 	query := `
-		SELECT *
+		SELECT ` + postColumns + `
 		FROM post
-		ORDER BY created_at DESC LIMIT $1 OFFSET $2
+		ORDER BY created_at DESC, post_id DESC LIMIT $1 OFFSET $2
 	`
 
 	rows, err := r.Database.Queryx(query, limit, offset)
@@ -72,55 +115,87 @@ func (r *PostRepository) GetPosts(limit, offset int) ([]PostWithMedia, error) {
 		log.Printf("error retrieving posts: %v\n", err)
 		return nil, err
 	}
-	defer func(rows *sqlx.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("error retrieving posts database: %v\n", err)
-		}
-	}(rows)
+	defer rows.Close()
 
+	var posts []PostWithMedia
 	for rows.Next() {
-		var post PostWithMedia
-		if err := rows.StructScan(&post); err != nil {
-			log.Printf("error scanning posts: %v\n", err)
-			continue
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
 		}
 		posts = append(posts, post)
 	}
-	return posts, nil
+	return posts, rows.Err()
 }
 
 func (r *PostRepository) GetPostByID(id uuid.UUID) (*PostWithMedia, error) {
-	var post PostWithMedia
-
 	query := `
-		SELECT *
+		SELECT ` + postColumns + `
 		FROM post
 		WHERE post_id = $1
 	`
 
-	err := r.Database.QueryRowx(query, id).StructScan(&post)
+	post, err := scanPost(r.Database.QueryRowx(query, id))
 	if err != nil {
 		return nil, err
 	}
-
 	return &post, nil
 }
 
-func (r *PostRepository) UpdatePost(post schema.Post) error {
+// postColumns are the columns scanPost reads from the post table.
+const postColumns = `post_id, author_id, media_id, COALESCE(media_url, ''), title, body, tags, recipe_id, created_at, updated_at`
+
+// scanPost reads a row of postColumns.
+func scanPost(row interface{ Scan(...interface{}) error }) (PostWithMedia, error) {
+	var post PostWithMedia
+	var mediaID *uuid.UUID
+	err := row.Scan(&post.Id, &post.AuthorId, &mediaID, &post.MediaURL, &post.Title, &post.Body, &post.Tags,
+		&post.RecipeID, &post.CreatedAt, &post.UpdatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("error scanning post: %v\n", err)
+		}
+		return post, err
+	}
+	if mediaID != nil {
+		post.MediaId = *mediaID
+	}
+	if post.RecipeID != nil {
+		post.Recipe = &schema.Recipe{Id: *post.RecipeID}
+	}
+	return post, nil
+}
+
+// UpdatePost updates a post and its tags together.
+func (r *PostRepository) UpdatePost(post schema.Post) (err error) {
 	query := `
-		UPDATE post SET title = $1, body = $2, tags = $3 WHERE id = $4
+		UPDATE post SET title = $1, body = $2, tags = $3 WHERE post_id = $4
 	`
-	_, err := r.Database.MustExec(query, post.Title, post.Body, post.Tags, post.Id)
+	tx, err := r.Database.Beginx()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(query, post.Title, post.Body, post.Tags, post.Id)
+	if err != nil {
+		return err
+	}
+
+	if err = setPostTags(tx, post.Id, post.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *PostRepository) DeletePost(id uuid.UUID) error {
 	query := `
-		DELETE FROM post WHERE id = $1
+		DELETE FROM post WHERE post_id = $1
 	`
 	_, err := r.Database.MustExec(query, id)
 	if err != nil {
@@ -137,3 +212,74 @@ func (r *PostRepository) GetTotalPostsCount() (int, error) {
 	}
 	return count, nil
 }
+
+// FeedCursor marks the last post of a feed page; the next page starts with
+// the post right after it.
+type FeedCursor struct {
+	CreatedAt time.Time
+	PostID    uuid.UUID
+}
+
+// GetFeed returns posts by the accounts userID follows, and by userID
+// itself, newest first. The feed is assembled when read: for every author
+// only their newest posts before the cursor are fetched through the
+// (author_id, created_at, post_id) index, so the cost depends on the number
+// of followed accounts and the page size, not on the total number of posts.
+func (r *PostRepository) GetFeed(userID uuid.UUID, after *FeedCursor, limit int) ([]schema.PostWithMedia, error) {
+	// Without a cursor start from the newest possible post
+	cursorTime := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursorID := uuid.Max
+	if after != nil {
+		cursorTime = after.CreatedAt
+		cursorID = after.PostID
+	}
+
+	query := `
+		SELECT p.post_id, p.author_id, p.media_id, p.media_url, p.title, p.body, p.tags, p.recipe_id, p.created_at, p.updated_at
+		FROM (
+			SELECT followee_id AS author_id FROM follows WHERE follower_id = $1 AND status = 'accepted'
+			UNION ALL
+			SELECT $1
+		) authors
+		CROSS JOIN LATERAL (
+			SELECT *
+			FROM post
+			WHERE post.author_id = authors.author_id AND (post.created_at, post.post_id) < ($2, $3)
+			ORDER BY post.created_at DESC, post.post_id DESC
+			LIMIT $4
+		) p
+		ORDER BY p.created_at DESC, p.post_id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.Database.Queryx(query, userID, cursorTime, cursorID, limit)
+	if err != nil {
+		log.Printf("error retrieving feed: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// scanPosts reads rows of post_id, author_id, media_id, media_url, title,
+// body, tags, recipe_id, created_at and updated_at.
+func scanPosts(rows *sqlx.Rows) ([]schema.PostWithMedia, error) {
+	var posts []schema.PostWithMedia
+	for rows.Next() {
+		var post schema.PostWithMedia
+		var tags pq.StringArray
+		var recipeID *uuid.UUID
+		err := rows.Scan(&post.Id, &post.AuthorId, &post.MediaId, &post.MediaURL, &post.Title, &post.Body, &tags, &recipeID, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			log.Printf("error scanning posts: %v\n", err)
+			return nil, err
+		}
+		post.Tags = tags
+		if recipeID != nil {
+			post.Recipe = &schema.Recipe{Id: *recipeID}
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+// statementPrefixes returns the first words of each statement, enough to
+// tell them apart.
+func statementPrefixes(statements []string) []string {
+	prefixes := make([]string, len(statements))
+	for i, statement := range statements {
+		fields := strings.Fields(statement)
+		prefixes[i] = strings.Join(fields[:min(3, len(fields))], " ")
+	}
+	return prefixes
+}
+
+func TestPostRepository_WritesTagsWithPost(t *testing.T) {
+	post := schema.Post{Id: uuid.New(), AuthorId: uuid.New(), Title: "Soup", Tags: []string{"soup", "autumn"}}
+
+	tests := []struct {
+		name     string
+		write    func(repo *PostRepository) error
+		expected []string
+	}{
+		{
+			name: "Create",
+			write: func(repo *PostRepository) error {
+				return repo.CreatePost(post, uuid.New(), "https://cdn/soup.jpg")
+			},
+			expected: []string{"BEGIN", "INSERT INTO post", "INSERT INTO tags", "DELETE FROM post_tags", "INSERT INTO post_tags", "COMMIT"},
+		},
+		{
+			name: "Update",
+			write: func(repo *PostRepository) error {
+				return repo.UpdatePost(post)
+			},
+			expected: []string{"BEGIN", "UPDATE post SET", "INSERT INTO tags", "DELETE FROM post_tags", "INSERT INTO post_tags", "COMMIT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			repo := NewPostRepository(db)
+			fake.queue([]string{"id"}, []driver.Value{int64(1)})
+
+			if err := tt.write(repo); err != nil {
+				t.Fatalf("Failed to write post: %v", err)
+			}
+
+			got := statementPrefixes(fake.statements)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Fatalf("Expected statements %v, got %v", tt.expected, got)
+			}
+			// Only tags the post no longer has are deleted, so the rows of
+			// kept tags keep their created_at
+			for i, statement := range fake.statements {
+				if strings.HasPrefix(got[i], "DELETE FROM post_tags") && !strings.Contains(statement, "NOT (tag = ANY($2))") {
+					t.Errorf("Expected only removed tags to be deleted, got %q", statement)
+				}
+			}
+		})
+	}
+}
+
+func TestPostRepository_GetPostsByAuthorID(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewPostRepository(db)
+
+	authorID, mediaID := uuid.New(), uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"post_id", "author_id", "media_id", "media_url", "title", "body", "tags", "recipe_id", "created_at", "updated_at"}
+	fake.queue(columns,
+		[]driver.Value{uuid.NewString(), authorID.String(), mediaID.String(), "https://cdn/post.jpg", "Carbonara", "", "{pasta}", nil, createdAt, createdAt},
+		[]driver.Value{uuid.NewString(), authorID.String(), nil, nil, "Cacio e pepe", "", "{}", nil, createdAt, createdAt},
+	)
+
+	posts, err := repo.GetPostsByAuthorID(authorID)
+	if err != nil {
+		t.Fatalf("Failed to fetch posts: %v", err)
+	}
+	if len(posts) != 2 || posts[0].Title != "Carbonara" || posts[1].Title != "Cacio e pepe" {
+		t.Fatalf("Expected both posts, got %+v", posts)
+	}
+	if posts[0].MediaId == nil || *posts[0].MediaId != mediaID || posts[0].AuthorId != authorID {
+		t.Errorf("Unexpected post: %+v", posts[0])
+	}
+
+	// A row that cannot be read fails the listing instead of going missing
+	fake.queue(columns, []driver.Value{uuid.NewString(), authorID.String(), nil, nil, nil, "", "{}", nil, createdAt, createdAt})
+	if _, err := repo.GetPostsByAuthorID(authorID); err == nil {
+		t.Error("Expected a scan error")
+	}
+}
+
+func TestPostRepository_GetPostByID(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewPostRepository(db)
+
+	postID, authorID, mediaID, recipeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"post_id", "author_id", "media_id", "coalesce", "title", "body", "tags", "recipe_id", "created_at", "updated_at"}
+	fake.queue(columns, []driver.Value{postID.String(), authorID.String(), mediaID.String(), "https://cdn/post.jpg", "Carbonara", "Creamy", "{pasta,dinner}", recipeID.String(), createdAt, createdAt})
+
+	post, err := repo.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	if post.Id != postID || post.AuthorId != authorID || post.MediaId != mediaID || post.MediaURL != "https://cdn/post.jpg" {
+		t.Errorf("Unexpected post: %+v", post)
+	}
+	if len(post.Tags) != 2 || post.RecipeID == nil || *post.RecipeID != recipeID {
+		t.Errorf("Expected tags and recipe to be scanned, got %v %v", post.Tags, post.RecipeID)
+	}
+	if !post.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected created_at %v, got %v", createdAt, post.CreatedAt)
+	}
+
+	// Posts without media or a recipe are read as well
+	fake.queue(columns, []driver.Value{postID.String(), authorID.String(), nil, "", "Toast", "", "{}", nil, createdAt, createdAt})
+	post, err = repo.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	if post.MediaId != uuid.Nil || post.RecipeID != nil {
+		t.Errorf("Expected nullable columns to stay empty, got %+v", post)
+	}
+
+	fake.queue(columns)
+	if _, err := repo.GetPostByID(uuid.New()); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestPostRepository_ModeratorRemovesPost(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewPostRepository(db)
+
+	// Moderation loads the post from the columns the post table has, then
+	// deletes it
+	postID := uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"post_id", "author_id", "media_id", "coalesce", "title", "body", "tags", "recipe_id", "created_at", "updated_at"}
+	fake.queue(columns, []driver.Value{postID.String(), uuid.NewString(), nil, "", "Spam", "Buy now", "{}", nil, createdAt, createdAt})
+
+	post, err := repo.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("Failed to load post: %v", err)
+	}
+	if err := repo.DeletePost(post.Id); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+
+	got := statementPrefixes(fake.statements)
+	if len(got) != 2 || got[0] != "SELECT post_id, author_id," || got[1] != "DELETE FROM post" {
+		t.Fatalf("Expected a load and a delete, got %v", got)
+	}
+	if fake.args[1][0] != postID.String() {
+		t.Errorf("Expected post %s to be deleted, got %v", postID, fake.args[1][0])
+	}
+}
@@ -1,12 +1,19 @@
 package repository
 
 import (
+	"database/sql"
+	"html"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/ingredients"
 	"github.com/smilecs/foody/schema"
+	"github.com/smilecs/foody/units"
 )
 
 type RecipeRepository struct {
@@ -20,6 +27,11 @@ func NewRecipeRepository(db config.Database) *RecipeRepository {
 type RecipeWithMedia struct {
 	schema.Recipe
 	MediaURL string `db:"media_url"`
+	// Reactions and CommentCount are filled in by handlers.
+	Reactions    *schema.ReactionSummary `db:"-" json:"reactions,omitempty"`
+	CommentCount int                     `db:"-" json:"comment_count"`
+	// Scaling is set when the recipe was scaled to a number of servings.
+	Scaling *units.Scaling `db:"-" json:"scaling,omitempty"`
 }
 
 func (r *RecipeRepository) CreateRecipe(recipe schema.Recipe, mediaID uuid.UUID, mediaURL string) error {
@@ -61,13 +73,14 @@ func (r *RecipeRepository) CreateRecipe(recipe schema.Recipe, mediaID uuid.UUID,
 	// Insert ingredients
 	if len(recipe.Ingredients) > 0 {
 		ingredientsQuery := `
-			INSERT INTO recipe_ingredients (recipe_id, name, quantity, unit)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO recipe_ingredients (recipe_id, name, normalized_name, quantity, unit)
+			VALUES ($1, $2, $3, $4, $5)
 		`
 		for _, ingredient := range recipe.Ingredients {
 			_, err = tx.Exec(ingredientsQuery,
 				recipe.Id,
 				ingredient.Name,
+				ingredients.Normalize(ingredient.Name),
 				ingredient.Quantity,
 				ingredient.Unit,
 			)
@@ -97,17 +110,39 @@ func (r *RecipeRepository) CreateRecipe(recipe schema.Recipe, mediaID uuid.UUID,
 		}
 	}
 
+	if err = refreshSearchVector(tx, recipe.Id); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (r *RecipeRepository) GetRecipes(limit, offset int) ([]RecipeWithMedia, error) {
-	var recipes []RecipeWithMedia
+// refreshSearchVector reindexes a recipe for full-text search. Titles weigh
+// most, then descriptions and ingredients, then steps.
+func refreshSearchVector(tx *sqlx.Tx, recipeID uuid.UUID) error {
+	query := `
+		UPDATE recipe r SET search_vector =
+			setweight(to_tsvector('english', r.title), 'A') ||
+			setweight(to_tsvector('english', r.description), 'B') ||
+			setweight(to_tsvector('english', COALESCE(
+				(SELECT string_agg(name, ' ') FROM recipe_ingredients WHERE recipe_id = r.recipe_id), '')), 'B') ||
+			setweight(to_tsvector('english', COALESCE(
+				(SELECT string_agg(description, ' ') FROM recipe_steps WHERE recipe_id = r.recipe_id), '')), 'C')
+		WHERE r.recipe_id = $1
+	`
+	if _, err := tx.Exec(query, recipeID); err != nil {
+		log.Printf("error indexing recipe: %v\n", err)
+		return err
+	}
+	return nil
+}
 
+func (r *RecipeRepository) GetRecipes(limit, offset int) ([]RecipeWithMedia, error) {
 	query := `
-		SELECT r.*, m.url as media_url
+		SELECT ` + recipeColumns + `
 		FROM recipe r
 		LEFT JOIN media m ON r.media_id = m.media_id
-		ORDER BY r.created_at DESC LIMIT $1 OFFSET $2
+		ORDER BY r.created_at DESC, r.recipe_id LIMIT $1 OFFSET $2
 	`
 
 	rows, err := r.Database.Queryx(query, limit, offset)
@@ -115,120 +150,97 @@ func (r *RecipeRepository) GetRecipes(limit, offset int) ([]RecipeWithMedia, err
 		log.Printf("error retrieving recipes: %v\n", err)
 		return nil, err
 	}
-	defer func(rows *sqlx.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("error closing rows: %v\n", err)
-		}
-	}(rows)
 
+	var recipes []RecipeWithMedia
 	for rows.Next() {
-		var recipe RecipeWithMedia
-		if err := rows.StructScan(&recipe); err != nil {
-			log.Printf("error scanning recipe: %v\n", err)
-			continue
-		}
-
-		// Get ingredients
-		ingredientsQuery := `SELECT name, quantity, unit FROM recipe_ingredients WHERE recipe_id = $1`
-		ingredientRows, err := r.Database.Queryx(ingredientsQuery, recipe.Id)
-		if err != nil {
-			log.Printf("error retrieving ingredients: %v\n", err)
-			continue
-		}
-		defer ingredientRows.Close()
-
-		for ingredientRows.Next() {
-			var ingredient schema.Ingredient
-			if err := ingredientRows.StructScan(&ingredient); err != nil {
-				log.Printf("error scanning ingredient: %v\n", err)
-				continue
-			}
-			recipe.Ingredients = append(recipe.Ingredients, ingredient)
-		}
-
-		// Get steps
-		stepsQuery := `SELECT step_order, description FROM recipe_steps WHERE recipe_id = $1 ORDER BY step_order`
-		stepRows, err := r.Database.Queryx(stepsQuery, recipe.Id)
+		recipe, err := scanRecipe(rows)
 		if err != nil {
-			log.Printf("error retrieving steps: %v\n", err)
-			continue
+			rows.Close()
+			return nil, err
 		}
-		defer stepRows.Close()
+		recipes = append(recipes, recipe)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		for stepRows.Next() {
-			var step schema.Step
-			if err := stepRows.StructScan(&step); err != nil {
-				log.Printf("error scanning step: %v\n", err)
-				continue
-			}
-			recipe.Steps = append(recipe.Steps, step)
+	// The page is read before its details so only one result set is open
+	for i := range recipes {
+		if err := r.loadRecipeDetails(&recipes[i]); err != nil {
+			return nil, err
 		}
-
-		recipes = append(recipes, recipe)
 	}
 	return recipes, nil
 }
 
 func (r *RecipeRepository) GetRecipeByID(id uuid.UUID) (*RecipeWithMedia, error) {
-	var recipe RecipeWithMedia
 	query := `
-		SELECT r.*, m.url as media_url
+		SELECT ` + recipeColumns + `
 		FROM recipe r
 		LEFT JOIN media m ON r.media_id = m.media_id
 		WHERE r.recipe_id = $1
 	`
-	err := r.Database.QueryRowx(query, id).StructScan(&recipe)
+	recipe, err := scanRecipe(r.Database.QueryRowx(query, id))
 	if err != nil {
 		return nil, err
 	}
+	if err := r.loadRecipeDetails(&recipe); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
 
-	// Get ingredients
-	ingredientsQuery := `SELECT name, quantity, unit FROM recipe_ingredients WHERE recipe_id = $1`
-	ingredientRows, err := r.Database.Queryx(ingredientsQuery, id)
+// loadRecipeDetails fills in a recipe's ingredients and steps.
+func (r *RecipeRepository) loadRecipeDetails(recipe *RecipeWithMedia) error {
+	ingredientsQuery := `SELECT name, quantity, unit FROM recipe_ingredients WHERE recipe_id = $1 ORDER BY id`
+	ingredientRows, err := r.Database.Queryx(ingredientsQuery, recipe.Id)
 	if err != nil {
-		return nil, err
+		log.Printf("error retrieving ingredients: %v\n", err)
+		return err
 	}
 	defer ingredientRows.Close()
 
 	for ingredientRows.Next() {
 		var ingredient schema.Ingredient
-		if err := ingredientRows.StructScan(&ingredient); err != nil {
+		if err := ingredientRows.Scan(&ingredient.Name, &ingredient.Quantity, &ingredient.Unit); err != nil {
 			log.Printf("error scanning ingredient: %v\n", err)
-			continue
+			return err
 		}
 		recipe.Ingredients = append(recipe.Ingredients, ingredient)
 	}
+	if err := ingredientRows.Err(); err != nil {
+		return err
+	}
 
-	// Get steps
 	stepsQuery := `SELECT step_order, description FROM recipe_steps WHERE recipe_id = $1 ORDER BY step_order`
-	stepRows, err := r.Database.Queryx(stepsQuery, id)
+	stepRows, err := r.Database.Queryx(stepsQuery, recipe.Id)
 	if err != nil {
-		return nil, err
+		log.Printf("error retrieving steps: %v\n", err)
+		return err
 	}
 	defer stepRows.Close()
 
 	for stepRows.Next() {
 		var step schema.Step
-		if err := stepRows.StructScan(&step); err != nil {
+		if err := stepRows.Scan(&step.Order, &step.Description); err != nil {
 			log.Printf("error scanning step: %v\n", err)
-			continue
+			return err
 		}
 		recipe.Steps = append(recipe.Steps, step)
 	}
-
-	return &recipe, nil
+	return stepRows.Err()
 }
 
+// GetRecipesByAuthorID lists an author's recipes, newest first, without
+// their ingredients and steps.
 func (r *RecipeRepository) GetRecipesByAuthorID(authorID uuid.UUID) ([]RecipeWithMedia, error) {
-	var recipes []RecipeWithMedia
-
 	query := `
-		SELECT r.*, m.url as media_url
+		SELECT ` + recipeColumns + `
 		FROM recipe r
 		LEFT JOIN media m ON r.media_id = m.media_id
 		WHERE r.author_id = $1
-		ORDER BY r.created_at DESC
+		ORDER BY r.created_at DESC, r.recipe_id
 	`
 
 	rows, err := r.Database.Queryx(query, authorID)
@@ -236,22 +248,45 @@ func (r *RecipeRepository) GetRecipesByAuthorID(authorID uuid.UUID) ([]RecipeWit
 		log.Printf("error retrieving recipes: %v\n", err)
 		return nil, err
 	}
-	defer func(rows *sqlx.Rows) {
-		err := rows.Close()
+	defer rows.Close()
+
+	var recipes []RecipeWithMedia
+	for rows.Next() {
+		recipe, err := scanRecipe(rows)
 		if err != nil {
-			log.Printf("error closing rows: %v\n", err)
+			return nil, err
 		}
-	}(rows)
+		recipes = append(recipes, recipe)
+	}
+	return recipes, rows.Err()
+}
 
-	for rows.Next() {
-		var recipe RecipeWithMedia
-		if err := rows.StructScan(&recipe); err != nil {
+// recipeColumns are the columns scanRecipe reads, selected from recipe r
+// left joined with its media m. Durations are read as seconds.
+const recipeColumns = `r.recipe_id, r.author_id, r.media_id, COALESCE(m.url, ''), r.title, r.description,
+		EXTRACT(EPOCH FROM r.prep_time)::bigint, EXTRACT(EPOCH FROM r.cook_time)::bigint,
+		EXTRACT(EPOCH FROM r.total_time)::bigint, r.servings, r.created_at, r.updated_at`
+
+// scanRecipe reads a row of recipeColumns.
+func scanRecipe(row interface{ Scan(...interface{}) error }) (RecipeWithMedia, error) {
+	var recipe RecipeWithMedia
+	var mediaID *uuid.UUID
+	var prep, cook, total *int64
+	err := row.Scan(&recipe.Id, &recipe.AuthorId, &mediaID, &recipe.MediaURL, &recipe.Title, &recipe.Description,
+		&prep, &cook, &total, &recipe.Servings, &recipe.CreatedAt, &recipe.UpdatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
 			log.Printf("error scanning recipe: %v\n", err)
-			continue
 		}
-		recipes = append(recipes, recipe)
+		return recipe, err
 	}
-	return recipes, nil
+	if mediaID != nil {
+		recipe.MediaId = *mediaID
+	}
+	recipe.PrepTime = secondsDuration(prep)
+	recipe.CookTime = secondsDuration(cook)
+	recipe.TotalTime = secondsDuration(total)
+	return recipe, nil
 }
 
 func (r *RecipeRepository) UpdateRecipe(recipe schema.Recipe) error {
@@ -297,13 +332,14 @@ func (r *RecipeRepository) UpdateRecipe(recipe schema.Recipe) error {
 	// Insert new ingredients
 	if len(recipe.Ingredients) > 0 {
 		ingredientsQuery := `
-			INSERT INTO recipe_ingredients (recipe_id, name, quantity, unit)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO recipe_ingredients (recipe_id, name, normalized_name, quantity, unit)
+			VALUES ($1, $2, $3, $4, $5)
 		`
 		for _, ingredient := range recipe.Ingredients {
 			_, err = tx.Exec(ingredientsQuery,
 				recipe.Id,
 				ingredient.Name,
+				ingredients.Normalize(ingredient.Name),
 				ingredient.Quantity,
 				ingredient.Unit,
 			)
@@ -331,6 +367,10 @@ func (r *RecipeRepository) UpdateRecipe(recipe schema.Recipe) error {
 		}
 	}
 
+	if err = refreshSearchVector(tx, recipe.Id); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -353,3 +393,220 @@ func (r *RecipeRepository) GetTotalRecipesCount() (int, error) {
 	}
 	return count, nil
 }
+
+// RecipeSearch describes a recipe search. Zero values do not filter.
+type RecipeSearch struct {
+	// Query is matched against the title, description, ingredients and
+	// steps, using web search syntax: quoted phrases, "or" and -word.
+	Query string
+	// IncludeIngredients must all appear in a recipe's ingredient names and
+	// ExcludeIngredients none of them.
+	IncludeIngredients []string
+	ExcludeIngredients []string
+	MaxTotalTime       *time.Duration
+	MinServings        *int
+	MaxServings        *int
+	AuthorId           *uuid.UUID
+}
+
+// RecipeSearchResult is a recipe matching a search, with its relevance and
+// a snippet of the matching text. The snippet is HTML: the recipe text is
+// escaped and the search terms are wrapped in <mark> tags.
+type RecipeSearchResult struct {
+	RecipeWithMedia
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// recipeSearchFilter selects the recipes matching a search. $1 is the text
+// query, $2 to $5 the optional author, maximum total time in seconds and
+// servings range, $6 and $7 the included and excluded ingredient patterns.
+const recipeSearchFilter = `
+	FROM recipe r
+	CROSS JOIN (SELECT websearch_to_tsquery('english', $1) AS query) q
+	WHERE ($1 = '' OR r.search_vector @@ q.query)
+		AND ($2::uuid IS NULL OR r.author_id = $2)
+		AND ($3::bigint IS NULL OR r.total_time <= $3 * INTERVAL '1 second')
+		AND ($4::int IS NULL OR r.servings >= $4)
+		AND ($5::int IS NULL OR r.servings <= $5)
+		AND NOT EXISTS (
+			SELECT 1 FROM unnest($6::text[]) AS included(pattern)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM recipe_ingredients ri
+				WHERE ri.recipe_id = r.recipe_id AND ri.name ILIKE included.pattern
+			)
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM recipe_ingredients ri, unnest($7::text[]) AS excluded(pattern)
+			WHERE ri.recipe_id = r.recipe_id AND ri.name ILIKE excluded.pattern
+		)
+`
+
+// args returns the parameters of recipeSearchFilter for the search.
+func (s RecipeSearch) args() []interface{} {
+	var maxSeconds *int64
+	if s.MaxTotalTime != nil {
+		seconds := int64(s.MaxTotalTime.Seconds())
+		maxSeconds = &seconds
+	}
+	return []interface{}{
+		s.Query,
+		s.AuthorId,
+		maxSeconds,
+		s.MinServings,
+		s.MaxServings,
+		pq.Array(containsPatterns(s.IncludeIngredients)),
+		pq.Array(containsPatterns(s.ExcludeIngredients)),
+	}
+}
+
+// containsPatterns turns each term into an ILIKE pattern matching names
+// that contain it.
+func containsPatterns(terms []string) []string {
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		term = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+		patterns[i] = "%" + term + "%"
+	}
+	return patterns
+}
+
+// SearchRecipes returns the recipes matching search, most relevant first.
+// Without a text query recipes are ordered newest first. Snippets are only
+// built for the page returned.
+func (r *RecipeRepository) SearchRecipes(search RecipeSearch, limit, offset int) ([]RecipeSearchResult, error) {
+	query := `
+		WITH matches AS (
+			SELECT r.recipe_id, r.author_id, r.media_id, r.title, r.description,
+				EXTRACT(EPOCH FROM r.prep_time)::bigint AS prep_seconds,
+				EXTRACT(EPOCH FROM r.cook_time)::bigint AS cook_seconds,
+				EXTRACT(EPOCH FROM r.total_time)::bigint AS total_seconds,
+				r.servings, r.created_at, r.updated_at,
+				CASE WHEN $1 = '' THEN 0 ELSE ts_rank_cd(r.search_vector, q.query) END AS rank,
+				q.query
+			` + recipeSearchFilter + `
+			ORDER BY rank DESC, r.created_at DESC, r.recipe_id
+			LIMIT $8 OFFSET $9
+		)
+		SELECT m.recipe_id, m.author_id, m.media_id, COALESCE(media.url, ''), m.title, m.description,
+			m.prep_seconds, m.cook_seconds, m.total_seconds, m.servings, m.created_at, m.updated_at, m.rank,
+			CASE WHEN $1 = '' THEN left(m.description, 200) ELSE ts_headline('english',
+				translate(concat_ws(' ', m.title, m.description,
+					(SELECT string_agg(name, ', ') FROM recipe_ingredients WHERE recipe_id = m.recipe_id),
+					(SELECT string_agg(description, ' ' ORDER BY step_order) FROM recipe_steps WHERE recipe_id = m.recipe_id)),
+					$10, ''),
+				m.query, 'StartSel=' || left($10, 1) || ', StopSel=' || right($10, 1) || ', MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "')
+			END AS snippet
+		FROM matches m
+		LEFT JOIN media ON media.media_id = m.media_id
+		ORDER BY m.rank DESC, m.created_at DESC, m.recipe_id
+	`
+	rows, err := r.Database.Queryx(query, append(search.args(), limit, offset, snippetStart+snippetStop)...)
+	if err != nil {
+		log.Printf("error searching recipes: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RecipeSearchResult
+	for rows.Next() {
+		var result RecipeSearchResult
+		var mediaID *uuid.UUID
+		var prep, cook, total *int64
+		err := rows.Scan(&result.Id, &result.AuthorId, &mediaID, &result.MediaURL, &result.Title, &result.Description,
+			&prep, &cook, &total, &result.Servings, &result.CreatedAt, &result.UpdatedAt, &result.Rank, &result.Snippet)
+		if err != nil {
+			log.Printf("error scanning recipe search result: %v\n", err)
+			return nil, err
+		}
+		if mediaID != nil {
+			result.MediaId = *mediaID
+		}
+		result.PrepTime = secondsDuration(prep)
+		result.CookTime = secondsDuration(cook)
+		result.TotalTime = secondsDuration(total)
+		result.Snippet = highlightSnippet(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// snippetStart and snippetStop delimit the search terms in the snippets
+// Postgres makes. They are private use characters, which are removed from
+// the recipe text first, so the text can be escaped before the delimiters
+// become <mark> tags.
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+var snippetMarks = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>")
+
+// highlightSnippet escapes snippet for HTML and turns its delimited search
+// terms into <mark> tags.
+func highlightSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+// CountRecipeSearch counts the recipes matching search.
+func (r *RecipeRepository) CountRecipeSearch(search RecipeSearch) (int, error) {
+	var count int
+	if err := r.Database.QueryRowx(`SELECT COUNT(*) `+recipeSearchFilter, search.args()...).Scan(&count); err != nil {
+		log.Printf("error counting recipe search: %v\n", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func secondsDuration(seconds *int64) *time.Duration {
+	if seconds == nil {
+		return nil
+	}
+	d := time.Duration(*seconds) * time.Second
+	return &d
+}
+
+// RecipeIngredientNames are the ingredient names of a recipe.
+type RecipeIngredientNames struct {
+	RecipeId uuid.UUID
+	Names    []string
+}
+
+// GetRecipesByIngredientWords returns the ingredients of up to limit
+// recipes with an ingredient whose normalized name contains one of words,
+// the recipes with the most such ingredients first.
+func (r *RecipeRepository) GetRecipesByIngredientWords(words []string, limit int) ([]RecipeIngredientNames, error) {
+	query := `
+		SELECT ri.recipe_id, array_agg(ri.name ORDER BY ri.id)
+		FROM recipe_ingredients ri
+		JOIN (
+			SELECT recipe_id, COUNT(*) AS shared
+			FROM recipe_ingredients
+			WHERE string_to_array(normalized_name, ' ') && $1::text[]
+			GROUP BY recipe_id
+			ORDER BY shared DESC, recipe_id
+			LIMIT $2
+		) candidates ON candidates.recipe_id = ri.recipe_id
+		GROUP BY ri.recipe_id, candidates.shared
+		ORDER BY candidates.shared DESC, ri.recipe_id
+	`
+	rows, err := r.Database.Queryx(query, pq.Array(words), limit)
+	if err != nil {
+		log.Printf("error fetching recipes by ingredient: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []RecipeIngredientNames
+	for rows.Next() {
+		var recipe RecipeIngredientNames
+		var names pq.StringArray
+		if err := rows.Scan(&recipe.RecipeId, &names); err != nil {
+			log.Printf("error scanning recipe ingredients: %v\n", err)
+			return nil, err
+		}
+		recipe.Names = names
+		recipes = append(recipes, recipe)
+	}
+	return recipes, rows.Err()
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRecipeRepository_SearchRecipesEscapesSnippets(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewRecipeRepository(db)
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"recipe_id", "author_id", "media_id", "url", "title", "description", "prep_seconds", "cook_seconds",
+		"total_seconds", "servings", "created_at", "updated_at", "rank", "snippet"}
+	snippet := `<img src=x onerror=alert(1)> ` + snippetStart + `Jammy` + snippetStop + ` & sweet "tomatoes"`
+	fake.queue(columns, []driver.Value{
+		uuid.NewString(), uuid.NewString(), nil, "", "<b>Tomatoes</b>", "Sweet", nil, nil, int64(3600), int64(4),
+		createdAt, createdAt, 0.5, snippet,
+	})
+
+	results, err := repo.SearchRecipes(RecipeSearch{Query: "jammy"}, 20, 0)
+	if err != nil {
+		t.Fatalf("Failed to search recipes: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	expected := `&lt;img src=x onerror=alert(1)&gt; <mark>Jammy</mark> &amp; sweet &#34;tomatoes&#34;`
+	if results[0].Snippet != expected {
+		t.Errorf("Expected snippet %q, got %q", expected, results[0].Snippet)
+	}
+	if results[0].TotalTime == nil || *results[0].TotalTime != time.Hour {
+		t.Errorf("Expected total time of an hour, got %v", results[0].TotalTime)
+	}
+
+	// The delimiters are passed to Postgres rather than written in the query
+	if strings.Contains(fake.statements[0], "<mark>") {
+		t.Error("Expected the query not to put HTML into snippets")
+	}
+	args := fake.args[0]
+	if args[len(args)-1] != snippetStart+snippetStop {
+		t.Errorf("Expected snippet delimiters as the last argument, got %v", args[len(args)-1])
+	}
+}
+
+func TestRecipeRepository_GetRecipesByAuthorID(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewRecipeRepository(db)
+
+	authorID, mediaID := uuid.New(), uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"recipe_id", "author_id", "media_id", "coalesce", "title", "description", "int8", "int8", "int8",
+		"servings", "created_at", "updated_at"}
+	fake.queue(columns,
+		[]driver.Value{uuid.NewString(), authorID.String(), mediaID.String(), "https://cdn/soup.jpg", "Soup", "Warm", int64(600), int64(1800), int64(2400), int64(4), createdAt, createdAt},
+		[]driver.Value{uuid.NewString(), authorID.String(), nil, "", "Salad", "", nil, nil, nil, nil, createdAt, createdAt},
+	)
+
+	recipes, err := repo.GetRecipesByAuthorID(authorID)
+	if err != nil {
+		t.Fatalf("Failed to fetch recipes: %v", err)
+	}
+	if len(recipes) != 2 {
+		t.Fatalf("Expected 2 recipes, got %d", len(recipes))
+	}
+	soup := recipes[0]
+	if soup.Title != "Soup" || soup.AuthorId != authorID || soup.MediaId != mediaID || soup.MediaURL != "https://cdn/soup.jpg" {
+		t.Errorf("Unexpected recipe: %+v", soup)
+	}
+	if soup.PrepTime == nil || *soup.PrepTime != 10*time.Minute || soup.Servings == nil || *soup.Servings != 4 {
+		t.Errorf("Expected times and servings to be scanned, got %v %v", soup.PrepTime, soup.Servings)
+	}
+	if recipes[1].PrepTime != nil || recipes[1].Servings != nil || recipes[1].MediaId != uuid.Nil {
+		t.Errorf("Expected nullable columns to stay empty, got %+v", recipes[1])
+	}
+	if strings.Contains(fake.statements[0], "r.*") {
+		t.Error("Expected the query to list its columns")
+	}
+
+	// A row that cannot be read fails the listing instead of going missing
+	fake.queue(columns, []driver.Value{uuid.NewString(), authorID.String(), nil, "", nil, "", nil, nil, nil, nil, createdAt, createdAt})
+	if _, err := repo.GetRecipesByAuthorID(authorID); err == nil {
+		t.Error("Expected a scan error")
+	}
+}
+
+func TestRecipeRepository_GetRecipeByID(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewRecipeRepository(db)
+
+	recipeID, authorID := uuid.New(), uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"recipe_id", "author_id", "media_id", "coalesce", "title", "description", "int8", "int8", "int8",
+		"servings", "created_at", "updated_at"}
+	fake.queue(columns, []driver.Value{recipeID.String(), authorID.String(), nil, "", "Soup", "Warm", int64(600), nil, nil, int64(4), createdAt, createdAt})
+	fake.queue([]string{"name", "quantity", "unit"},
+		[]driver.Value{"onion", []byte("1.50"), "piece"},
+		[]driver.Value{"stock", []byte("500.00"), "ml"},
+	)
+	fake.queue([]string{"step_order", "description"},
+		[]driver.Value{int64(1), "Chop the onion"},
+		[]driver.Value{int64(2), "Simmer in stock"},
+	)
+
+	recipe, err := repo.GetRecipeByID(recipeID)
+	if err != nil {
+		t.Fatalf("Failed to fetch recipe: %v", err)
+	}
+	if recipe.Id != recipeID || recipe.AuthorId != authorID || recipe.Title != "Soup" {
+		t.Errorf("Unexpected recipe: %+v", recipe)
+	}
+	if len(recipe.Ingredients) != 2 || recipe.Ingredients[0].Quantity != 1.5 || recipe.Ingredients[1].Unit != "ml" {
+		t.Errorf("Expected both ingredients, got %+v", recipe.Ingredients)
+	}
+	if len(recipe.Steps) != 2 || recipe.Steps[1].Order != 2 || recipe.Steps[1].Description != "Simmer in stock" {
+		t.Errorf("Expected both steps, got %+v", recipe.Steps)
+	}
+	if !strings.Contains(fake.statements[0], "EXTRACT(EPOCH FROM r.prep_time)") || strings.Contains(fake.statements[0], "r.*") {
+		t.Errorf("Expected explicit recipe columns, got %q", fake.statements[0])
+	}
+
+	fake.queue(columns)
+	if _, err := repo.GetRecipeByID(uuid.New()); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type SessionRepository struct {
+	Database config.Database
+}
+
+func NewSessionRepository(db config.Database) *SessionRepository {
+	return &SessionRepository{Database: db}
+}
+
+const sessionColumns = `session_id, user_id, refresh_token_hash, previous_token_hash, user_agent, expires_at, revoked_at, last_used_at, created_at`
+
+func (r *SessionRepository) CreateSession(session schema.Session) error {
+	query := `
+		INSERT INTO sessions (session_id, user_id, refresh_token_hash, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.Database.Exec(query, session.Id, session.UserId, session.RefreshTokenHash, session.UserAgent, session.ExpiresAt)
+	if err != nil {
+		log.Printf("error creating session: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetSessionByID(id uuid.UUID) (*schema.Session, error) {
+	var session schema.Session
+	err := r.Database.QueryRowx("SELECT "+sessionColumns+" FROM sessions WHERE session_id = $1", id).StructScan(&session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionByTokenHash looks a session up by its current refresh token hash,
+// or by the hash it held before the last rotation so that replays of an old
+// refresh token can be detected by the caller.
+func (r *SessionRepository) GetSessionByTokenHash(hash string) (*schema.Session, error) {
+	var session schema.Session
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE refresh_token_hash = $1 OR previous_token_hash = $1"
+	err := r.Database.QueryRowx(query, hash).StructScan(&session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession swaps the refresh token hash of an active session. The update
+// only applies while the session still holds oldHash, so two concurrent
+// refreshes with the same token cannot both succeed.
+func (r *SessionRepository) RotateSession(id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $1, expires_at = $2, last_used_at = $3
+		WHERE session_id = $4 AND refresh_token_hash = $5 AND revoked_at IS NULL
+	`
+	result, err := r.Database.Exec(query, newHash, expiresAt, time.Now(), id, oldHash)
+	if err != nil {
+		log.Printf("error rotating session: %v\n", err)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeSession(id uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE session_id = $2 AND revoked_at IS NULL`
+	_, err := r.Database.Exec(query, time.Now(), id)
+	if err != nil {
+		log.Printf("error revoking session: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeUserSessions(userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	_, err := r.Database.Exec(query, time.Now(), userID)
+	if err != nil {
+		log.Printf("error revoking user sessions: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type TagRepository struct {
+	Database config.Database
+}
+
+func NewTagRepository(db config.Database) *TagRepository {
+	return &TagRepository{Database: db}
+}
+
+// setPostTags replaces the tags of a post within tx. Tags must already be
+// normalized; tags used for the first time are created. Tags the post
+// keeps are left alone, so their created_at still says when they were
+// first put on the post and edits do not count towards trending tags.
+func setPostTags(tx *sqlx.Tx, postID uuid.UUID, tags []string) error {
+	_, err := tx.Exec(`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, pq.Array(tags))
+	if err != nil {
+		log.Printf("error creating tags: %v\n", err)
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM post_tags WHERE post_id = $1 AND NOT (tag = ANY($2))`, postID, pq.Array(tags))
+	if err != nil {
+		log.Printf("error removing post tags: %v\n", err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO post_tags (post_id, tag)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (post_id, tag) DO NOTHING
+	`, postID, pq.Array(tags))
+	if err != nil {
+		log.Printf("error adding post tags: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetPostsByTag lists the posts carrying tag, newest first.
+func (r *TagRepository) GetPostsByTag(tag string, limit, offset int) ([]schema.PostWithMedia, error) {
+	query := `
+		SELECT p.post_id, p.author_id, p.media_id, p.media_url, p.title, p.body, p.tags, p.recipe_id, p.created_at, p.updated_at
+		FROM post_tags pt
+		JOIN post p ON p.post_id = pt.post_id
+		WHERE pt.tag = $1
+		ORDER BY p.created_at DESC, p.post_id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.Database.Queryx(query, tag, limit, offset)
+	if err != nil {
+		log.Printf("error fetching posts by tag: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func (r *TagRepository) CountPostsByTag(tag string) (int, error) {
+	var count int
+	if err := r.Database.QueryRowx(`SELECT COUNT(*) FROM post_tags WHERE tag = $1`, tag).Scan(&count); err != nil {
+		log.Printf("error counting posts by tag: %v\n", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// SearchTags returns the tags starting with prefix, most used first.
+func (r *TagRepository) SearchTags(prefix string, limit int) ([]schema.Tag, error) {
+	query := `
+		SELECT t.name, COUNT(pt.post_id) AS post_count
+		FROM tags t
+		LEFT JOIN post_tags pt ON pt.tag = t.name
+		WHERE t.name LIKE $1
+		GROUP BY t.name
+		ORDER BY post_count DESC, t.name
+		LIMIT $2
+	`
+	// Tags may contain underscores, which LIKE reads as a wildcard
+	pattern := strings.ReplaceAll(prefix, "_", `\_`) + "%"
+	return r.listTags(query, pattern, limit)
+}
+
+// GetTrendingTags returns the tags put on the most posts since the given
+// time. Ties go to the tag used most recently.
+func (r *TagRepository) GetTrendingTags(since time.Time, limit int) ([]schema.Tag, error) {
+	query := `
+		SELECT tag AS name, COUNT(*) AS post_count
+		FROM post_tags
+		WHERE created_at >= $1
+		GROUP BY tag
+		ORDER BY post_count DESC, MAX(created_at) DESC
+		LIMIT $2
+	`
+	return r.listTags(query, since, limit)
+}
+
+func (r *TagRepository) listTags(query string, args ...interface{}) ([]schema.Tag, error) {
+	rows, err := r.Database.Queryx(query, args...)
+	if err != nil {
+		log.Printf("error fetching tags: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []schema.Tag
+	for rows.Next() {
+		var tag schema.Tag
+		if err := rows.StructScan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
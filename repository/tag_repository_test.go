@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTagRepository_GetPostsByTag(t *testing.T) {
+	db, fake := newFakeDB(t)
+	repo := NewTagRepository(db)
+
+	postID, authorID, mediaID, recipeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"post_id", "author_id", "media_id", "media_url", "title", "body", "tags", "recipe_id", "created_at", "updated_at"}
+	fake.queue(columns,
+		[]driver.Value{postID.String(), authorID.String(), mediaID.String(), "https://cdn/post.jpg", "Carbonara", "#pasta tonight", "{pasta,dinner}", recipeID.String(), createdAt, createdAt},
+		[]driver.Value{uuid.NewString(), authorID.String(), nil, nil, "Cacio e pepe", "", "{pasta}", nil, createdAt, createdAt},
+	)
+
+	posts, err := repo.GetPostsByTag("pasta", 20, 0)
+	if err != nil {
+		t.Fatalf("Failed to fetch posts: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(posts))
+	}
+
+	post := posts[0]
+	if post.Id != postID || post.AuthorId != authorID || post.Title != "Carbonara" || !post.CreatedAt.Equal(createdAt) {
+		t.Errorf("Unexpected post: %+v", post)
+	}
+	if post.MediaId == nil || *post.MediaId != mediaID || post.MediaURL == nil || *post.MediaURL != "https://cdn/post.jpg" {
+		t.Errorf("Expected media to be scanned, got %v %v", post.MediaId, post.MediaURL)
+	}
+	if len(post.Tags) != 2 || post.Tags[0] != "pasta" || post.Tags[1] != "dinner" {
+		t.Errorf("Expected tags to be scanned, got %v", post.Tags)
+	}
+	if post.Recipe == nil || post.Recipe.Id != recipeID {
+		t.Errorf("Expected recipe to be scanned, got %+v", post.Recipe)
+	}
+	if posts[1].MediaId != nil || posts[1].Recipe != nil {
+		t.Errorf("Expected nullable columns to stay empty, got %+v", posts[1])
+	}
+
+	if args := fake.args[0]; len(args) != 3 || args[0] != "pasta" || args[1] != int64(20) || args[2] != int64(0) {
+		t.Errorf("Unexpected query arguments: %v", args)
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type TwoFactorRepository struct {
+	Database config.Database
+}
+
+func NewTwoFactorRepository(db config.Database) *TwoFactorRepository {
+	return &TwoFactorRepository{Database: db}
+}
+
+func (r *TwoFactorRepository) GetTOTP(userID uuid.UUID) (*schema.TOTPSecret, error) {
+	var totp schema.TOTPSecret
+	query := `SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_totp WHERE user_id = $1`
+	err := r.Database.QueryRowx(query, userID).StructScan(&totp)
+	if err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// SavePendingTOTP stores a new secret awaiting confirmation, replacing any
+// earlier pending secret. It never overwrites an enabled secret.
+func (r *TwoFactorRepository) SavePendingTOTP(userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_used_step = NULL
+		WHERE user_totp.enabled_at IS NULL
+	`
+	_, err := r.Database.Exec(query, userID, secret, time.Now())
+	if err != nil {
+		log.Printf("error saving totp secret: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// EnableTOTP confirms a pending secret and records the step of the code
+// that confirmed it.
+func (r *TwoFactorRepository) EnableTOTP(userID uuid.UUID, step int64) error {
+	query := `UPDATE user_totp SET enabled_at = $1, last_used_step = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, time.Now(), step, userID)
+	if err != nil {
+		log.Printf("error enabling totp: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// UseTOTPStep records that the code for step was used. It returns
+// sql.ErrNoRows if that step or a later one was already used.
+func (r *TwoFactorRepository) UseTOTPStep(userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp SET last_used_step = $1
+		WHERE user_id = $2 AND (last_used_step IS NULL OR last_used_step < $1)
+		RETURNING user_id
+	`
+	var id uuid.UUID
+	return r.Database.QueryRowx(query, step, userID).Scan(&id)
+}
+
+// DisableTOTP removes the secret together with all recovery codes.
+func (r *TwoFactorRepository) DisableTOTP(userID uuid.UUID) error {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Printf("error deleting recovery codes: %v\n", err)
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		log.Printf("error deleting totp secret: %v\n", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores the
+// given hashes as the new set.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	tx, err := r.Database.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Printf("error deleting recovery codes: %v\n", err)
+		return err
+	}
+	for _, hash := range hashes {
+		_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			log.Printf("error creating recovery code: %v\n", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used. It returns
+// sql.ErrNoRows when the code is unknown or already used.
+func (r *TwoFactorRepository) ConsumeRecoveryCode(userID uuid.UUID, hash string) error {
+	query := `
+		UPDATE recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+		RETURNING id
+	`
+	var id int
+	return r.Database.QueryRowx(query, time.Now(), userID, hash).Scan(&id)
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	err := r.Database.QueryRowx(query, userID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -1,14 +1,22 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/smilecs/foody/config"
 	"github.com/smilecs/foody/schema"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrUsernameTaken is returned when a username change collides with an
+// existing account.
+var ErrUsernameTaken = errors.New("username is already taken")
+
 type UserRepository struct {
 	Database config.Database
 }
@@ -17,9 +25,13 @@ func NewUserRepository(db config.Database) *UserRepository {
 	return &UserRepository{Database: db}
 }
 
+// userColumns lists the users columns scanned into schema.User. The date of
+// birth is formatted so it round-trips with the value given at signup.
+const userColumns = `user_id, name, username, email, email_verified, media_id, COALESCE(to_char(date_of_birth, 'YYYY-MM-DD'), '') AS date_of_birth, bio, username_changed_at, deletion_scheduled_at, role, suspended_at, is_private, password`
+
 func (r *UserRepository) GetUserByID(id uuid.UUID) (*schema.User, error) {
 	var user schema.User
-	err := r.Database.QueryRowx("SELECT * FROM users WHERE user_id = $1", id).StructScan(&user)
+	err := r.Database.QueryRowx("SELECT "+userColumns+" FROM users WHERE user_id = $1", id).StructScan(&user)
 	if err != nil {
 		return nil, err
 	}
@@ -51,27 +63,228 @@ func (r *UserRepository) CreateUser(user schema.User, password string, mediaID u
 	return "", nil
 }
 
+// dummyPasswordHash is compared against when no account matches, so that a
+// login for an unknown email takes as long as one with a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// AuthUser reports whether password is correct for the account with the
+// given email. Unknown emails and wrong passwords both return false with no
+// error; an error means the check itself failed.
 func (r *UserRepository) AuthUser(email, password string) (bool, error) {
 	var hashedPassword string
 
 	query := `SELECT password FROM users WHERE email = $1`
 	err := r.Database.QueryRowx(query, email).Scan(&hashedPassword)
+	// Accounts created through an identity provider have no password
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && hashedPassword == "") {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
-	return true, err
+	return true, nil
 }
 
 func (r *UserRepository) GetUserByEmail(email string) (*schema.User, error) {
 	var user schema.User
-	err := r.Database.QueryRowx("SELECT * FROM users WHERE email = $1", email).StructScan(&user)
+	err := r.Database.QueryRowx("SELECT "+userColumns+" FROM users WHERE email = $1", email).StructScan(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdatePassword(userID uuid.UUID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("error hashing password: %v", err)
+		return err
+	}
+
+	query := `UPDATE users SET password = $1, updated_at = $2 WHERE user_id = $3`
+	_, err = r.Database.Exec(query, hashedPassword, time.Now(), userID)
+	if err != nil {
+		log.Printf("error updating password: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(userID uuid.UUID) error {
+	query := `UPDATE users SET email_verified = TRUE, email_verified_at = $1, updated_at = $1 WHERE user_id = $2`
+	_, err := r.Database.Exec(query, time.Now(), userID)
+	if err != nil {
+		log.Printf("error marking email verified: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) GetUserByUsername(username string) (*schema.User, error) {
+	var user schema.User
+	err := r.Database.QueryRowx("SELECT "+userColumns+" FROM users WHERE LOWER(username) = LOWER($1)", username).StructScan(&user)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (r *UserRepository) UpdateProfile(user schema.User) error {
+	query := `
+		UPDATE users SET name = $1, bio = $2, date_of_birth = NULLIF($3, '')::date, is_private = $4, updated_at = $5
+		WHERE user_id = $6
+	`
+	_, err := r.Database.Exec(query, user.Name, user.Bio, user.DOB, user.IsPrivate, time.Now(), user.Id)
+	if err != nil {
+		log.Printf("error updating profile: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateAvatar(userID, mediaID uuid.UUID) error {
+	query := `UPDATE users SET media_id = $1, updated_at = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, mediaID, time.Now(), userID)
+	if err != nil {
+		log.Printf("error updating avatar: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateUsername changes the username and records when it happened so the
+// change cooldown can be enforced.
+func (r *UserRepository) UpdateUsername(userID uuid.UUID, username string) error {
+	query := `UPDATE users SET username = $1, username_changed_at = $2, updated_at = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, username, time.Now(), userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrUsernameTaken
+		}
+		log.Printf("error updating username: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ScheduleDeletion marks the account for removal once at has passed.
+func (r *UserRepository) ScheduleDeletion(userID uuid.UUID, at time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $1, updated_at = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, at, time.Now(), userID)
+	if err != nil {
+		log.Printf("error scheduling deletion: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) CancelDeletion(userID uuid.UUID) error {
+	query := `UPDATE users SET deletion_scheduled_at = NULL, updated_at = $1 WHERE user_id = $2`
+	_, err := r.Database.Exec(query, time.Now(), userID)
+	if err != nil {
+		log.Printf("error cancelling deletion: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetUsersDueForDeletion returns the IDs of accounts whose deletion grace
+// period ended before now.
+func (r *UserRepository) GetUsersDueForDeletion(now time.Time) ([]uuid.UUID, error) {
+	rows, err := r.Database.Queryx(`SELECT user_id FROM users WHERE deletion_scheduled_at <= $1`, now)
+	if err != nil {
+		log.Printf("error retrieving users due for deletion: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteUser removes the account; posts, recipes, meal plans, media rows and
+// sessions are removed by the foreign key cascades.
+func (r *UserRepository) DeleteUser(userID uuid.UUID) error {
+	_, err := r.Database.Exec(`DELETE FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Printf("error deleting user: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) ListUsers(limit, offset int) ([]schema.User, error) {
+	var users []schema.User
+
+	query := "SELECT " + userColumns + " FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2"
+	rows, err := r.Database.Queryx(query, limit, offset)
+	if err != nil {
+		log.Printf("error retrieving users: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user schema.User
+		if err := rows.StructScan(&user); err != nil {
+			log.Printf("error scanning user: %v", err)
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *UserRepository) GetTotalUsersCount() (int, error) {
+	var count int
+	err := r.Database.QueryRowx("SELECT COUNT(*) FROM users").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetSuspended suspends the account when suspended is true and lifts the
+// suspension otherwise.
+func (r *UserRepository) SetSuspended(userID uuid.UUID, suspended bool) error {
+	var suspendedAt *time.Time
+	if suspended {
+		now := time.Now()
+		suspendedAt = &now
+	}
+
+	query := `UPDATE users SET suspended_at = $1, updated_at = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, suspendedAt, time.Now(), userID)
+	if err != nil {
+		log.Printf("error updating suspension: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateRole(userID uuid.UUID, role schema.Role) error {
+	query := `UPDATE users SET role = $1, updated_at = $2 WHERE user_id = $3`
+	_, err := r.Database.Exec(query, role, time.Now(), userID)
+	if err != nil {
+		log.Printf("error updating role: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/config"
+	"github.com/smilecs/foody/schema"
+)
+
+type UserTokenRepository struct {
+	Database config.Database
+}
+
+func NewUserTokenRepository(db config.Database) *UserTokenRepository {
+	return &UserTokenRepository{Database: db}
+}
+
+func (r *UserTokenRepository) CreateUserToken(token schema.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.Database.Exec(query, token.TokenHash, token.UserId, token.Purpose, token.ExpiresAt)
+	if err != nil {
+		log.Printf("error creating user token: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns the
+// user it belongs to. It returns sql.ErrNoRows when the token is unknown,
+// expired or has already been used.
+func (r *UserTokenRepository) ConsumeUserToken(hash string, purpose schema.TokenPurpose) (uuid.UUID, error) {
+	query := `
+		UPDATE user_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`
+	var userID uuid.UUID
+	err := r.Database.QueryRowx(query, time.Now(), hash, purpose).Scan(&userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// InvalidateUserTokens expires every outstanding token of the given purpose
+// for a user, so that only the most recently issued one can be used.
+func (r *UserTokenRepository) InvalidateUserTokens(userID uuid.UUID, purpose schema.TokenPurpose) error {
+	query := `UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL`
+	_, err := r.Database.Exec(query, time.Now(), userID, purpose)
+	if err != nil {
+		log.Printf("error invalidating user tokens: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// GetLatestUserToken returns the most recently issued token of the given
+// purpose for a user, used to throttle resends.
+func (r *UserTokenRepository) GetLatestUserToken(userID uuid.UUID, purpose schema.TokenPurpose) (*schema.UserToken, error) {
+	var token schema.UserToken
+	query := `
+		SELECT token_hash, user_id, purpose, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.Database.QueryRowx(query, userID, purpose).StructScan(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
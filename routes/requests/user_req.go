@@ -1,8 +1,111 @@
 package requests
 
+import (
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
 type UserReq struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	DOB      string `json:"date_of_birth"`
 }
+
+// UpdateProfileReq is a partial profile update; nil fields are left as is.
+type UpdateProfileReq struct {
+	Name *string `json:"name"`
+	Bio  *string `json:"bio"`
+	DOB  *string `json:"date_of_birth"`
+	// IsPrivate makes new followers need approval.
+	IsPrivate *bool `json:"is_private"`
+}
+
+type UpdateUsernameReq struct {
+	Username string `json:"username"`
+}
+
+// PasswordConfirmReq re-confirms the password before a sensitive action.
+type PasswordConfirmReq struct {
+	Password string `json:"password"`
+}
+
+type UpdateRoleReq struct {
+	Role string `json:"role"`
+}
+
+type TwoFactorCodeReq struct {
+	Code string `json:"code"`
+}
+
+// CreateAPIKeyReq creates a personal API key. A key without scopes can do
+// everything its owner can; ExpiresInDays of nil means it never expires.
+type CreateAPIKeyReq struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+// CommentReq creates a comment, or a reply when ParentId is set.
+type CommentReq struct {
+	Body     string     `json:"body"`
+	ParentId *uuid.UUID `json:"parent_id"`
+}
+
+type UpdateCommentReq struct {
+	Body string `json:"body"`
+}
+
+type CollectionReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsPublic    bool   `json:"is_public"`
+}
+
+// UpdateCollectionReq is a partial collection update; nil fields are left
+// as is.
+type UpdateCollectionReq struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsPublic    *bool   `json:"is_public"`
+}
+
+type CollectionItemReq struct {
+	RecipeId uuid.UUID `json:"recipe_id"`
+	Note     string    `json:"note"`
+}
+
+type UpdateCollectionItemReq struct {
+	Note string `json:"note"`
+}
+
+// ReorderCollectionReq lists every recipe of a collection in its new order.
+type ReorderCollectionReq struct {
+	RecipeIds []uuid.UUID `json:"recipe_ids"`
+}
+
+// NotificationPreferencesReq turns notification types on or off. Types
+// that are left out keep their current setting.
+type NotificationPreferencesReq map[string]bool
+
+// PantryMatchReq lists the ingredients a user has at hand. MaxMissing is
+// how many ingredients a recipe may lack to be listed, and Staples whether
+// salt, water and the like count as at hand; both have defaults when nil.
+type PantryMatchReq struct {
+	Ingredients []string `json:"ingredients"`
+	MaxMissing  *int     `json:"max_missing"`
+	Staples     *bool    `json:"staples"`
+}
+
+// RecipeReq is a recipe to create or update. IngredientLines are pasted
+// ingredient lines like "1 1/2 cups flour, sifted"; they are parsed and
+// added after Ingredients.
+type RecipeReq struct {
+	schema.Recipe
+	IngredientLines []string `json:"ingredient_lines"`
+}
+
+// ParseIngredientsReq is a pasted ingredient list, one ingredient per line.
+type ParseIngredientsReq struct {
+	Text string `json:"text"`
+}
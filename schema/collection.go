@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultCollectionName names the collection every user saves recipes to
+// unless they pick another one.
+const DefaultCollectionName = "Saved"
+
+// Collection is a user-curated, ordered list of recipes, a cookbook.
+type Collection struct {
+	Id          uuid.UUID `db:"collection_id" json:"collection_id"`
+	UserId      uuid.UUID `db:"user_id" json:"user_id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	IsPublic    bool      `db:"is_public" json:"is_public"`
+	// IsDefault marks the user's "Saved" collection, which cannot be
+	// renamed or deleted.
+	IsDefault bool      `db:"is_default" json:"is_default"`
+	ItemCount int       `db:"item_count" json:"item_count"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (c Collection) OwnerID() uuid.UUID { return c.UserId }
+
+// CollectionItem is a recipe in a collection. Items are listed by Position,
+// starting at 0.
+type CollectionItem struct {
+	CollectionId uuid.UUID `db:"collection_id" json:"collection_id"`
+	RecipeId     uuid.UUID `db:"recipe_id" json:"recipe_id"`
+	Position     int       `db:"position" json:"position"`
+	Note         string    `db:"note" json:"note"`
+	AddedAt      time.Time `db:"added_at" json:"added_at"`
+}
@@ -0,0 +1,31 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// MaxCommentLength caps the length of a comment body in characters.
+const MaxCommentLength = 2000
+
+// Comment is a comment on a post or recipe. Replies carry the top-level
+// comment they answer in ParentId; replies cannot be replied to.
+type Comment struct {
+	Id             uuid.UUID  `db:"comment_id" json:"comment_id"`
+	TargetType     TargetType `db:"target_type" json:"target_type"`
+	TargetId       uuid.UUID  `db:"target_id" json:"target_id"`
+	ParentId       *uuid.UUID `db:"parent_id" json:"parent_id,omitempty"`
+	AuthorId       uuid.UUID  `db:"author_id" json:"author_id"`
+	AuthorUsername string     `db:"author_username" json:"author_username"`
+	Body           string     `db:"body" json:"body"`
+	// Mentions lists the usernames of the users mentioned in Body.
+	Mentions   pq.StringArray `db:"mentions" json:"mentions"`
+	ReplyCount int            `db:"reply_count" json:"reply_count"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at" json:"updated_at"`
+	EditedAt   *time.Time     `db:"edited_at" json:"edited_at,omitempty"`
+}
+
+func (c Comment) OwnerID() uuid.UUID { return c.AuthorId }
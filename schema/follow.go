@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FollowStatus string
+
+const (
+	// FollowPending is a request to follow a private account that the
+	// account has not approved yet.
+	FollowPending  FollowStatus = "pending"
+	FollowAccepted FollowStatus = "accepted"
+)
+
+type Follow struct {
+	FollowerId uuid.UUID    `db:"follower_id" json:"follower_id"`
+	FolloweeId uuid.UUID    `db:"followee_id" json:"followee_id"`
+	Status     FollowStatus `db:"status" json:"status"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
+	AcceptedAt *time.Time   `db:"accepted_at" json:"accepted_at,omitempty"`
+}
+
+// FollowListEntry is a user in a list of followers, followed accounts or
+// follow requests.
+type FollowListEntry struct {
+	UserId     uuid.UUID    `db:"user_id" json:"user_id"`
+	Username   string       `db:"username" json:"username"`
+	Name       string       `db:"name" json:"name"`
+	Status     FollowStatus `db:"status" json:"status"`
+	FollowedAt time.Time    `db:"created_at" json:"followed_at"`
+}
+
+// FollowCounts counts accepted follows in each direction and the pending
+// requests a user has received.
+type FollowCounts struct {
+	Followers int `db:"followers" json:"followers_count"`
+	Following int `db:"following" json:"following_count"`
+	Requests  int `db:"requests" json:"follow_requests_count"`
+}
@@ -0,0 +1,31 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account at an external OpenID Connect provider to
+// a user. Subject is the provider's stable id for the account.
+type UserIdentity struct {
+	Provider  string    `db:"provider" json:"provider"`
+	Subject   string    `db:"subject" json:"-"`
+	UserId    uuid.UUID `db:"user_id" json:"-"`
+	Email     string    `db:"email" json:"email"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// OIDCAuthRequest is the state kept between sending a user to a provider
+// and the provider redirecting back. It is looked up by the hash of the
+// state parameter and can only be used once.
+type OIDCAuthRequest struct {
+	StateHash    string `db:"state_hash"`
+	Provider     string `db:"provider"`
+	Nonce        string `db:"nonce"`
+	CodeVerifier string `db:"code_verifier"`
+	// LinkUserId is set when a signed in user is linking the provider to
+	// their account rather than logging in.
+	LinkUserId *uuid.UUID `db:"link_user_id"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TargetType is the kind of item an interaction is attached to.
+type TargetType string
+
+const (
+	TargetPost   TargetType = "post"
+	TargetRecipe TargetType = "recipe"
+	// TargetComment is only used by notifications about a comment, such as
+	// replies and mentions.
+	TargetComment TargetType = "comment"
+)
+
+// Reaction is a like or one of a small set of emoji reactions. Emoji are
+// stored by name and left to clients to draw.
+type Reaction string
+
+const (
+	ReactionLike  Reaction = "like"
+	ReactionLove  Reaction = "love"  // ❤️
+	ReactionYum   Reaction = "yum"   // 😋
+	ReactionFire  Reaction = "fire"  // 🔥
+	ReactionLaugh Reaction = "laugh" // 😂
+	ReactionWow   Reaction = "wow"   // 😮
+)
+
+// Valid reports whether r is one of the known reactions.
+func (r Reaction) Valid() bool {
+	switch r {
+	case ReactionLike, ReactionLove, ReactionYum, ReactionFire, ReactionLaugh, ReactionWow:
+		return true
+	}
+	return false
+}
+
+// Interaction is a user's reaction to a post or recipe. A user can leave
+// each reaction once per item.
+type Interaction struct {
+	UserId     uuid.UUID  `db:"user_id" json:"user_id"`
+	TargetType TargetType `db:"target_type" json:"target_type"`
+	TargetId   uuid.UUID  `db:"target_id" json:"target_id"`
+	Reaction   Reaction   `db:"reaction" json:"reaction"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// ReactionSummary counts the reactions on an item and lists the ones the
+// viewing user left.
+type ReactionSummary struct {
+	Likes       int              `json:"like_count"`
+	Counts      map[Reaction]int `json:"reaction_counts"`
+	LikedByMe   bool             `json:"liked_by_me"`
+	MyReactions []Reaction       `json:"my_reactions"`
+}
+
+// NewReactionSummary returns a summary of an item nobody reacted to.
+func NewReactionSummary() *ReactionSummary {
+	return &ReactionSummary{Counts: map[Reaction]int{}, MyReactions: []Reaction{}}
+}
+
+// Add records count reactions of kind reaction, mine telling whether the
+// viewing user is among them.
+func (s *ReactionSummary) Add(reaction Reaction, count int, mine bool) {
+	s.Counts[reaction] += count
+	if reaction == ReactionLike {
+		s.Likes += count
+	}
+	if mine {
+		s.MyReactions = append(s.MyReactions, reaction)
+		if reaction == ReactionLike {
+			s.LikedByMe = true
+		}
+	}
+}
+
+// Total counts the reactions of every kind.
+func (s *ReactionSummary) Total() int {
+	total := 0
+	for _, count := range s.Counts {
+		total += count
+	}
+	return total
+}
+
+// Reactor is a user in the list of who reacted to an item.
+type Reactor struct {
+	UserId    uuid.UUID `db:"user_id" json:"user_id"`
+	Username  string    `db:"username" json:"username"`
+	Name      string    `db:"name" json:"name"`
+	Reaction  Reaction  `db:"reaction" json:"reaction"`
+	ReactedAt time.Time `db:"created_at" json:"reacted_at"`
+}
@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoginFailureReason string
+
+const (
+	// InvalidCredentials covers both unknown emails and wrong passwords.
+	InvalidCredentials  LoginFailureReason = "invalid_credentials"
+	InvalidSecondFactor LoginFailureReason = "invalid_second_factor"
+	// LoginLocked attempts were refused without checking the password and
+	// do not extend the lockout.
+	LoginLocked LoginFailureReason = "locked"
+)
+
+// LoginAttempt is the audit record of a single login attempt. Failed
+// attempts drive the per-account and per-IP lockout.
+type LoginAttempt struct {
+	Id        int                 `db:"id" json:"-"`
+	Email     string              `db:"email" json:"email"`
+	IP        string              `db:"ip" json:"ip"`
+	UserId    *uuid.UUID          `db:"user_id" json:"user_id,omitempty"`
+	Success   bool                `db:"success" json:"success"`
+	Reason    *LoginFailureReason `db:"reason" json:"reason,omitempty"`
+	UserAgent string              `db:"user_agent" json:"user_agent"`
+	CreatedAt time.Time           `db:"created_at" json:"created_at"`
+}
+
+// LoginFailures summarizes recent failed attempts for an account or IP.
+type LoginFailures struct {
+	Count       int        `db:"count"`
+	LastFailure *time.Time `db:"last_failure"`
+}
@@ -4,32 +4,65 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	Id       uuid.UUID `json:"user_id"`
-	Name     string    `json:"name"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Media    Media     `json:"media"`
-	DOB      string    `json:"date_of_birth"`
-	Password string    `json:"-"`
+	Id                uuid.UUID  `db:"user_id" json:"user_id"`
+	Name              string     `db:"name" json:"name"`
+	Username          string     `db:"username" json:"username"`
+	Email             string     `db:"email" json:"email"`
+	EmailVerified     bool       `db:"email_verified" json:"email_verified"`
+	MediaId           *uuid.UUID `db:"media_id" json:"-"`
+	Media             Media      `db:"-" json:"media"`
+	DOB               string     `db:"date_of_birth" json:"date_of_birth"`
+	Bio               string     `db:"bio" json:"bio"`
+	UsernameChangedAt *time.Time `db:"username_changed_at" json:"username_changed_at,omitempty"`
+	// DeletionScheduledAt is set while an account deletion is pending.
+	DeletionScheduledAt *time.Time `db:"deletion_scheduled_at" json:"deletion_scheduled_at,omitempty"`
+	Role                Role       `db:"role" json:"role"`
+	SuspendedAt         *time.Time `db:"suspended_at" json:"suspended_at,omitempty"`
+	// IsPrivate accounts approve each follower.
+	IsPrivate bool   `db:"is_private" json:"is_private"`
+	Password  string `db:"password" json:"-"`
 }
 
 type Post struct {
-	Id       uuid.UUID `json:"post_id"`
-	Title    string    `json:"title"`
-	Body     string    `json:"body"`
-	MediaId  uuid.UUID `json:"media_id"`
-	AuthorId uuid.UUID `json:"author_id"`
-	Tags     string    `json:"tags"`
-	Recipe   *Recipe   `json:"recipe,omitempty"`
+	Id       uuid.UUID      `json:"post_id"`
+	Title    string         `json:"title"`
+	Body     string         `json:"body"`
+	MediaId  uuid.UUID      `json:"media_id"`
+	AuthorId uuid.UUID      `json:"author_id"`
+	Tags     pq.StringArray `json:"tags"`
+	Recipe   *Recipe        `json:"recipe,omitempty"`
 }
 
+func (p Post) OwnerID() uuid.UUID { return p.AuthorId }
+
 type Ingredient struct {
 	Name     string  `json:"name"`
 	Quantity float64 `json:"quantity"`
 	Unit     string  `json:"unit"`
+	// Display is the quantity written for the kitchen, like "1 1/2". It
+	// is only set on scaled recipes.
+	Display string `json:"display,omitempty"`
 }
 
 type Step struct {
@@ -53,6 +86,8 @@ type Recipe struct {
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
+func (r Recipe) OwnerID() uuid.UUID { return r.AuthorId }
+
 type MealType string
 
 const (
@@ -74,6 +109,8 @@ type MealPlan struct {
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
+func (m MealPlan) OwnerID() uuid.UUID { return m.AuthorId }
+
 type MediaType string
 
 const (
@@ -82,11 +119,10 @@ const (
 )
 
 type Media struct {
-	Id        uuid.UUID `json:"media_id"`
-	URL       string    `json:"url"`
-	MediaType MediaType `json:"media_type"`
-	AuthorId  uuid.UUID `json:"author_id"`
+	Id        uuid.UUID `db:"media_id" json:"media_id"`
+	URL       string    `db:"url" json:"url"`
+	MediaType MediaType `db:"media_type" json:"media_type"`
+	AuthorId  uuid.UUID `db:"author_id" json:"author_id"`
 }
 
-type Interaction struct {
-}
+func (m Media) OwnerID() uuid.UUID { return m.AuthorId }
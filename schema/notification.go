@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type NotificationType string
+
+const (
+	NotifyFollow         NotificationType = "follow"
+	NotifyFollowRequest  NotificationType = "follow_request"
+	NotifyFollowAccepted NotificationType = "follow_accepted"
+	NotifyLike           NotificationType = "like"
+	NotifyReaction       NotificationType = "reaction"
+	NotifyComment        NotificationType = "comment"
+	NotifyReply          NotificationType = "reply"
+	NotifyMention        NotificationType = "mention"
+)
+
+// NotificationTypes lists every notification type, in the order they are
+// shown in the preferences.
+var NotificationTypes = []NotificationType{
+	NotifyFollow, NotifyFollowRequest, NotifyFollowAccepted, NotifyLike,
+	NotifyReaction, NotifyComment, NotifyReply, NotifyMention,
+}
+
+// Valid reports whether t is one of the known notification types.
+func (t NotificationType) Valid() bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Aggregates reports whether unread notifications of this type about the
+// same item are merged into one, as in "3 people liked your recipe".
+func (t NotificationType) Aggregates() bool {
+	switch t {
+	case NotifyFollowAccepted, NotifyMention:
+		return false
+	}
+	return true
+}
+
+// Notification tells a user that others interacted with them or their
+// content. ActorIds and ActorUsernames hold the most recent actors only;
+// ActorCount counts all of them.
+type Notification struct {
+	Id             uuid.UUID        `db:"notification_id" json:"notification_id"`
+	UserId         uuid.UUID        `db:"user_id" json:"-"`
+	Type           NotificationType `db:"type" json:"type"`
+	TargetType     *TargetType      `db:"target_type" json:"target_type,omitempty"`
+	TargetId       *uuid.UUID       `db:"target_id" json:"target_id,omitempty"`
+	GroupKey       string           `db:"group_key" json:"-"`
+	ActorIds       pq.StringArray   `db:"actor_ids" json:"actor_ids"`
+	ActorUsernames pq.StringArray   `db:"actor_usernames" json:"actor_usernames"`
+	ActorCount     int              `db:"actor_count" json:"actor_count"`
+	Message        string           `db:"-" json:"message"`
+	CreatedAt      time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time        `db:"updated_at" json:"updated_at"`
+	ReadAt         *time.Time       `db:"read_at" json:"read_at,omitempty"`
+}
+
+// Summary describes the notification in a sentence such as "alice and 2
+// others liked your recipe".
+func (n Notification) Summary() string {
+	actors := "Someone"
+	if len(n.ActorUsernames) > 0 {
+		actors = n.ActorUsernames[0]
+		switch {
+		case n.ActorCount == 2 && len(n.ActorUsernames) > 1:
+			actors += " and " + n.ActorUsernames[1]
+		case n.ActorCount == 2:
+			actors += " and 1 other"
+		case n.ActorCount > 2:
+			actors += fmt.Sprintf(" and %d others", n.ActorCount-1)
+		}
+	}
+
+	item := "content"
+	if n.TargetType != nil {
+		item = string(*n.TargetType)
+	}
+
+	switch n.Type {
+	case NotifyFollow:
+		return actors + " started following you"
+	case NotifyFollowRequest:
+		return actors + " asked to follow you"
+	case NotifyFollowAccepted:
+		return actors + " accepted your follow request"
+	case NotifyLike:
+		return actors + " liked your " + item
+	case NotifyReaction:
+		return actors + " reacted to your " + item
+	case NotifyComment:
+		return actors + " commented on your " + item
+	case NotifyReply:
+		return actors + " replied to your comment"
+	case NotifyMention:
+		return actors + " mentioned you in a comment"
+	}
+	return actors + " interacted with you"
+}
+
+// NotificationPreference turns a type of notification on or off for a
+// user. Types without a preference are on.
+type NotificationPreference struct {
+	UserId  uuid.UUID        `db:"user_id" json:"-"`
+	Type    NotificationType `db:"type" json:"type"`
+	Enabled bool             `db:"enabled" json:"enabled"`
+}
@@ -0,0 +1,26 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a server-side login session. The refresh token handed to the
+// client is never stored, only its hash.
+type Session struct {
+	Id                uuid.UUID  `db:"session_id" json:"session_id"`
+	UserId            uuid.UUID  `db:"user_id" json:"user_id"`
+	RefreshTokenHash  string     `db:"refresh_token_hash" json:"-"`
+	PreviousTokenHash *string    `db:"previous_token_hash" json:"-"`
+	UserAgent         string     `db:"user_agent" json:"user_agent"`
+	ExpiresAt         time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt         *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	LastUsedAt        time.Time  `db:"last_used_at" json:"last_used_at"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Active reports whether the session can still be used.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,15 @@
+package schema
+
+const (
+	// MaxTagLength is the longest a tag can be, in characters.
+	MaxTagLength = 50
+	// MaxTagsPerPost caps how many tags one post can carry.
+	MaxTagsPerPost = 10
+)
+
+// Tag is a hashtag together with the number of posts carrying it. Trending
+// tags only count posts tagged within the trending window.
+type Tag struct {
+	Name      string `db:"name" json:"name"`
+	PostCount int    `db:"post_count" json:"post_count"`
+}
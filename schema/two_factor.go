@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPSecret is a user's authenticator app secret. It is pending until the
+// user confirms enrollment with a valid code.
+type TOTPSecret struct {
+	UserId    uuid.UUID  `db:"user_id" json:"-"`
+	Secret    string     `db:"secret" json:"-"`
+	EnabledAt *time.Time `db:"enabled_at" json:"enabled_at,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, kept so a
+	// code cannot be replayed within its validity window.
+	LastUsedStep *int64    `db:"last_used_step" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+func (t TOTPSecret) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// RecoveryCode is a single-use code for logging in without the
+// authenticator app. Only the hash of the code is stored.
+type RecoveryCode struct {
+	UserId    uuid.UUID  `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
@@ -0,0 +1,28 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenPurpose string
+
+const (
+	PasswordResetToken     TokenPurpose = "password_reset"
+	EmailVerificationToken TokenPurpose = "email_verification"
+	// LoginChallengeToken links the password step of a login to the
+	// second factor step.
+	LoginChallengeToken TokenPurpose = "login_challenge"
+)
+
+// UserToken is a single-use, expiring token delivered to a user out of band.
+// Only the hash of the token is stored.
+type UserToken struct {
+	TokenHash string       `db:"token_hash" json:"-"`
+	UserId    uuid.UUID    `db:"user_id" json:"user_id"`
+	Purpose   TokenPurpose `db:"purpose" json:"purpose"`
+	ExpiresAt time.Time    `db:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time   `db:"used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,87 @@
+package units
+
+import (
+	"github.com/smilecs/foody/ingredients"
+	"github.com/smilecs/foody/schema"
+)
+
+// densities are the weights in grams of a millilitre of ingredients that
+// imperial recipes measure in cups and metric recipes weigh, keyed by
+// normalized name. Liquids are left out; they are measured by volume in
+// both systems.
+var densities = map[string]float64{
+	"flour":              0.528,
+	"almond flour":       0.406,
+	"coconut flour":      0.473,
+	"sugar":              0.845,
+	"brown sugar":        0.9,
+	"powdered sugar":     0.507,
+	"icing sugar":        0.507,
+	"confectioner sugar": 0.507,
+	"butter":             0.959,
+	"rice":               0.782,
+	"oat":                0.38,
+	"cocoa":              0.359,
+	"cocoa powder":       0.359,
+	"cornstarch":         0.54,
+	"cornflour":          0.54,
+	"honey":              1.42,
+	"chocolate chip":     0.72,
+	"breadcrumb":         0.46,
+}
+
+// Density returns the weight in grams of a millilitre of the named
+// ingredient, if known. The most specific known ingredient the name is a
+// kind of is used, so "bread flour" weighs as flour but "almond flour" as
+// almond flour.
+func Density(name string) (float64, bool) {
+	normalized := ingredients.Normalize(name)
+	best := ""
+	for known := range densities {
+		if ingredients.Covers(known, normalized) && len(known) > len(best) {
+			best = known
+		}
+	}
+	density, ok := densities[best]
+	return density, ok
+}
+
+// ToSystem returns ingredient with its quantity in system. Ingredients
+// with a known density move between volume and mass as they change system,
+// so cups of flour become grams in metric. Spoons are used in both
+// systems and are kept, as are count units and unknown units.
+func ToSystem(ingredient schema.Ingredient, system System) schema.Ingredient {
+	unit, ok := Lookup(ingredient.Unit)
+	if !ok || ingredient.Quantity <= 0 || unit.Dimension == Count || unit.System == system {
+		return ingredient
+	}
+	if system == Metric && (unit == Teaspoon || unit == Tablespoon) {
+		return ingredient
+	}
+
+	base := ingredient.Quantity * unit.Base
+	target := unit.Dimension
+	if density, ok := Density(ingredient.Name); ok {
+		switch {
+		case system == Metric && unit.Dimension == Volume:
+			base, target = base*density, Mass
+		case system == Imperial && unit.Dimension == Mass:
+			base, target = base/density, Volume
+		}
+	}
+
+	ingredient.Quantity, unit, ingredient.Display = fit(base, ladders[target][system])
+	ingredient.Unit = unit.Symbol
+	return ingredient
+}
+
+// ConvertRecipe returns recipe with the quantities of its ingredients in
+// system.
+func ConvertRecipe(recipe schema.Recipe, system System) schema.Recipe {
+	converted := make([]schema.Ingredient, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		converted[i] = ToSystem(ingredient, system)
+	}
+	recipe.Ingredients = converted
+	return recipe
+}
@@ -0,0 +1,93 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// fractions are the parts of a whole measuring cups and spoons come in.
+var fractions = []struct {
+	value float64
+	text  string
+}{
+	{0, ""}, {1.0 / 8, "1/8"}, {1.0 / 4, "1/4"}, {1.0 / 3, "1/3"}, {3.0 / 8, "3/8"},
+	{1.0 / 2, "1/2"}, {5.0 / 8, "5/8"}, {2.0 / 3, "2/3"}, {3.0 / 4, "3/4"}, {7.0 / 8, "7/8"}, {1, ""},
+}
+
+// kitchenTolerance is how far, relative to the amount, a quantity may be
+// from a kitchen fraction to count as measurable with it.
+const kitchenTolerance = 0.05
+
+// KitchenRound rounds amount to the nearest whole number plus one of the
+// fractions measuring cups and spoons come in, and returns it with its
+// written form, like 1.5 and "1 1/2". Amounts of ten and over are rounded
+// to whole numbers. Positive amounts never round down to zero.
+func KitchenRound(amount float64) (float64, string) {
+	if amount <= 0 {
+		return 0, "0"
+	}
+	if amount >= 10 {
+		rounded := math.Round(amount)
+		return rounded, strconv.FormatFloat(rounded, 'f', -1, 64)
+	}
+
+	whole := math.Floor(amount)
+	best := 0
+	for i, fraction := range fractions {
+		if math.Abs(whole+fraction.value-amount) < math.Abs(whole+fractions[best].value-amount) {
+			best = i
+		}
+	}
+	if whole == 0 && best == 0 {
+		best = 1
+	}
+
+	fraction := fractions[best]
+	value := whole + fraction.value
+	switch {
+	case fraction.text == "":
+		return value, strconv.FormatFloat(value, 'f', -1, 64)
+	case whole == 0:
+		return value, fraction.text
+	default:
+		return value, fmt.Sprintf("%v %s", whole, fraction.text)
+	}
+}
+
+// cupFractions are the sizes of a set of measuring cups.
+var cupFractions = []float64{0, 1.0 / 4, 1.0 / 3, 1.0 / 2, 2.0 / 3, 3.0 / 4}
+
+// measurable reports whether amount is close to whole measuring cups plus
+// one of the fractional cups.
+func measurable(amount float64) bool {
+	whole := math.Floor(amount)
+	for _, fraction := range append(cupFractions, 1) {
+		if math.Abs(whole+fraction-amount) <= amount*kitchenTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricRound rounds amount to a precision that suits its size: halves
+// below ten, whole numbers below a hundred, fives below a thousand and
+// tens above.
+func MetricRound(amount float64) float64 {
+	var step float64
+	switch {
+	case amount < 10:
+		step = 0.5
+	case amount < 100:
+		step = 1
+	case amount < 1000:
+		step = 5
+	default:
+		step = 10
+	}
+	rounded := math.Round(amount/step) * step
+	if rounded == 0 && amount > 0 {
+		return step
+	}
+	return rounded
+}
@@ -0,0 +1,222 @@
+package units
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/smilecs/foody/schema"
+)
+
+// ParsedIngredient is an ingredient read from a line of text. Ranges like
+// "2-3 cloves" have their low end in Quantity and their high end in
+// MaxQuantity. Note holds preparation and other remarks, like "sifted".
+type ParsedIngredient struct {
+	Line        string   `json:"line"`
+	Name        string   `json:"name"`
+	Quantity    float64  `json:"quantity"`
+	MaxQuantity *float64 `json:"max_quantity,omitempty"`
+	Unit        string   `json:"unit"`
+	Note        string   `json:"note,omitempty"`
+}
+
+// Ingredient returns the parsed ingredient as stored on recipes, with the
+// note kept after the name. Recipes store a single quantity, so a range
+// keeps its low end as the quantity and adds its high end to the note, as
+// in "garlic, minced; up to 3".
+func (p ParsedIngredient) Ingredient() schema.Ingredient {
+	var notes []string
+	if p.Note != "" {
+		notes = append(notes, p.Note)
+	}
+	if p.MaxQuantity != nil {
+		_, high := KitchenRound(*p.MaxQuantity)
+		notes = append(notes, "up to "+high)
+	}
+
+	name := p.Name
+	if len(notes) > 0 {
+		name += ", " + strings.Join(notes, "; ")
+	}
+	return schema.Ingredient{Name: name, Quantity: p.Quantity, Unit: p.Unit}
+}
+
+var (
+	// ErrNoIngredient is returned for lines without an ingredient name,
+	// like "2 cups".
+	ErrNoIngredient = errors.New("Line has no ingredient")
+	// ErrEmptyLine is returned for blank lines and section headers like
+	// "For the sauce:".
+	ErrEmptyLine = errors.New("Line is empty")
+)
+
+// unicodeFractions are the vulgar fraction characters recipes are pasted
+// with.
+var unicodeFractions = strings.NewReplacer(
+	"½", " 1/2", "⅓", " 1/3", "⅔", " 2/3", "¼", " 1/4", "¾", " 3/4",
+	"⅕", " 1/5", "⅖", " 2/5", "⅗", " 3/5", "⅘", " 4/5", "⅙", " 1/6", "⅚", " 5/6",
+	"⅛", " 1/8", "⅜", " 3/8", "⅝", " 5/8", "⅞", " 7/8", "⁄", "/",
+	"–", "-", "—", "-",
+)
+
+// number is a whole number with a fraction, a fraction, a number with
+// thousands separators or a decimal. Commas grouping three digits, as in
+// "1,000", separate thousands; other commas are decimal commas, as in
+// "1,5".
+const number = `\d+\s+\d+/\d+|\d+/\d+|` + thousands + `|\d*[.,]\d+|\d+`
+
+// thousands is a number with commas between groups of three digits.
+const thousands = `\d{1,3}(?:,\d{3})+(?:\.\d+)?\b`
+
+var (
+	quantityPattern  = regexp.MustCompile(`^(` + number + `)(?:\s*(?:-|to|or)\s*(` + number + `))?`)
+	bulletPattern    = regexp.MustCompile(`^(?:[-*•·]|\d+[.)]\s)\s*`)
+	parenPattern     = regexp.MustCompile(`\s*\(([^)]*)\)`)
+	thousandsPattern = regexp.MustCompile(`^` + thousands + `$`)
+	remarkPattern    = regexp.MustCompile(`(?i)[\s,]+(to taste|optional|as needed|for serving|for garnish|or more|or less)$`)
+)
+
+// wordQuantities are quantities written as words.
+var wordQuantities = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"dozen": 12, "half": 0.5, "quarter": 0.25,
+}
+
+// ParseIngredient reads an ingredient line such as "1 1/2 cups all-purpose
+// flour, sifted" into its name, quantity, canonical unit and note. It
+// understands mixed and unicode fractions, decimals, ranges like "2-3" or
+// "2 to 3", quantities written as words, units by any of their aliases,
+// remarks in parentheses and after a comma, and trailing remarks like "to
+// taste".
+func ParseIngredient(line string) (ParsedIngredient, error) {
+	parsed := ParsedIngredient{Line: line}
+	text := strings.TrimSpace(unicodeFractions.Replace(line))
+	text = bulletPattern.ReplaceAllString(text, "")
+	if text == "" || strings.HasSuffix(text, ":") {
+		return parsed, ErrEmptyLine
+	}
+
+	var notes []string
+	for _, match := range parenPattern.FindAllStringSubmatch(text, -1) {
+		if note := strings.TrimSpace(match[1]); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	text = strings.TrimSpace(parenPattern.ReplaceAllString(text, ""))
+
+	text, hasQuantity := parsed.readQuantity(text)
+	text = parsed.readUnit(text, hasQuantity)
+
+	// What follows the first comma, and remarks like "to taste" at the
+	// end, are notes rather than part of the name
+	name, note, _ := strings.Cut(text, ",")
+	if note = strings.TrimSpace(note); note != "" {
+		notes = append(notes, note)
+	}
+	if match := remarkPattern.FindStringSubmatch(name); match != nil {
+		name = strings.TrimSuffix(name, match[0])
+		notes = append(notes, strings.ToLower(match[1]))
+	}
+
+	parsed.Name = strings.TrimSpace(name)
+	parsed.Note = strings.Join(notes, "; ")
+	if parsed.Name == "" {
+		return parsed, ErrNoIngredient
+	}
+	return parsed, nil
+}
+
+// readQuantity takes the quantity or range text starts with and returns
+// the rest of text.
+func (p *ParsedIngredient) readQuantity(text string) (string, bool) {
+	if match := quantityPattern.FindStringSubmatchIndex(text); match != nil {
+		low, lowOK := parseNumber(text[match[2]:match[3]])
+		if lowOK {
+			p.Quantity = low
+			if match[4] >= 0 {
+				if high, ok := parseNumber(text[match[4]:match[5]]); ok && high > low {
+					p.MaxQuantity = &high
+				}
+			}
+			return strings.TrimSpace(text[match[1]:]), true
+		}
+	}
+
+	// Words multiply, as in "a dozen" and "half a"
+	words := strings.Fields(text)
+	quantity, n := 1.0, 0
+	for n < len(words)-1 {
+		value, ok := wordQuantities[strings.ToLower(words[n])]
+		if !ok {
+			break
+		}
+		quantity *= value
+		n++
+	}
+	if n == 0 {
+		return text, false
+	}
+	p.Quantity = quantity
+	return strings.Join(words[n:], " "), true
+}
+
+// readUnit takes the unit text starts with, by up to two words, and
+// returns the rest of text without a leading "of". Without a quantity a
+// unit is only taken when "of" follows, as in "pinch of salt", so names
+// like "whole milk" are left alone.
+func (p *ParsedIngredient) readUnit(text string, hasQuantity bool) string {
+	words := strings.Fields(text)
+	for n := min(2, len(words)); n > 0; n-- {
+		unit, ok := Lookup(strings.Join(words[:n], " "))
+		if !ok {
+			continue
+		}
+		rest := words[n:]
+		of := len(rest) > 0 && strings.EqualFold(rest[0], "of")
+		if !hasQuantity && !of {
+			return text
+		}
+		if of {
+			rest = rest[1:]
+		}
+		if !hasQuantity {
+			p.Quantity = 1
+		}
+		p.Unit = unit.Symbol
+		return strings.Join(rest, " ")
+	}
+
+	if len(words) > 0 && strings.EqualFold(words[0], "of") {
+		return strings.Join(words[1:], " ")
+	}
+	return text
+}
+
+// parseNumber reads a whole number, fraction, mixed number or decimal,
+// with a period or a comma, or a number with thousands separators.
+func parseNumber(text string) (float64, bool) {
+	fields := strings.Fields(text)
+	total := 0.0
+	for _, field := range fields {
+		if thousandsPattern.MatchString(field) {
+			field = strings.ReplaceAll(field, ",", "")
+		}
+		if numerator, denominator, found := strings.Cut(field, "/"); found {
+			n, err1 := strconv.ParseFloat(numerator, 64)
+			d, err2 := strconv.ParseFloat(denominator, 64)
+			if err1 != nil || err2 != nil || d == 0 {
+				return 0, false
+			}
+			total += n / d
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.Replace(field, ",", ".", 1), 64)
+		if err != nil {
+			return 0, false
+		}
+		total += value
+	}
+	return total, true
+}
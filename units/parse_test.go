@@ -0,0 +1,169 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseIngredient(t *testing.T) {
+	tests := []struct {
+		line        string
+		name        string
+		quantity    float64
+		maxQuantity float64
+		unit        string
+		note        string
+		err         error
+	}{
+		// Whole numbers, fractions and decimals
+		{line: "2 eggs", name: "eggs", quantity: 2},
+		{line: "1 cup sugar", name: "sugar", quantity: 1, unit: "cup"},
+		{line: "1/2 cup milk", name: "milk", quantity: 0.5, unit: "cup"},
+		{line: "1 1/2 cups all-purpose flour, sifted", name: "all-purpose flour", quantity: 1.5, unit: "cup", note: "sifted"},
+		{line: "2 3/4 cups water", name: "water", quantity: 2.75, unit: "cup"},
+		{line: "0.5 kg potatoes", name: "potatoes", quantity: 0.5, unit: "kg"},
+		{line: ".25 tsp cayenne", name: "cayenne", quantity: 0.25, unit: "tsp"},
+		{line: "1,5 kg potatoes", name: "potatoes", quantity: 1.5, unit: "kg"},
+		{line: "1,000 g flour", name: "flour", quantity: 1000, unit: "g"},
+		{line: "2,500.5 ml water", name: "water", quantity: 2500.5, unit: "ml"},
+		{line: "1,000-1,250 g flour", name: "flour", quantity: 1000, maxQuantity: 1250, unit: "g"},
+		{line: "1,0001 g salt", name: "salt", quantity: 1.0001, unit: "g"},
+		{line: "12 oz pasta", name: "pasta", quantity: 12, unit: "oz"},
+
+		// Unicode fractions
+		{line: "½ cup butter", name: "butter", quantity: 0.5, unit: "cup"},
+		{line: "1½ cups milk", name: "milk", quantity: 1.5, unit: "cup"},
+		{line: "1 ½ tsp vanilla extract", name: "vanilla extract", quantity: 1.5, unit: "tsp"},
+		{line: "¾ tsp salt", name: "salt", quantity: 0.75, unit: "tsp"},
+		{line: "⅓ cup honey", name: "honey", quantity: 1.0 / 3, unit: "cup"},
+		{line: "2⅔ cups oats", name: "oats", quantity: 2 + 2.0/3, unit: "cup"},
+		{line: "⅛ tsp nutmeg", name: "nutmeg", quantity: 0.125, unit: "tsp"},
+		{line: "1⁄4 cup sugar", name: "sugar", quantity: 0.25, unit: "cup"},
+
+		// Ranges
+		{line: "2-3 cloves garlic, minced", name: "garlic", quantity: 2, maxQuantity: 3, unit: "clove", note: "minced"},
+		{line: "2 - 3 tbsp olive oil", name: "olive oil", quantity: 2, maxQuantity: 3, unit: "tbsp"},
+		{line: "2–3 tbsp olive oil", name: "olive oil", quantity: 2, maxQuantity: 3, unit: "tbsp"},
+		{line: "2 to 3 cups stock", name: "stock", quantity: 2, maxQuantity: 3, unit: "cup"},
+		{line: "1 or 2 eggs", name: "eggs", quantity: 1, maxQuantity: 2},
+		{line: "1/2-1 tsp chili flakes", name: "chili flakes", quantity: 0.5, maxQuantity: 1, unit: "tsp"},
+		{line: "10-12 cherry tomatoes", name: "cherry tomatoes", quantity: 10, maxQuantity: 12},
+
+		// Unit aliases
+		{line: "3 tablespoons butter", name: "butter", quantity: 3, unit: "tbsp"},
+		{line: "3 Tbsp. butter", name: "butter", quantity: 3, unit: "tbsp"},
+		{line: "1 teaspoon baking soda", name: "baking soda", quantity: 1, unit: "tsp"},
+		{line: "200g dark chocolate", name: "dark chocolate", quantity: 200, unit: "g"},
+		{line: "200 grams dark chocolate", name: "dark chocolate", quantity: 200, unit: "g"},
+		{line: "500ml chicken stock", name: "chicken stock", quantity: 500, unit: "ml"},
+		{line: "1 litre water", name: "water", quantity: 1, unit: "l"},
+		{line: "1.5kg beef brisket", name: "beef brisket", quantity: 1.5, unit: "kg"},
+		{line: "2 lbs ground beef", name: "ground beef", quantity: 2, unit: "lb"},
+		{line: "8 fl oz cream", name: "cream", quantity: 8, unit: "fl oz"},
+		{line: "8 fluid ounces cream", name: "cream", quantity: 8, unit: "fl oz"},
+		{line: "1 pint blueberries", name: "blueberries", quantity: 1, unit: "pt"},
+		{line: "2 cups of flour", name: "flour", quantity: 2, unit: "cup"},
+		{line: "4 slices bacon", name: "bacon", quantity: 4, unit: "slice"},
+		{line: "1 stick butter, softened", name: "butter", quantity: 1, unit: "stick", note: "softened"},
+		{line: "1 bunch cilantro", name: "cilantro", quantity: 1, unit: "bunch"},
+		{line: "2 tins chopped tomatoes", name: "chopped tomatoes", quantity: 2, unit: "can"},
+
+		// Words as quantities
+		{line: "a pinch of salt", name: "salt", quantity: 1, unit: "pinch"},
+		{line: "pinch of salt", name: "salt", quantity: 1, unit: "pinch"},
+		{line: "one onion, diced", name: "onion", quantity: 1, note: "diced"},
+		{line: "half an onion", name: "onion", quantity: 0.5},
+		{line: "half a cup of milk", name: "milk", quantity: 0.5, unit: "cup"},
+		{line: "a dozen eggs", name: "eggs", quantity: 12},
+		{line: "two dozen clams", name: "clams", quantity: 24},
+
+		// Notes
+		{line: "1 (14 oz) can diced tomatoes", name: "diced tomatoes", quantity: 1, unit: "can", note: "14 oz"},
+		{line: "2 large eggs, at room temperature", name: "large eggs", quantity: 2, note: "at room temperature"},
+		{line: "1 cup walnuts (optional)", name: "walnuts", quantity: 1, unit: "cup", note: "optional"},
+		{line: "1 onion, peeled, finely chopped", name: "onion", quantity: 1, note: "peeled, finely chopped"},
+		{line: "1 lemon (zest and juice), halved", name: "lemon", quantity: 1, note: "zest and juice; halved"},
+		{line: "Salt and pepper, to taste", name: "Salt and pepper", note: "to taste"},
+		{line: "salt to taste", name: "salt", note: "to taste"},
+		{line: "Fresh parsley, for garnish", name: "Fresh parsley", note: "for garnish"},
+		{line: "Parmesan for serving", name: "Parmesan", note: "for serving"},
+
+		// No quantity or unit
+		{line: "salt", name: "salt"},
+		{line: "Whole milk", name: "Whole milk"},
+		{line: "1 cup whole milk", name: "whole milk", quantity: 1, unit: "cup"},
+		{line: "Juice of 1 lemon", name: "Juice of 1 lemon"},
+		{line: "  3 carrots  ", name: "carrots", quantity: 3},
+
+		// Bullets
+		{line: "- 2 cups rice", name: "rice", quantity: 2, unit: "cup"},
+		{line: "* 1 tsp cumin", name: "cumin", quantity: 1, unit: "tsp"},
+		{line: "• 4 eggs", name: "eggs", quantity: 4},
+
+		// Lines without an ingredient
+		{line: "", err: ErrEmptyLine},
+		{line: "   ", err: ErrEmptyLine},
+		{line: "For the sauce:", err: ErrEmptyLine},
+		{line: "2 cups", quantity: 2, unit: "cup", err: ErrNoIngredient},
+		{line: "3", quantity: 3, err: ErrNoIngredient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			parsed, err := ParseIngredient(tt.line)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if tt.err == ErrEmptyLine {
+				return
+			}
+			if parsed.Name != tt.name {
+				t.Errorf("expected name %q, got %q", tt.name, parsed.Name)
+			}
+			if math.Abs(parsed.Quantity-tt.quantity) > 1e-9 {
+				t.Errorf("expected quantity %v, got %v", tt.quantity, parsed.Quantity)
+			}
+			if tt.maxQuantity == 0 && parsed.MaxQuantity != nil {
+				t.Errorf("expected no max quantity, got %v", *parsed.MaxQuantity)
+			}
+			if tt.maxQuantity != 0 && (parsed.MaxQuantity == nil || *parsed.MaxQuantity != tt.maxQuantity) {
+				t.Errorf("expected max quantity %v, got %v", tt.maxQuantity, parsed.MaxQuantity)
+			}
+			if parsed.Unit != tt.unit {
+				t.Errorf("expected unit %q, got %q", tt.unit, parsed.Unit)
+			}
+			if parsed.Note != tt.note {
+				t.Errorf("expected note %q, got %q", tt.note, parsed.Note)
+			}
+		})
+	}
+}
+
+func TestParsedIngredient_Ingredient(t *testing.T) {
+	tests := []struct {
+		line     string
+		name     string
+		quantity float64
+		unit     string
+	}{
+		{line: "1 1/2 cups all-purpose flour, sifted", name: "all-purpose flour, sifted", quantity: 1.5, unit: "cup"},
+		{line: "2 eggs", name: "eggs", quantity: 2},
+		// Ranges keep their high end in the name
+		{line: "2-3 cloves garlic, minced", name: "garlic, minced; up to 3", quantity: 2, unit: "clove"},
+		{line: "1/2-3/4 tsp chili flakes", name: "chili flakes, up to 3/4", quantity: 0.5, unit: "tsp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			parsed, err := ParseIngredient(tt.line)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			ingredient := parsed.Ingredient()
+			if ingredient.Name != tt.name || ingredient.Quantity != tt.quantity || ingredient.Unit != tt.unit {
+				t.Errorf("Unexpected ingredient: %+v", ingredient)
+			}
+		})
+	}
+}
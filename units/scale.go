@@ -0,0 +1,101 @@
+package units
+
+import (
+	"strconv"
+
+	"github.com/smilecs/foody/schema"
+)
+
+// NoServingsWarning explains why a recipe was left unscaled.
+const NoServingsWarning = "Recipe does not say how many servings it makes, so it was not scaled"
+
+// epsilon absorbs the float error of converting between units.
+const epsilon = 1e-9
+
+// Scaling describes how a recipe was scaled.
+type Scaling struct {
+	Servings         int     `json:"servings"`
+	OriginalServings *int    `json:"original_servings,omitempty"`
+	Factor           float64 `json:"factor"`
+	Warning          string  `json:"warning,omitempty"`
+}
+
+// Fit converts amount of unit to the unit of the same system that reads
+// best: the largest one the amount is at least one of, like 48 tsp to
+// 1 cup and 1/2 tbsp to 1 1/2 tsp. Cups and pounds are also used for
+// amounts of a quarter and up that a measuring cup holds, like 8 tbsp to
+// 1/2 cup, but 6 tbsp stay 6 tbsp. The amount is rounded for measuring in
+// the kitchen and returned with its written form. Count units have no
+// larger unit; only their amount is rounded.
+func Fit(amount float64, unit *Unit) (float64, *Unit, string) {
+	if unit.Dimension == Count {
+		value, text := KitchenRound(amount)
+		return value, unit, text
+	}
+	return fit(amount*unit.Base, ladders[unit.Dimension][unit.System])
+}
+
+// fit picks the unit of ladder that reads best for base millilitres or
+// grams, as described at Fit.
+func fit(base float64, ladder []*Unit) (float64, *Unit, string) {
+	fitted := ladder[0]
+	for i := len(ladder) - 1; i > 0; i-- {
+		value := base / ladder[i].Base
+		largest := i == len(ladder)-1
+		if value >= 1-epsilon || (largest && fitted.System == Imperial && value >= 0.25 && measurable(value)) {
+			fitted = ladder[i]
+			break
+		}
+	}
+
+	if fitted.System == Metric {
+		smallest := ladder[0].Base
+		value := MetricRound(base/smallest) * smallest / fitted.Base
+		return value, fitted, strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	value, text := KitchenRound(base / fitted.Base)
+	return value, fitted, text
+}
+
+// ScaleIngredient multiplies the quantity of ingredient by factor, moving
+// it to a better reading unit when its unit is known. Ingredients without
+// a quantity, like salt to taste, are left as they are.
+func ScaleIngredient(ingredient schema.Ingredient, factor float64) schema.Ingredient {
+	if ingredient.Quantity <= 0 {
+		return ingredient
+	}
+	amount := ingredient.Quantity * factor
+
+	unit, ok := Lookup(ingredient.Unit)
+	if !ok {
+		ingredient.Quantity, ingredient.Display = KitchenRound(amount)
+		return ingredient
+	}
+
+	value, fitted, text := Fit(amount, unit)
+	ingredient.Quantity, ingredient.Display = value, text
+	if fitted != unit {
+		ingredient.Unit = fitted.Symbol
+	}
+	return ingredient
+}
+
+// ScaleRecipe returns recipe with its ingredients scaled to make servings.
+// A recipe without a servings count has nothing to scale from and is
+// returned as is, with a warning in the scaling.
+func ScaleRecipe(recipe schema.Recipe, servings int) (schema.Recipe, Scaling) {
+	scaling := Scaling{Servings: servings, OriginalServings: recipe.Servings, Factor: 1}
+	if recipe.Servings == nil || *recipe.Servings <= 0 {
+		scaling.Warning = NoServingsWarning
+		return recipe, scaling
+	}
+
+	scaling.Factor = float64(servings) / float64(*recipe.Servings)
+	scaled := make([]schema.Ingredient, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		scaled[i] = ScaleIngredient(ingredient, scaling.Factor)
+	}
+	recipe.Ingredients = scaled
+	recipe.Servings = &servings
+	return recipe, scaling
+}
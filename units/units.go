@@ -0,0 +1,145 @@
+// Package units understands the measuring units recipes are written in,
+// converts quantities between them and scales recipes to a number of
+// servings.
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dimension is what a unit measures. Only units of the same dimension
+// convert into each other, and count units not at all.
+type Dimension int
+
+const (
+	Volume Dimension = iota + 1
+	Mass
+	Count
+)
+
+// System is the measuring system a unit belongs to. Quantities are kept in
+// the system the recipe was written in unless another one is asked for.
+type System int
+
+const (
+	Metric System = iota + 1
+	Imperial
+)
+
+// ParseSystem returns the system named "metric" or "imperial".
+func ParseSystem(name string) (System, error) {
+	switch strings.ToLower(name) {
+	case "metric":
+		return Metric, nil
+	case "imperial":
+		return Imperial, nil
+	}
+	return 0, fmt.Errorf("Unknown unit system: %s", name)
+}
+
+// Unit is a measuring unit. Base is the size of the unit in millilitres
+// for volumes and grams for masses. Aliases are the other ways recipes
+// write it.
+type Unit struct {
+	Symbol    string
+	Aliases   []string
+	Dimension Dimension
+	System    System
+	Base      float64
+}
+
+var (
+	Teaspoon   = &Unit{Symbol: "tsp", Aliases: []string{"tsps", "teaspoon", "teaspoons"}, Dimension: Volume, System: Imperial, Base: 4.92892159375}
+	Tablespoon = &Unit{Symbol: "tbsp", Aliases: []string{"tbsps", "tbs", "tbl", "tablespoon", "tablespoons"}, Dimension: Volume, System: Imperial, Base: 14.78676478125}
+	FluidOunce = &Unit{Symbol: "fl oz", Aliases: []string{"floz", "fluid ounce", "fluid ounces"}, Dimension: Volume, System: Imperial, Base: 29.5735295625}
+	Cup        = &Unit{Symbol: "cup", Aliases: []string{"cups"}, Dimension: Volume, System: Imperial, Base: 236.5882365}
+	Pint       = &Unit{Symbol: "pt", Aliases: []string{"pint", "pints"}, Dimension: Volume, System: Imperial, Base: 473.176473}
+	Quart      = &Unit{Symbol: "qt", Aliases: []string{"quart", "quarts"}, Dimension: Volume, System: Imperial, Base: 946.352946}
+	Gallon     = &Unit{Symbol: "gal", Aliases: []string{"gallon", "gallons"}, Dimension: Volume, System: Imperial, Base: 3785.411784}
+	Millilitre = &Unit{Symbol: "ml", Aliases: []string{"mls", "millilitre", "millilitres", "milliliter", "milliliters", "cc"}, Dimension: Volume, System: Metric, Base: 1}
+	Litre      = &Unit{Symbol: "l", Aliases: []string{"ltr", "litre", "litres", "liter", "liters"}, Dimension: Volume, System: Metric, Base: 1000}
+	Gram       = &Unit{Symbol: "g", Aliases: []string{"gr", "gm", "gms", "gram", "grams", "gramme", "grammes"}, Dimension: Mass, System: Metric, Base: 1}
+	Kilogram   = &Unit{Symbol: "kg", Aliases: []string{"kgs", "kilo", "kilos", "kilogram", "kilograms"}, Dimension: Mass, System: Metric, Base: 1000}
+	Ounce      = &Unit{Symbol: "oz", Aliases: []string{"ozs", "ounce", "ounces"}, Dimension: Mass, System: Imperial, Base: 28.349523125}
+	Pound      = &Unit{Symbol: "lb", Aliases: []string{"lbs", "pound", "pounds"}, Dimension: Mass, System: Imperial, Base: 453.59237}
+)
+
+// registry is every known unit. Count units only name what is counted.
+var registry = []*Unit{
+	Teaspoon, Tablespoon, FluidOunce, Cup, Pint, Quart, Gallon, Millilitre, Litre,
+	Gram, Kilogram, Ounce, Pound,
+	{Symbol: "piece", Aliases: []string{"pieces", "pc", "pcs", "whole", "each", "ea"}, Dimension: Count},
+	{Symbol: "clove", Aliases: []string{"cloves"}, Dimension: Count},
+	{Symbol: "can", Aliases: []string{"cans", "tin", "tins"}, Dimension: Count},
+	{Symbol: "slice", Aliases: []string{"slices"}, Dimension: Count},
+	{Symbol: "pinch", Aliases: []string{"pinches"}, Dimension: Count},
+	{Symbol: "dash", Aliases: []string{"dashes"}, Dimension: Count},
+	{Symbol: "bunch", Aliases: []string{"bunches"}, Dimension: Count},
+	{Symbol: "sprig", Aliases: []string{"sprigs"}, Dimension: Count},
+	{Symbol: "handful", Aliases: []string{"handfuls"}, Dimension: Count},
+	{Symbol: "stick", Aliases: []string{"sticks"}, Dimension: Count},
+	{Symbol: "package", Aliases: []string{"packages", "pkg", "pack", "packs", "packet", "packets"}, Dimension: Count},
+	{Symbol: "head", Aliases: []string{"heads"}, Dimension: Count},
+}
+
+// names maps the symbol and aliases of every unit to the unit.
+var names = make(map[string]*Unit)
+
+func init() {
+	for _, unit := range registry {
+		names[unit.Symbol] = unit
+		for _, alias := range unit.Aliases {
+			names[alias] = unit
+		}
+	}
+}
+
+// ladders lists, from smallest to largest, the units a quantity may be
+// converted to as it grows or shrinks.
+var ladders = map[Dimension]map[System][]*Unit{
+	Volume: {
+		Imperial: {Teaspoon, Tablespoon, Cup},
+		Metric:   {Millilitre, Litre},
+	},
+	Mass: {
+		Imperial: {Ounce, Pound},
+		Metric:   {Gram, Kilogram},
+	},
+}
+
+// Units returns every known unit.
+func Units() []*Unit {
+	return registry
+}
+
+// Lookup returns the unit named name, ignoring case, spacing and a
+// trailing period.
+func Lookup(name string) (*Unit, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	unit, ok := names[strings.Join(strings.Fields(name), " ")]
+	return unit, ok
+}
+
+// Canonical returns the symbol of the unit named name, like "g" for
+// "Grams". An empty name is a quantity without a unit, like 2 eggs, and
+// stays empty. Unknown units are an error.
+func Canonical(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil
+	}
+	unit, ok := Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("Unknown unit: %s", name)
+	}
+	return unit.Symbol, nil
+}
+
+// Convert returns amount of unit from in unit to. Units of different
+// dimensions and count units do not convert; false is returned for them.
+func Convert(amount float64, from, to *Unit) (float64, bool) {
+	if from.Dimension != to.Dimension || from.Dimension == Count {
+		return 0, false
+	}
+	return amount * from.Base / to.Base, true
+}
@@ -0,0 +1,227 @@
+package units
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/smilecs/foody/schema"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected *Unit
+	}{
+		{"tsp", Teaspoon},
+		{"Tbsp.", Tablespoon},
+		{" Cups ", Cup},
+		{"fl  oz", FluidOunce},
+		{"Grams", Gram},
+		{"lbs", Pound},
+		{"smidgen", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		unit, ok := Lookup(tt.name)
+		if ok != (tt.expected != nil) || unit != tt.expected {
+			t.Errorf("Lookup(%q) = %v, expected %v", tt.name, unit, tt.expected)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if value, ok := Convert(3, Teaspoon, Tablespoon); !ok || math.Abs(value-1) > 0.001 {
+		t.Errorf("Expected 3 tsp to be 1 tbsp, got %v", value)
+	}
+	if value, ok := Convert(1, Pound, Gram); !ok || math.Abs(value-453.592) > 0.001 {
+		t.Errorf("Expected 1 lb to be 453.592 g, got %v", value)
+	}
+	if _, ok := Convert(1, Cup, Gram); ok {
+		t.Error("Expected volumes not to convert to masses")
+	}
+}
+
+func TestKitchenRound(t *testing.T) {
+	tests := []struct {
+		amount       float64
+		expected     float64
+		expectedText string
+	}{
+		{1, 1, "1"},
+		{1.5, 1.5, "1 1/2"},
+		{0.33, 1.0 / 3, "1/3"},
+		{2.74, 2.75, "2 3/4"},
+		{2.7, 2.0 + 2.0/3, "2 2/3"},
+		{0.01, 0.125, "1/8"},
+		{0.96, 1, "1"},
+		{12.4, 12, "12"},
+		{0, 0, "0"},
+	}
+
+	for _, tt := range tests {
+		value, text := KitchenRound(tt.amount)
+		if math.Abs(value-tt.expected) > 1e-9 || text != tt.expectedText {
+			t.Errorf("KitchenRound(%v) = %v %q, expected %v %q", tt.amount, value, text, tt.expected, tt.expectedText)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name         string
+		amount       float64
+		unit         *Unit
+		expected     float64
+		expectedUnit *Unit
+		expectedText string
+	}{
+		{"Teaspoons to a cup", 48, Teaspoon, 1, Cup, "1"},
+		{"Teaspoons to a tablespoon", 3, Teaspoon, 1, Tablespoon, "1"},
+		{"Tablespoons to half a cup", 8, Tablespoon, 0.5, Cup, "1/2"},
+		{"Tablespoons that are no cup fraction", 6, Tablespoon, 6, Tablespoon, "6"},
+		{"Half a tablespoon to teaspoons", 0.5, Tablespoon, 1.5, Teaspoon, "1 1/2"},
+		{"Ounces to pounds", 24, Ounce, 1.5, Pound, "1 1/2"},
+		{"Grams to kilograms", 1250, Gram, 1.25, Kilogram, "1.25"},
+		{"Grams stay grams", 340.2, Gram, 340, Gram, "340"},
+		{"Litres to millilitres", 0.25, Litre, 250, Millilitre, "250"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, unit, text := Fit(tt.amount, tt.unit)
+			if math.Abs(value-tt.expected) > 1e-9 || unit != tt.expectedUnit || text != tt.expectedText {
+				t.Errorf("Fit(%v %s) = %v %s (%q), expected %v %s (%q)",
+					tt.amount, tt.unit.Symbol, value, unit.Symbol, text, tt.expected, tt.expectedUnit.Symbol, tt.expectedText)
+			}
+		})
+	}
+}
+
+func TestScaleRecipe(t *testing.T) {
+	servings := 4
+	recipe := schema.Recipe{
+		Servings: &servings,
+		Ingredients: []schema.Ingredient{
+			{Name: "Sugar", Quantity: 4, Unit: "tablespoons"},
+			{Name: "Eggs", Quantity: 3},
+			{Name: "Flour", Quantity: 500, Unit: "g"},
+			{Name: "Salt", Unit: "to taste"},
+		},
+	}
+
+	scaled, scaling := ScaleRecipe(recipe, 6)
+	if scaling.Factor != 1.5 || scaling.Warning != "" || *scaled.Servings != 6 || *scaling.OriginalServings != 4 {
+		t.Errorf("Unexpected scaling: %+v", scaling)
+	}
+	expected := []schema.Ingredient{
+		{Name: "Sugar", Quantity: 6, Unit: "tablespoons", Display: "6"},
+		{Name: "Eggs", Quantity: 4.5, Display: "4 1/2"},
+		{Name: "Flour", Quantity: 750, Unit: "g", Display: "750"},
+		{Name: "Salt", Unit: "to taste"},
+	}
+	for i, ingredient := range scaled.Ingredients {
+		if ingredient != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], ingredient)
+		}
+	}
+	if recipe.Ingredients[0].Quantity != 4 || *recipe.Servings != 4 {
+		t.Error("Expected the original recipe to be left unchanged")
+	}
+
+	// Growing moves to larger units
+	scaled, _ = ScaleRecipe(recipe, 16)
+	if sugar := scaled.Ingredients[0]; sugar.Quantity != 1 || sugar.Unit != "cup" {
+		t.Errorf("Expected 16 tbsp of sugar to be 1 cup, got %v %s", sugar.Quantity, sugar.Unit)
+	}
+
+	// Recipes without servings are not scaled
+	recipe.Servings = nil
+	unscaled, scaling := ScaleRecipe(recipe, 8)
+	if scaling.Warning != NoServingsWarning || scaling.Factor != 1 || unscaled.Ingredients[0].Quantity != 4 {
+		t.Errorf("Expected recipe without servings to be left unscaled, got %+v", scaling)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    string
+		expectedErr bool
+	}{
+		{"Grams", "g", false},
+		{"TABLESPOONS", "tbsp", false},
+		{"fluid ounces", "fl oz", false},
+		{"Cloves", "clove", false},
+		{"", "", false},
+		{"smidgen", "", true},
+	}
+
+	for _, tt := range tests {
+		unit, err := Canonical(tt.name)
+		if unit != tt.expected || (err != nil) != tt.expectedErr {
+			t.Errorf("Canonical(%q) = %q, %v, expected %q", tt.name, unit, err, tt.expected)
+		}
+	}
+
+	for _, unit := range Units() {
+		if found, ok := Lookup(unit.Symbol); !ok || found != unit {
+			t.Errorf("Expected %q to look up its own unit", unit.Symbol)
+		}
+	}
+}
+
+func TestDensity(t *testing.T) {
+	flour, _ := Density("flour")
+	tests := []struct {
+		name     string
+		expected float64
+		known    bool
+	}{
+		{"Bread Flour", flour, true},
+		{"almond flour", 0.406, true},
+		{"Light brown sugar", 0.9, true},
+		{"Milk", 0, false},
+	}
+
+	for _, tt := range tests {
+		density, ok := Density(tt.name)
+		if ok != tt.known || density != tt.expected {
+			t.Errorf("Density(%q) = %v, %v, expected %v", tt.name, density, ok, tt.expected)
+		}
+	}
+}
+
+func TestToSystem(t *testing.T) {
+	tests := []struct {
+		name       string
+		ingredient schema.Ingredient
+		system     System
+		expected   string
+	}{
+		{"Flour by weight", schema.Ingredient{Name: "Flour", Quantity: 2, Unit: "cup"}, Metric, "250 g"},
+		{"Milk by volume", schema.Ingredient{Name: "Milk", Quantity: 4, Unit: "cups"}, Metric, "945 ml"},
+		{"Large volumes in litres", schema.Ingredient{Name: "Stock", Quantity: 2, Unit: "qt"}, Metric, "1.89 l"},
+		{"Spoons are kept", schema.Ingredient{Name: "Flour", Quantity: 2, Unit: "tbsp"}, Metric, "2 tbsp"},
+		{"Mass without density", schema.Ingredient{Name: "Chicken", Quantity: 2, Unit: "lb"}, Metric, "905 g"},
+		{"Sugar by volume", schema.Ingredient{Name: "Sugar", Quantity: 100, Unit: "g"}, Imperial, "1/2 cup"},
+		{"Small amounts in spoons", schema.Ingredient{Name: "Butter", Quantity: 15, Unit: "g"}, Imperial, "1 tbsp"},
+		{"Mass stays mass", schema.Ingredient{Name: "Beef", Quantity: 1, Unit: "kg"}, Imperial, "2 1/4 lb"},
+		{"Same system unchanged", schema.Ingredient{Name: "Flour", Quantity: 300, Unit: "g"}, Metric, "300 g"},
+		{"Count units unchanged", schema.Ingredient{Name: "Garlic", Quantity: 2, Unit: "clove"}, Imperial, "2 clove"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted := ToSystem(tt.ingredient, tt.system)
+			quantity := converted.Display
+			if quantity == "" {
+				quantity = strconv.FormatFloat(converted.Quantity, 'f', -1, 64)
+			}
+			if got := quantity + " " + converted.Unit; got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -2,42 +2,51 @@ package utils
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+const (
+	// AccessTokenTTL is kept short because access tokens are only revocable
+	// through their session; clients renew them with a refresh token.
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
 )
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID   `json:"user_id"`
+	Email     string      `json:"email"`
+	Role      schema.Role `json:"role"`
+	SessionID uuid.UUID   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, email string) (string, error) {
-	// Get secret key from environment variable
-	secretKey := []byte(os.Getenv("JWT_SECRET_KEY"))
-	if len(secretKey) == 0 {
-		return "", errors.New("JWT secret key not found")
-	}
-
+// GenerateToken signs an access token with the active key and names the key
+// in the kid header.
+func (ks *KeySet) GenerateToken(userID uuid.UUID, email string, role schema.Role, sessionID uuid.UUID) (string, error) {
 	// Create claims with user data
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
 
 	// Generate signed token
-	tokenString, err := token.SignedString(secretKey)
+	tokenString, err := token.SignedString(ks.active.Private)
 	if err != nil {
 		return "", err
 	}
@@ -45,17 +54,21 @@ func GenerateToken(userID uuid.UUID, email string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
-	// Get secret key from environment variable
-	secretKey := []byte(os.Getenv("JWT_SECRET_KEY"))
-	if len(secretKey) == 0 {
-		return nil, errors.New("JWT secret key not found")
-	}
-
+// ValidateToken verifies a token against the key named by its kid header.
+func (ks *KeySet) ValidateToken(tokenString string) (*Claims, error) {
 	// Parse token
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// Never let the token choose a different algorithm than its key
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key of a KeySet. Keys without a private half can only
+// verify tokens; they are kept around while tokens signed with a retired key
+// may still be in circulation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet signs access tokens with its active key and verifies them with any
+// key whose kid matches the token header.
+//
+// On disk a key set is a directory of PEM files named <kid>.pem holding
+// either an RSA or Ed25519 private key, or a public key for verification
+// only. To rotate, add the new private key, make it active, and replace the
+// old private key with its public half once its tokens have expired.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. The key named activeKID
+// signs new tokens; when activeKID is empty the private key with the
+// greatest kid is used, so date-based kids rotate by adding a file.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseKey(kid, content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err := ks.Add(key); err != nil {
+			return nil, err
+		}
+		if key.Private != nil && activeKID == "" {
+			ks.active = key
+		}
+	}
+
+	if activeKID != "" {
+		ks.active = ks.keys[activeKID]
+	}
+	if ks.active == nil || ks.active.Private == nil {
+		return nil, fmt.Errorf("no private signing key found in %s", dir)
+	}
+	return ks, nil
+}
+
+// NewKeySet returns a key set that signs with active.
+func NewKeySet(active *SigningKey) (*KeySet, error) {
+	if active.Private == nil {
+		return nil, errors.New("active key must have a private key")
+	}
+	ks := &KeySet{active: active, keys: make(map[string]*SigningKey)}
+	return ks, ks.Add(active)
+}
+
+// GenerateEd25519Key creates a fresh signing key, mostly useful in tests
+// and local development where no key directory is configured.
+func GenerateEd25519Key(kid string) (*SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+}
+
+// Add makes key available for verification.
+func (ks *KeySet) Add(key *SigningKey) error {
+	if _, exists := ks.keys[key.ID]; exists {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	ks.keys[key.ID] = key
+	return nil
+}
+
+// ActiveKeyID returns the kid of the key new tokens are signed with.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.ID
+}
+
+func parseKey(kid string, content []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// JWK is the public half of a SigningKey in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every key in the set, ordered by kid.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
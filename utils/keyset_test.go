@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/smilecs/foody/schema"
+)
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	content := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), content, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func writeRSAKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	writePEM(t, dir, kid, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	return key
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+	return key
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2026-01")
+	writeEd25519Key(t, dir, "2026-07")
+
+	// The greatest kid signs by default
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+	if ks.ActiveKeyID() != "2026-07" {
+		t.Errorf("Expected active key 2026-07, got %s", ks.ActiveKeyID())
+	}
+
+	// An explicit active key wins
+	ks, err = LoadKeySet(dir, "2026-01")
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+	if ks.ActiveKeyID() != "2026-01" {
+		t.Errorf("Expected active key 2026-01, got %s", ks.ActiveKeyID())
+	}
+
+	if _, err := LoadKeySet(dir, "missing"); err == nil {
+		t.Error("Expected error for unknown active key")
+	}
+	if _, err := LoadKeySet(t.TempDir(), ""); err == nil {
+		t.Error("Expected error for empty key directory")
+	}
+
+	bad := t.TempDir()
+	os.WriteFile(filepath.Join(bad, "broken.pem"), []byte("not a key"), 0600)
+	if _, err := LoadKeySet(bad, ""); err == nil {
+		t.Error("Expected error for malformed key file")
+	}
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for _, kid := range []string{"rsa", "ed25519"} {
+		t.Run(kid, func(t *testing.T) {
+			dir := t.TempDir()
+			if kid == "rsa" {
+				writeRSAKey(t, dir, kid)
+			} else {
+				writeEd25519Key(t, dir, kid)
+			}
+			ks, err := LoadKeySet(dir, "")
+			if err != nil {
+				t.Fatalf("Failed to load key set: %v", err)
+			}
+
+			userID, sessionID := uuid.New(), uuid.New()
+			token, err := ks.GenerateToken(userID, "user@example.com", schema.RoleAdmin, sessionID)
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+
+			parsed, _, _ := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if parsed.Header["kid"] != kid {
+				t.Errorf("Expected kid header %q, got %v", kid, parsed.Header["kid"])
+			}
+
+			claims, err := ks.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("Failed to validate token: %v", err)
+			}
+			if claims.UserID != userID || claims.SessionID != sessionID || claims.Role != schema.RoleAdmin {
+				t.Errorf("Unexpected claims: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := writeRSAKey(t, dir, "2026-01")
+	before, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+	oldToken, _ := before.GenerateToken(uuid.New(), "user@example.com", schema.RoleUser, uuid.New())
+
+	// Rotate: a new key signs and the old one is kept for verification only
+	writeEd25519Key(t, dir, "2026-07")
+	der, _ := x509.MarshalPKIXPublicKey(&oldKey.PublicKey)
+	writePEM(t, dir, "2026-01", "PUBLIC KEY", der)
+
+	after, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load rotated key set: %v", err)
+	}
+	if _, err := after.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected token from retired key to still verify: %v", err)
+	}
+	newToken, _ := after.GenerateToken(uuid.New(), "user@example.com", schema.RoleUser, uuid.New())
+	if _, err := before.ValidateToken(newToken); err == nil {
+		t.Error("Expected key set without the new key to reject its tokens")
+	}
+
+	// A verify-only key can never be made active
+	if _, err := LoadKeySet(dir, "2026-01"); err == nil {
+		t.Error("Expected error when activating a public-only key")
+	}
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[1].KeyType != "OKP" {
+		t.Errorf("Unexpected JWKS: %+v", jwks)
+	}
+	if jwks.Keys[0].N == "" || jwks.Keys[0].E != "AQAB" || jwks.Keys[1].X == "" {
+		t.Errorf("JWKS is missing key material: %+v", jwks)
+	}
+}
+
+func TestKeySet_RejectsForgedTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "main")
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+
+	claims := Claims{UserID: uuid.New(), Role: schema.RoleAdmin}
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmac.Header["kid"] = "main"
+	hmacToken, _ := hmac.SignedString([]byte("guessed-secret"))
+
+	other, _ := GenerateEd25519Key("other")
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknown.Header["kid"] = "other"
+	unknownToken, _ := unknown.SignedString(other.Private)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "HMAC token", token: hmacToken},
+		{name: "Unknown kid", token: unknownToken},
+		{name: "Garbage", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ks.ValidateToken(tt.token); err == nil {
+				t.Error("Expected token to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B, truncated to six digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Failed to compute code: %v", err)
+		}
+		if code != tt.expected {
+			t.Errorf("At %d expected %s, got %s", tt.unix, tt.expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	now := time.Now()
+	code, _ := TOTPCode(secret, TOTPStep(now))
+
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		expected bool
+	}{
+		{name: "Current code", code: code, at: now, expected: true},
+		{name: "Previous period allowed for drift", code: code, at: now.Add(TOTPPeriod), expected: true},
+		{name: "Code too old", code: code, at: now.Add(3 * TOTPPeriod), expected: false},
+		{name: "Wrong code", code: "000000", at: now, expected: code == "000000"},
+		{name: "Wrong length", code: "12345", at: now, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(secret, tt.code, tt.at); ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+
+	step, _ := ValidateTOTP(secret, code, now)
+	if step != TOTPStep(now) {
+		t.Errorf("Expected matched step %d, got %d", TOTPStep(now), step)
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("foody", "cook@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Invalid URI: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Unexpected URI: %s", uri)
+	}
+	if parsed.Path != "/foody:cook@example.com" {
+		t.Errorf("Unexpected label: %s", parsed.Path)
+	}
+	if parsed.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || parsed.Query().Get("issuer") != "foody" {
+		t.Errorf("Unexpected parameters: %s", parsed.RawQuery)
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("Failed to generate recovery code: %v", err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Errorf("Unexpected recovery code format: %s", code)
+	}
+	if NormalizeRecoveryCode(strings.ToUpper(code)) != strings.ReplaceAll(code, "-", "") {
+		t.Error("Expected normalization to ignore case and dashes")
+	}
+}